@@ -1,6 +1,7 @@
 package adaptor
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -39,6 +40,13 @@ type Adaptor interface {
 	Writer(chan struct{}, *sync.WaitGroup) (client.Writer, error)
 }
 
+// ContextAdaptor is implemented by an Adaptor whose writer is bound to ctx, writes made without a
+// context of their own are aborted once ctx is done. The pipeline calls WriterContext in place of
+// Writer when it's implemented.
+type ContextAdaptor interface {
+	WriterContext(ctx context.Context) (client.Writer, error)
+}
+
 // Connectable defines the interface that adapters should follow to have their connections set
 // on load
 // Connect() allows the adaptor an opportunity to setup connections prior to Start()
@@ -5,6 +5,7 @@ import (
 	_ "github.com/compose/transporter/adaptor/elasticsearch"
 	_ "github.com/compose/transporter/adaptor/file"
 	_ "github.com/compose/transporter/adaptor/mongodb"
+	_ "github.com/compose/transporter/adaptor/nats"
 	_ "github.com/compose/transporter/adaptor/postgres"
 	_ "github.com/compose/transporter/adaptor/rabbitmq"
 	_ "github.com/compose/transporter/adaptor/rethinkdb"
@@ -1,10 +1,13 @@
 package clients
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/url"
 
 	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/message"
 	"github.com/hashicorp/go-version"
 )
 
@@ -26,11 +29,46 @@ func Add(v string, constraint version.Constraints, creator Creator) {
 	Clients[v] = &VersionedClient{constraint, creator}
 }
 
-// ClientOptions defines the available options that can be used to configured the client.Writer
+// ClientOptions defines the available options that can be used to configured the client.Writer,
+// Context is used by writes made without a context of their own and defaults to
+// context.Background().
 type ClientOptions struct {
 	URLs       []string
 	UserInfo   *url.Userinfo
 	HTTPClient *http.Client
 	Index      string
 	ParentID   string
+	Context    context.Context
+}
+
+// BulkError is returned when some of the documents in a _bulk request couldn't be written, the
+// whole batch is written again if it's retried.
+type BulkError struct {
+	Failed int
+	Total  int
+}
+
+func (e BulkError) Error() string {
+	return fmt.Sprintf("_bulk request failed for %d of %d documents", e.Failed, e.Total)
+}
+
+// Document returns the ID of the message's document and the document to send without the _id
+// field, or the parentID field when set, leaving the message's data unchanged so the message can
+// be written again.
+func Document(msg message.Msg, parentID string) (id string, parent string, doc map[string]interface{}) {
+	doc = make(map[string]interface{}, len(msg.Data()))
+	for k, v := range msg.Data() {
+		doc[k] = v
+	}
+	if _, ok := doc["_id"]; ok {
+		id = msg.ID()
+		delete(doc, "_id")
+	}
+	if parentID != "" {
+		if p, ok := doc[parentID].(string); ok {
+			parent = p
+			delete(doc, parentID)
+		}
+	}
+	return id, parent, doc
 }
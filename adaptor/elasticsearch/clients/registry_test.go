@@ -5,6 +5,8 @@ import (
 	"testing"
 
 	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/ops"
 	version "github.com/hashicorp/go-version"
 )
 
@@ -36,3 +38,31 @@ func TestAdd(t *testing.T) {
 func mockCreator(opts *ClientOptions) (client.Writer, error) {
 	return mock, nil
 }
+
+var documentTests = []struct {
+	name     string
+	data     map[string]interface{}
+	parentID string
+	id       string
+	parent   string
+	doc      map[string]interface{}
+}{
+	{"no id", map[string]interface{}{"hello": "world"}, "", "", "", map[string]interface{}{"hello": "world"}},
+	{"id", map[string]interface{}{"_id": 1, "hello": "world"}, "", "1", "", map[string]interface{}{"hello": "world"}},
+	{"parent", map[string]interface{}{"_id": "a", "pid": "b"}, "pid", "a", "b", map[string]interface{}{}},
+	{"missing parent", map[string]interface{}{"_id": "a"}, "pid", "a", "", map[string]interface{}{}},
+}
+
+func TestDocument(t *testing.T) {
+	for _, dt := range documentTests {
+		msg := message.From(ops.Insert, "test", dt.data)
+		before := len(msg.Data())
+		id, parent, doc := Document(msg, dt.parentID)
+		if id != dt.id || parent != dt.parent || !reflect.DeepEqual(doc, dt.doc) {
+			t.Errorf("[%s] wrong document, expected %s %s %v, got %s %s %v", dt.name, dt.id, dt.parent, dt.doc, id, parent, doc)
+		}
+		if len(msg.Data()) != before {
+			t.Errorf("[%s] message data was modified, %v", dt.name, msg.Data())
+		}
+	}
+}
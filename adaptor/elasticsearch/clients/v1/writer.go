@@ -14,7 +14,8 @@ import (
 )
 
 var (
-	_ client.Writer = &Writer{}
+	_ client.Writer        = &Writer{}
+	_ client.ContextWriter = &Writer{}
 )
 
 // Writer implements client.Writer and client.Session for sending requests to an elasticsearch
@@ -23,6 +24,8 @@ type Writer struct {
 	index    string
 	esClient *elastic.Client
 	logger   log.Logger
+	// ctx is the context of writes made with Write and WriteBatch
+	ctx context.Context
 }
 
 func init() {
@@ -48,12 +51,23 @@ func init() {
 			esClient: esClient,
 			logger:   log.With("writer", "elasticsearch").With("version", 1),
 		}
+		if w.ctx = opts.Context; w.ctx == nil {
+			w.ctx = context.Background()
+		}
 		return w, nil
 	})
 }
 
 func (w *Writer) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	write := w.WriteContext(msg)
 	return func(s client.Session) (message.Msg, error) {
+		return write(w.ctx, s)
+	}
+}
+
+// WriteContext writes msg, the request is canceled once ctx is done.
+func (w *Writer) WriteContext(msg message.Msg) func(context.Context, client.Session) (message.Msg, error) {
+	return func(ctx context.Context, s client.Session) (message.Msg, error) {
 		indexType := msg.Namespace()
 		var id string
 		if _, ok := msg.Data()["_id"]; ok {
@@ -63,11 +77,11 @@ func (w *Writer) Write(msg message.Msg) func(client.Session) (message.Msg, error
 		var err error
 		switch msg.OP() {
 		case ops.Delete:
-			_, err = w.esClient.Delete().Index(w.index).Type(indexType).Id(id).Do(context.TODO())
+			_, err = w.esClient.Delete().Index(w.index).Type(indexType).Id(id).Do(ctx)
 		case ops.Insert:
-			_, err = w.esClient.Index().Index(w.index).Type(indexType).Id(id).BodyJson(msg.Data()).Do(context.TODO())
+			_, err = w.esClient.Index().Index(w.index).Type(indexType).Id(id).BodyJson(msg.Data()).Do(ctx)
 		case ops.Update:
-			_, err = w.esClient.Index().Index(w.index).Type(indexType).BodyJson(msg.Data()).Id(id).Do(context.TODO())
+			_, err = w.esClient.Index().Index(w.index).Type(indexType).BodyJson(msg.Data()).Id(id).Do(ctx)
 		}
 		if msg.Confirms() != nil && err == nil {
 			msg.Confirms() <- struct{}{}
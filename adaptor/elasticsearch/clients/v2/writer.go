@@ -3,8 +3,7 @@ package v2
 import (
 	"context"
 	"fmt"
-	"sync"
-	"time"
+	"net/http"
 
 	elastic "gopkg.in/olivere/elastic.v3"
 
@@ -17,19 +16,20 @@ import (
 )
 
 var (
-	_ client.Writer = &Writer{}
-	_ client.Closer = &Writer{}
+	_ client.Writer             = &Writer{}
+	_ client.ContextWriter      = &Writer{}
+	_ client.BatchWriter        = &Writer{}
+	_ client.ContextBatchWriter = &Writer{}
 )
 
-// Writer implements client.Writer and client.Session for sending requests to an elasticsearch
-// cluster via its _bulk API.
+// Writer implements client.BatchWriter for sending requests to an elasticsearch cluster via its
+// _bulk API, each batch is sent in a single request.
 type Writer struct {
-	index string
-	bp    *elastic.BulkProcessor
-	sync.Mutex
-	confirmChan chan struct{}
-	logger      log.Logger
-	writeError  error
+	index    string
+	esClient *elastic.Client
+	logger   log.Logger
+	// ctx is the context of writes made with Write and WriteBatch
+	ctx context.Context
 }
 
 func init() {
@@ -51,95 +51,99 @@ func init() {
 			return nil, err
 		}
 		w := &Writer{
-			index:  opts.Index,
-			logger: log.With("writer", "elasticsearch").With("version", 2),
+			index:    opts.Index,
+			esClient: esClient,
+			logger:   log.With("writer", "elasticsearch").With("version", 2),
 		}
-		p, err := esClient.BulkProcessor().
-			Name("TransporterWorker-1").
-			Workers(2).
-			BulkActions(1000).              // commit if # requests >= 1000
-			BulkSize(2 << 20).              // commit if size of requests >= 2 MB
-			FlushInterval(5 * time.Second). // commit every 5s
-			Before(w.preBulkProcessor).
-			After(w.postBulkProcessor).
-			Do(context.TODO())
-		if err != nil {
-			return nil, err
+		if w.ctx = opts.Context; w.ctx == nil {
+			w.ctx = context.Background()
 		}
-		w.bp = p
 		return w, nil
 	})
 }
 
 func (w *Writer) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	write := w.WriteContext(msg)
 	return func(s client.Session) (message.Msg, error) {
-		if w.writeError != nil {
-			return msg, w.writeError
-		}
-		w.Lock()
-		w.confirmChan = msg.Confirms()
-		w.Unlock()
-		indexType := msg.Namespace()
-		var id string
-		if _, ok := msg.Data()["_id"]; ok {
-			id = msg.ID()
-			msg.Data().Delete("_id")
-		}
+		return write(w.ctx, s)
+	}
+}
 
-		var br elastic.BulkableRequest
-		switch msg.OP() {
-		case ops.Delete:
-			// we need to flush any pending writes here or this could fail because we're using
-			// more than 1 worker
-			w.bp.Flush()
-			br = elastic.NewBulkDeleteRequest().Index(w.index).Type(indexType).Id(id)
-		case ops.Insert:
-			br = elastic.NewBulkIndexRequest().Index(w.index).Type(indexType).Id(id).Doc(msg.Data())
-		case ops.Update:
-			br = elastic.NewBulkUpdateRequest().Index(w.index).Type(indexType).Id(id).Doc(msg.Data())
+// WriteContext writes msg in a _bulk request of its own, the request is canceled once ctx is done.
+func (w *Writer) WriteContext(msg message.Msg) func(context.Context, client.Session) (message.Msg, error) {
+	return func(ctx context.Context, s client.Session) (message.Msg, error) {
+		if err := w.WriteBatchContext([]message.Msg{msg})(ctx, s); err != nil {
+			return nil, err
+		}
+		if msg.Confirms() != nil {
+			msg.Confirms() <- struct{}{}
 		}
-		w.bp.Add(br)
 		return msg, nil
 	}
 }
 
-// Close is called by clients.Close() when it receives on the done channel.
-func (w *Writer) Close() {
-	w.logger.Infoln("closing BulkProcessor")
-	w.bp.Close()
-}
-
-func (w *Writer) preBulkProcessor(executionID int64, requests []elastic.BulkableRequest) {
-	// we need to lock the Writer to ensure the confirmChan is not changed until postBulkProcessor has been called
-	w.Lock()
+// WriteBatch sends msgs in a single _bulk request, the batch fails if any of the documents
+// couldn't be written.
+func (w *Writer) WriteBatch(msgs []message.Msg) func(client.Session) error {
+	write := w.WriteBatchContext(msgs)
+	return func(s client.Session) error {
+		return write(w.ctx, s)
+	}
 }
 
-func (w *Writer) postBulkProcessor(executionID int64, reqs []elastic.BulkableRequest, resp *elastic.BulkResponse, err error) {
-	defer w.Unlock()
-	if resp != nil && err == nil {
-		w.logger.With("executionID", executionID).
-			With("took", fmt.Sprintf("%dms", resp.Took)).
+// WriteBatchContext is WriteBatch, the request is canceled once ctx is done.
+func (w *Writer) WriteBatchContext(msgs []message.Msg) func(context.Context, client.Session) error {
+	return func(ctx context.Context, _ client.Session) error {
+		bulk := w.esClient.Bulk()
+		for _, msg := range msgs {
+			if br := w.request(msg); br != nil {
+				bulk.Add(br)
+			}
+		}
+		total := bulk.NumberOfActions()
+		if total == 0 {
+			return nil
+		}
+		resp, err := bulk.Do(ctx)
+		if err != nil {
+			w.logger.Errorln(err)
+			return err
+		}
+		w.logger.With("took", fmt.Sprintf("%dms", resp.Took)).
 			With("succeeeded", len(resp.Succeeded())).
 			With("failed", len(resp.Failed())).
 			Debugln("_bulk flush completed")
-
-		if len(resp.Failed()) > 0 {
-			for i, f := range resp.Failed() {
-				w.logger.With("executionID", executionID).
-					With("index", f.Index).
-					With("type", f.Type).
-					With("id", f.Id).
-					With("error", fmt.Sprintf("%#v", f.Error)).
-					Errorln(fmt.Sprintf("_bulk failed list [%d]", i))
+		var failed int
+		for i, f := range resp.Failed() {
+			if f.Status == http.StatusNotFound && f.Error == nil {
+				// the document being deleted doesn't exist
+				continue
 			}
+			w.logger.With("index", f.Index).
+				With("type", f.Type).
+				With("id", f.Id).
+				With("error", fmt.Sprintf("%#v", f.Error)).
+				Errorln(fmt.Sprintf("_bulk failed list [%d]", i))
+			failed++
 		}
-
-		if w.confirmChan != nil && len(resp.Failed()) == 0 {
-			w.confirmChan <- struct{}{}
+		if failed > 0 {
+			return clients.BulkError{Failed: failed, Total: total}
 		}
+		return nil
 	}
-	if err != nil {
-		w.logger.With("executionID", executionID).Errorln(err)
+}
+
+// request returns the bulk request for msg, or nil if its op isn't written.
+func (w *Writer) request(msg message.Msg) elastic.BulkableRequest {
+	indexType := msg.Namespace()
+	id, _, doc := clients.Document(msg, "")
+	switch msg.OP() {
+	case ops.Delete:
+		return elastic.NewBulkDeleteRequest().Index(w.index).Type(indexType).Id(id)
+	case ops.Insert:
+		return elastic.NewBulkIndexRequest().Index(w.index).Type(indexType).Id(id).Doc(doc)
+	case ops.Update:
+		return elastic.NewBulkUpdateRequest().Index(w.index).Type(indexType).Id(id).Doc(doc)
 	}
-	w.writeError = err
+	return nil
 }
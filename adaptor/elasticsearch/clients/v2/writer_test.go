@@ -8,7 +8,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/compose/transporter/adaptor"
 	"github.com/compose/transporter/adaptor/elasticsearch/clients"
 	"github.com/compose/transporter/client"
 	"github.com/compose/transporter/log"
@@ -67,8 +66,6 @@ type countResponse struct {
 }
 
 func TestWriter(t *testing.T) {
-	confirms, cleanup := adaptor.MockConfirmWrites()
-	defer adaptor.VerifyWriteConfirmed(cleanup, t)
 	opts := &clients.ClientOptions{
 		URLs:       []string{testURL},
 		HTTPClient: http.DefaultClient,
@@ -76,27 +73,15 @@ func TestWriter(t *testing.T) {
 	}
 	vc := clients.Clients["v2"]
 	w, _ := vc.Creator(opts)
-	w.Write(
-		message.WithConfirms(
-			confirms,
-			message.From(ops.Insert, testType, map[string]interface{}{"hello": "world"})),
-	)(nil)
-	w.Write(
-		message.WithConfirms(
-			confirms,
-			message.From(ops.Insert, testType, map[string]interface{}{"_id": "booya", "hello": "world"})),
-	)(nil)
-	w.Write(
-		message.WithConfirms(
-			confirms,
-			message.From(ops.Update, testType, map[string]interface{}{"_id": "booya", "hello": "goodbye"})),
-	)(nil)
-	w.Write(
-		message.WithConfirms(
-			confirms,
-			message.From(ops.Delete, testType, map[string]interface{}{"_id": "booya", "hello": "goodbye"})),
-	)(nil)
-	w.(client.Closer).Close()
+	msgs := []message.Msg{
+		message.From(ops.Insert, testType, map[string]interface{}{"hello": "world"}),
+		message.From(ops.Insert, testType, map[string]interface{}{"_id": "booya", "hello": "world"}),
+		message.From(ops.Update, testType, map[string]interface{}{"_id": "booya", "hello": "goodbye"}),
+		message.From(ops.Delete, testType, map[string]interface{}{"_id": "booya", "hello": "goodbye"}),
+	}
+	if err := w.(client.BatchWriter).WriteBatch(msgs)(nil); err != nil {
+		t.Fatalf("unexpected WriteBatch error, %s", err)
+	}
 
 	if _, err := http.Get(fullURL("/_refresh")); err != nil {
 		t.Fatalf("_refresh request failed, %s", err)
@@ -9,7 +9,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/compose/transporter/adaptor"
 	"github.com/compose/transporter/adaptor/elasticsearch/clients"
 	"github.com/compose/transporter/client"
 	"github.com/compose/transporter/log"
@@ -98,8 +97,6 @@ type elasticResponse struct {
  * This tests non-parent-child insert,update,delete
  */
 func TestWriter(t *testing.T) {
-	confirms, cleanup := adaptor.MockConfirmWrites()
-	defer adaptor.VerifyWriteConfirmed(cleanup, t)
 	opts := &clients.ClientOptions{
 		URLs:       []string{testURL},
 		HTTPClient: http.DefaultClient,
@@ -107,27 +104,15 @@ func TestWriter(t *testing.T) {
 	}
 	vc := clients.Clients["v5"]
 	w, _ := vc.Creator(opts)
-	w.Write(
-		message.WithConfirms(
-			confirms,
-			message.From(ops.Insert, testType, map[string]interface{}{"hello": "world"})),
-	)(nil)
-	w.Write(
-		message.WithConfirms(
-			confirms,
-			message.From(ops.Insert, testType, map[string]interface{}{"_id": "booya", "hello": "world"})),
-	)(nil)
-	w.Write(
-		message.WithConfirms(
-			confirms,
-			message.From(ops.Update, testType, map[string]interface{}{"_id": "booya", "hello": "goodbye"})),
-	)(nil)
-	w.Write(
-		message.WithConfirms(
-			confirms,
-			message.From(ops.Delete, testType, map[string]interface{}{"_id": "booya", "hello": "goodbye"})),
-	)(nil)
-	w.(client.Closer).Close()
+	msgs := []message.Msg{
+		message.From(ops.Insert, testType, map[string]interface{}{"hello": "world"}),
+		message.From(ops.Insert, testType, map[string]interface{}{"_id": "booya", "hello": "world"}),
+		message.From(ops.Update, testType, map[string]interface{}{"_id": "booya", "hello": "goodbye"}),
+		message.From(ops.Delete, testType, map[string]interface{}{"_id": "booya", "hello": "goodbye"}),
+	}
+	if err := w.(client.BatchWriter).WriteBatch(msgs)(nil); err != nil {
+		t.Fatalf("unexpected WriteBatch error, %s", err)
+	}
 
 	if _, err := http.Get(fullURL("/_refresh")); err != nil {
 		t.Fatalf("_refresh request failed, %s", err)
@@ -150,8 +135,6 @@ func TestWriter(t *testing.T) {
  * This tests parent-child inserts and updates
  */
 func TestWithParentWriter(t *testing.T) {
-	confirms, cleanup := adaptor.MockConfirmWrites()
-	defer adaptor.VerifyWriteConfirmed(cleanup, t)
 	opts := &clients.ClientOptions{
 		URLs:       []string{testURL},
 		HTTPClient: http.DefaultClient,
@@ -162,25 +145,15 @@ func TestWithParentWriter(t *testing.T) {
 	createMapping()
 	vc := clients.Clients["v5"]
 	w, _ := vc.Creator(opts)
-	// insert parent
-	w.Write(
-		message.WithConfirms(
-			confirms,
-			message.From(ops.Insert, "company", map[string]interface{}{"_id": "9g2g", "name": "gingerbreadhouse"})),
-	)(nil)
-	// insert child
-	w.Write(
-		message.WithConfirms(
-			confirms,
-			message.From(ops.Insert, "employee", map[string]interface{}{"_id": "9g6g", "name": "witch", "parent_id": "gingerbreadhouse"})),
-	)(nil)
-	// update child
-	w.Write(
-		message.WithConfirms(
-			confirms,
-			message.From(ops.Update, "employee", map[string]interface{}{"_id": "9g6g", "name": "wickedwitch", "parent_id": "gingerbreadhouse"})),
-	)(nil)
-	w.(client.Closer).Close()
+	// insert the parent, then insert and update its child
+	msgs := []message.Msg{
+		message.From(ops.Insert, "company", map[string]interface{}{"_id": "9g2g", "name": "gingerbreadhouse"}),
+		message.From(ops.Insert, "employee", map[string]interface{}{"_id": "9g6g", "name": "witch", "parent_id": "gingerbreadhouse"}),
+		message.From(ops.Update, "employee", map[string]interface{}{"_id": "9g6g", "name": "wickedwitch", "parent_id": "gingerbreadhouse"}),
+	}
+	if err := w.(client.BatchWriter).WriteBatch(msgs)(nil); err != nil {
+		t.Fatalf("unexpected WriteBatch error, %s", err)
+	}
 	if _, err := http.Get(parentFullURL("/_refresh")); err != nil {
 		t.Fatalf("_refresh request failed, %s", err)
 	}
@@ -220,12 +193,11 @@ func TestWithParentWriter(t *testing.T) {
 
 	w2, _ := vc.Creator(opts)
 	// delete child
-	w2.Write(
-		message.WithConfirms(
-			confirms,
-			message.From(ops.Delete, "employee", map[string]interface{}{"_id": "9g6g", "name": "wickedwitch", "parent_id": "gingerbreadhouse"})),
-	)(nil)
-	w2.(client.Closer).Close()
+	if _, err := w2.Write(
+		message.From(ops.Delete, "employee", map[string]interface{}{"_id": "9g6g", "name": "wickedwitch", "parent_id": "gingerbreadhouse"}),
+	)(nil); err != nil {
+		t.Fatalf("unexpected Write error, %s", err)
+	}
 	time.Sleep(1 * time.Second)
 	deletedCountResp, err := http.Get(parentFullURL("/employee/_count"))
 	if err != nil {
@@ -1,6 +1,7 @@
 package elasticsearch
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -35,7 +36,8 @@ const (
 )
 
 var (
-	_ adaptor.Adaptor = &Elasticsearch{}
+	_ adaptor.Adaptor        = &Elasticsearch{}
+	_ adaptor.ContextAdaptor = &Elasticsearch{}
 )
 
 // Elasticsearch is an adaptor to connect a pipeline to
@@ -78,10 +80,16 @@ func (e *Elasticsearch) Reader() (client.Reader, error) {
 
 // Writer determines the which underlying writer to used based on the cluster's version.
 func (e *Elasticsearch) Writer(done chan struct{}, wg *sync.WaitGroup) (client.Writer, error) {
-	return setupWriter(e)
+	return setupWriter(context.Background(), e)
 }
 
-func setupWriter(conf *Elasticsearch) (client.Writer, error) {
+// WriterContext is Writer, the version request and any write made without a context of its own
+// are canceled once ctx is done.
+func (e *Elasticsearch) WriterContext(ctx context.Context) (client.Writer, error) {
+	return setupWriter(ctx, e)
+}
+
+func setupWriter(ctx context.Context, conf *Elasticsearch) (client.Writer, error) {
 	uri, err := url.Parse(conf.URI)
 	if err != nil {
 		return nil, client.InvalidURIError{URI: conf.URI, Err: err.Error()}
@@ -104,6 +112,7 @@ func setupWriter(conf *Elasticsearch) (client.Writer, error) {
 
 	hostsAndPorts := strings.Split(uri.Host, ",")
 	stringVersion, err := determineVersion(
+		ctx,
 		fmt.Sprintf("%s://%s", uri.Scheme, hostsAndPorts[0]),
 		uri.User,
 		httpClient,
@@ -127,6 +136,7 @@ func setupWriter(conf *Elasticsearch) (client.Writer, error) {
 				HTTPClient: httpClient,
 				Index:      uri.Path[1:],
 				ParentID:   conf.ParentID,
+				Context:    ctx,
 			}
 			versionedClient, _ := vc.Creator(opts)
 			return versionedClient, nil
@@ -136,11 +146,12 @@ func setupWriter(conf *Elasticsearch) (client.Writer, error) {
 	return nil, client.VersionError{URI: conf.URI, V: stringVersion, Err: "unsupported client"}
 }
 
-func determineVersion(uri string, user *url.Userinfo, httpClient *http.Client) (string, error) {
+func determineVersion(ctx context.Context, uri string, user *url.Userinfo, httpClient *http.Client) (string, error) {
 	req, err := http.NewRequest(http.MethodGet, uri, nil)
 	if err != nil {
 		return "", err
 	}
+	req = req.WithContext(ctx)
 	if user != nil {
 		if pwd, ok := user.Password(); ok {
 			req.SetBasicAuth(user.Username(), pwd)
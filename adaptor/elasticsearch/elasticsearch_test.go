@@ -1,6 +1,7 @@
 package elasticsearch
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -10,6 +11,7 @@ import (
 	"github.com/compose/transporter/adaptor"
 	"github.com/compose/transporter/client"
 	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/ops"
 )
 
 var (
@@ -123,6 +125,24 @@ var clientTests = []struct {
 	},
 }
 
+func TestWriterContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Elasticsearch{BaseConfig: adaptor.BaseConfig{URI: goodVersionServer.URL}}
+	w, err := e.WriterContext(ctx)
+	if err != nil {
+		t.Fatalf("unexpected WriterContext() error, %s", err)
+	}
+	cancel()
+	msg := message.From(ops.Insert, "test", map[string]interface{}{"_id": "1"})
+	if _, err := w.Write(msg)(nil); err == nil {
+		t.Error("no Write error after the context was canceled")
+	}
+	connErr := client.ConnectError{Reason: goodVersionServer.URL}
+	if _, err := e.WriterContext(ctx); err != connErr {
+		t.Errorf("wrong WriterContext() error, expected %s, got %v", connErr, err)
+	}
+}
+
 func TestInit(t *testing.T) {
 	defer func() {
 		goodVersionServer.Close()
@@ -1,6 +1,7 @@
 package file
 
 import (
+	"context"
 	"sync"
 
 	"github.com/compose/transporter/adaptor"
@@ -10,6 +11,7 @@ import (
 const (
 	sampleConfig = `{
   "uri": "stdout://"
+  // "bulk": false
 }`
 
 	description = "an adaptor that reads / writes files"
@@ -19,6 +21,7 @@ const (
 // source / sink for file's on disk, as well as a sink to stdout.
 type File struct {
 	adaptor.BaseConfig
+	Bulk bool `json:"bulk"`
 }
 
 func init() {
@@ -40,9 +43,18 @@ func (f *File) Reader() (client.Reader, error) {
 	return newReader(), nil
 }
 
-// Writer instantiates a Writer for use with working with the file.
+// Writer instantiates a Writer for use with working with the file, messages are written in
+// batches if bulk is enabled.
 func (f *File) Writer(done chan struct{}, wg *sync.WaitGroup) (client.Writer, error) {
-	return newWriter(), nil
+	return f.WriterContext(context.Background())
+}
+
+// WriterContext instantiates a Writer which stops writing once ctx is done.
+func (f *File) WriterContext(ctx context.Context) (client.Writer, error) {
+	if f.Bulk {
+		return newBulker(ctx), nil
+	}
+	return newWriter(ctx), nil
 }
 
 // Description for file adaptor
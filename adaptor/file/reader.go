@@ -1,6 +1,7 @@
 package file
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 
@@ -12,7 +13,8 @@ import (
 )
 
 var (
-	_ client.Reader = &Reader{}
+	_ client.Reader        = &Reader{}
+	_ client.ContextReader = &Reader{}
 )
 
 // Reader implements the behavior defined by client.Reader for interfacing with the file.
@@ -24,35 +26,49 @@ func newReader() client.Reader {
 
 func (r *Reader) Read(_ map[string]client.MessageSet, filterFn client.NsFilterFunc) client.MessageChanFunc {
 	return func(s client.Session, done chan struct{}) (chan client.MessageSet, error) {
-		out := make(chan client.MessageSet)
-		session := s.(*Session)
-		ns := session.file.Name()
-		go func() {
-			defer close(out)
-			results := r.decodeFile(session, done)
-			for {
+		return r.read(filterFn, s, done)
+	}
+}
+
+// ReadContext reads like Read until ctx is done.
+func (r *Reader) ReadContext(_ map[string]client.MessageSet, filterFn client.NsFilterFunc) func(context.Context, client.Session) (chan client.MessageSet, error) {
+	return func(ctx context.Context, s client.Session) (chan client.MessageSet, error) {
+		return r.read(filterFn, s, ctx.Done())
+	}
+}
+
+func (r *Reader) read(filterFn client.NsFilterFunc, s client.Session, done <-chan struct{}) (chan client.MessageSet, error) {
+	out := make(chan client.MessageSet)
+	session := s.(*Session)
+	ns := session.file.Name()
+	go func() {
+		defer close(out)
+		results := r.decodeFile(session, done)
+		for {
+			select {
+			case <-done:
+				return
+			case result, ok := <-results:
+				if !ok {
+					log.With("file", ns).Infoln("Read completed")
+					return
+				}
+				if !filterFn(ns) {
+					continue
+				}
 				select {
+				case out <- client.MessageSet{Msg: message.From(ops.Insert, ns, result)}:
 				case <-done:
 					return
-				case result, ok := <-results:
-					if !ok {
-						log.With("file", ns).Infoln("Read completed")
-						return
-					}
-					if filterFn(ns) {
-						out <- client.MessageSet{
-							Msg: message.From(ops.Insert, ns, result),
-						}
-					}
 				}
 			}
-		}()
+		}
+	}()
 
-		return out, nil
-	}
+	return out, nil
 }
 
-func (r *Reader) decodeFile(s *Session, done chan struct{}) chan data.Data {
+func (r *Reader) decodeFile(s *Session, done <-chan struct{}) chan data.Data {
 	out := make(chan data.Data)
 	go func() {
 		defer close(out)
@@ -65,7 +81,11 @@ func (r *Reader) decodeFile(s *Session, done chan struct{}) chan data.Data {
 				log.With("file", s.file.Name()).Errorf("Can't unmarshal document (%v)", err)
 				continue
 			}
-			out <- doc
+			select {
+			case out <- doc:
+			case <-done:
+				return
+			}
 		}
 	}()
 	return out
@@ -1,9 +1,11 @@
 package file
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/compose/transporter/adaptor"
 	"github.com/compose/transporter/client"
@@ -44,3 +46,33 @@ func TestRead(t *testing.T) {
 		t.Errorf("unexpected message count, expected %d, got %d\n", 10, 10)
 	}
 }
+
+func TestReadContext(t *testing.T) {
+	c, err := NewClient(WithURI(fmt.Sprintf("file://%s", filepath.Join("testdata", "start_test.json"))))
+	if err != nil {
+		t.Fatalf("unexpected NewClient() error, %s", err)
+	}
+	s, err := c.Connect()
+	if err != nil {
+		t.Fatalf("unexpected Connect() error, %s", err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	msgChan, err := newReader().(client.ContextReader).ReadContext(map[string]client.MessageSet{}, func(string) bool { return true })(ctx, s)
+	if err != nil {
+		t.Fatalf("unexpected ReadContext() error, %s", err)
+	}
+	<-msgChan
+	cancel()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-msgChan:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("reading didn't stop once the context was canceled")
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package file
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 
@@ -8,18 +9,37 @@ import (
 	"github.com/compose/transporter/message"
 )
 
-var _ client.Writer = &Writer{}
+var (
+	_ client.Writer             = &Writer{}
+	_ client.ContextWriter      = &Writer{}
+	_ client.BatchWriter        = &Bulk{}
+	_ client.ContextBatchWriter = &Bulk{}
+)
 
 // Writer implements client.Writer for use with Files
-type Writer struct{}
+type Writer struct {
+	// ctx is the context of writes made with Write and WriteBatch
+	ctx context.Context
+}
 
-func newWriter() *Writer {
-	w := &Writer{}
+func newWriter(ctx context.Context) *Writer {
+	w := &Writer{ctx: ctx}
 	return w
 }
 
 func (w *Writer) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	write := w.WriteContext(msg)
 	return func(s client.Session) (message.Msg, error) {
+		return write(w.ctx, s)
+	}
+}
+
+// WriteContext writes msg unless ctx is already done.
+func (w *Writer) WriteContext(msg message.Msg) func(context.Context, client.Session) (message.Msg, error) {
+	return func(ctx context.Context, s client.Session) (message.Msg, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		if err := dumpMessage(msg, s.(*Session).file); err != nil {
 			return nil, err
 		}
@@ -30,6 +50,39 @@ func (w *Writer) Write(msg message.Msg) func(client.Session) (message.Msg, error
 	}
 }
 
+// Bulk implements client.BatchWriter for use with Files, the pipeline writes messages to it in
+// batches.
+type Bulk struct {
+	*Writer
+}
+
+func newBulker(ctx context.Context) *Bulk {
+	return &Bulk{Writer: newWriter(ctx)}
+}
+
+// WriteBatch writes every message in msgs to the file.
+func (b *Bulk) WriteBatch(msgs []message.Msg) func(client.Session) error {
+	write := b.WriteBatchContext(msgs)
+	return func(s client.Session) error {
+		return write(b.ctx, s)
+	}
+}
+
+// WriteBatchContext is WriteBatch, no more messages are written once ctx is done.
+func (b *Bulk) WriteBatchContext(msgs []message.Msg) func(context.Context, client.Session) error {
+	return func(ctx context.Context, s client.Session) error {
+		for _, msg := range msgs {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := dumpMessage(msg, s.(*Session).file); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
 func dumpMessage(msg message.Msg, f *os.File) error {
 	return json.NewEncoder(f).Encode(msg.Data())
 }
@@ -2,6 +2,7 @@ package file
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -26,7 +27,7 @@ func TestWrite(t *testing.T) {
 	tmpSession := &Session{file: f}
 	confirms, cleanup := adaptor.MockConfirmWrites()
 	defer adaptor.VerifyWriteConfirmed(cleanup, t)
-	w := newWriter()
+	w := newWriter(context.Background())
 	for i := 0; i < 2; i++ {
 		msg := message.From(ops.Insert, "test", map[string]interface{}{"_id": "546656989330a846dc7ce327", "test": "hello world"})
 		if i == 0 {
@@ -44,3 +45,83 @@ func TestWrite(t *testing.T) {
 		t.Errorf("mismatched data in file, expected %s, got %s", string(expected), string(actual))
 	}
 }
+
+func TestWriteBatch(t *testing.T) {
+	tmpD, err := ioutil.TempDir("", "write_batch_test")
+	if err != nil {
+		t.Fatalf("unable to create tmp dir, %s", err)
+	}
+	defer os.RemoveAll(tmpD)
+	f, err := os.Create(filepath.Join(tmpD, "data.json"))
+	if err != nil {
+		t.Fatalf("unable to create file, %s", err)
+	}
+	defer f.Close()
+	msgs := make([]message.Msg, 2)
+	for i := range msgs {
+		msgs[i] = message.From(ops.Insert, "test", map[string]interface{}{"_id": "546656989330a846dc7ce327", "test": "hello world"})
+	}
+	if err := newBulker(context.Background()).WriteBatch(msgs)(&Session{file: f}); err != nil {
+		t.Errorf("unexpected WriteBatch error, %s\n", err)
+	}
+	golden := filepath.Join("testdata", "write_test.golden")
+	expected, _ := ioutil.ReadFile(golden)
+	actual, _ := ioutil.ReadFile(filepath.Join(tmpD, "data.json"))
+
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("mismatched data in file, expected %s, got %s", string(expected), string(actual))
+	}
+}
+
+func TestWriteContextDone(t *testing.T) {
+	tmpD, err := ioutil.TempDir("", "write_context_test")
+	if err != nil {
+		t.Fatalf("unable to create tmp dir, %s", err)
+	}
+	defer os.RemoveAll(tmpD)
+	f, err := os.Create(filepath.Join(tmpD, "data.json"))
+	if err != nil {
+		t.Fatalf("unable to create file, %s", err)
+	}
+	defer f.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	msg := message.From(ops.Insert, "test", map[string]interface{}{"_id": "546656989330a846dc7ce327"})
+	if _, err := newWriter(context.Background()).WriteContext(msg)(ctx, &Session{file: f}); err != context.Canceled {
+		t.Errorf("wrong WriteContext error, expected %s, got %v", context.Canceled, err)
+	}
+	if err := newBulker(context.Background()).WriteBatchContext([]message.Msg{msg})(ctx, &Session{file: f}); err != context.Canceled {
+		t.Errorf("wrong WriteBatchContext error, expected %s, got %v", context.Canceled, err)
+	}
+	if actual, _ := ioutil.ReadFile(filepath.Join(tmpD, "data.json")); len(actual) != 0 {
+		t.Errorf("messages written after the context was canceled, %s", actual)
+	}
+}
+
+func TestWriterContextDone(t *testing.T) {
+	tmpD, err := ioutil.TempDir("", "writer_context_test")
+	if err != nil {
+		t.Fatalf("unable to create tmp dir, %s", err)
+	}
+	defer os.RemoveAll(tmpD)
+	f, err := os.Create(filepath.Join(tmpD, "data.json"))
+	if err != nil {
+		t.Fatalf("unable to create file, %s", err)
+	}
+	defer f.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	msg := message.From(ops.Insert, "test", map[string]interface{}{"_id": "546656989330a846dc7ce327"})
+	for _, bulk := range []bool{false, true} {
+		w, err := (&File{Bulk: bulk}).WriterContext(ctx)
+		if err != nil {
+			t.Fatalf("unexpected WriterContext() error, %s", err)
+		}
+		if _, err := w.Write(msg)(&Session{file: f}); err != context.Canceled {
+			t.Errorf("[bulk %t] wrong Write error, expected %s, got %v", bulk, context.Canceled, err)
+		}
+	}
+	if actual, _ := ioutil.ReadFile(filepath.Join(tmpD, "data.json")); len(actual) != 0 {
+		t.Errorf("messages written after the context was canceled, %s", actual)
+	}
+}
@@ -1,8 +1,7 @@
 package mongodb
 
 import (
-	"sync"
-	"time"
+	"context"
 
 	"github.com/compose/transporter/client"
 	"github.com/compose/transporter/log"
@@ -18,119 +17,148 @@ const (
 )
 
 var (
-	_ client.Writer = &Bulk{}
+	_ client.Writer             = &Bulk{}
+	_ client.ContextWriter      = &Bulk{}
+	_ client.BatchWriter        = &Bulk{}
+	_ client.ContextBatchWriter = &Bulk{}
 )
 
-// Bulk implements client.Writer for use with MongoDB and takes advantage of the Bulk API for
-// performance improvements.
+// Bulk implements client.BatchWriter for use with MongoDB and takes advantage of the Bulk API for
+// performance improvements, the operations on each collection in a batch are run together.
 type Bulk struct {
+	// ctx is the context of writes made with Write and WriteBatch
+	ctx context.Context
+}
+
+// bulkBatch holds the bulk operation of each collection written to in a batch.
+type bulkBatch struct {
+	s       *mgo.Session
+	colls   []string
 	bulkMap map[string]*bulkOperation
-	*sync.RWMutex
-	confirmChan chan struct{}
 }
 
 type bulkOperation struct {
-	s          *mgo.Session
 	bulk       *mgo.Bulk
 	opCounter  int
 	bsonOpSize int
 }
 
-func newBulker(done chan struct{}, wg *sync.WaitGroup) *Bulk {
-	b := &Bulk{
-		bulkMap: make(map[string]*bulkOperation),
-		RWMutex: &sync.RWMutex{},
-	}
-	wg.Add(1)
-	go b.run(done, wg)
-	return b
+func newBulker(ctx context.Context) *Bulk {
+	return &Bulk{ctx: ctx}
 }
 
 func (b *Bulk) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	write := b.WriteContext(msg)
 	return func(s client.Session) (message.Msg, error) {
-		coll := msg.Namespace()
-		b.Lock()
-		b.confirmChan = msg.Confirms()
-		bOp, ok := b.bulkMap[coll]
-		if !ok {
-			s := s.(*Session).mgoSession.Copy()
-			bOp = &bulkOperation{
-				s:    s,
-				bulk: s.DB("").C(coll).Bulk(),
-			}
-			b.bulkMap[coll] = bOp
+		return write(b.ctx, s)
+	}
+}
+
+// WriteContext writes msg in a batch of its own, failing once the deadline of ctx has passed.
+func (b *Bulk) WriteContext(msg message.Msg) func(context.Context, client.Session) (message.Msg, error) {
+	return func(ctx context.Context, s client.Session) (message.Msg, error) {
+		if err := b.WriteBatchContext([]message.Msg{msg})(ctx, s); err != nil {
+			return nil, err
+		}
+		if msg.Confirms() != nil {
+			msg.Confirms() <- struct{}{}
 		}
-		bs, err := bson.Marshal(msg.Data())
+		return msg, nil
+	}
+}
+
+// WriteBatch runs a bulk operation per collection with the msgs written to it, a collection's
+// operation is run early if it grows past the size limits.
+func (b *Bulk) WriteBatch(msgs []message.Msg) func(client.Session) error {
+	write := b.WriteBatchContext(msgs)
+	return func(s client.Session) error {
+		return write(b.ctx, s)
+	}
+}
+
+// WriteBatchContext is WriteBatch, failing once the deadline of ctx has passed.
+func (b *Bulk) WriteBatchContext(msgs []message.Msg) func(context.Context, client.Session) error {
+	return func(ctx context.Context, s client.Session) error {
+		session, err := contextSession(ctx, s.(*Session).mgoSession)
 		if err != nil {
-			log.Infof("unable to marshal doc to BSON, can't calculate size", err)
+			return err
 		}
-		// add the 4 bytes for the MsgHeader
-		// https://docs.mongodb.com/manual/reference/mongodb-wire-protocol/#standard-message-header
-		msgSize := len(bs) + 4
-
-		// if the next op is going to put us over, flush and recreate bOp
-		if bOp.opCounter >= maxObjSize || bOp.bsonOpSize+msgSize >= maxBSONObjSize {
-			err = b.flush(coll, bOp)
-			if err == nil && b.confirmChan != nil {
-				b.confirmChan <- struct{}{}
+		batch := newBulkBatch(session)
+		defer batch.s.Close()
+		for _, msg := range msgs {
+			if err := batch.add(msg); err != nil {
+				return err
 			}
-			s := s.(*Session).mgoSession.Copy()
-			bOp = &bulkOperation{
-				s:    s,
-				bulk: s.DB("").C(coll).Bulk(),
-			}
-			b.bulkMap[coll] = bOp
 		}
+		return batch.flushAll()
+	}
+}
 
-		switch msg.OP() {
-		case ops.Delete:
-			bOp.bulk.Remove(bson.M{"_id": msg.Data().Get("_id")})
-		case ops.Insert:
-			bOp.bulk.Insert(msg.Data())
-		case ops.Update:
-			bOp.bulk.Update(bson.M{"_id": msg.Data().Get("_id")}, msg.Data())
-		}
-		bOp.bsonOpSize += msgSize
-		bOp.opCounter++
-		b.Unlock()
-		return msg, err
+func newBulkBatch(s *mgo.Session) *bulkBatch {
+	return &bulkBatch{
+		s:       s,
+		bulkMap: make(map[string]*bulkOperation),
 	}
 }
 
-func (b *Bulk) run(done chan struct{}, wg *sync.WaitGroup) {
-	defer wg.Done()
-	for {
-		select {
-		case <-time.After(2 * time.Second):
-			if err := b.flushAll(); err != nil {
-				log.Errorf("flush error, %s", err)
-				return
-			}
-		case <-done:
-			log.Debugln("received done channel")
-			if err := b.flushAll(); err != nil {
-				log.Errorf("flush error, %s", err)
-			}
-			return
+func (b *bulkBatch) add(msg message.Msg) error {
+	coll := msg.Namespace()
+	bOp, ok := b.bulkMap[coll]
+	if !ok {
+		bOp = b.newOperation(coll)
+	}
+	bs, err := bson.Marshal(msg.Data())
+	if err != nil {
+		log.Infof("unable to marshal doc to BSON, can't calculate size, %s", err)
+	}
+	// add the 4 bytes for the MsgHeader
+	// https://docs.mongodb.com/manual/reference/mongodb-wire-protocol/#standard-message-header
+	msgSize := len(bs) + 4
+
+	// if the next op is going to put us over, flush and recreate bOp
+	if bOp.opCounter >= maxObjSize || bOp.bsonOpSize+msgSize >= maxBSONObjSize {
+		if err := b.flush(coll, bOp); err != nil {
+			return err
 		}
+		bOp = b.newOperation(coll)
 	}
+
+	switch msg.OP() {
+	case ops.Delete:
+		bOp.bulk.Remove(bson.M{"_id": msg.Data().Get("_id")})
+	case ops.Insert:
+		bOp.bulk.Insert(msg.Data())
+	case ops.Update:
+		bOp.bulk.Update(bson.M{"_id": msg.Data().Get("_id")}, msg.Data())
+	}
+	bOp.bsonOpSize += msgSize
+	bOp.opCounter++
+	return nil
+}
+
+func (b *bulkBatch) newOperation(coll string) *bulkOperation {
+	if _, ok := b.bulkMap[coll]; !ok {
+		b.colls = append(b.colls, coll)
+	}
+	bOp := &bulkOperation{bulk: b.s.DB("").C(coll).Bulk()}
+	b.bulkMap[coll] = bOp
+	return bOp
 }
 
-func (b *Bulk) flushAll() error {
-	b.Lock()
-	for c, bOp := range b.bulkMap {
-		if err := b.flush(c, bOp); err != nil {
+// flushAll runs the operation of each collection in the order they were first written to.
+func (b *bulkBatch) flushAll() error {
+	for _, c := range b.colls {
+		if err := b.flush(c, b.bulkMap[c]); err != nil {
 			return err
 		}
 	}
-	if b.confirmChan != nil {
-		b.confirmChan <- struct{}{}
-	}
-	b.Unlock()
 	return nil
 }
 
-func (b *Bulk) flush(c string, bOp *bulkOperation) error {
+func (b *bulkBatch) flush(c string, bOp *bulkOperation) error {
+	if bOp.opCounter == 0 {
+		return nil
+	}
 	log.With("collection", c).With("opCounter", bOp.opCounter).With("bsonOpSize", bOp.bsonOpSize).Debugln("flushing bulk messages")
 	_, err := bOp.bulk.Run()
 	if err != nil && !mgo.IsDup(err) {
@@ -143,8 +171,7 @@ func (b *Bulk) flush(c string, bOp *bulkOperation) error {
 			return err
 		}
 	}
-	bOp.s.Close()
+	bOp.opCounter = 0
 	log.With("collection", c).Debugln("flush complete")
-	delete(b.bulkMap, c)
 	return nil
 }
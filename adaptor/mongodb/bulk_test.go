@@ -1,11 +1,10 @@
 package mongodb
 
 import (
+	"context"
 	"crypto/rand"
 	"fmt"
-	"sync"
 	"testing"
-	"time"
 
 	"gopkg.in/mgo.v2/bson"
 
@@ -44,11 +43,7 @@ func checkBulkCount(c string, countQuery bson.M, expectedCount int, t *testing.T
 }
 
 func TestBulkWrite(t *testing.T) {
-	confirms, cleanup := adaptor.MockConfirmWrites()
-	defer adaptor.VerifyWriteConfirmed(cleanup, t)
-	var wg sync.WaitGroup
-	done := make(chan struct{})
-	b := newBulker(done, &wg)
+	b := newBulker(context.Background())
 
 	c, _ := NewClient(WithURI(fmt.Sprintf("mongodb://127.0.0.1:27017/%s", bulkTestData.DB)))
 	s, err := c.Connect()
@@ -57,23 +52,41 @@ func TestBulkWrite(t *testing.T) {
 	}
 	defer s.(*Session).Close()
 	for _, bt := range bulkTests {
+		var msgs []message.Msg
 		for i := 0; i < testBulkMsgCount; i++ {
 			data := map[string]interface{}{"_id": i, "i": i}
 			for k, v := range bt.extraData {
 				data[k] = v
 			}
-			b.Write(message.WithConfirms(confirms, message.From(bt.op, bulkTestData.C, data)))(s)
+			msgs = append(msgs, message.From(bt.op, bulkTestData.C, data))
+		}
+		if err := b.WriteBatch(msgs)(s); err != nil {
+			t.Errorf("unexpected WriteBatch error, %s", err)
 		}
-		time.Sleep(3 * time.Second)
 		checkBulkCount(bulkTestData.C, bt.countQuery, bt.expectedCount, t)
 	}
-	close(done)
+}
+
+func TestBulkWriteConfirms(t *testing.T) {
+	confirms, cleanup := adaptor.MockConfirmWrites()
+	defer adaptor.VerifyWriteConfirmed(cleanup, t)
+	b := newBulker(context.Background())
+
+	c, _ := NewClient(WithURI(fmt.Sprintf("mongodb://127.0.0.1:27017/%s", bulkTestData.DB)))
+	s, err := c.Connect()
+	if err != nil {
+		t.Fatalf("unable to initialize connection to mongodb, %s", err)
+	}
+	defer s.(*Session).Close()
+	msg := message.From(ops.Insert, "confirms", map[string]interface{}{"_id": 0})
+	if _, err := b.Write(message.WithConfirms(confirms, msg))(s); err != nil {
+		t.Errorf("unexpected Write error, %s", err)
+	}
+	checkBulkCount("confirms", bson.M{}, 1, t)
 }
 
 func TestBulkWriteMixedOps(t *testing.T) {
-	var wg sync.WaitGroup
-	done := make(chan struct{})
-	b := newBulker(done, &wg)
+	b := newBulker(context.Background())
 
 	c, _ := NewClient(WithURI(fmt.Sprintf("mongodb://127.0.0.1:27017/%s", bulkTestData.DB)))
 	s, err := c.Connect()
@@ -82,30 +95,31 @@ func TestBulkWriteMixedOps(t *testing.T) {
 	}
 	defer s.(*Session).Close()
 	mixedModeC := "mixed_mode"
-	b.Write(message.From(ops.Insert, mixedModeC, map[string]interface{}{"_id": 0}))(s)
-	b.Write(message.From(ops.Insert, mixedModeC, map[string]interface{}{"_id": 1}))(s)
-	b.Write(message.From(ops.Insert, mixedModeC, map[string]interface{}{"_id": 2}))(s)
-	b.Write(message.From(ops.Update, mixedModeC, map[string]interface{}{"_id": 2, "hello": "world"}))(s)
-	b.Write(message.From(ops.Insert, mixedModeC, map[string]interface{}{"_id": 3}))(s)
-	b.Write(message.From(ops.Update, mixedModeC, map[string]interface{}{"_id": 1, "moar": "tests"}))(s)
-	b.Write(message.From(ops.Insert, mixedModeC, map[string]interface{}{"_id": 4, "say": "goodbye"}))(s)
-	b.Write(message.From(ops.Delete, mixedModeC, map[string]interface{}{"_id": 1, "moar": "tests"}))(s)
-	b.Write(message.From(ops.Delete, mixedModeC, map[string]interface{}{"_id": 3}))(s)
-	b.Write(message.From(ops.Insert, mixedModeC, map[string]interface{}{"_id": 5}))(s)
+	msgs := []message.Msg{
+		message.From(ops.Insert, mixedModeC, map[string]interface{}{"_id": 0}),
+		message.From(ops.Insert, mixedModeC, map[string]interface{}{"_id": 1}),
+		message.From(ops.Insert, mixedModeC, map[string]interface{}{"_id": 2}),
+		message.From(ops.Update, mixedModeC, map[string]interface{}{"_id": 2, "hello": "world"}),
+		message.From(ops.Insert, mixedModeC, map[string]interface{}{"_id": 3}),
+		message.From(ops.Update, mixedModeC, map[string]interface{}{"_id": 1, "moar": "tests"}),
+		message.From(ops.Insert, mixedModeC, map[string]interface{}{"_id": 4, "say": "goodbye"}),
+		message.From(ops.Delete, mixedModeC, map[string]interface{}{"_id": 1, "moar": "tests"}),
+		message.From(ops.Delete, mixedModeC, map[string]interface{}{"_id": 3}),
+		message.From(ops.Insert, mixedModeC, map[string]interface{}{"_id": 5}),
+	}
+	if err := b.WriteBatch(msgs)(s); err != nil {
+		t.Errorf("unexpected WriteBatch error, %s", err)
+	}
 
 	// so... after the ops get flushed we should have the following:
 	// 4 docs left
 	// _id: 2 should have been updated
-	time.Sleep(3 * time.Second)
 	checkBulkCount(mixedModeC, bson.M{}, 4, t)
 	checkBulkCount(mixedModeC, bson.M{"_id": 2, "hello": "world"}, 1, t)
-	close(done)
 }
 
 func TestBulkOpCount(t *testing.T) {
-	var wg sync.WaitGroup
-	done := make(chan struct{})
-	b := newBulker(done, &wg)
+	b := newBulker(context.Background())
 
 	c, _ := NewClient(WithURI(fmt.Sprintf("mongodb://127.0.0.1:27017/%s", bulkTestData.DB)))
 	s, err := c.Connect()
@@ -113,20 +127,18 @@ func TestBulkOpCount(t *testing.T) {
 		t.Fatalf("unable to initialize connection to mongodb, %s", err)
 	}
 	defer s.(*Session).Close()
+	var msgs []message.Msg
 	for i := 0; i < maxObjSize; i++ {
-		b.Write(message.From(ops.Insert, "bar", map[string]interface{}{"i": i}))(s)
+		msgs = append(msgs, message.From(ops.Insert, "bar", map[string]interface{}{"i": i}))
+	}
+	if err := b.WriteBatch(msgs)(s); err != nil {
+		t.Errorf("unexpected WriteBatch error, %s", err)
 	}
-	close(done)
-	wg.Wait()
 	checkBulkCount("bar", bson.M{}, maxObjSize, t)
 }
 
 func TestBulkIsDup(t *testing.T) {
-	confirms, cleanup := adaptor.MockConfirmWrites()
-	defer adaptor.VerifyWriteConfirmed(cleanup, t)
-	var wg sync.WaitGroup
-	done := make(chan struct{})
-	b := newBulker(done, &wg)
+	b := newBulker(context.Background())
 
 	c, _ := NewClient(WithURI(fmt.Sprintf("mongodb://127.0.0.1:27017/%s", bulkTestData.DB)))
 	s, err := c.Connect()
@@ -134,54 +146,27 @@ func TestBulkIsDup(t *testing.T) {
 		t.Fatalf("unable to initialize connection to mongodb, %s", err)
 	}
 	defer s.(*Session).Close()
+	var msgs []message.Msg
 	for i := 0; i < testBulkMsgCount; i++ {
-		b.Write(
-			message.WithConfirms(
-				confirms,
-				message.From(ops.Insert, "dupErr", map[string]interface{}{"_id": i, "i": i}),
-			),
-		)(s)
-	}
-	time.Sleep(3 * time.Second)
+		msgs = append(msgs, message.From(ops.Insert, "dupErr", map[string]interface{}{"_id": i, "i": i}))
+	}
+	if err := b.WriteBatch(msgs)(s); err != nil {
+		t.Errorf("unexpected WriteBatch error, %s", err)
+	}
 	checkBulkCount("dupErr", bson.M{}, testBulkMsgCount, t)
 
+	msgs = nil
 	for i := 0; i < (2 * testBulkMsgCount); i++ {
-		b.Write(
-			message.WithConfirms(
-				confirms,
-				message.From(ops.Insert, "dupErr", map[string]interface{}{"_id": i, "i": i}),
-			),
-		)(s)
+		msgs = append(msgs, message.From(ops.Insert, "dupErr", map[string]interface{}{"_id": i, "i": i}))
 	}
-
-	close(done)
-	wg.Wait()
-	checkBulkCount("dupErr", bson.M{}, (2 * testBulkMsgCount), t)
-}
-
-func TestFlushOnDone(t *testing.T) {
-	var wg sync.WaitGroup
-	done := make(chan struct{})
-	b := newBulker(done, &wg)
-
-	c, _ := NewClient(WithURI(fmt.Sprintf("mongodb://127.0.0.1:27017/%s", bulkTestData.DB)))
-	s, err := c.Connect()
-	if err != nil {
-		t.Fatalf("unable to initialize connection to mongodb, %s", err)
+	if err := b.WriteBatch(msgs)(s); err != nil {
+		t.Errorf("unexpected WriteBatch error, %s", err)
 	}
-	defer s.(*Session).Close()
-	for i := 0; i < testBulkMsgCount; i++ {
-		b.Write(message.From(ops.Insert, "baz", map[string]interface{}{"i": i}))(s)
-	}
-	close(done)
-	wg.Wait()
-	checkBulkCount("baz", bson.M{}, testBulkMsgCount, t)
+	checkBulkCount("dupErr", bson.M{}, (2 * testBulkMsgCount), t)
 }
 
 func TestBulkMulitpleCollections(t *testing.T) {
-	var wg sync.WaitGroup
-	done := make(chan struct{})
-	b := newBulker(done, &wg)
+	b := newBulker(context.Background())
 
 	c, _ := NewClient(WithURI(fmt.Sprintf("mongodb://127.0.0.1:27017/%s", bulkTestData.DB)))
 	s, err := c.Connect()
@@ -189,34 +174,32 @@ func TestBulkMulitpleCollections(t *testing.T) {
 		t.Fatalf("unable to initialize connection to mongodb, %s", err)
 	}
 	defer s.(*Session).Close()
+	var msgs []message.Msg
 	for i := 0; i < (maxObjSize + 1); i++ {
-		b.Write(message.From(ops.Insert, "multi_c", map[string]interface{}{"i": i}))(s)
+		msgs = append(msgs, message.From(ops.Insert, "multi_c", map[string]interface{}{"i": i}))
 	}
 	for i := 0; i < testBulkMsgCount; i++ {
-		b.Write(message.From(ops.Insert, "multi_a", map[string]interface{}{"i": i}))(s)
-		b.Write(message.From(ops.Insert, "multi_b", map[string]interface{}{"i": i}))(s)
+		msgs = append(msgs,
+			message.From(ops.Insert, "multi_a", map[string]interface{}{"i": i}),
+			message.From(ops.Insert, "multi_b", map[string]interface{}{"i": i}),
+		)
+	}
+	if err := b.WriteBatch(msgs)(s); err != nil {
+		t.Errorf("unexpected WriteBatch error, %s", err)
 	}
-	checkBulkCount("multi_a", bson.M{}, 0, t)
-	checkBulkCount("multi_b", bson.M{}, 0, t)
-	checkBulkCount("multi_c", bson.M{}, maxObjSize, t)
-	time.Sleep(3 * time.Second)
 	checkBulkCount("multi_a", bson.M{}, testBulkMsgCount, t)
 	checkBulkCount("multi_b", bson.M{}, testBulkMsgCount, t)
 	checkBulkCount("multi_c", bson.M{}, (maxObjSize + 1), t)
 }
 
 func TestBulkSize(t *testing.T) {
-	b := &Bulk{
-		bulkMap: make(map[string]*bulkOperation),
-		RWMutex: &sync.RWMutex{},
-	}
-
 	c, _ := NewClient(WithURI(fmt.Sprintf("mongodb://127.0.0.1:27017/%s", bulkTestData.DB)))
 	s, err := c.Connect()
 	if err != nil {
 		t.Fatalf("unable to initialize connection to mongodb, %s", err)
 	}
 	defer s.(*Session).Close()
+	b := newBulkBatch(s.(*Session).mgoSession)
 
 	var bsonSize int
 	for i := 0; i < (maxObjSize - 1); i++ {
@@ -228,7 +211,9 @@ func TestBulkSize(t *testing.T) {
 		}
 		bsonSize += (len(bs) + 4)
 
-		b.Write(message.From(ops.Insert, "size", doc))(s)
+		if err := b.add(message.From(ops.Insert, "size", doc)); err != nil {
+			t.Fatalf("unexpected add error, %s", err)
+		}
 	}
 	bOp := b.bulkMap["size"]
 	if int(bOp.bsonOpSize) != bsonSize {
@@ -1,6 +1,7 @@
 package mongodb
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"sync"
@@ -27,7 +28,8 @@ const (
 )
 
 var (
-	_ adaptor.Adaptor = &mongoDB{}
+	_ adaptor.Adaptor        = &mongoDB{}
+	_ adaptor.ContextAdaptor = &mongoDB{}
 
 	// ErrCollectionFilter is returned when an error occurs attempting to Unmarshal the string.
 	ErrCollectionFilter = errors.New("malformed collection_filters")
@@ -78,10 +80,15 @@ func (m *mongoDB) Reader() (client.Reader, error) {
 }
 
 func (m *mongoDB) Writer(done chan struct{}, wg *sync.WaitGroup) (client.Writer, error) {
+	return m.WriterContext(context.Background())
+}
+
+// WriterContext returns a writer whose writes fail once ctx is done.
+func (m *mongoDB) WriterContext(ctx context.Context) (client.Writer, error) {
 	if m.Bulk {
-		return newBulker(done, wg), nil
+		return newBulker(ctx), nil
 	}
-	return newWriter(), nil
+	return newWriter(ctx), nil
 }
 
 func (m *mongoDB) Description() string {
@@ -1,6 +1,7 @@
 package mongodb
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -18,7 +19,8 @@ import (
 )
 
 var (
-	_ client.Reader = &Reader{}
+	_ client.Reader        = &Reader{}
+	_ client.ContextReader = &Reader{}
 
 	// DefaultCollectionFilter is an empty map of empty maps
 	DefaultCollectionFilter = map[string]CollectionFilter{}
@@ -50,57 +52,69 @@ type iterationComplete struct {
 
 func (r *Reader) Read(resumeMap map[string]client.MessageSet, filterFn client.NsFilterFunc) client.MessageChanFunc {
 	return func(s client.Session, done chan struct{}) (chan client.MessageSet, error) {
-		out := make(chan client.MessageSet)
-		session := s.(*Session).mgoSession.Copy()
-		go func() {
-			defer func() {
-				session.Close()
-				close(out)
-			}()
-			log.With("db", session.DB("").Name).Infoln("starting Read func")
-			collections, err := r.listCollections(session.Copy(), filterFn)
-			if err != nil {
-				log.With("db", session.DB("").Name).Errorf("unable to list collections, %s", err)
-				return
+		return r.read(resumeMap, filterFn, s, done)
+	}
+}
+
+// ReadContext reads like Read until ctx is done, the copy and tailing of every collection stop
+// along with it.
+func (r *Reader) ReadContext(resumeMap map[string]client.MessageSet, filterFn client.NsFilterFunc) func(context.Context, client.Session) (chan client.MessageSet, error) {
+	return func(ctx context.Context, s client.Session) (chan client.MessageSet, error) {
+		return r.read(resumeMap, filterFn, s, ctx.Done())
+	}
+}
+
+func (r *Reader) read(resumeMap map[string]client.MessageSet, filterFn client.NsFilterFunc, s client.Session, done <-chan struct{}) (chan client.MessageSet, error) {
+	out := make(chan client.MessageSet)
+	session := s.(*Session).mgoSession.Copy()
+	go func() {
+		defer func() {
+			session.Close()
+			close(out)
+		}()
+		log.With("db", session.DB("").Name).Infoln("starting Read func")
+		collections, err := r.listCollections(session.Copy(), filterFn)
+		if err != nil {
+			log.With("db", session.DB("").Name).Errorf("unable to list collections, %s", err)
+			return
+		}
+		var wg sync.WaitGroup
+		for _, c := range collections {
+			var lastID interface{}
+			oplogTime := timeAsMongoTimestamp(time.Now())
+			var mode commitlog.Mode // default to Copy
+			if m, ok := resumeMap[c]; ok {
+				lastID = m.Msg.Data().Get("_id")
+				mode = m.Mode
+				oplogTime = timeAsMongoTimestamp(time.Unix(m.Timestamp, 0))
 			}
-			var wg sync.WaitGroup
-			for _, c := range collections {
-				var lastID interface{}
-				oplogTime := timeAsMongoTimestamp(time.Now())
-				var mode commitlog.Mode // default to Copy
-				if m, ok := resumeMap[c]; ok {
-					lastID = m.Msg.Data().Get("_id")
-					mode = m.Mode
-					oplogTime = timeAsMongoTimestamp(time.Unix(m.Timestamp, 0))
+			if mode == commitlog.Copy {
+				if err := r.iterateCollection(r.iterate(lastID, session.Copy(), c, done), out, done, int64(oplogTime)>>32); err != nil {
+					log.With("db", session.DB("").Name).Errorln(err)
+					return
 				}
-				if mode == commitlog.Copy {
-					if err := r.iterateCollection(r.iterate(lastID, session.Copy(), c), out, done, int64(oplogTime)>>32); err != nil {
-						log.With("db", session.DB("").Name).Errorln(err)
+				log.With("db", session.DB("").Name).With("collection", c).Infoln("iterating complete")
+			}
+			if r.tail {
+				wg.Add(1)
+				log.With("collection", c).Infof("oplog start timestamp: %d", oplogTime)
+				go func(wg *sync.WaitGroup, c string, o bson.MongoTimestamp) {
+					defer wg.Done()
+					errc := r.tailCollection(c, session.Copy(), o, out, done)
+					for err := range errc {
+						log.With("db", session.DB("").Name).With("collection", c).Errorln(err)
 						return
 					}
-					log.With("db", session.DB("").Name).With("collection", c).Infoln("iterating complete")
-				}
-				if r.tail {
-					wg.Add(1)
-					log.With("collection", c).Infof("oplog start timestamp: %d", oplogTime)
-					go func(wg *sync.WaitGroup, c string, o bson.MongoTimestamp) {
-						defer wg.Done()
-						errc := r.tailCollection(c, session.Copy(), o, out, done)
-						for err := range errc {
-							log.With("db", session.DB("").Name).With("collection", c).Errorln(err)
-							return
-						}
-					}(&wg, c, oplogTime)
-				}
+				}(&wg, c, oplogTime)
 			}
-			log.With("db", session.DB("").Name).Infoln("Read completed")
-			// this will block if we're tailing
-			wg.Wait()
-			return
-		}()
+		}
+		log.With("db", session.DB("").Name).Infoln("Read completed")
+		// this will block if we're tailing
+		wg.Wait()
+		return
+	}()
 
-		return out, nil
-	}
+	return out, nil
 }
 
 func (r *Reader) listCollections(mgoSession *mgo.Session, filterFn func(name string) bool) ([]string, error) {
@@ -124,16 +138,17 @@ func (r *Reader) listCollections(mgoSession *mgo.Session, filterFn func(name str
 	return colls, nil
 }
 
-func (r *Reader) iterateCollection(in <-chan message.Msg, out chan<- client.MessageSet, done chan struct{}, origOplogTime int64) error {
+func (r *Reader) iterateCollection(in <-chan message.Msg, out chan<- client.MessageSet, done <-chan struct{}, origOplogTime int64) error {
 	for {
 		select {
 		case msg, ok := <-in:
 			if !ok {
 				return nil
 			}
-			out <- client.MessageSet{
-				Msg:       msg,
-				Timestamp: origOplogTime,
+			select {
+			case out <- client.MessageSet{Msg: msg, Timestamp: origOplogTime}:
+			case <-done:
+				return errors.New("iteration cancelled")
 			}
 		case <-done:
 			return errors.New("iteration cancelled")
@@ -141,7 +156,7 @@ func (r *Reader) iterateCollection(in <-chan message.Msg, out chan<- client.Mess
 	}
 }
 
-func (r *Reader) iterate(lastID interface{}, s *mgo.Session, c string) <-chan message.Msg {
+func (r *Reader) iterate(lastID interface{}, s *mgo.Session, c string, done <-chan struct{}) <-chan message.Msg {
 	msgChan := make(chan message.Msg)
 	go func() {
 		defer func() {
@@ -159,7 +174,13 @@ func (r *Reader) iterate(lastID interface{}, s *mgo.Session, c string) <-chan me
 				if id, ok := result["_id"]; ok {
 					lastID = id
 				}
-				msgChan <- message.From(ops.Insert, c, data.Data(result))
+				select {
+				case msgChan <- message.From(ops.Insert, c, data.Data(result)):
+				case <-done:
+					iter.Close()
+					session.Close()
+					return
+				}
 				result = bson.M{}
 			}
 			if err := iter.Err(); err != nil {
@@ -224,7 +245,7 @@ func sortable(id interface{}) bool {
 	return false
 }
 
-func (r *Reader) tailCollection(c string, mgoSession *mgo.Session, oplogTime bson.MongoTimestamp, out chan<- client.MessageSet, done chan struct{}) chan error {
+func (r *Reader) tailCollection(c string, mgoSession *mgo.Session, oplogTime bson.MongoTimestamp, out chan<- client.MessageSet, done <-chan struct{}) chan error {
 	errc := make(chan error)
 	go func() {
 		defer func() {
@@ -276,10 +297,11 @@ func (r *Reader) tailCollection(c string, mgoSession *mgo.Session, oplogTime bso
 						msg := message.From(op, c, data.Data(doc)).(*message.Base)
 						msg.TS = int64(result.Ts) >> 32
 
-						out <- client.MessageSet{
-							Msg:       msg,
-							Timestamp: msg.TS,
-							Mode:      commitlog.Sync,
+						select {
+						case out <- client.MessageSet{Msg: msg, Timestamp: msg.TS, Mode: commitlog.Sync}:
+						case <-done:
+							log.With("db", db).Infoln("tailing stopping...")
+							return
 						}
 						oplogTime = result.Ts
 					}
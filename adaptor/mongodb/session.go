@@ -1,6 +1,9 @@
 package mongodb
 
 import (
+	"context"
+	"time"
+
 	"github.com/compose/transporter/client"
 	mgo "gopkg.in/mgo.v2"
 )
@@ -16,3 +19,22 @@ var _ client.Session = &Session{}
 func (s *Session) Close() {
 	s.mgoSession.Close()
 }
+
+// contextSession returns a copy of the mgo.Session whose operations fail once the deadline of ctx
+// has passed, mgo has no way to cancel an operation before then. The copy must be closed.
+func contextSession(ctx context.Context, s *mgo.Session) (*mgo.Session, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c := s.Copy()
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			c.Close()
+			return nil, context.DeadlineExceeded
+		}
+		c.SetSyncTimeout(timeout)
+		c.SetSocketTimeout(timeout)
+	}
+	return c, nil
+}
@@ -1,6 +1,8 @@
 package mongodb
 
 import (
+	"context"
+
 	"github.com/compose/transporter/client"
 	"github.com/compose/transporter/log"
 	"github.com/compose/transporter/message"
@@ -9,15 +11,21 @@ import (
 	"gopkg.in/mgo.v2/bson"
 )
 
-var _ client.Writer = &Writer{}
+var (
+	_ client.Writer         = &Writer{}
+	_ client.ContextWriter  = &Writer{}
+	_ client.ParallelWriter = &Writer{}
+)
 
 // Writer implements client.Writer for use with MongoDB
 type Writer struct {
+	// ctx is the context of writes made with Write
+	ctx      context.Context
 	writeMap map[ops.Op]func(message.Msg, *mgo.Collection) error
 }
 
-func newWriter() *Writer {
-	w := &Writer{}
+func newWriter(ctx context.Context) *Writer {
+	w := &Writer{ctx: ctx}
 	w.writeMap = map[ops.Op]func(message.Msg, *mgo.Collection) error{
 		ops.Insert: insertMsg,
 		ops.Update: updateMsg,
@@ -27,7 +35,15 @@ func newWriter() *Writer {
 }
 
 func (w *Writer) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	write := w.WriteContext(msg)
 	return func(s client.Session) (message.Msg, error) {
+		return write(w.ctx, s)
+	}
+}
+
+// WriteContext writes msg, failing once the deadline of ctx has passed.
+func (w *Writer) WriteContext(msg message.Msg) func(context.Context, client.Session) (message.Msg, error) {
+	return func(ctx context.Context, s client.Session) (message.Msg, error) {
 		writeFunc, ok := w.writeMap[msg.OP()]
 		if !ok {
 			log.Infof("no function registered for operation, %s\n", msg.OP())
@@ -36,7 +52,12 @@ func (w *Writer) Write(msg message.Msg) func(client.Session) (message.Msg, error
 			}
 			return msg, nil
 		}
-		if err := writeFunc(msg, msgCollection(msg, s)); err != nil {
+		session, err := contextSession(ctx, s.(*Session).mgoSession)
+		if err != nil {
+			return nil, err
+		}
+		defer session.Close()
+		if err := writeFunc(msg, session.DB("").C(msg.Namespace())); err != nil {
 			return nil, err
 		}
 		if msg.Confirms() != nil {
@@ -46,8 +67,10 @@ func (w *Writer) Write(msg message.Msg) func(client.Session) (message.Msg, error
 	}
 }
 
-func msgCollection(msg message.Msg, s client.Session) *mgo.Collection {
-	return s.(*Session).mgoSession.DB("").C(msg.Namespace())
+// WritesInParallel implements client.ParallelWriter, each message is written and confirmed on its
+// own so several can be written at once.
+func (w *Writer) WritesInParallel() bool {
+	return true
 }
 
 func insertMsg(msg message.Msg, c *mgo.Collection) error {
@@ -1,6 +1,7 @@
 package mongodb
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"testing"
@@ -28,7 +29,7 @@ var optests = []struct {
 }
 
 func TestOpFunc(t *testing.T) {
-	w := newWriter()
+	w := newWriter(context.Background())
 	for _, ot := range optests {
 		if _, ok := w.writeMap[ot.op]; ok != ot.registered {
 			t.Errorf("op (%s) registration incorrect, expected %+v, got %+v\n", ot.op.String(), ot.registered, ok)
@@ -90,7 +91,7 @@ func TestInsert(t *testing.T) {
 		t.Fatalf("unable to initialize connection to mongodb, %s", err)
 	}
 	defer s.(*Session).Close()
-	w := newWriter()
+	w := newWriter(context.Background())
 	for _, it := range inserttests {
 		for _, data := range it.data {
 			msg := message.WithConfirms(confirms, message.From(ops.Insert, it.collection, data))
@@ -149,7 +150,7 @@ func TestUpdate(t *testing.T) {
 		t.Fatalf("unable to initialize connection to mongodb, %s", err)
 	}
 	defer s.(*Session).Close()
-	w := newWriter()
+	w := newWriter(context.Background())
 	for _, ut := range updatetests {
 		// Insert data
 		ut.originalDoc.Set("_id", ut.id)
@@ -200,7 +201,7 @@ func TestDelete(t *testing.T) {
 		t.Fatalf("unable to initialize connection to mongodb, %s", err)
 	}
 	defer s.(*Session).Close()
-	w := newWriter()
+	w := newWriter(context.Background())
 	for _, dt := range deletetests {
 		// Insert data
 		dt.originalDoc.Set("_id", dt.id)
@@ -226,6 +227,33 @@ var (
 	restartCount = 100
 )
 
+func TestWriteContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	msg := message.From(ops.Insert, "done", map[string]interface{}{"_id": 0})
+	if _, err := newWriter(context.Background()).WriteContext(msg)(ctx, defaultSession); err != context.Canceled {
+		t.Errorf("wrong WriteContext error, expected %s, got %v", context.Canceled, err)
+	}
+	if err := newBulker(context.Background()).WriteBatchContext([]message.Msg{msg})(ctx, defaultSession); err != context.Canceled {
+		t.Errorf("wrong WriteBatchContext error, expected %s, got %v", context.Canceled, err)
+	}
+}
+
+func TestWriterContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	msg := message.From(ops.Insert, "done", map[string]interface{}{"_id": 0})
+	for _, bulk := range []bool{false, true} {
+		w, err := (&mongoDB{Bulk: bulk}).WriterContext(ctx)
+		if err != nil {
+			t.Fatalf("unexpected WriterContext() error, %s", err)
+		}
+		if _, err := w.Write(msg)(defaultSession); err != context.Canceled {
+			t.Errorf("[bulk %t] wrong Write error, expected %s, got %v", bulk, context.Canceled, err)
+		}
+	}
+}
+
 func TestRestartWrites(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping RestartWrites in short mode")
@@ -247,7 +275,7 @@ func TestRestartWrites(t *testing.T) {
 		log.Errorf("failed to drop database (%s), may affect tests!, %s", writerTestData.DB, dropErr)
 	}
 
-	w := newWriter()
+	w := newWriter(context.Background())
 	done := make(chan struct{})
 	go func() {
 		for {
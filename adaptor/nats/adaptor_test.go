@@ -0,0 +1,105 @@
+package nats
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/compose/transporter/log"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	testOpts          = test.DefaultTestOptions
+	testNATS          *server.Server
+	defaultTestClient *Client
+	defaultSession    *Session
+
+	streamsToTest = []TestData{readerStreamTestData, writerStreamTestData}
+)
+
+// TestData describes a JetStream stream created for the tests, the stream stores every subject
+// starting with Namespace.
+type TestData struct {
+	Stream      string
+	Namespace   string
+	InsertCount int
+}
+
+func setup() {
+	log.Infoln("setting up tests")
+
+	storeDir, err := ioutil.TempDir("", "nats_test")
+	if err != nil {
+		log.Errorf("unable to create store directory, %s", err)
+		os.Exit(1)
+	}
+	testOpts.Port = -1
+	testOpts.JetStream = true
+	testOpts.StoreDir = storeDir
+	testNATS = test.RunServer(&testOpts)
+	// restarts listen on the same port so clients can reconnect
+	testOpts.Port = testNATS.Addr().(*net.TCPAddr).Port
+
+	defaultTestClient, _ = NewClient(WithURI(testNATS.ClientURL()))
+	defaultTestClient.reconnectWait = 10 * time.Millisecond
+	s, err := defaultTestClient.Connect()
+	if err != nil {
+		log.Errorf("unable to initialize connection to nats, %s", err)
+		os.Exit(1)
+	}
+	defaultSession = s.(*Session)
+	for _, testData := range streamsToTest {
+		setupData(testData)
+	}
+}
+
+func setupData(data TestData) {
+	js, err := defaultSession.jetStream()
+	if err != nil {
+		log.Errorf("failed to create stream (%s), may affect tests!, %s", data.Stream, err)
+		return
+	}
+	js.DeleteStream(data.Stream)
+	// streams are stored in files so they're kept when the server restarts
+	if _, err := js.AddStream(&nats.StreamConfig{
+		Name:     data.Stream,
+		Subjects: []string{data.Namespace + ".>"},
+		Storage:  nats.FileStorage,
+	}); err != nil {
+		log.Errorf("failed to create stream (%s), may affect tests!, %s", data.Stream, err)
+		return
+	}
+	for i := 0; i < data.InsertCount; i++ {
+		if _, err := js.Publish(data.Namespace+".insert", []byte(fmt.Sprintf(`{"id": %d, "message": "hello"}`, i))); err != nil {
+			log.Errorf("failed to publish to stream (%s), may affect tests!, %s", data.Stream, err)
+		}
+	}
+}
+
+// restartServer shuts the server down and starts it again on the same port.
+func restartServer() {
+	testNATS.Shutdown()
+	testNATS.WaitForShutdown()
+	testNATS = test.RunServer(&testOpts)
+}
+
+func TestMain(m *testing.M) {
+	setup()
+	code := m.Run()
+	shutdown()
+	os.Exit(code)
+}
+
+func shutdown() {
+	log.Infoln("shutting down tests")
+	defaultTestClient.Close()
+	testNATS.Shutdown()
+	os.RemoveAll(testOpts.StoreDir)
+	log.Infoln("tests shutdown complete")
+}
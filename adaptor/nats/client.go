@@ -0,0 +1,190 @@
+package nats
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/log"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// DefaultURI is the default endpoint of NATS on the local machine.
+	// Primarily used when initializing a new Client without a specific URI.
+	DefaultURI = "nats://127.0.0.1:4222"
+
+	// DefaultTimeout is the default time.Duration used when connecting and waiting for responses
+	// from the server.
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultReconnectWait is the time waited between attempts to reconnect to the server.
+	DefaultReconnectWait = 2 * time.Second
+)
+
+var (
+	_ client.Client = &Client{}
+	_ client.Closer = &Client{}
+)
+
+// ClientOptionFunc is a function that configures a Client.
+// It is used in NewClient.
+type ClientOptionFunc func(*Client) error
+
+// Client wraps the underlying connection to a NATS server. The connection is re-established
+// whenever it's lost, and every subscription is made again, until the Client is closed.
+type Client struct {
+	uri           *url.URL
+	timeout       time.Duration
+	reconnectWait time.Duration
+	tlsConfig     *tls.Config
+	conn          *nats.Conn
+}
+
+// NewClient creates a new client to work with NATS.
+//
+// The caller can configure the new client by passing configuration options
+// to the func.
+//
+// Example:
+//
+//   client, err := NewClient(
+//     WithURI("nats://localhost:4222"),
+//     WithTimeout("30s"))
+//
+// If no URI is configured, it uses DefaultURI.
+//
+// An error is also returned when a configuration option is invalid
+func NewClient(options ...ClientOptionFunc) (*Client, error) {
+	u, _ := url.Parse(DefaultURI)
+	c := &Client{
+		uri:           u,
+		timeout:       DefaultTimeout,
+		reconnectWait: DefaultReconnectWait,
+	}
+	for _, option := range options {
+		if err := option(c); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// WithURI defines the full connection string for the NATS connection, credentials in the URI
+// are used as the user and password, or a token when there's no password.
+func WithURI(uri string) ClientOptionFunc {
+	return func(c *Client) error {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return client.InvalidURIError{URI: uri, Err: err.Error()}
+		}
+		switch u.Scheme {
+		case "nats", "tls":
+		default:
+			return client.InvalidURIError{URI: uri, Err: "scheme must be nats or tls"}
+		}
+		c.uri = u
+		return nil
+	}
+}
+
+// WithTimeout overrides the DefaultTimeout and should be parseable by time.ParseDuration
+func WithTimeout(timeout string) ClientOptionFunc {
+	return func(c *Client) error {
+		if timeout == "" {
+			c.timeout = DefaultTimeout
+			return nil
+		}
+		t, err := time.ParseDuration(timeout)
+		if err != nil {
+			return client.InvalidTimeoutError{Timeout: timeout}
+		}
+		c.timeout = t
+		return nil
+	}
+}
+
+// WithSSL configures the connection to use TLS.
+func WithSSL(ssl bool) ClientOptionFunc {
+	return func(c *Client) error {
+		if ssl {
+			tlsConfig := &tls.Config{InsecureSkipVerify: true}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			c.tlsConfig = tlsConfig
+		}
+		return nil
+	}
+}
+
+// WithCACerts configures the RootCAs for the underlying TLS connection
+func WithCACerts(certs []string) ClientOptionFunc {
+	return func(c *Client) error {
+		if len(certs) > 0 {
+			roots := x509.NewCertPool()
+			for _, cert := range certs {
+				if _, err := os.Stat(cert); err == nil {
+					c, err := ioutil.ReadFile(cert)
+					if err != nil {
+						return err
+					}
+					cert = string(c)
+				}
+				if ok := roots.AppendCertsFromPEM([]byte(cert)); !ok {
+					return client.ErrInvalidCert
+				}
+			}
+			if c.tlsConfig != nil {
+				c.tlsConfig.RootCAs = roots
+			} else {
+				c.tlsConfig = &tls.Config{RootCAs: roots}
+			}
+			c.tlsConfig.InsecureSkipVerify = false
+		}
+		return nil
+	}
+}
+
+// Connect satisfies the client.Client interface.
+func (c *Client) Connect() (client.Session, error) {
+	if c.conn == nil {
+		opts := []nats.Option{
+			nats.Timeout(c.timeout),
+			nats.MaxReconnects(-1),
+			nats.ReconnectWait(c.reconnectWait),
+			nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+				if err != nil {
+					log.With("uri", c.uri.Host).Errorf("disconnected, %s", err)
+				}
+			}),
+			nats.ReconnectHandler(func(nc *nats.Conn) {
+				log.With("uri", nc.ConnectedUrl()).Infoln("reconnected")
+			}),
+		}
+		if c.tlsConfig != nil {
+			opts = append(opts, nats.Secure(c.tlsConfig))
+		}
+		conn, err := nats.Connect(c.uri.String(), opts...)
+		if err != nil {
+			return nil, client.ConnectError{Reason: err.Error()}
+		}
+		c.conn = conn
+	}
+	return &Session{c.conn, c.timeout}, nil
+}
+
+// Close implements necessary calls to cleanup the underlying connection.
+func (c *Client) Close() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
+// Session wraps the connection for use by a Reader/Writer.
+type Session struct {
+	conn    *nats.Conn
+	timeout time.Duration
+}
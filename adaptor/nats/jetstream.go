@@ -0,0 +1,85 @@
+package nats
+
+import (
+	"context"
+	"time"
+
+	"github.com/compose/transporter/log"
+	"github.com/nats-io/nats.go"
+)
+
+// jetStream returns the context used to publish to and consume from streams, its API requests
+// wait for the session's timeout.
+func (s *Session) jetStream() (nats.JetStreamContext, error) {
+	return s.conn.JetStream(nats.MaxWait(s.timeout))
+}
+
+// pull binds to the durable pull consumer of the stream, which is created unless it exists, and
+// fetches batches of messages from it until done.
+func (r *Reader) pull(s *Session, done chan struct{}) (<-chan *nats.Msg, error) {
+	js, err := s.jetStream()
+	if err != nil {
+		return nil, err
+	}
+	// without a subject the consumer isn't filtered
+	var subject string
+	if len(r.subjects) == 1 && r.subjects[0] != DefaultReadSubject {
+		subject = r.subjects[0]
+	}
+	opts := []nats.SubOpt{nats.BindStream(r.stream), nats.AckExplicit(), nats.DeliverAll()}
+	if r.maxPending > 0 {
+		opts = append(opts, nats.MaxAckPending(r.maxPending))
+	}
+	sub, err := js.PullSubscribe(subject, r.durable, opts...)
+	if err != nil {
+		return nil, err
+	}
+	log.With("stream", r.stream).With("durable", r.durable).Infoln("consuming")
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-done
+		cancel()
+	}()
+	msgs := make(chan *nats.Msg)
+	go func() {
+		defer close(msgs)
+		// unsubscribing would delete the durable consumer, draining leaves it in place
+		defer sub.Drain()
+		for {
+			// a request made while disconnected is lost, so the fetch is made again once it expires
+			fetchCtx, fetchCancel := context.WithTimeout(ctx, pullExpires)
+			batch, err := sub.Fetch(r.batchSize, nats.Context(fetchCtx))
+			fetchCancel()
+			switch {
+			case ctx.Err() != nil, err == nats.ErrConnectionClosed:
+				return
+			case err != nil && err != context.DeadlineExceeded:
+				log.With("stream", r.stream).Errorf("unable to fetch messages, %s", err)
+				select {
+				case <-time.After(time.Second):
+				case <-done:
+					return
+				}
+			}
+			for _, m := range batch {
+				select {
+				case msgs <- m:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+	return msgs, nil
+}
+
+// acknowledge replies to a JetStream message with ack, e.g. (*nats.Msg).Ack, core NATS messages
+// aren't acknowledged.
+func (r *Reader) acknowledge(m *nats.Msg, ack func(*nats.Msg, ...nats.AckOpt) error) {
+	if r.stream == "" {
+		return
+	}
+	if err := ack(m); err != nil {
+		log.With("subject", m.Subject).Errorf("unable to acknowledge message, %s", err)
+	}
+}
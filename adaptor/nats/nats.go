@@ -0,0 +1,124 @@
+package nats
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/compose/transporter/adaptor"
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/message/codec"
+)
+
+const (
+	// DefaultCodec is used to encode and decode message bodies when no codec is configured.
+	DefaultCodec = "json"
+
+	sampleConfig = `{
+  "uri": "${NATS_URI}"
+  // "timeout": "10s",
+  // "subject": "{{.Namespace}}.{{.Op}}", // template for the subject each message is published to
+  // "read_subjects": [">"], // subjects consumed when used as a source, may contain wildcards
+  // "queue_group": "", // share messages between every reader in the group
+  // "jetstream": false, // wait for streams to store published messages and consume from stream
+  // "stream": "", // stream consumed when jetstream is true
+  // "durable": "transporter", // name of the durable consumer created on stream
+  // "batch_size": 100, // messages requested from the consumer at a time
+  // "max_ack_pending": 0, // unacknowledged messages per consumer, 0 uses the server default
+  // "codec": "json", // json, bson, msgpack, raw or protobuf
+  // "detect_content_type": false, // decode messages with the codec for their Content-Type header
+  // "ssl": false,
+  // "cacerts": ["/path/to/cert.pem"]
+}`
+
+	description = "an adaptor that publishes to and subscribes to NATS subjects, including JetStream streams"
+)
+
+var (
+	_ adaptor.Adaptor = &NATS{}
+
+	// ErrMissingStream is returned when reading with jetstream enabled but no stream configured.
+	ErrMissingStream = errors.New("stream is required to read with jetstream")
+)
+
+// NATS defines all configurable elements for connecting to and publishing/subscribing to NATS.
+type NATS struct {
+	adaptor.BaseConfig
+	codec.Options
+	Codec             string   `json:"codec"`
+	DetectContentType bool     `json:"detect_content_type"`
+	Subject           string   `json:"subject"`
+	ReadSubjects      []string `json:"read_subjects"`
+	QueueGroup        string   `json:"queue_group"`
+	JetStream         bool     `json:"jetstream"`
+	Stream            string   `json:"stream"`
+	Durable           string   `json:"durable"`
+	BatchSize         int      `json:"batch_size"`
+	MaxAckPending     int      `json:"max_ack_pending"`
+	SSL               bool     `json:"ssl"`
+	CACerts           []string `json:"cacerts"`
+}
+
+func init() {
+	adaptor.Add(
+		"nats",
+		func() adaptor.Adaptor {
+			return &NATS{
+				BaseConfig: adaptor.BaseConfig{URI: DefaultURI},
+				Codec:      DefaultCodec,
+				Subject:    DefaultSubject,
+				Durable:    DefaultDurable,
+				BatchSize:  DefaultBatchSize,
+			}
+		},
+	)
+}
+
+func (n *NATS) Client() (client.Client, error) {
+	return NewClient(WithURI(n.URI),
+		WithTimeout(n.Timeout),
+		WithSSL(n.SSL),
+		WithCACerts(n.CACerts))
+}
+
+func (n *NATS) Reader() (client.Reader, error) {
+	reader := newReader(n.ReadSubjects)
+	if n.JetStream {
+		if n.Stream == "" {
+			return nil, ErrMissingStream
+		}
+		reader.stream = n.Stream
+		reader.durable = n.Durable
+		reader.maxPending = n.MaxAckPending
+	}
+	reader.queueGroup = n.QueueGroup
+	if n.BatchSize > 0 {
+		reader.batchSize = n.BatchSize
+	}
+	if err := reader.setCodec(n.Codec, n.Options, n.DetectContentType); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (n *NATS) Writer(done chan struct{}, wg *sync.WaitGroup) (client.Writer, error) {
+	c, err := codec.GetCodec(n.Codec, n.Options)
+	if err != nil {
+		return nil, err
+	}
+	w, err := newWriter(n.Subject, n.JetStream)
+	if err != nil {
+		return nil, err
+	}
+	w.Codec = c
+	return w, nil
+}
+
+// Description for nats adaptor
+func (n *NATS) Description() string {
+	return description
+}
+
+// SampleConfig for nats adaptor
+func (n *NATS) SampleConfig() string {
+	return sampleConfig
+}
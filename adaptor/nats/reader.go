@@ -0,0 +1,216 @@
+package nats
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/commitlog"
+	"github.com/compose/transporter/log"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/codec"
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// DefaultReadSubject subscribes to every subject.
+	DefaultReadSubject = ">"
+
+	// DefaultDurable is the name of the JetStream consumer created by the Reader.
+	DefaultDurable = "transporter"
+
+	// DefaultBatchSize is the number of messages requested from the JetStream consumer at a time.
+	DefaultBatchSize = 100
+
+	pullExpires = 5 * time.Second
+)
+
+var (
+	_ client.Reader = &Reader{}
+	_ client.Acker  = &Reader{}
+)
+
+// Reader implements client.Reader by subscribing to subjects, the namespace of each message is its
+// subject without the trailing op token added by the Writer. When reading from a JetStream stream,
+// messages are pulled from a durable consumer and only acknowledged once every sink has committed
+// them, so anything which hasn't been written when transporter stops is redelivered.
+type Reader struct {
+	subjects   []string
+	queueGroup string
+	stream     string
+	durable    string
+	batchSize  int
+	maxPending int
+
+	codec             codec.Codec
+	detectContentType bool
+	codecs            map[string]codec.Codec
+
+	sync.Mutex
+	pending map[message.Msg]*nats.Msg
+}
+
+func newReader(subjects []string) *Reader {
+	if len(subjects) == 0 {
+		subjects = []string{DefaultReadSubject}
+	}
+	return &Reader{
+		subjects:  subjects,
+		durable:   DefaultDurable,
+		batchSize: DefaultBatchSize,
+		codec:     codec.JSON{},
+		pending:   make(map[message.Msg]*nats.Msg),
+	}
+}
+
+// setCodec sets the codec used to decode messages, when detectContentType is set the Content-Type
+// header selects between every registered codec which can be created with opts.
+func (r *Reader) setCodec(name string, opts codec.Options, detectContentType bool) error {
+	c, err := codec.GetCodec(name, opts)
+	if err != nil {
+		return err
+	}
+	r.codec = c
+	r.detectContentType = detectContentType
+	if detectContentType {
+		r.codecs = make(map[string]codec.Codec)
+		for _, n := range codec.RegisteredCodecs() {
+			if c, err := codec.GetCodec(n, opts); err == nil {
+				r.codecs[n] = c
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Reader) decode(m *nats.Msg) (data.Data, error) {
+	c := r.codec
+	if r.detectContentType {
+		if name, ok := codec.ForContentType(m.Header.Get("Content-Type")); ok {
+			if detected, ok := r.codecs[name]; ok {
+				c = detected
+			}
+		}
+	}
+	return c.Decode(m.Data)
+}
+
+func (r *Reader) Read(_ map[string]client.MessageSet, filterFn client.NsFilterFunc) client.MessageChanFunc {
+	return func(s client.Session, done chan struct{}) (chan client.MessageSet, error) {
+		session := s.(*Session)
+		var (
+			msgs <-chan *nats.Msg
+			err  error
+		)
+		if r.stream != "" {
+			msgs, err = r.pull(session, done)
+		} else {
+			msgs, err = r.subscribe(session, done)
+		}
+		if err != nil {
+			return nil, err
+		}
+		out := make(chan client.MessageSet)
+		go func() {
+			defer close(out)
+			for {
+				select {
+				case <-done:
+					return
+				case m, ok := <-msgs:
+					if !ok {
+						log.Infoln("Read completed")
+						return
+					}
+					ns, op := namespace(m.Subject)
+					if !filterFn(ns) {
+						r.acknowledge(m, (*nats.Msg).Ack)
+						continue
+					}
+					d, err := r.decode(m)
+					if err != nil {
+						log.With("subject", m.Subject).Errorf("unable to decode message, %s", err)
+						r.acknowledge(m, (*nats.Msg).Term)
+						continue
+					}
+					msg := message.From(op, ns, d.AsMap())
+					if r.stream != "" {
+						r.Lock()
+						r.pending[msg] = m
+						r.Unlock()
+					}
+					select {
+					case out <- client.MessageSet{Msg: msg, Mode: commitlog.Sync}:
+					case <-done:
+						return
+					}
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+// subscribe delivers the messages of every subject to a single channel, the subscriptions are
+// made again by the connection whenever it reconnects.
+func (r *Reader) subscribe(s *Session, done chan struct{}) (<-chan *nats.Msg, error) {
+	msgs := make(chan *nats.Msg, r.batchSize)
+	subs := make([]*nats.Subscription, 0, len(r.subjects))
+	for _, subject := range r.subjects {
+		sub, err := s.conn.ChanQueueSubscribe(subject, r.queueGroup, msgs)
+		if err != nil {
+			unsubscribe(subs)
+			return nil, err
+		}
+		log.With("subject", subject).Infoln("subscribed")
+		subs = append(subs, sub)
+	}
+	go func() {
+		<-done
+		unsubscribe(subs)
+	}()
+	return msgs, nil
+}
+
+func unsubscribe(subs []*nats.Subscription) {
+	for _, sub := range subs {
+		if err := sub.Unsubscribe(); err != nil && err != nats.ErrConnectionClosed {
+			log.With("subject", sub.Subject).Errorf("unable to unsubscribe, %s", err)
+		}
+	}
+}
+
+// namespace splits the op written by the Writer's default subject from the end of the subject,
+// any other subject is read as an insert into the namespace of the whole subject.
+func namespace(subject string) (string, ops.Op) {
+	i := strings.LastIndex(subject, ".")
+	if i <= 0 {
+		return subject, ops.Insert
+	}
+	switch op := subject[i+1:]; op {
+	case "insert", "update", "delete":
+		return subject[:i], ops.OpTypeFromString(op)
+	}
+	return subject, ops.Insert
+}
+
+// Ack implements client.Acker, acknowledging JetStream messages once they've been committed.
+func (r *Reader) Ack(msgs []client.MessageSet) error {
+	var err error
+	for _, m := range msgs {
+		r.Lock()
+		jm, ok := r.pending[m.Msg]
+		delete(r.pending, m.Msg)
+		r.Unlock()
+		if !ok {
+			continue
+		}
+		if aerr := jm.Ack(); aerr != nil && err == nil {
+			err = aerr
+		}
+	}
+	return err
+}
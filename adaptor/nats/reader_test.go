@@ -0,0 +1,191 @@
+package nats
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/message/ops"
+	"github.com/nats-io/nats.go"
+)
+
+var readerStreamTestData = TestData{"READER", "reader_stream", 10}
+
+var namespaceTests = []struct {
+	subject string
+	ns      string
+	op      ops.Op
+}{
+	{"db.orders.insert", "db.orders", ops.Insert},
+	{"db.orders.update", "db.orders", ops.Update},
+	{"orders.delete", "orders", ops.Delete},
+	{"orders.paid", "orders.paid", ops.Insert},
+	{"delete", "delete", ops.Insert},
+	{"orders", "orders", ops.Insert},
+}
+
+func TestNamespace(t *testing.T) {
+	for _, nt := range namespaceTests {
+		ns, op := namespace(nt.subject)
+		if ns != nt.ns || op != nt.op {
+			t.Errorf("[%s] wrong namespace, expected %s %s, got %s %s", nt.subject, nt.ns, nt.op, ns, op)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping Read in short mode")
+	}
+	reader := newReader([]string{"reader_test.>"})
+	done := make(chan struct{})
+	msgChan, err := reader.Read(map[string]client.MessageSet{},
+		func(ns string) bool { return ns != "reader_test.skip" })(defaultSession, done)
+	if err != nil {
+		t.Fatalf("unexpected Read error, %s\n", err)
+	}
+	if err := defaultSession.conn.FlushTimeout(time.Second); err != nil {
+		t.Fatalf("unexpected flush error, %s", err)
+	}
+	for _, subject := range []string{"reader_test.foo.insert", "reader_test.foo.delete", "reader_test.skip", "other.insert"} {
+		if err := defaultSession.conn.Publish(subject, []byte(`{"id": 1}`)); err != nil {
+			t.Fatalf("unexpected publish error, %s", err)
+		}
+	}
+	for _, op := range []ops.Op{ops.Insert, ops.Delete} {
+		select {
+		case m := <-msgChan:
+			if m.Msg.Namespace() != "reader_test.foo" || m.Msg.OP() != op {
+				t.Errorf("wrong message, expected %s reader_test.foo, got %s %s", op, m.Msg.OP(), m.Msg.Namespace())
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s", op)
+		}
+	}
+	checkCount("filtered", 0, msgChan, t)
+	close(done)
+}
+
+func TestReadJetStream(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping Read in short mode")
+	}
+	js, err := defaultSession.jetStream()
+	if err != nil {
+		t.Fatalf("unexpected JetStream error, %s", err)
+	}
+	// start from a new consumer so every message is delivered
+	js.DeleteConsumer(readerStreamTestData.Stream, "reader_test")
+	reader := newReader([]string{readerStreamTestData.Namespace + ".>"})
+	reader.stream = readerStreamTestData.Stream
+	reader.durable = "reader_test"
+	reader.batchSize = 3
+	done := make(chan struct{})
+	msgChan, err := reader.Read(map[string]client.MessageSet{}, func(string) bool { return true })(defaultSession, done)
+	if err != nil {
+		t.Fatalf("unexpected Read error, %s\n", err)
+	}
+	var msgs []client.MessageSet
+	for i := 0; i < readerStreamTestData.InsertCount; i++ {
+		select {
+		case m := <-msgChan:
+			msgs = append(msgs, m)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for message %d", i)
+		}
+	}
+	if len(reader.pending) != readerStreamTestData.InsertCount {
+		t.Errorf("wrong number of pending messages, expected %d, got %d", readerStreamTestData.InsertCount, len(reader.pending))
+	}
+	// only the first half are committed, the rest are redelivered to the durable consumer
+	if err := reader.Ack(msgs[:5]); err != nil {
+		t.Fatalf("unexpected Ack error, %s", err)
+	}
+	if err := defaultSession.conn.FlushTimeout(time.Second); err != nil {
+		t.Fatalf("unexpected flush error, %s", err)
+	}
+	close(done)
+	info, err := js.ConsumerInfo(readerStreamTestData.Stream, "reader_test")
+	if err != nil {
+		t.Fatalf("unexpected ConsumerInfo error, %s", err)
+	}
+	if info.AckFloor.Stream != 5 || info.NumAckPending != 5 {
+		t.Errorf("wrong messages acknowledged, expected 5 acknowledged and 5 pending, got %d and %d", info.AckFloor.Stream, info.NumAckPending)
+	}
+}
+
+func TestReadReconnect(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping Read in short mode")
+	}
+	c, err := NewClient(WithURI(testNATS.ClientURL()))
+	if err != nil {
+		t.Fatalf("unexpected NewClient error, %s", err)
+	}
+	c.reconnectWait = 10 * time.Millisecond
+	defer c.Close()
+	s, err := c.Connect()
+	if err != nil {
+		t.Fatalf("unexpected Connect error, %s", err)
+	}
+	reader := newReader([]string{"reconnect_test.>"})
+	done := make(chan struct{})
+	defer close(done)
+	msgChan, err := reader.Read(map[string]client.MessageSet{}, func(string) bool { return true })(s, done)
+	if err != nil {
+		t.Fatalf("unexpected Read error, %s\n", err)
+	}
+	conns := []*nats.Conn{s.(*Session).conn, defaultSession.conn}
+	reconnects := make([]uint64, len(conns))
+	for i, conn := range conns {
+		reconnects[i] = conn.Stats().Reconnects
+	}
+	restartServer()
+	// wait for both connections to reconnect and resubscribe before publishing
+	timeout := time.After(5 * time.Second)
+	for i, conn := range conns {
+		for conn.Stats().Reconnects == reconnects[i] {
+			select {
+			case <-timeout:
+				t.Fatalf("timed out waiting to reconnect")
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		if err := conn.FlushTimeout(time.Second); err != nil {
+			t.Fatalf("unexpected flush error, %s", err)
+		}
+	}
+	if err := defaultSession.conn.Publish("reconnect_test.insert", []byte(`{"id": 1}`)); err != nil {
+		t.Fatalf("unexpected publish error, %s", err)
+	}
+	select {
+	case m := <-msgChan:
+		if m.Msg.Namespace() != "reconnect_test" {
+			t.Errorf("wrong message, expected reconnect_test, got %s", m.Msg.Namespace())
+		}
+	case <-timeout:
+		t.Fatalf("timed out waiting for message after reconnecting")
+	}
+}
+
+func checkCount(desc string, expected int, msgChan <-chan client.MessageSet, t *testing.T) {
+	var numMsgs int
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func(wg *sync.WaitGroup) {
+		for {
+			select {
+			case <-msgChan:
+				numMsgs++
+			case <-time.After(1 * time.Second):
+				wg.Done()
+				return
+			}
+		}
+	}(&wg)
+	wg.Wait()
+	if numMsgs != expected {
+		t.Errorf("[%s] bad message count, expected %d, got %d\n", desc, expected, numMsgs)
+	}
+}
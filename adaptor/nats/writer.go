@@ -0,0 +1,127 @@
+package nats
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/codec"
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// DefaultSubject publishes each message to a subject made of its namespace and op, e.g.
+	// "inventory.items.update".
+	DefaultSubject = "{{.Namespace}}.{{.Op}}"
+)
+
+var (
+	_ client.Writer         = &Writer{}
+	_ client.ParallelWriter = &Writer{}
+)
+
+// InvalidSubjectError is returned when the subject rendered for a message can't be published to.
+type InvalidSubjectError struct {
+	Subject string
+}
+
+func (e InvalidSubjectError) Error() string {
+	return fmt.Sprintf("invalid subject, %q", e.Subject)
+}
+
+// Writer implements client.Writer by publishing messages to the subject rendered from its
+// template. When JetStream is set, each message is only confirmed once the stream has stored it.
+type Writer struct {
+	JetStream bool
+	Codec     codec.Codec
+
+	subject *template.Template
+}
+
+// subjectData is passed to the subject template.
+type subjectData struct {
+	Namespace string
+	Op        string
+	Data      data.Data
+}
+
+func newWriter(subject string, jetStream bool) (*Writer, error) {
+	t, err := template.New("subject").Option("missingkey=error").Parse(subject)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{JetStream: jetStream, Codec: codec.JSON{}, subject: t}, nil
+}
+
+func (w *Writer) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	return func(s client.Session) (message.Msg, error) {
+		switch msg.OP() {
+		case ops.Insert, ops.Update, ops.Delete:
+			session := s.(*Session)
+			subject, err := w.subjectFor(msg)
+			if err != nil {
+				return nil, err
+			}
+			body, err := w.Codec.Encode(msg.Data())
+			if err != nil {
+				return nil, err
+			}
+			m := &nats.Msg{Subject: subject, Data: body}
+			if session.conn.HeadersSupported() {
+				m.Header = nats.Header{"Content-Type": {w.Codec.ContentType()}}
+			}
+			if w.JetStream {
+				js, err := session.jetStream()
+				if err != nil {
+					return nil, err
+				}
+				if _, err := js.PublishMsg(m); err != nil {
+					return nil, err
+				}
+			} else if err := session.conn.PublishMsg(m); err != nil {
+				return nil, err
+			}
+		}
+		if msg.Confirms() != nil {
+			msg.Confirms() <- struct{}{}
+		}
+		return msg, nil
+	}
+}
+
+// WritesInParallel implements client.ParallelWriter, the connection can be published to by several
+// goroutines and each message is confirmed once it's been published.
+func (w *Writer) WritesInParallel() bool {
+	return true
+}
+
+func (w *Writer) subjectFor(msg message.Msg) (string, error) {
+	var b bytes.Buffer
+	if err := w.subject.Execute(&b, subjectData{msg.Namespace(), msg.OP().String(), msg.Data()}); err != nil {
+		return "", err
+	}
+	subject := b.String()
+	if !validSubject(subject) {
+		return "", InvalidSubjectError{subject}
+	}
+	return subject, nil
+}
+
+// validSubject reports whether messages can be published to the subject, it must have no empty
+// tokens, whitespace or wildcards.
+func validSubject(subject string) bool {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n*>") {
+		return false
+	}
+	for _, token := range strings.Split(subject, ".") {
+		if token == "" {
+			return false
+		}
+	}
+	return true
+}
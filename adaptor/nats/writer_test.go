@@ -0,0 +1,99 @@
+package nats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/codec"
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
+	"github.com/nats-io/nats.go"
+)
+
+var writerStreamTestData = TestData{"WRITER", "writer_stream", 0}
+
+var subjectTests = []struct {
+	template string
+	msg      message.Msg
+	subject  string
+	err      bool
+}{
+	{DefaultSubject, message.From(ops.Insert, "db.orders", data.Data{}), "db.orders.insert", false},
+	{"events.{{.Data.type}}", message.From(ops.Update, "orders", data.Data{"type": "paid"}), "events.paid", false},
+	{"events.{{.Data.type}}", message.From(ops.Update, "orders", data.Data{}), "", true},
+	{"{{.Namespace}}", message.From(ops.Insert, "my orders", data.Data{}), "", true},
+	{"{{.Namespace}}.{{.Op}}", message.From(ops.Insert, "", data.Data{}), "", true},
+}
+
+func TestSubject(t *testing.T) {
+	for _, st := range subjectTests {
+		w, err := newWriter(st.template, false)
+		if err != nil {
+			t.Fatalf("unexpected newWriter error, %s", err)
+		}
+		subject, err := w.subjectFor(st.msg)
+		if (err != nil) != st.err {
+			t.Errorf("[%s] wrong error, expected error %t, got %v", st.template, st.err, err)
+		}
+		if subject != st.subject {
+			t.Errorf("[%s] wrong subject, expected %s, got %s", st.template, st.subject, subject)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	w, err := newWriter(DefaultSubject, false)
+	if err != nil {
+		t.Fatalf("unexpected newWriter error, %s", err)
+	}
+	w.Codec = codec.Msgpack{}
+	sub, err := defaultSession.conn.SubscribeSync("writer_test.*")
+	if err != nil {
+		t.Fatalf("unexpected subscribe error, %s", err)
+	}
+	defer sub.Unsubscribe()
+	if err := defaultSession.conn.FlushTimeout(time.Second); err != nil {
+		t.Fatalf("unexpected flush error, %s", err)
+	}
+
+	confirms := make(chan struct{}, 1)
+	msg := message.WithConfirms(confirms, message.From(ops.Update, "writer_test", data.Data{"id": 1}))
+	if _, err := w.Write(msg)(defaultSession); err != nil {
+		t.Fatalf("unexpected Write error, %s", err)
+	}
+	select {
+	case <-confirms:
+	default:
+		t.Errorf("message wasn't confirmed")
+	}
+	m, err := sub.NextMsg(time.Second)
+	if err != nil {
+		t.Fatalf("unexpected NextMsg error, %s", err)
+	}
+	if m.Subject != "writer_test.update" || m.Header.Get("Content-Type") != "application/msgpack" {
+		t.Errorf("wrong message received, %+v", m)
+	}
+	d, err := codec.Msgpack{}.Decode(m.Data)
+	if err != nil || d.Get("id") != int64(1) {
+		t.Errorf("wrong data received, %+v, %v", d, err)
+	}
+}
+
+func TestWriteJetStream(t *testing.T) {
+	w, err := newWriter(DefaultSubject, true)
+	if err != nil {
+		t.Fatalf("unexpected newWriter error, %s", err)
+	}
+	for i := 0; i < 5; i++ {
+		msg := message.From(ops.Insert, writerStreamTestData.Namespace, data.Data{"id": i})
+		if _, err := w.Write(msg)(defaultSession); err != nil {
+			t.Fatalf("unexpected Write error, %s", err)
+		}
+	}
+	// nothing is stored for this subject so no stream acknowledges it
+	msg := message.From(ops.Insert, "writer_no_stream", data.Data{"id": 1})
+	if _, err := w.Write(msg)(defaultSession); err != nats.ErrNoStreamResponse {
+		t.Errorf("wrong Write error, expected %s, got %v", nats.ErrNoStreamResponse, err)
+	}
+}
@@ -70,6 +70,10 @@ var (
 		readerTestData,
 		readerComplexTestData,
 		tailerTestData,
+		tailerSnapshotTestData,
+		triggerTailerTestData,
+		triggerSnapshotTestData,
+		pollerTestData,
 		writerTestData,
 		writerComplexTestData,
 		writerUpdateTestData,
@@ -77,6 +81,9 @@ var (
 		writerComplexUpdateTestData,
 		writerComplexDeleteTestData,
 		writerComplexDeletePkTestData,
+		bulkTestData,
+		autoSchemaTestData,
+		childTablesTestData,
 	}
 
 	randomHeros = []string{"Superwoman", "Wonder Woman", "Batman", "Superman",
@@ -0,0 +1,216 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/log"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/ops"
+	"github.com/lib/pq"
+)
+
+// copySeqColumn is added to the temporary tables upserts are copied into to record the order the
+// rows were copied in.
+const copySeqColumn = "transporter_copy_seq"
+
+var (
+	_ client.Writer             = &Bulk{}
+	_ client.ContextWriter      = &Bulk{}
+	_ client.BatchWriter        = &Bulk{}
+	_ client.ContextBatchWriter = &Bulk{}
+)
+
+// Bulk implements client.BatchWriter for use with Postgres, each batch is written in a single
+// transaction and consecutive inserts into the same table are loaded with COPY FROM STDIN.
+type Bulk struct {
+	*Writer
+	copyCounter int
+}
+
+func newBulker(w *Writer) *Bulk {
+	return &Bulk{Writer: w}
+}
+
+func (b *Bulk) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	write := b.WriteContext(msg)
+	return func(s client.Session) (message.Msg, error) {
+		return write(context.Background(), s)
+	}
+}
+
+// WriteContext writes msg in a batch of its own, aborting the transaction once ctx is done.
+func (b *Bulk) WriteContext(msg message.Msg) func(context.Context, client.Session) (message.Msg, error) {
+	return func(ctx context.Context, s client.Session) (message.Msg, error) {
+		if err := b.writeBatch(ctx, []message.Msg{msg}, s.(*Session).pqSession); err != nil {
+			return nil, err
+		}
+		if msg.Confirms() != nil {
+			msg.Confirms() <- struct{}{}
+		}
+		return msg, nil
+	}
+}
+
+// WriteBatch writes msgs in a single transaction.
+func (b *Bulk) WriteBatch(msgs []message.Msg) func(client.Session) error {
+	write := b.WriteBatchContext(msgs)
+	return func(s client.Session) error {
+		return write(context.Background(), s)
+	}
+}
+
+// WriteBatchContext is WriteBatch, the transaction is rolled back once ctx is done.
+func (b *Bulk) WriteBatchContext(msgs []message.Msg) func(context.Context, client.Session) error {
+	return func(ctx context.Context, s client.Session) error {
+		return b.writeBatch(ctx, msgs, s.(*Session).pqSession)
+	}
+}
+
+func (b *Bulk) writeBatch(ctx context.Context, msgs []message.Msg, db *sql.DB) error {
+	msgs = b.supported(msgs)
+	if len(msgs) == 0 {
+		return nil
+	}
+	log.With("msg_count", len(msgs)).Debugln("flushing bulk messages")
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	s := contextExecer{ctx, tx}
+	for i := 0; i < len(msgs); {
+		n := b.insertRun(msgs, i)
+		if n > 1 {
+			err = b.copyMsgs(msgs[i:i+n], s)
+		} else {
+			err = b.writeMsg(msgs[i], s)
+		}
+		if err != nil {
+			tx.Rollback()
+			b.resetSchema()
+			return err
+		}
+		i += n
+	}
+	if err := tx.Commit(); err != nil {
+		b.resetSchema()
+		return err
+	}
+	log.With("msg_count", len(msgs)).Debugln("flush complete")
+	return nil
+}
+
+// supported returns the msgs with an operation which can be written.
+func (b *Bulk) supported(msgs []message.Msg) []message.Msg {
+	supported := make([]message.Msg, 0, len(msgs))
+	for _, msg := range msgs {
+		if _, ok := b.writeMap[msg.OP()]; !ok {
+			log.Infof("no function registered for operation, %s", msg.OP())
+			continue
+		}
+		supported = append(supported, msg)
+	}
+	return supported
+}
+
+// insertRun returns the number of consecutive messages, starting at i, which are inserts into
+// the same table with the same set of columns and can therefore be written with a single COPY.
+// Documents with child tables are always written one at a time.
+func (b *Bulk) insertRun(msgs []message.Msg, i int) int {
+	first := msgs[i]
+	if _, ok := b.childTables[first.Namespace()]; ok || first.OP() != ops.Insert {
+		return 1
+	}
+	columns := strings.Join(sortedKeys(first), ",")
+	n := 1
+	for _, m := range msgs[i+1:] {
+		if m.OP() != ops.Insert || m.Namespace() != first.Namespace() || strings.Join(sortedKeys(m), ",") != columns {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// copyMsgs loads the messages with COPY FROM STDIN. Since COPY has no conflict handling, upserts
+// are copied into a temporary table first and then merged into the target table, keeping only the
+// last message copied for each primary key as a row can't be updated twice by one INSERT.
+func (b *Bulk) copyMsgs(msgs []message.Msg, tx execer) error {
+	namespace := msgs[0].Namespace()
+	columns := sortedKeys(msgs[0])
+	schema, table := splitNamespace(namespace)
+	log.With("table", namespace).With("msg_count", len(msgs)).Debugln("COPY")
+	if b.autoSchema {
+		if err := b.ensureSchema(msgs[0], tx); err != nil {
+			return err
+		}
+	}
+	columnTypes, err := b.columnTypes(namespace, tx)
+	if err != nil {
+		return err
+	}
+
+	var conflict string
+	if b.upsert {
+		if conflict, err = b.onConflict(namespace, columns, tx); err != nil {
+			return err
+		}
+	}
+	if conflict != "" {
+		b.copyCounter++
+		schema, table = "", fmt.Sprintf("transporter_copy_%d", b.copyCounter)
+		if _, err := tx.Exec(fmt.Sprintf("CREATE TEMP TABLE %v (LIKE %v, %v bigserial) ON COMMIT DROP;", table, quoteNamespace(namespace), copySeqColumn)); err != nil {
+			return err
+		}
+	}
+
+	var stmt *sql.Stmt
+	if schema == "" {
+		stmt, err = tx.Prepare(pq.CopyIn(table, columns...))
+	} else {
+		stmt, err = tx.Prepare(pq.CopyInSchema(schema, table, columns...))
+	}
+	if err != nil {
+		return err
+	}
+	for _, m := range msgs {
+		vals := make([]interface{}, len(columns))
+		for i, column := range columns {
+			vals[i] = columnValue(columnTypes[column], m.Data().Get(column))
+		}
+		if _, err := stmt.Exec(vals...); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+	if err := stmt.Close(); err != nil || conflict == "" {
+		return err
+	}
+
+	pkeys, err := b.primaryKeys(namespace, tx)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(pkeys))
+	for key := range pkeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var (
+		cols    = strings.Join(quoteIdentifiers(columns), ", ")
+		keyCols = strings.Join(quoteIdentifiers(keys), ", ")
+	)
+	_, err = tx.Exec(fmt.Sprintf(
+		"INSERT INTO %v (%v) SELECT DISTINCT ON (%v) %v FROM %v ORDER BY %v, %v DESC%v;",
+		quoteNamespace(namespace), cols, keyCols, cols, table, keyCols, copySeqColumn, conflict,
+	))
+	return err
+}
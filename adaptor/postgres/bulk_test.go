@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
+)
+
+var (
+	bulkTestData = &TestData{"bulk_test", "bulk_test_table", basicSchema, 0}
+)
+
+func TestBulkReplay(t *testing.T) {
+	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", bulkTestData.DB)))
+	if err != nil {
+		t.Fatalf("unable to initialize connection to postgres, %s", err)
+	}
+	defer c.Close()
+	s, err := c.Connect()
+	if err != nil {
+		t.Fatalf("unable to obtain session to postgres, %s", err)
+	}
+	b := newBulker(newWriter(true, false))
+	// every document is written twice to simulate the commitlog replaying messages
+	var msgs []message.Msg
+	for _, colvar := range []string{"hello world", "goodbye world"} {
+		for i := 0; i < 10; i++ {
+			msgs = append(msgs, message.From(
+				ops.Insert,
+				fmt.Sprintf("public.%s", bulkTestData.Table),
+				data.Data{"id": i, "colvar": colvar, "coltimestamp": time.Now().UTC()}),
+			)
+		}
+	}
+	msgs = append(msgs, message.From(
+		ops.Update,
+		fmt.Sprintf("public.%s", bulkTestData.Table),
+		data.Data{"id": 10, "colvar": "robin", "coltimestamp": time.Now().UTC()}),
+	)
+	if err := b.WriteBatch(msgs)(s); err != nil {
+		t.Errorf("unexpected WriteBatch error, %s\n", err)
+	}
+
+	var count int
+	if err := s.(*Session).pqSession.
+		QueryRow(fmt.Sprintf("SELECT COUNT(id) FROM %s WHERE colvar = 'goodbye world';", bulkTestData.Table)).
+		Scan(&count); err != nil {
+		t.Errorf("unable to count table, %s", err)
+	}
+	if count != 10 {
+		t.Errorf("wrong document count, expected 10, got %d", count)
+	}
+	var colvar string
+	if err := s.(*Session).pqSession.
+		QueryRow(fmt.Sprintf("SELECT colvar FROM %s WHERE id = 10;", bulkTestData.Table)).
+		Scan(&colvar); err != nil || colvar != "robin" {
+		t.Errorf("upserted update not found, %s, %s", colvar, err)
+	}
+}
+
+func TestBulkUpsertDuplicateID(t *testing.T) {
+	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", bulkTestData.DB)))
+	if err != nil {
+		t.Fatalf("unable to initialize connection to postgres, %s", err)
+	}
+	defer c.Close()
+	s, err := c.Connect()
+	if err != nil {
+		t.Fatalf("unable to obtain session to postgres, %s", err)
+	}
+	b := newBulker(newWriter(true, true))
+	// the documents are copied together so the last one with each _id has to win
+	var msgs []message.Msg
+	for _, d := range []data.Data{
+		{"_id": "batman", "name": "Bruce Wayne"},
+		{"_id": "robin", "name": "Dick Grayson"},
+		{"_id": "batman", "name": "Dick Grayson"},
+	} {
+		msgs = append(msgs, message.From(ops.Insert, "public.bulk_duplicate_test_table", d))
+	}
+	if err := b.WriteBatch(msgs)(s); err != nil {
+		t.Fatalf("unexpected WriteBatch error, %s\n", err)
+	}
+
+	var count int
+	if err := s.(*Session).pqSession.
+		QueryRow("SELECT COUNT(*) FROM bulk_duplicate_test_table;").
+		Scan(&count); err != nil {
+		t.Errorf("unable to count table, %s", err)
+	}
+	if count != 2 {
+		t.Errorf("wrong document count, expected 2, got %d", count)
+	}
+	var name string
+	if err := s.(*Session).pqSession.
+		QueryRow("SELECT name FROM bulk_duplicate_test_table WHERE _id = 'batman';").
+		Scan(&name); err != nil || name != "Dick Grayson" {
+		t.Errorf("last duplicate not written, %s, %s", name, err)
+	}
+}
+
+func TestInsertRun(t *testing.T) {
+	b := newBulker(newWriter(false, false))
+	msgs := []message.Msg{
+		message.From(ops.Insert, "public.a", data.Data{"id": 1, "name": "a"}),
+		message.From(ops.Insert, "public.a", data.Data{"name": "b", "id": 2}),
+		message.From(ops.Insert, "public.a", data.Data{"id": 3}),
+		message.From(ops.Update, "public.a", data.Data{"id": 3, "name": "c"}),
+		message.From(ops.Insert, "public.b", data.Data{"id": 1}),
+	}
+	for i, expected := range map[int]int{0: 2, 1: 1, 2: 1, 3: 1, 4: 1} {
+		if n := b.insertRun(msgs, i); n != expected {
+			t.Errorf("[%d] wrong insert run, expected %d, got %d", i, expected, n)
+		}
+	}
+}
+
+func TestBulkWriteBatchError(t *testing.T) {
+	db, _ := sql.Open("postgres", "postgres://127.0.0.1:1/bulk_test?sslmode=disable&connect_timeout=1")
+	defer db.Close()
+	s := &Session{pqSession: db, db: "bulk_test"}
+	b := newBulker(newWriter(false, false))
+	msgs := []message.Msg{message.From(ops.Insert, "public.a", data.Data{"id": 1})}
+	if err := b.WriteBatch(msgs)(s); err == nil {
+		t.Error("expected a WriteBatch error")
+	}
+	if err := b.WriteBatch([]message.Msg{message.From(ops.Command, "public.a", data.Data{})})(s); err != nil {
+		t.Errorf("unexpected WriteBatch error for an unsupported op, %s", err)
+	}
+}
+
+func TestBulkWriteBatchContextDone(t *testing.T) {
+	db, _ := sql.Open("postgres", "postgres://127.0.0.1:1/bulk_test?sslmode=disable&connect_timeout=1")
+	defer db.Close()
+	s := &Session{pqSession: db, db: "bulk_test"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	msgs := []message.Msg{message.From(ops.Insert, "public.a", data.Data{"id": 1})}
+	if err := newBulker(newWriter(false, false)).WriteBatchContext(msgs)(ctx, s); err != context.Canceled {
+		t.Errorf("wrong WriteBatchContext error, expected %s, got %v", context.Canceled, err)
+	}
+}
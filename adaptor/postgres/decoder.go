@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
+	"github.com/lib/pq"
+)
+
+const (
+	testDecodingPlugin = "test_decoding"
+	wal2jsonPlugin     = "wal2json"
+	pgoutputPlugin     = "pgoutput"
+)
+
+var (
+	// ErrMissingPublication is returned when the pgoutput plugin is configured without a publication.
+	ErrMissingPublication = errors.New("publication is required when using the pgoutput plugin")
+)
+
+// UnsupportedPluginError represents the error when an unknown logical decoding plugin has been set.
+type UnsupportedPluginError struct {
+	Plugin string
+}
+
+func (e UnsupportedPluginError) Error() string {
+	return fmt.Sprintf("unsupported logical decoding plugin, %s", e.Plugin)
+}
+
+// change represents a single row level change decoded from the replication slot.
+type change struct {
+	op        ops.Op
+	namespace string
+	data      data.Data
+	// oldKeys holds the replica identity of the row prior to an update or delete, it will be
+	// nil when the plugin did not provide it.
+	oldKeys data.Data
+}
+
+// keyChanged reports whether an update moved the row to a new replica identity.
+func (c change) keyChanged() bool {
+	if c.op != ops.Update || c.oldKeys == nil {
+		return false
+	}
+	for k, v := range c.oldKeys {
+		if !reflect.DeepEqual(c.data.Get(k), v) {
+			return true
+		}
+	}
+	return false
+}
+
+// transaction groups every change that was committed together.
+type transaction struct {
+	xid       uint32
+	timestamp int64
+	changes   []change
+}
+
+// decoder converts the output of a logical decoding plugin into transactions.
+type decoder interface {
+	// plugin returns the name of the output plugin the decoder understands.
+	plugin() string
+	// options returns the plugin options used to start replication from the slot.
+	options() []string
+	// decode is called with the data of every message streamed from the slot, in order, and
+	// returns any transactions completed by it.
+	decode([]byte) ([]transaction, error)
+}
+
+func newDecoder(plugin, publication string) (decoder, error) {
+	switch plugin {
+	case "", testDecodingPlugin:
+		return &testDecoder{}, nil
+	case wal2jsonPlugin:
+		return &wal2jsonDecoder{}, nil
+	case pgoutputPlugin:
+		if publication == "" {
+			return nil, ErrMissingPublication
+		}
+		return newPgoutputDecoder(publication), nil
+	}
+	return nil, UnsupportedPluginError{plugin}
+}
+
+// pluginOptions formats pairs of option names and values as the options of START_REPLICATION.
+func pluginOptions(pairs ...string) []string {
+	var opts []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		opts = append(opts, fmt.Sprintf("%v '%v'", pq.QuoteIdentifier(pairs[i]), strings.Replace(pairs[i+1], "'", "''", -1)))
+	}
+	return opts
+}
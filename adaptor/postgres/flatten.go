@@ -0,0 +1,269 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/compose/mejson"
+	"github.com/compose/transporter/log"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
+	"github.com/lib/pq"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// childValueColumn is the column elements of an array which aren't documents are written to.
+const childValueColumn = "value"
+
+var (
+	// ErrInvalidChildTable is returned when a child table is configured without a field, table
+	// or foreign_key.
+	ErrInvalidChildTable = errors.New("child_tables require a field, table and foreign_key")
+)
+
+// ChildTable maps a field of the documents written to a table onto the rows of a child table.
+// A nested document becomes a single row and every element of an array becomes a row, elements
+// which aren't documents are written to the value column. Each row holds the primary key of the
+// parent row in ForeignKey. When Key is set, rows are matched on that field and updated in
+// place, otherwise the child rows of a document are replaced whenever it's written.
+type ChildTable struct {
+	Field      string `json:"field"`
+	Table      string `json:"table"`
+	ForeignKey string `json:"foreign_key"`
+	Key        string `json:"key"`
+}
+
+func validateChildTables(childTables map[string][]ChildTable) error {
+	for _, children := range childTables {
+		for _, c := range children {
+			if c.Field == "" || c.Table == "" || c.ForeignKey == "" {
+				return ErrInvalidChildTable
+			}
+		}
+	}
+	return nil
+}
+
+// writeMsg writes the message with the function registered for its operation. Fields mapped to
+// child tables are removed from the parent row and written to the child tables after it, or
+// deleted before it when the message is a delete.
+func (w *Writer) writeMsg(m message.Msg, s execer) error {
+	write := w.writeMap[m.OP()]
+	children, ok := w.childTables[m.Namespace()]
+	if !ok {
+		return write(m, s)
+	}
+	parent := withoutFields(m, children)
+	if m.OP() == ops.Delete {
+		parentKey, err := w.parentKey(parent, s)
+		if err != nil {
+			return err
+		}
+		for _, c := range children {
+			if err := w.deleteChildRows(c, parentKey, s); err != nil {
+				return err
+			}
+		}
+		return write(parent, s)
+	}
+	if err := write(parent, s); err != nil {
+		return err
+	}
+	parentKey, err := w.parentKey(parent, s)
+	if err != nil {
+		return err
+	}
+	for _, c := range children {
+		rows := childRows(m.Data().Get(c.Field), c.ForeignKey, parentKey)
+		if c.Key == "" {
+			err = w.replaceChildRows(c, parentKey, rows, s)
+		} else {
+			err = w.diffChildRows(c, parentKey, rows, s)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parentKey returns the value of the parent table's primary key which is written to the foreign
+// key of its child rows, the parent table must have a single column primary key.
+func (w *Writer) parentKey(m message.Msg, s execer) (interface{}, error) {
+	pkeys, err := w.primaryKeys(m.Namespace(), s)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkeys) != 1 {
+		return nil, fmt.Errorf("child tables require a single column primary key on %v, found %d columns", m.Namespace(), len(pkeys))
+	}
+	for key := range pkeys {
+		if value, ok := m.Data().Has(key); ok && value != nil {
+			return prepareValue(value), nil
+		}
+		return nil, fmt.Errorf("primary key %v of %v is missing, unable to write child rows", key, m.Namespace())
+	}
+	return nil, nil
+}
+
+func (w *Writer) replaceChildRows(c ChildTable, parentKey interface{}, rows []data.Data, s execer) error {
+	if err := w.deleteChildRows(c, parentKey, s); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := w.insertMsg(message.From(ops.Insert, c.Table, row), s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffChildRows removes the child rows whose key is no longer in the document, updates the rows
+// which still are and inserts the new ones.
+func (w *Writer) diffChildRows(c ChildTable, parentKey interface{}, rows []data.Data, s execer) error {
+	keys := make([]interface{}, len(rows))
+	for i, row := range rows {
+		value, ok := row.Has(c.Key)
+		if !ok || value == nil {
+			return fmt.Errorf("child row of %v is missing key %v", c.Table, c.Key)
+		}
+		keys[i] = prepareValue(value)
+	}
+	exists, err := w.childTableExists(c, s)
+	if err != nil {
+		return err
+	}
+	if exists {
+		query := fmt.Sprintf("DELETE FROM %v WHERE %v = $1 AND NOT (%v = ANY($2));",
+			quoteNamespace(c.Table), pq.QuoteIdentifier(c.ForeignKey), pq.QuoteIdentifier(c.Key))
+		log.With("table", c.Table).Debugln("DELETE")
+		if _, err := s.Exec(query, parentKey, pq.Array(keys)); err != nil {
+			return err
+		}
+	}
+
+	for i, row := range rows {
+		m := message.From(ops.Update, c.Table, row)
+		if w.autoSchema {
+			if err := w.ensureSchema(m, s); err != nil {
+				return err
+			}
+		}
+		columnTypes, err := w.columnTypes(c.Table, s)
+		if err != nil {
+			return err
+		}
+		// the foreign key is always set so the statement is valid for rows with no other columns
+		var (
+			sets = []string{fmt.Sprintf("%v=$1", pq.QuoteIdentifier(c.ForeignKey))}
+			vals = []interface{}{parentKey, keys[i]}
+		)
+		for _, key := range sortedKeys(m) {
+			if key == c.ForeignKey || key == c.Key {
+				continue
+			}
+			vals = append(vals, columnValue(columnTypes[key], row.Get(key)))
+			sets = append(sets, fmt.Sprintf("%v=$%v", pq.QuoteIdentifier(key), len(vals)))
+		}
+		query := fmt.Sprintf("UPDATE %v SET %v WHERE %v = $1 AND %v = $2;",
+			quoteNamespace(c.Table), strings.Join(sets, ", "), pq.QuoteIdentifier(c.ForeignKey), pq.QuoteIdentifier(c.Key))
+		log.With("table", c.Table).Debugln("UPDATE")
+		result, err := s.Exec(query, vals...)
+		if err != nil {
+			return err
+		}
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if updated == 0 {
+			if err := w.insertMsg(message.From(ops.Insert, c.Table, row), s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Writer) deleteChildRows(c ChildTable, parentKey interface{}, s execer) error {
+	exists, err := w.childTableExists(c, s)
+	if err != nil || !exists {
+		return err
+	}
+	log.With("table", c.Table).Debugln("DELETE")
+	_, err = s.Exec(fmt.Sprintf("DELETE FROM %v WHERE %v = $1;", quoteNamespace(c.Table), pq.QuoteIdentifier(c.ForeignKey)), parentKey)
+	return err
+}
+
+// childTableExists reports whether the child table has been created, with auto_schema enabled
+// child tables are only created once a row is written to them so there may be nothing to delete.
+func (w *Writer) childTableExists(c ChildTable, s execer) (bool, error) {
+	if !w.autoSchema {
+		return true, nil
+	}
+	columns, err := w.columnTypes(c.Table, s)
+	return len(columns) > 0, err
+}
+
+// withoutFields returns a copy of the message without the fields mapped to child tables.
+func withoutFields(m message.Msg, children []ChildTable) message.Msg {
+	d := make(data.Data, len(m.Data()))
+	for key, value := range m.Data() {
+		d[key] = value
+	}
+	for _, c := range children {
+		d.Delete(c.Field)
+	}
+	return message.From(m.OP(), m.Namespace(), d)
+}
+
+// childRows converts the value of a field mapped to a child table into rows, each holding the
+// parent key in the foreign key column.
+func childRows(value interface{}, foreignKey string, parentKey interface{}) []data.Data {
+	if value == nil {
+		return nil
+	}
+	elems := []interface{}{value}
+	if _, ok := asDocument(value); !ok {
+		if _, ok := value.([]byte); !ok {
+			if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+				elems = make([]interface{}, rv.Len())
+				for i := range elems {
+					elems[i] = rv.Index(i).Interface()
+				}
+			}
+		}
+	}
+
+	rows := make([]data.Data, 0, len(elems))
+	for _, e := range elems {
+		row := data.Data{}
+		if doc, ok := asDocument(e); ok {
+			for key, value := range doc {
+				row[key] = value
+			}
+		} else {
+			row[childValueColumn] = e
+		}
+		row[foreignKey] = parentKey
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func asDocument(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return v, true
+	case data.Data:
+		return v, true
+	case bson.M:
+		return v, true
+	case mejson.M:
+		return v, true
+	}
+	return nil, false
+}
@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/compose/transporter/adaptor"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var childRowsTests = []struct {
+	value    interface{}
+	expected []data.Data
+}{
+	{nil, []data.Data{}},
+	{
+		map[string]interface{}{"city": "gotham"},
+		[]data.Data{{"city": "gotham", "order_id": 1}},
+	},
+	{
+		[]interface{}{bson.M{"sku": "cape"}, map[string]interface{}{"sku": "mask"}},
+		[]data.Data{{"sku": "cape", "order_id": 1}, {"sku": "mask", "order_id": 1}},
+	},
+	{
+		[]string{"cape", "mask"},
+		[]data.Data{{"value": "cape", "order_id": 1}, {"value": "mask", "order_id": 1}},
+	},
+	{
+		"cape",
+		[]data.Data{{"value": "cape", "order_id": 1}},
+	},
+}
+
+func TestChildRows(t *testing.T) {
+	for _, ct := range childRowsTests {
+		rows := childRows(ct.value, "order_id", 1)
+		if len(rows) == 0 && len(ct.expected) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(rows, ct.expected) {
+			t.Errorf("wrong child rows for %v, expected %v, got %v", ct.value, ct.expected, rows)
+		}
+	}
+}
+
+func TestWithoutFields(t *testing.T) {
+	m := message.From(ops.Insert, "public.orders", data.Data{"id": 1, "items": []interface{}{}, "address": bson.M{}})
+	parent := withoutFields(m, []ChildTable{{Field: "items"}, {Field: "address"}})
+	if !reflect.DeepEqual(parent.Data(), data.Data{"id": 1}) {
+		t.Errorf("child fields not removed, %v", parent.Data())
+	}
+	if len(m.Data()) != 3 {
+		t.Errorf("original message was modified, %v", m.Data())
+	}
+}
+
+var (
+	childTablesTestData = &TestData{"writer_child_tables_test", "orders", "id INTEGER PRIMARY KEY, customer TEXT", 0}
+)
+
+func TestChildTables(t *testing.T) {
+	confirms, cleanup := adaptor.MockConfirmWrites()
+	defer adaptor.VerifyWriteConfirmed(cleanup, t)
+	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", childTablesTestData.DB)))
+	if err != nil {
+		t.Fatalf("unable to initialize connection to postgres, %s", err)
+	}
+	defer c.Close()
+	s, err := c.Connect()
+	if err != nil {
+		t.Fatalf("unable to obtain session to postgres, %s", err)
+	}
+
+	w := newWriter(false, true)
+	w.childTables = map[string][]ChildTable{
+		"public.orders": {
+			{Field: "items", Table: "public.order_items", ForeignKey: "order_id", Key: "sku"},
+			{Field: "address", Table: "public.order_addresses", ForeignKey: "order_id"},
+			{Field: "tags", Table: "public.order_tags", ForeignKey: "order_id"},
+		},
+	}
+	msgs := []message.Msg{
+		message.From(ops.Insert, "public.orders", data.Data{
+			"id":       1,
+			"customer": "bruce",
+			"items": []interface{}{
+				map[string]interface{}{"sku": "cape", "qty": 1},
+				map[string]interface{}{"sku": "mask", "qty": 2},
+			},
+			"address": map[string]interface{}{"city": "gotham"},
+			"tags":    []interface{}{"rush", "gift"},
+		}),
+		message.From(ops.Update, "public.orders", data.Data{
+			"id":       1,
+			"customer": "bruce",
+			"items": []interface{}{
+				map[string]interface{}{"sku": "mask", "qty": 3},
+				map[string]interface{}{"sku": "belt", "qty": 1},
+			},
+			"address": map[string]interface{}{"city": "metropolis"},
+			"tags":    []interface{}{"gift"},
+		}),
+		message.From(ops.Insert, "public.orders", data.Data{"id": 2, "customer": "diana", "tags": []interface{}{"rush"}}),
+	}
+	for _, m := range msgs {
+		if _, err := w.Write(message.WithConfirms(confirms, m))(s); err != nil {
+			t.Fatalf("unexpected Write error, %s\n", err)
+		}
+	}
+
+	rows, err := s.(*Session).pqSession.Query("SELECT sku, qty FROM order_items WHERE order_id = 1 ORDER BY sku;")
+	if err != nil {
+		t.Fatalf("Error on test query: %v", err)
+	}
+	items := map[string]int{}
+	for rows.Next() {
+		var (
+			sku string
+			qty int
+		)
+		if err := rows.Scan(&sku, &qty); err != nil {
+			t.Fatalf("Error scanning row: %v", err)
+		}
+		items[sku] = qty
+	}
+	rows.Close()
+	if !reflect.DeepEqual(items, map[string]int{"mask": 3, "belt": 1}) {
+		t.Errorf("wrong order items, %v", items)
+	}
+
+	var city string
+	if err := s.(*Session).pqSession.QueryRow("SELECT city FROM order_addresses WHERE order_id = 1;").Scan(&city); err != nil || city != "metropolis" {
+		t.Errorf("wrong order address, %s, %v", city, err)
+	}
+
+	if _, err := w.Write(message.WithConfirms(confirms, message.From(ops.Delete, "public.orders", data.Data{"id": 1})))(s); err != nil {
+		t.Fatalf("unexpected Delete error, %s\n", err)
+	}
+	for table, expected := range map[string]int{"orders": 1, "order_items": 0, "order_addresses": 0, "order_tags": 1} {
+		var count int
+		if err := s.(*Session).pqSession.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s;", table)).Scan(&count); err != nil {
+			t.Errorf("unable to count %s, %s", table, err)
+		}
+		if count != expected {
+			t.Errorf("wrong row count for %s, expected %d, got %d", table, expected, count)
+		}
+	}
+}
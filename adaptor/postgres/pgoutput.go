@@ -0,0 +1,274 @@
+package postgres
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/compose/transporter/log"
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
+	"github.com/lib/pq/oid"
+)
+
+const (
+	// pgEpoch is the unix time of 2000-01-01, the epoch used for timestamps in the
+	// replication protocol.
+	pgEpoch = 946684800
+
+	oidJSONB  oid.Oid = 3802
+	oidAJSONB oid.Oid = 3807
+	oidAFloat oid.Oid = 1021
+	oidTime   oid.Oid = 1083
+)
+
+// pgoutputTypes maps the type OIDs sent in relation messages to the type names understood by
+// typedValue, any other type is treated as text.
+var pgoutputTypes = map[oid.Oid]string{
+	oid.T_bool:        "boolean",
+	oid.T_int2:        "smallint",
+	oid.T_int4:        "integer",
+	oid.T_int8:        "bigint",
+	oid.T_oid:         "oid",
+	oid.T_float4:      "real",
+	oid.T_float8:      "double precision",
+	oid.T_numeric:     "numeric",
+	oid.T_money:       "money",
+	oid.T_json:        "json",
+	oidJSONB:          "jsonb",
+	oid.T_bytea:       "bytea",
+	oid.T_date:        "date",
+	oidTime:           "time without time zone",
+	oid.T_timestamp:   "timestamp without time zone",
+	oid.T_timestamptz: "timestamp with time zone",
+	oid.T__bool:       "boolean[]",
+	oid.T__int2:       "smallint[]",
+	oid.T__int4:       "integer[]",
+	oid.T__int8:       "bigint[]",
+	oidAFloat:         "real[]",
+	oid.T__float8:     "double precision[]",
+	oid.T__numeric:    "numeric[]",
+	oid.T__text:       "text[]",
+	oid.T__varchar:    "character varying[]",
+	oid.T__json:       "json[]",
+	oidAJSONB:         "jsonb[]",
+}
+
+// pgoutputDecoder parses the binary logical replication protocol emitted by the pgoutput plugin
+// that ships with Postgres 10 and later.
+type pgoutputDecoder struct {
+	publication string
+	relations   map[uint32]pgoutputRelation
+	current     *transaction
+}
+
+type pgoutputRelation struct {
+	namespace string
+	columns   []pgoutputColumn
+}
+
+type pgoutputColumn struct {
+	name     string
+	typeName string
+	key      bool
+}
+
+func newPgoutputDecoder(publication string) *pgoutputDecoder {
+	return &pgoutputDecoder{
+		publication: publication,
+		relations:   make(map[uint32]pgoutputRelation),
+	}
+}
+
+func (p *pgoutputDecoder) plugin() string {
+	return pgoutputPlugin
+}
+
+func (p *pgoutputDecoder) options() []string {
+	return pluginOptions("proto_version", "1", "publication_names", p.publication)
+}
+
+func (p *pgoutputDecoder) decode(b []byte) ([]transaction, error) {
+	r := &pgoutputReader{b: b}
+	switch r.byte() {
+	case 'B':
+		r.uint64() // final LSN
+		ts := int64(r.uint64())
+		xid := r.uint32()
+		p.current = &transaction{xid: xid, timestamp: ts/1000000 + pgEpoch}
+	case 'C':
+		r.byte()   // flags
+		r.uint64() // commit LSN
+		r.uint64() // end LSN
+		ts := int64(r.uint64())
+		if r.err != nil || p.current == nil {
+			break
+		}
+		txn := *p.current
+		txn.timestamp = ts/1000000 + pgEpoch
+		p.current = nil
+		return []transaction{txn}, nil
+	case 'R':
+		id := r.uint32()
+		rel := pgoutputRelation{}
+		schema, table := r.string(), r.string()
+		if schema == "" {
+			schema = "pg_catalog"
+		}
+		rel.namespace = fmt.Sprintf("%v.%v", schema, table)
+		r.byte() // replica identity setting
+		n := int(r.uint16())
+		for i := 0; i < n && r.err == nil; i++ {
+			flags := r.byte()
+			name := r.string()
+			typeOid := oid.Oid(r.uint32())
+			r.uint32() // type modifier
+			rel.columns = append(rel.columns, pgoutputColumn{name, pgoutputTypes[typeOid], flags&1 == 1})
+		}
+		p.relations[id] = rel
+	case 'I', 'U', 'D':
+		if err := p.decodeChange(b[0], r); err != nil {
+			return nil, err
+		}
+	default:
+		// origin, type and truncate messages carry nothing we need
+	}
+	return nil, r.err
+}
+
+func (p *pgoutputDecoder) decodeChange(kind byte, r *pgoutputReader) error {
+	id := r.uint32()
+	rel, ok := p.relations[id]
+	if !ok {
+		return fmt.Errorf("received change for unknown relation %d", id)
+	}
+	c := change{namespace: rel.namespace}
+	switch kind {
+	case 'I':
+		c.op = ops.Insert
+	case 'U':
+		c.op = ops.Update
+	case 'D':
+		c.op = ops.Delete
+	}
+	for r.err == nil && len(r.b) > 0 {
+		switch tupleType := r.byte(); tupleType {
+		case 'K', 'O':
+			c.oldKeys = make(data.Data)
+			for k, v := range rel.tupleData(r) {
+				if rel.isKey(k) {
+					c.oldKeys[k] = v
+				}
+			}
+		case 'N':
+			c.data = rel.tupleData(r)
+		default:
+			return fmt.Errorf("unexpected tuple type %q", tupleType)
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	if c.data == nil {
+		c.data = c.oldKeys
+	}
+	if p.current == nil {
+		log.With("table", c.namespace).Infoln("change received outside of a transaction")
+		p.current = &transaction{}
+	}
+	p.current.changes = append(p.current.changes, c)
+	return nil
+}
+
+func (rel pgoutputRelation) isKey(name string) bool {
+	for _, c := range rel.columns {
+		if c.name == name {
+			return c.key
+		}
+	}
+	return false
+}
+
+// tupleData reads a TupleData section, unchanged TOASTed values are left out of the result.
+func (rel pgoutputRelation) tupleData(r *pgoutputReader) data.Data {
+	d := make(data.Data)
+	n := int(r.uint16())
+	for i := 0; i < n && r.err == nil; i++ {
+		var col pgoutputColumn
+		if i < len(rel.columns) {
+			col = rel.columns[i]
+		}
+		switch r.byte() {
+		case 'n':
+			d[col.name] = nil
+		case 'u':
+		case 't':
+			l := int(r.uint32())
+			d[col.name] = typedValue(string(r.bytes(l)), col.typeName)
+		}
+	}
+	return d
+}
+
+// pgoutputReader consumes the big-endian encoded fields of a pgoutput message, the first read
+// past the end of the message sets err and every subsequent read returns a zero value.
+type pgoutputReader struct {
+	b   []byte
+	err error
+}
+
+func (r *pgoutputReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *pgoutputReader) byte() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *pgoutputReader) uint16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *pgoutputReader) uint32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *pgoutputReader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *pgoutputReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	for i, c := range r.b {
+		if c == 0 {
+			s := string(r.b[:i])
+			r.b = r.b[i+1:]
+			return s
+		}
+	}
+	r.err = io.ErrUnexpectedEOF
+	return ""
+}
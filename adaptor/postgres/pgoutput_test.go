@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
+	"github.com/lib/pq/oid"
+)
+
+type pgoutputMsg struct {
+	bytes.Buffer
+}
+
+func (m *pgoutputMsg) put(vals ...interface{}) *pgoutputMsg {
+	for _, v := range vals {
+		switch v := v.(type) {
+		case string:
+			m.WriteString(v)
+			m.WriteByte(0)
+		case []byte:
+			binary.Write(m, binary.BigEndian, uint32(len(v)))
+			m.Write(v)
+		default:
+			binary.Write(m, binary.BigEndian, v)
+		}
+	}
+	return m
+}
+
+func TestPgoutputDecode(t *testing.T) {
+	d := newPgoutputDecoder("transporter")
+	msgs := [][]byte{
+		new(pgoutputMsg).put(byte('B'), uint64(100), int64(0), uint32(42)).Bytes(),
+		new(pgoutputMsg).put(byte('R'), uint32(1), "public", "heros", byte('d'), uint16(2),
+			byte(1), "id", uint32(oid.T_int4), int32(-1),
+			byte(0), "name", uint32(oid.T_text), int32(-1)).Bytes(),
+		new(pgoutputMsg).put(byte('I'), uint32(1), byte('N'), uint16(2),
+			byte('t'), []byte("1"), byte('t'), []byte("Batman")).Bytes(),
+		new(pgoutputMsg).put(byte('U'), uint32(1), byte('K'), uint16(2),
+			byte('t'), []byte("1"), byte('n'),
+			byte('N'), uint16(2), byte('t'), []byte("2"), byte('u')).Bytes(),
+		new(pgoutputMsg).put(byte('D'), uint32(1), byte('K'), uint16(2),
+			byte('t'), []byte("2"), byte('n')).Bytes(),
+	}
+	for _, m := range msgs {
+		txns, err := d.decode(m)
+		if err != nil {
+			t.Fatalf("unexpected decode error, %s", err)
+		}
+		if len(txns) != 0 {
+			t.Fatalf("transaction returned before commit, %+v", txns)
+		}
+	}
+	txns, err := d.decode(new(pgoutputMsg).put(byte('C'), byte(0), uint64(100), uint64(101), int64(1000000)).Bytes())
+	if err != nil {
+		t.Fatalf("unexpected decode error, %s", err)
+	}
+	expected := []transaction{
+		{
+			xid:       42,
+			timestamp: pgEpoch + 1,
+			changes: []change{
+				{op: ops.Insert, namespace: "public.heros", data: data.Data{"id": int64(1), "name": "Batman"}},
+				{op: ops.Update, namespace: "public.heros", data: data.Data{"id": int64(2)}, oldKeys: data.Data{"id": int64(1)}},
+				{op: ops.Delete, namespace: "public.heros", data: data.Data{"id": int64(2)}, oldKeys: data.Data{"id": int64(2)}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(txns, expected) {
+		t.Errorf("bad transactions, expected %+v, got %+v", expected, txns)
+	}
+}
+
+func TestPgoutputDecodeShortMessage(t *testing.T) {
+	d := newPgoutputDecoder("transporter")
+	if _, err := d.decode(new(pgoutputMsg).put(byte('R'), uint32(1), "public").Bytes()); err == nil {
+		t.Error("expected error decoding truncated message but didn't receive one")
+	}
+}
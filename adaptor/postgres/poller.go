@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/commitlog"
+	"github.com/compose/transporter/log"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/ops"
+	"github.com/lib/pq"
+)
+
+var (
+	_ client.Reader = &Poller{}
+)
+
+// Poller implements client.Reader for databases where neither logical decoding nor triggers
+// can be used. After copying, every table with the watermark column is periodically queried for
+// rows whose watermark is past the last one sent.
+type Poller struct {
+	reader       *Reader
+	column       string
+	batchSize    int
+	pollInterval time.Duration
+}
+
+func newPoller(column string) client.Reader {
+	return &Poller{
+		reader:       newReader().(*Reader),
+		column:       column,
+		batchSize:    defaultBatchSize,
+		pollInterval: defaultPollInterval,
+	}
+}
+
+// pollTable tracks the watermark of a polled table, last holds the watermark followed by the
+// primary key of the last row sent so rows sharing a watermark aren't skipped.
+type pollTable struct {
+	name    string
+	columns [][]string
+	keys    []string
+	last    []interface{}
+}
+
+func (p *Poller) Read(resumeMap map[string]client.MessageSet, filterFn client.NsFilterFunc) client.MessageChanFunc {
+	return func(s client.Session, done chan struct{}) (chan client.MessageSet, error) {
+		session := s.(*Session)
+		tables, err := p.pollTables(session, resumeMap, filterFn)
+		if err != nil {
+			return nil, err
+		}
+		readFunc := p.reader.Read(resumeMap, filterFn)
+		msgChan, err := readFunc(s, done)
+		if err != nil {
+			return nil, err
+		}
+		out := make(chan client.MessageSet)
+		go func() {
+			defer close(out)
+			// read until reader done
+			for msg := range msgChan {
+				select {
+				case out <- msg:
+				case <-done:
+					return
+				}
+			}
+
+			log.With("db", session.db).With("watermark_column", p.column).Infoln("polling for changes...")
+			for {
+				for _, t := range tables {
+					if err := p.poll(session, t, out, done); err != nil {
+						log.With("db", session.db).With("table", t.name).Errorf("error polling table %v", err)
+					}
+				}
+				select {
+				case <-done:
+					log.With("db", session.db).Infoln("polling stopping...")
+					return
+				case <-time.After(p.pollInterval):
+				}
+			}
+		}()
+
+		return out, nil
+	}
+}
+
+// pollTables finds the tables with the watermark column along with the watermark to start
+// polling from. The watermark is taken from the resume map for tables which were being polled,
+// tables about to be copied start from the newest watermark before the copy. The watermark of
+// tables whose copy was interrupted isn't known so they are polled from the start.
+func (p *Poller) pollTables(s *Session, resumeMap map[string]client.MessageSet, filterFn client.NsFilterFunc) ([]*pollTable, error) {
+	names, err := baseTables(s.pqSession, filterFn)
+	if err != nil {
+		return nil, err
+	}
+	var tables []*pollTable
+	for _, name := range names {
+		columns, err := tableColumnTypes(name, s.pqSession)
+		if err != nil {
+			return nil, err
+		}
+		var found bool
+		for _, c := range columns {
+			found = found || c[0] == p.column
+		}
+		if !found {
+			log.With("db", s.db).With("table", name).With("watermark_column", p.column).Infoln("table has no watermark column, it will only be copied")
+			continue
+		}
+		pkeys, err := orderedPrimaryKeys(name, s.pqSession)
+		if err != nil {
+			return nil, err
+		}
+		t := &pollTable{name: name, columns: columns, keys: append([]string{p.column}, pkeys...)}
+		m, ok := resumeMap[name]
+		switch {
+		case !ok:
+			var watermark interface{}
+			if err := s.pqSession.QueryRow(fmt.Sprintf("SELECT max(%v) FROM %v;", pq.QuoteIdentifier(p.column), quoteNamespace(name))).Scan(&watermark); err != nil {
+				return nil, err
+			}
+			if watermark != nil {
+				t.last = []interface{}{watermark}
+			}
+		case m.Mode == commitlog.Sync:
+			t.last = keyValues(m.Msg.Data(), t.keys)
+		}
+		log.With("db", s.db).With("table", name).With("watermark", t.last).Infoln("polling table")
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// poll sends every row past the table's watermark, in watermark order, as an update.
+func (p *Poller) poll(s *Session, t *pollTable, out chan<- client.MessageSet, done chan struct{}) error {
+	for {
+		query, args := p.pollQuery(t)
+		rows, err := s.pqSession.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		var count int
+		for rows.Next() {
+			doc, err := scanDoc(rows, t.columns)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			count++
+			if last := keyValues(doc, t.keys); last != nil {
+				t.last = last
+			}
+			select {
+			case out <- client.MessageSet{
+				Msg:       message.From(ops.Update, t.name, doc),
+				Timestamp: time.Now().Unix(),
+				Mode:      commitlog.Sync,
+			}:
+			case <-done:
+				rows.Close()
+				return nil
+			}
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return err
+		}
+		rows.Close()
+		if count < p.batchSize {
+			return nil
+		}
+	}
+}
+
+func (p *Poller) pollQuery(t *pollTable) (string, []interface{}) {
+	keys := make([]string, len(t.keys))
+	for i, k := range t.keys {
+		keys[i] = pq.QuoteIdentifier(k)
+	}
+	var where string
+	if t.last != nil {
+		placeholders := make([]string, len(t.last))
+		for i := range t.last {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+		where = fmt.Sprintf(" WHERE (%v) > (%v)", strings.Join(keys[:len(t.last)], ", "), strings.Join(placeholders, ", "))
+	}
+	return fmt.Sprintf("SELECT * FROM %v%v ORDER BY %v LIMIT %d", quoteNamespace(t.name), where, strings.Join(keys, ", "), p.batchSize), t.last
+}
@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/commitlog"
+	"github.com/compose/transporter/message/ops"
+)
+
+var (
+	pollerTestData = &TestData{"poller_test", "poller_test_table", basicSchema, 10}
+)
+
+func TestPoller(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping Poller in short mode")
+	}
+	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", pollerTestData.DB)))
+	if err != nil {
+		t.Fatalf("unable to initialize connection to postgres, %s", err)
+	}
+	defer c.Close()
+	s, err := c.Connect()
+	if err != nil {
+		t.Fatalf("unable to obtain session to postgres, %s", err)
+	}
+
+	r := newPoller("coltimestamp")
+	r.(*Poller).pollInterval = 100 * time.Millisecond
+	readFunc := r.Read(map[string]client.MessageSet{}, func(table string) bool {
+		return table == fmt.Sprintf("public.%s", pollerTestData.Table)
+	})
+	done := make(chan struct{})
+	msgChan, err := readFunc(s, done)
+	if err != nil {
+		t.Fatalf("unexpected Read error, %s\n", err)
+	}
+	checkCount("initial drain", pollerTestData.InsertCount, msgChan, t)
+
+	for i := 0; i < 5; i++ {
+		s.(*Session).pqSession.Exec(fmt.Sprintf("UPDATE %s SET colvar = 'hello', coltimestamp = now() at time zone 'utc' + interval '1 minute' WHERE id = %d;", pollerTestData.Table, i))
+	}
+	polled := make(chan client.MessageSet)
+	go func() {
+		for msg := range msgChan {
+			if msg.Msg.OP() != ops.Update || msg.Mode != commitlog.Sync {
+				t.Errorf("unexpected polled message, %s %v", msg.Msg.OP(), msg.Mode)
+			}
+			polled <- msg
+		}
+		close(polled)
+	}()
+	checkCount("polled data", 5, polled, t)
+	close(done)
+}
+
+var pollQueryTests = []struct {
+	last          []interface{}
+	expectedQuery string
+}{
+	{
+		nil,
+		`SELECT * FROM "public"."heros" ORDER BY "updated_at", "id" LIMIT 10`,
+	},
+	{
+		[]interface{}{time.Unix(0, 0)},
+		`SELECT * FROM "public"."heros" WHERE ("updated_at") > ($1) ORDER BY "updated_at", "id" LIMIT 10`,
+	},
+	{
+		[]interface{}{time.Unix(0, 0), int64(4)},
+		`SELECT * FROM "public"."heros" WHERE ("updated_at", "id") > ($1, $2) ORDER BY "updated_at", "id" LIMIT 10`,
+	},
+}
+
+func TestPollQuery(t *testing.T) {
+	p := &Poller{column: "updated_at", batchSize: 10}
+	for _, pt := range pollQueryTests {
+		query, args := p.pollQuery(&pollTable{name: "public.heros", keys: []string{"updated_at", "id"}, last: pt.last})
+		if query != pt.expectedQuery {
+			t.Errorf("wrong query, expected %s, got %s", pt.expectedQuery, query)
+		}
+		if len(args) != len(pt.last) {
+			t.Errorf("wrong number of args, expected %d, got %d", len(pt.last), len(args))
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package postgres
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/compose/transporter/adaptor"
@@ -15,22 +18,61 @@ const (
 	sampleConfig = `{
   "uri": "${POSTGRESQL_URI}"
   // "debug": false,
+  // "upsert": false,
+  // "bulk": false,
+  // "auto_schema": false,
+  // "child_tables": {"public.orders": [{"field": "items", "table": "public.order_items", "foreign_key": "order_id", "key": "sku"}]},
   // "tail": false,
-  // "replication_slot": "slot"
+  // "tail_method": "logical_decoding", // logical_decoding, trigger or polling
+  // "replication_slot": "slot",
+  // "logical_decoding_plugin": "test_decoding", // test_decoding, wal2json or pgoutput
+  // "publication": "transporter", // required when using pgoutput
+  // "changelog_table": "public.transporter_changelog", // used when tail_method is trigger
+  // "listen": false,
+  // "watermark_column": "updated_at" // required when tail_method is polling
 }`
 )
 
+const (
+	logicalDecodingTailMethod = "logical_decoding"
+	triggerTailMethod         = "trigger"
+	pollingTailMethod         = "polling"
+)
+
 var (
 	_ adaptor.Adaptor = &postgres{}
+
+	// ErrMissingWatermarkColumn is returned when the polling tail method is configured without a
+	// watermark_column.
+	ErrMissingWatermarkColumn = errors.New("watermark_column is required when tail_method is polling")
 )
 
+// UnsupportedTailMethodError represents the error when an unknown tail_method has been set.
+type UnsupportedTailMethodError struct {
+	Method string
+}
+
+func (e UnsupportedTailMethodError) Error() string {
+	return fmt.Sprintf("unsupported tail method, %s", e.Method)
+}
+
 // Postgres is an adaptor to read / write to postgres.
 // it works as a source by copying files, and then optionally tailing the oplog
 type postgres struct {
 	adaptor.BaseConfig
-	Debug           bool   `json:"debug" doc:"display debug information"`
-	Tail            bool   `json:"tail" doc:"if tail is true, then the postgres source will tail the oplog after copying the namespace"`
-	ReplicationSlot string `json:"replication_slot" doc:"required if tail is true; sets the replication slot to use for logical decoding"`
+	Debug           bool                    `json:"debug" doc:"display debug information"`
+	Tail            bool                    `json:"tail" doc:"if tail is true, then the postgres source will tail the oplog after copying the namespace"`
+	TailMethod      string                  `json:"tail_method" doc:"how changes are read when tail is true; logical_decoding (default), trigger or polling"`
+	ReplicationSlot string                  `json:"replication_slot" doc:"required if tail is true; sets the replication slot to use for logical decoding"`
+	Plugin          string                  `json:"logical_decoding_plugin" doc:"the output plugin the replication slot was created with; test_decoding (default), wal2json or pgoutput"`
+	Publication     string                  `json:"publication" doc:"required if logical_decoding_plugin is pgoutput; sets the publication to subscribe to"`
+	ChangelogTable  string                  `json:"changelog_table" doc:"the table changes are recorded in when tail_method is trigger, defaults to public.transporter_changelog"`
+	Listen          bool                    `json:"listen" doc:"if listen is true, the changelog is read as soon as a change is notified rather than polled every second"`
+	WatermarkColumn string                  `json:"watermark_column" doc:"required if tail_method is polling; the updated_at timestamp or increasing id column used to find changed rows"`
+	Upsert          bool                    `json:"upsert" doc:"if upsert is true, inserts and updates are written with INSERT ... ON CONFLICT DO UPDATE"`
+	Bulk            bool                    `json:"bulk" doc:"if bulk is true, writes are batched into transactions and inserts are loaded with COPY"`
+	AutoSchema      bool                    `json:"auto_schema" doc:"if auto_schema is true, missing tables and columns are created from the types of the document values"`
+	ChildTables     map[string][]ChildTable `json:"child_tables" doc:"maps nested documents and arrays of a table onto child tables, keyed by the parent table"`
 }
 
 func init() {
@@ -47,14 +89,37 @@ func (p *postgres) Client() (client.Client, error) {
 }
 
 func (p *postgres) Reader() (client.Reader, error) {
-	if p.Tail {
-		return newTailer(p.ReplicationSlot), nil
+	if !p.Tail {
+		return newReader(), nil
+	}
+	switch strings.ToLower(p.TailMethod) {
+	case "", logicalDecodingTailMethod:
+		d, err := newDecoder(p.Plugin, p.Publication)
+		if err != nil {
+			return nil, err
+		}
+		return newTailer(p.URI, p.ReplicationSlot, d), nil
+	case triggerTailMethod:
+		return newTriggerTailer(p.URI, p.ChangelogTable, p.Listen), nil
+	case pollingTailMethod:
+		if p.WatermarkColumn == "" {
+			return nil, ErrMissingWatermarkColumn
+		}
+		return newPoller(p.WatermarkColumn), nil
 	}
-	return newReader(), nil
+	return nil, UnsupportedTailMethodError{p.TailMethod}
 }
 
 func (p *postgres) Writer(done chan struct{}, wg *sync.WaitGroup) (client.Writer, error) {
-	return newWriter(), nil
+	if err := validateChildTables(p.ChildTables); err != nil {
+		return nil, err
+	}
+	w := newWriter(p.Upsert, p.AutoSchema)
+	w.childTables = p.ChildTables
+	if p.Bulk {
+		return newBulker(w), nil
+	}
+	return w, nil
 }
 
 // Description for postgres adaptor
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"sync"
 	"testing"
 
 	"github.com/compose/transporter/adaptor"
@@ -23,6 +24,16 @@ func TestSampleConfig(t *testing.T) {
 var initTests = []map[string]interface{}{
 	{"uri": DefaultURI},
 	{"uri": DefaultURI, "tail": true},
+	{"uri": DefaultURI, "bulk": true, "upsert": true},
+	{"uri": DefaultURI, "tail": true, "logical_decoding_plugin": "wal2json"},
+	{"uri": DefaultURI, "tail": true, "logical_decoding_plugin": "pgoutput", "publication": "transporter"},
+	{"uri": DefaultURI, "tail": true, "tail_method": "trigger", "listen": true},
+	{"uri": DefaultURI, "tail": true, "tail_method": "polling", "watermark_column": "updated_at"},
+	{"uri": DefaultURI, "child_tables": map[string]interface{}{
+		"public.orders": []interface{}{
+			map[string]interface{}{"field": "items", "table": "public.order_items", "foreign_key": "order_id"},
+		},
+	}},
 }
 
 func TestInit(t *testing.T) {
@@ -37,8 +48,59 @@ func TestInit(t *testing.T) {
 		if _, err := a.Reader(); err != nil {
 			t.Errorf("unexpected Reader() error, %s", err)
 		}
-		if _, err := a.Writer(nil, nil); err != nil {
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		if _, err := a.Writer(done, &wg); err != nil {
 			t.Errorf("unexpected Writer() error, %s", err)
 		}
+		close(done)
+		wg.Wait()
+	}
+}
+
+var readerErrTests = []struct {
+	config      map[string]interface{}
+	expectedErr error
+}{
+	{
+		map[string]interface{}{"uri": DefaultURI, "tail": true, "tail_method": "streaming"},
+		UnsupportedTailMethodError{"streaming"},
+	},
+	{
+		map[string]interface{}{"uri": DefaultURI, "tail": true, "tail_method": "polling"},
+		ErrMissingWatermarkColumn,
+	},
+	{
+		map[string]interface{}{"uri": DefaultURI, "tail": true, "logical_decoding_plugin": "decoderbufs"},
+		UnsupportedPluginError{"decoderbufs"},
+	},
+}
+
+func TestReaderErr(t *testing.T) {
+	for _, rt := range readerErrTests {
+		a, err := adaptor.GetAdaptor("postgres", rt.config)
+		if err != nil {
+			t.Fatalf("unexpected GetAdaptor() error, %s", err)
+		}
+		if _, err := a.Reader(); err != rt.expectedErr {
+			t.Errorf("wrong Reader() error, expected %v, got %v", rt.expectedErr, err)
+		}
+	}
+}
+
+func TestWriterErr(t *testing.T) {
+	a, err := adaptor.GetAdaptor("postgres", map[string]interface{}{
+		"uri": DefaultURI,
+		"child_tables": map[string]interface{}{
+			"public.orders": []interface{}{map[string]interface{}{"field": "items", "table": "public.order_items"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected GetAdaptor() error, %s", err)
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	if _, err := a.Writer(done, &wg); err != ErrInvalidChildTable {
+		t.Errorf("wrong Writer() error, expected %v, got %v", ErrInvalidChildTable, err)
 	}
 }
@@ -7,10 +7,12 @@ import (
 	"strings"
 
 	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/commitlog"
 	"github.com/compose/transporter/log"
 	"github.com/compose/transporter/message"
 	"github.com/compose/transporter/message/data"
 	"github.com/compose/transporter/message/ops"
+	"github.com/lib/pq"
 )
 
 var (
@@ -19,25 +21,43 @@ var (
 
 // Reader implements the behavior defined by client.Reader for interfacing with MongoDB.
 type Reader struct {
+	batchSize int
+	// snapshot, when set, is the name of an exported snapshot which every table is copied from.
+	snapshot string
 }
 
 func newReader() client.Reader {
-	return &Reader{}
+	return &Reader{batchSize: defaultBatchSize}
 }
 
 func (r *Reader) Read(resumeMap map[string]client.MessageSet, filterFn client.NsFilterFunc) client.MessageChanFunc {
 	return func(s client.Session, done chan struct{}) (chan client.MessageSet, error) {
 		out := make(chan client.MessageSet)
 		session := s.(*Session)
+		var (
+			q  execer = session.pqSession
+			tx *sql.Tx
+		)
+		if r.snapshot != "" {
+			var err error
+			if tx, err = beginSnapshot(session.pqSession, r.snapshot); err != nil {
+				return nil, err
+			}
+			log.With("db", session.db).With("snapshot", r.snapshot).Infoln("copying from snapshot")
+			q = tx
+		}
 		go func() {
 			defer close(out)
+			if tx != nil {
+				defer tx.Rollback()
+			}
 			log.With("db", session.db).Infoln("starting Read func")
-			tables, err := r.listTables(session.db, session.pqSession, filterFn)
+			tables, err := r.listTables(session.db, q, filterFn)
 			if err != nil {
 				log.With("db", session.db).Errorf("unable to list tables, %s", err)
 				return
 			}
-			results := r.iterateTable(session.db, session.pqSession, tables, done)
+			results := r.iterateTable(session.db, q, tables, resumeMap, done)
 			for {
 				select {
 				case <-done:
@@ -58,23 +78,33 @@ func (r *Reader) Read(resumeMap map[string]client.MessageSet, filterFn client.Ns
 	}
 }
 
-func (r *Reader) listTables(db string, session *sql.DB, filterFn func(name string) bool) (<-chan string, error) {
-	out := make(chan string)
+// listTables reads every table name before returning since the session may be a transaction,
+// which can't run another query until the result has been consumed.
+func (r *Reader) listTables(db string, session execer, filterFn func(name string) bool) (<-chan string, error) {
 	tablesResult, err := session.Query("SELECT table_schema,table_name FROM information_schema.tables")
 	if err != nil {
 		return nil, err
 	}
+	defer tablesResult.Close()
+	var names []string
+	for tablesResult.Next() {
+		var schema string
+		var tname string
+		err = tablesResult.Scan(&schema, &tname)
+		if err != nil {
+			log.With("db", db).Infoln("error scanning table name...")
+			continue
+		}
+		names = append(names, fmt.Sprintf("%s.%s", schema, tname))
+	}
+	if err := tablesResult.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make(chan string)
 	go func() {
 		defer close(out)
-		for tablesResult.Next() {
-			var schema string
-			var tname string
-			err = tablesResult.Scan(&schema, &tname)
-			if err != nil {
-				log.With("db", db).Infoln("error scanning table name...")
-				continue
-			}
-			name := fmt.Sprintf("%s.%s", schema, tname)
+		for _, name := range names {
 			if filterFn(name) && matchFunc(name) {
 				log.With("db", db).With("table", name).Infoln("sending for iteration...")
 				out <- name
@@ -99,7 +129,7 @@ type doc struct {
 	data  data.Data
 }
 
-func (r *Reader) iterateTable(db string, session *sql.DB, in <-chan string, done chan struct{}) <-chan doc {
+func (r *Reader) iterateTable(db string, session execer, in <-chan string, resumeMap map[string]client.MessageSet, done chan struct{}) <-chan doc {
 	out := make(chan doc)
 	go func() {
 		defer close(out)
@@ -109,75 +139,34 @@ func (r *Reader) iterateTable(db string, session *sql.DB, in <-chan string, done
 				if !ok {
 					return
 				}
-				log.With("db", db).With("table", c).With("table", c).Infoln("iterating...")
-				schemaTable := strings.Split(c, ".")
-				columnsResult, err := session.Query(fmt.Sprintf(`
-            SELECT c.column_name, c.data_type, e.data_type AS element_type
-            FROM information_schema.columns c LEFT JOIN information_schema.element_types e
-                 ON ((c.table_catalog, c.table_schema, c.table_name, 'TABLE', c.dtd_identifier)
-                   = (e.object_catalog, e.object_schema, e.object_name, e.object_type, e.collection_type_identifier))
-            WHERE c.table_schema = '%v' AND c.table_name = '%v'
-            ORDER BY c.ordinal_position;
-            `, schemaTable[0], schemaTable[1]))
+				m, resuming := resumeMap[c]
+				if resuming && m.Mode != commitlog.Copy {
+					log.With("db", db).With("table", c).Infoln("copy previously completed, skipping...")
+					continue
+				}
+				log.With("db", db).With("table", c).Infoln("iterating...")
+				columns, err := tableColumnTypes(c, session)
 				if err != nil {
 					log.With("db", db).With("table", c).Errorf("error getting columns %v", err)
 					continue
 				}
-				var columns [][]string
-				for columnsResult.Next() {
-					var columnName string
-					var columnType string
-					var columnArrayType sql.NullString // this value may be nil
-
-					err = columnsResult.Scan(&columnName, &columnType, &columnArrayType)
-					recoveredRegex := regexp.MustCompile("recovered")
-					if err != nil && !recoveredRegex.MatchString(err.Error()) {
-						log.With("table", c).Errorf("error scanning columns %v", err)
-						continue
-					}
-
-					if columnType == "ARRAY" {
-						columnType = fmt.Sprintf("%v[]", columnArrayType.String) // append [] to columnType if array
-					}
-
-					column := []string{columnName, columnType}
-					columns = append(columns, column)
+				pkeys, err := orderedPrimaryKeys(c, session)
+				if err != nil {
+					log.With("db", db).With("table", c).Errorf("error getting primary keys %v", err)
+					continue
 				}
-
-				// build docs for table
-				docsResult, err := session.Query(fmt.Sprintf("SELECT * FROM %v", c))
-
-				for docsResult.Next() {
-					dest := make([]interface{}, len(columns))
-					for i := range columns {
-						dest[i] = make([]byte, 30)
-						dest[i] = &dest[i]
-					}
-
-					var docMap map[string]interface{}
-					err = docsResult.Scan(dest...)
-					if err != nil {
-						log.With("table", c).Errorf("error scanning row %v", err)
-						continue
-					}
-
-					docMap = make(map[string]interface{})
-
-					for i, value := range dest {
-						switch value := value.(type) {
-						case []uint8:
-							docMap[columns[i][0]] = casifyValue(string(value), columns[i][1])
-						case string:
-							docMap[columns[i][0]] = casifyValue(string(value), columns[i][1])
-						default:
-							arrayRegexp := regexp.MustCompile("[[]]$")
-							if arrayRegexp.MatchString(columns[i][1]) {
-							} else {
-								docMap[columns[i][0]] = value
-							}
-						}
+				if len(pkeys) == 0 {
+					log.With("db", db).With("table", c).Infoln("table has no primary key, it will be copied in full")
+				}
+				var lastKey []interface{}
+				if resuming {
+					if lastKey = keyValues(m.Msg.Data(), pkeys); lastKey != nil {
+						log.With("db", db).With("table", c).With("key", lastKey).Infoln("resuming copy")
 					}
-					out <- doc{table: c, data: docMap}
+				}
+				if err := r.iteratePages(c, session, columns, pkeys, lastKey, out, done); err != nil {
+					log.With("db", db).With("table", c).Errorf("error iterating table %v", err)
+					continue
 				}
 				log.With("db", db).With("table", c).Infoln("iterating complete")
 			case <-done:
@@ -188,3 +177,179 @@ func (r *Reader) iterateTable(db string, session *sql.DB, in <-chan string, done
 	}()
 	return out
 }
+
+// iteratePages sends every row of the table ordered by primary key, fetching batchSize rows at a
+// time and continuing after the key of the last row sent, which makes the copy resumable.
+// Tables without a primary key are read with a single query.
+func (r *Reader) iteratePages(table string, session execer, columns [][]string, pkeys []string, lastKey []interface{}, out chan<- doc, done chan struct{}) error {
+	for {
+		query, args := r.pageQuery(table, pkeys, lastKey)
+		docsResult, err := session.Query(query, args...)
+		if err != nil {
+			return err
+		}
+		var count int
+		for docsResult.Next() {
+			docMap, err := scanDoc(docsResult, columns)
+			if err != nil {
+				log.With("table", table).Errorf("error scanning row %v", err)
+				continue
+			}
+			count++
+			if len(pkeys) > 0 {
+				lastKey = keyValues(docMap, pkeys)
+			}
+			select {
+			case out <- doc{table: table, data: docMap}:
+			case <-done:
+				docsResult.Close()
+				return nil
+			}
+		}
+		if err := docsResult.Err(); err != nil {
+			docsResult.Close()
+			return err
+		}
+		docsResult.Close()
+		if len(pkeys) == 0 || count < r.batchSize || lastKey == nil {
+			return nil
+		}
+	}
+}
+
+func (r *Reader) pageQuery(table string, pkeys []string, lastKey []interface{}) (string, []interface{}) {
+	if len(pkeys) == 0 {
+		return fmt.Sprintf("SELECT * FROM %v", quoteNamespace(table)), nil
+	}
+	keys := make([]string, len(pkeys))
+	for i, k := range pkeys {
+		keys[i] = pq.QuoteIdentifier(k)
+	}
+	var where string
+	if lastKey != nil {
+		placeholders := make([]string, len(lastKey))
+		for i := range lastKey {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+		where = fmt.Sprintf(" WHERE (%v) > (%v)", strings.Join(keys, ", "), strings.Join(placeholders, ", "))
+	}
+	return fmt.Sprintf("SELECT * FROM %v%v ORDER BY %v LIMIT %d", quoteNamespace(table), where, strings.Join(keys, ", "), r.batchSize), lastKey
+}
+
+// keyValues returns the values of the primary key columns in the document, or nil if any of
+// them are missing.
+func keyValues(d data.Data, pkeys []string) []interface{} {
+	if len(pkeys) == 0 {
+		return nil
+	}
+	values := make([]interface{}, len(pkeys))
+	for i, k := range pkeys {
+		v, ok := d.Has(k)
+		if !ok || v == nil {
+			return nil
+		}
+		values[i] = prepareValue(v)
+	}
+	return values
+}
+
+// tableColumnTypes returns the name and type of every column in the table, in order.
+func tableColumnTypes(table string, session execer) ([][]string, error) {
+	schemaTable := strings.Split(table, ".")
+	columnsResult, err := session.Query(fmt.Sprintf(`
+            SELECT c.column_name, c.data_type, e.data_type AS element_type
+            FROM information_schema.columns c LEFT JOIN information_schema.element_types e
+                 ON ((c.table_catalog, c.table_schema, c.table_name, 'TABLE', c.dtd_identifier)
+                   = (e.object_catalog, e.object_schema, e.object_name, e.object_type, e.collection_type_identifier))
+            WHERE c.table_schema = '%v' AND c.table_name = '%v'
+            ORDER BY c.ordinal_position;
+            `, schemaTable[0], schemaTable[1]))
+	if err != nil {
+		return nil, err
+	}
+	defer columnsResult.Close()
+	var columns [][]string
+	for columnsResult.Next() {
+		var columnName string
+		var columnType string
+		var columnArrayType sql.NullString // this value may be nil
+
+		err = columnsResult.Scan(&columnName, &columnType, &columnArrayType)
+		recoveredRegex := regexp.MustCompile("recovered")
+		if err != nil && !recoveredRegex.MatchString(err.Error()) {
+			log.With("table", table).Errorf("error scanning columns %v", err)
+			continue
+		}
+
+		if columnType == "ARRAY" {
+			columnType = fmt.Sprintf("%v[]", columnArrayType.String) // append [] to columnType if array
+		}
+
+		column := []string{columnName, columnType}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+func scanDoc(docsResult *sql.Rows, columns [][]string) (data.Data, error) {
+	dest := make([]interface{}, len(columns))
+	for i := range columns {
+		dest[i] = make([]byte, 30)
+		dest[i] = &dest[i]
+	}
+
+	if err := docsResult.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	docMap := make(map[string]interface{})
+
+	for i, value := range dest {
+		switch value := value.(type) {
+		case []uint8:
+			docMap[columns[i][0]] = casifyValue(string(value), columns[i][1])
+		case string:
+			docMap[columns[i][0]] = casifyValue(string(value), columns[i][1])
+		default:
+			arrayRegexp := regexp.MustCompile("[[]]$")
+			if arrayRegexp.MatchString(columns[i][1]) {
+			} else {
+				docMap[columns[i][0]] = value
+			}
+		}
+	}
+	return docMap, nil
+}
+
+// orderedPrimaryKeys returns the primary key columns of the table in the order they are
+// declared in the constraint.
+func orderedPrimaryKeys(namespace string, db execer) ([]string, error) {
+	var pkeys []string
+	tableSchema, tableName := splitNamespace(namespace)
+	rows, err := db.Query(`
+		SELECT
+			kcu.column_name
+		FROM information_schema.table_constraints tc
+			INNER JOIN information_schema.key_column_usage kcu
+				ON kcu.constraint_name = tc.constraint_name
+				AND kcu.table_schema = tc.table_schema
+				AND kcu.table_name = tc.table_name
+		WHERE tc.constraint_type = 'PRIMARY KEY'
+			AND tc.table_schema = $1
+			AND tc.table_name = $2
+		ORDER BY kcu.ordinal_position
+	`, tableSchema, tableName)
+	if err != nil {
+		return pkeys, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var columnName string
+		if err := rows.Scan(&columnName); err != nil {
+			return pkeys, err
+		}
+		pkeys = append(pkeys, columnName)
+	}
+	return pkeys, rows.Err()
+}
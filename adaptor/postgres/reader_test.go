@@ -7,7 +7,10 @@ import (
 	"time"
 
 	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/commitlog"
 	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
 )
 
 var (
@@ -126,3 +129,77 @@ func TestReadComplex(t *testing.T) {
 	}
 	close(done)
 }
+
+func TestReadResume(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping Read in short mode")
+	}
+
+	reader := newReader()
+	reader.(*Reader).batchSize = 3
+	ns := fmt.Sprintf("public.%s", readerTestData.Table)
+	resumeMap := map[string]client.MessageSet{
+		ns: {Msg: message.From(ops.Insert, ns, data.Data{"id": float64(4)}), Mode: commitlog.Copy},
+	}
+	readFunc := reader.Read(resumeMap, func(table string) bool { return table == ns })
+	done := make(chan struct{})
+	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", readerTestData.DB)))
+	if err != nil {
+		t.Fatalf("unable to initialize connection to postgres, %s", err)
+	}
+	defer c.Close()
+	s, err := c.Connect()
+	if err != nil {
+		t.Fatalf("unable to obtain session to postgres, %s", err)
+	}
+	msgChan, err := readFunc(s, done)
+	if err != nil {
+		t.Fatalf("unexpected Read error, %s\n", err)
+	}
+	expectedID := int64(5)
+	for msg := range msgChan {
+		if id := msg.Msg.Data().Get("id"); id != expectedID {
+			t.Errorf("unexpected id, expected %d, got %v", expectedID, id)
+		}
+		expectedID++
+	}
+	if expectedID != int64(readerTestData.InsertCount) {
+		t.Errorf("bad message count, expected %d, got %d\n", readerTestData.InsertCount-5, expectedID-5)
+	}
+	close(done)
+}
+
+var pageQueryTests = []struct {
+	pkeys         []string
+	lastKey       []interface{}
+	expectedQuery string
+}{
+	{
+		nil,
+		nil,
+		`SELECT * FROM "public"."heros"`,
+	},
+	{
+		[]string{"id"},
+		nil,
+		`SELECT * FROM "public"."heros" ORDER BY "id" LIMIT 10`,
+	},
+	{
+		[]string{"id", "colvar"},
+		[]interface{}{int64(4), "batman"},
+		`SELECT * FROM "public"."heros" WHERE ("id", "colvar") > ($1, $2) ORDER BY "id", "colvar" LIMIT 10`,
+	},
+}
+
+func TestPageQuery(t *testing.T) {
+	r := &Reader{batchSize: 10}
+	for _, pt := range pageQueryTests {
+		query, args := r.pageQuery("public.heros", pt.pkeys, pt.lastKey)
+		if query != pt.expectedQuery {
+			t.Errorf("wrong query, expected %s, got %s", pt.expectedQuery, query)
+		}
+		if len(args) != len(pt.lastKey) {
+			t.Errorf("wrong number of args, expected %d, got %d", len(pt.lastKey), len(args))
+		}
+	}
+}
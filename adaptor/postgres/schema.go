@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/compose/transporter/events"
+	"github.com/compose/transporter/log"
+	"github.com/compose/transporter/message"
+	"github.com/lib/pq"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SetEmitter implements client.EventEmitter, it is used to record the schema changes made when
+// auto_schema is enabled.
+func (w *Writer) SetEmitter(emit func(events.Event)) {
+	w.schemaLock.Lock()
+	defer w.schemaLock.Unlock()
+	w.emit = emit
+}
+
+// ensureSchema creates the table for the message namespace, and its schema, if it doesn't exist
+// and adds a column for every field of the message which the table doesn't have yet. The columns
+// of every table seen are cached for the lifetime of the Writer.
+func (w *Writer) ensureSchema(m message.Msg, s execer) error {
+	w.schemaLock.Lock()
+	defer w.schemaLock.Unlock()
+	namespace := m.Namespace()
+	cached, ok := w.columnCache[namespace]
+	if !ok {
+		var err error
+		if cached, err = tableColumns(namespace, s); err != nil {
+			return err
+		}
+	}
+	// the cached map may be in use by a caller of columnTypes so new columns are added to a copy
+	columns := make(map[string]string, len(cached))
+	for k, v := range cached {
+		columns[k] = v
+	}
+
+	if len(columns) == 0 {
+		if schema, _ := splitNamespace(namespace); schema != "public" {
+			if err := w.alterSchema(namespace, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %v;", pq.QuoteIdentifier(schema)), s); err != nil {
+				return err
+			}
+		}
+		var defs []string
+		for _, key := range sortedKeys(m) {
+			columnType := inferColumnType(m.Data().Get(key))
+			defs = append(defs, fmt.Sprintf("%v %v", pq.QuoteIdentifier(key), columnType))
+			columns[key] = columnType
+		}
+		if _, ok := m.Data().Has("_id"); ok {
+			defs = append(defs, fmt.Sprintf("PRIMARY KEY (%v)", pq.QuoteIdentifier("_id")))
+		}
+		if err := w.alterSchema(namespace, fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v (%v);", quoteNamespace(namespace), strings.Join(defs, ", ")), s); err != nil {
+			return err
+		}
+		w.pkLock.Lock()
+		delete(w.pkCache, namespace)
+		w.pkLock.Unlock()
+	} else {
+		for _, key := range sortedKeys(m) {
+			if _, ok := columns[key]; ok {
+				continue
+			}
+			columnType := inferColumnType(m.Data().Get(key))
+			if err := w.alterSchema(namespace, fmt.Sprintf("ALTER TABLE %v ADD COLUMN IF NOT EXISTS %v %v;", quoteNamespace(namespace), pq.QuoteIdentifier(key), columnType), s); err != nil {
+				return err
+			}
+			columns[key] = columnType
+		}
+	}
+	w.columnCache[namespace] = columns
+	return nil
+}
+
+func (w *Writer) alterSchema(namespace, statement string, s execer) error {
+	log.With("table", namespace).Infoln(statement)
+	if _, err := s.Exec(statement); err != nil {
+		return err
+	}
+	if w.emit != nil {
+		w.emit(events.NewSchemaEvent(time.Now().UnixNano(), namespace, statement))
+	}
+	return nil
+}
+
+// resetSchema clears the cached columns, it must be called when statements issued by
+// ensureSchema may have been rolled back.
+func (w *Writer) resetSchema() {
+	w.schemaLock.Lock()
+	defer w.schemaLock.Unlock()
+	w.columnCache = make(map[string]map[string]string)
+}
+
+// columnTypes returns the data type of every column of the table, the result is cached for the
+// lifetime of the Writer or until resetSchema is called.
+func (w *Writer) columnTypes(namespace string, s execer) (map[string]string, error) {
+	w.schemaLock.Lock()
+	defer w.schemaLock.Unlock()
+	if columns, ok := w.columnCache[namespace]; ok {
+		return columns, nil
+	}
+	columns, err := tableColumns(namespace, s)
+	if err != nil {
+		return nil, err
+	}
+	w.columnCache[namespace] = columns
+	return columns, nil
+}
+
+// inferColumnType returns the Postgres type used to store the value in a newly created column,
+// nested documents and arrays are stored as jsonb and anything unknown, including nil, as text.
+func inferColumnType(value interface{}) string {
+	switch value.(type) {
+	case nil, string, bson.ObjectId:
+		return "text"
+	case bool:
+		return "boolean"
+	case time.Time:
+		return "timestamp with time zone"
+	case []byte:
+		return "bytea"
+	case json.Number:
+		return "numeric"
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "bigint"
+	case reflect.Float32, reflect.Float64:
+		return "double precision"
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		return "jsonb"
+	}
+	return "text"
+}
+
+// tableColumns returns the data type of every column of the table keyed by column name, the
+// map is empty when the table doesn't exist.
+func tableColumns(namespace string, db execer) (map[string]string, error) {
+	columns := map[string]string{}
+	tableSchema, tableName := splitNamespace(namespace)
+	rows, err := db.Query(`
+		SELECT
+			column_name,
+			data_type
+		FROM information_schema.columns
+		WHERE table_schema = $1
+			AND table_name = $2
+	`, tableSchema, tableName)
+	if err != nil {
+		return columns, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var columnName, dataType string
+		if err := rows.Scan(&columnName, &dataType); err != nil {
+			return columns, err
+		}
+		columns[columnName] = dataType
+	}
+	return columns, rows.Err()
+}
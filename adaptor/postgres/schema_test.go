@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/compose/transporter/adaptor"
+	"github.com/compose/transporter/events"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var inferColumnTypeTests = []struct {
+	value    interface{}
+	expected string
+}{
+	{nil, "text"},
+	{"hello", "text"},
+	{bson.NewObjectId(), "text"},
+	{true, "boolean"},
+	{10, "bigint"},
+	{int32(10), "bigint"},
+	{uint8(10), "bigint"},
+	{1.5, "double precision"},
+	{time.Now(), "timestamp with time zone"},
+	{[]byte("bytes"), "bytea"},
+	{map[string]interface{}{"name": "batman"}, "jsonb"},
+	{bson.M{"name": "batman"}, "jsonb"},
+	{data.Data{"name": "batman"}, "jsonb"},
+	{[]interface{}{1, "two"}, "jsonb"},
+	{[]string{"one", "two"}, "jsonb"},
+}
+
+func TestInferColumnType(t *testing.T) {
+	for _, it := range inferColumnTypeTests {
+		if got := inferColumnType(it.value); got != it.expected {
+			t.Errorf("wrong column type for %T, expected %s, got %s", it.value, it.expected, got)
+		}
+	}
+}
+
+var (
+	autoSchemaTestData = &TestData{"writer_auto_schema_test", "unused_table", basicSchema, 0}
+)
+
+func TestAutoSchema(t *testing.T) {
+	confirms, cleanup := adaptor.MockConfirmWrites()
+	defer adaptor.VerifyWriteConfirmed(cleanup, t)
+	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", autoSchemaTestData.DB)))
+	if err != nil {
+		t.Fatalf("unable to initialize connection to postgres, %s", err)
+	}
+	defer c.Close()
+	s, err := c.Connect()
+	if err != nil {
+		t.Fatalf("unable to obtain session to postgres, %s", err)
+	}
+
+	var (
+		mu         sync.Mutex
+		statements []string
+	)
+	w := newWriter(true, true)
+	w.SetEmitter(func(e events.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		statements = append(statements, e.String())
+	})
+	docs := []data.Data{
+		{"_id": bson.NewObjectId(), "name": "batman"},
+		{"_id": bson.NewObjectId(), "name": "robin", "age": 15, "friends": []interface{}{"batman"}},
+		{"_id": bson.NewObjectId(), "name": "alfred", "address": map[string]interface{}{"city": "gotham"}, "Nick Name": "Pennyworth"},
+	}
+	for _, d := range docs {
+		if _, err := w.Write(message.WithConfirms(confirms, message.From(ops.Insert, "public.heroes", d)))(s); err != nil {
+			t.Errorf("unexpected Insert error, %s\n", err)
+		}
+	}
+	if len(statements) != 5 {
+		t.Errorf("wrong number of schema events, expected 5, got %d, %v", len(statements), statements)
+	}
+
+	var (
+		age     int
+		friends string
+	)
+	if err := s.(*Session).pqSession.
+		QueryRow("SELECT age, friends::text FROM heroes WHERE name = 'robin';").
+		Scan(&age, &friends); err != nil {
+		t.Fatalf("Error on test query: %v", err)
+	}
+	if age != 15 || friends != `["batman"]` {
+		t.Errorf("Values were not what they were expected to be: %v, %v", age, friends)
+	}
+
+	var city string
+	if err := s.(*Session).pqSession.
+		QueryRow("SELECT address->>'city' FROM heroes WHERE name = 'alfred';").
+		Scan(&city); err != nil || city != "gotham" {
+		t.Errorf("nested document not stored as jsonb, %s, %v", city, err)
+	}
+
+	var nickName string
+	if err := s.(*Session).pqSession.
+		QueryRow(`SELECT "Nick Name" FROM heroes WHERE name = 'alfred';`).
+		Scan(&nickName); err != nil || nickName != "Pennyworth" {
+		t.Errorf("quoted column not written, %s, %v", nickName, err)
+	}
+
+	pkeys, err := primaryKeys("public.heroes", s.(*Session).pqSession)
+	if err != nil || !pkeys["_id"] {
+		t.Errorf("_id was not created as the primary key, %v, %v", pkeys, err)
+	}
+}
+
+type schemaExecer struct {
+	execer
+	statements []string
+}
+
+func (s *schemaExecer) Exec(query string, args ...interface{}) (sql.Result, error) {
+	s.statements = append(s.statements, query)
+	return nil, nil
+}
+
+func TestEnsureSchemaCopiesColumns(t *testing.T) {
+	w := newWriter(false, true)
+	w.columnCache["public.heroes"] = map[string]string{"_id": "text"}
+	columns, err := w.columnTypes("public.heroes", nil)
+	if err != nil {
+		t.Fatalf("unexpected columnTypes error, %s", err)
+	}
+	s := &schemaExecer{}
+	if err := w.ensureSchema(message.From(ops.Insert, "public.heroes", data.Data{"_id": "1", "name": "batman"}), s); err != nil {
+		t.Fatalf("unexpected ensureSchema error, %s", err)
+	}
+	if len(s.statements) != 1 {
+		t.Errorf("wrong statements, expected a single ALTER TABLE, got %v", s.statements)
+	}
+	if _, ok := columns["name"]; ok {
+		t.Error("columns returned by columnTypes were modified")
+	}
+	if w.columnCache["public.heroes"]["name"] != "text" {
+		t.Errorf("new column wasn't cached, %v", w.columnCache["public.heroes"])
+	}
+}
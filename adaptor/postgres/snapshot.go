@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	"github.com/compose/transporter/log"
+	"github.com/lib/pq"
+)
+
+// slotSnapshot holds open the replication connection which created a logical replication slot,
+// the snapshot exported by the slot can only be imported while that connection stays idle.
+type slotSnapshot struct {
+	db              *sql.DB
+	conn            *sql.Conn
+	name            string
+	consistentPoint string
+}
+
+func (s *slotSnapshot) Close() {
+	s.conn.Close()
+	s.db.Close()
+}
+
+// exportSnapshot creates the replication slot, exporting a snapshot of the database as of the
+// slot's consistent point. Copying every table in that snapshot before consuming the slot
+// guarantees each change is seen exactly once, either in the copy or from the slot.
+// A nil slotSnapshot is returned when the slot already exists.
+func exportSnapshot(s *Session, uri, slot, plugin string) (*slotSnapshot, error) {
+	var exists bool
+	if err := s.pqSession.QueryRow("SELECT EXISTS (SELECT 1 FROM pg_replication_slots WHERE slot_name = $1);", slot).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists {
+		log.With("db", s.db).With("logical_decoding_slot", slot).Infoln("replication slot exists, copying without a snapshot")
+		return nil, nil
+	}
+
+	replicationURI, err := withReplication(uri)
+	if err != nil {
+		return nil, err
+	}
+	// there's really no way for this to error because we know the driver we're passing is
+	// available.
+	db, _ := sql.Open("postgres", replicationURI)
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	snapshot := &slotSnapshot{db: db, conn: conn}
+	var slotName, outputPlugin string
+	if err := conn.QueryRowContext(
+		context.Background(),
+		fmt.Sprintf("CREATE_REPLICATION_SLOT %v LOGICAL %v EXPORT_SNAPSHOT", pq.QuoteIdentifier(slot), pq.QuoteIdentifier(plugin)),
+	).Scan(&slotName, &snapshot.consistentPoint, &snapshot.name, &outputPlugin); err != nil {
+		snapshot.Close()
+		return nil, err
+	}
+	log.With("db", s.db).
+		With("logical_decoding_slot", slot).
+		With("consistent_point", snapshot.consistentPoint).
+		With("snapshot", snapshot.name).
+		Infoln("created replication slot")
+	return snapshot, nil
+}
+
+// withReplication adds the parameter which starts a logical replication connection to the URI.
+func withReplication(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("replication", "database")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// beginSnapshot starts a read only transaction which sees the database as of the exported
+// snapshot.
+func beginSnapshot(db *sql.DB, snapshot string) (*sql.Tx, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY"); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("SET TRANSACTION SNAPSHOT %v", pq.QuoteLiteral(snapshot))); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
@@ -1,7 +1,9 @@
 package postgres
 
 import (
+	"context"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"regexp"
@@ -15,31 +17,61 @@ import (
 	"github.com/compose/transporter/message"
 	"github.com/compose/transporter/message/data"
 	"github.com/compose/transporter/message/ops"
+	"github.com/jackc/pgx"
 )
 
 var (
 	_ client.Reader = &Tailer{}
 )
 
+const (
+	defaultBatchSize      = 1000
+	defaultPollInterval   = 1 * time.Second
+	defaultStatusInterval = 10 * time.Second
+)
+
 // Tailer implements the behavior defined by client.Tailer for interfacing with the MongoDB oplog.
 type Tailer struct {
-	reader          client.Reader
+	reader          *Reader
+	uri             string
 	replicationSlot string
+	decoder         decoder
+	// statusInterval is how often the position of the slot is reported to the server while no
+	// changes are streamed, replication is restarted retryInterval after it fails
+	statusInterval time.Duration
+	retryInterval  time.Duration
 }
 
-func newTailer(replicationSlot string) client.Reader {
-	return &Tailer{newReader(), replicationSlot}
+func newTailer(uri, replicationSlot string, d decoder) client.Reader {
+	return &Tailer{
+		reader:          newReader().(*Reader),
+		uri:             uri,
+		replicationSlot: replicationSlot,
+		decoder:         d,
+		statusInterval:  defaultStatusInterval,
+		retryInterval:   defaultPollInterval,
+	}
 }
 
 // Tail does the things
 func (t *Tailer) Read(resumeMap map[string]client.MessageSet, filterFn client.NsFilterFunc) client.MessageChanFunc {
 	return func(s client.Session, done chan struct{}) (chan client.MessageSet, error) {
+		session := s.(*Session)
+		snapshot, err := exportSnapshot(session, t.uri, t.replicationSlot, t.decoder.plugin())
+		if err != nil {
+			return nil, err
+		}
+		if snapshot != nil {
+			t.reader.snapshot = snapshot.name
+		}
 		readFunc := t.reader.Read(resumeMap, filterFn)
 		msgChan, err := readFunc(s, done)
 		if err != nil {
+			if snapshot != nil {
+				snapshot.Close()
+			}
 			return nil, err
 		}
-		session := s.(*Session)
 		out := make(chan client.MessageSet)
 		go func() {
 			defer close(out)
@@ -47,23 +79,25 @@ func (t *Tailer) Read(resumeMap map[string]client.MessageSet, filterFn client.Ns
 			for msg := range msgChan {
 				out <- msg
 			}
+			// the slot only returns changes committed after the snapshot was taken, so the copy
+			// hands off to it without gaps or duplicates
+			if snapshot != nil {
+				snapshot.Close()
+			}
 
 			// start tailing
-			log.With("db", session.db).With("logical_decoding_slot", t.replicationSlot).Infoln("Listening for changes...")
 			for {
+				err := t.stream(session, filterFn, out, done)
+				if err == nil {
+					log.With("db", session.db).Infoln("tailing stopping...")
+					return
+				}
+				log.With("db", session.db).Errorf("error streaming from logical decoding %v", err)
 				select {
 				case <-done:
 					log.With("db", session.db).Infoln("tailing stopping...")
 					return
-				case <-time.After(time.Second):
-					msgSlice, err := t.pluckFromLogicalDecoding(s.(*Session), filterFn)
-					if err != nil {
-						log.With("db", session.db).Errorf("error plucking from logical decoding %v", err)
-						continue
-					}
-					for _, msg := range msgSlice {
-						out <- msg
-					}
+				case <-time.After(t.retryInterval):
 				}
 			}
 		}()
@@ -72,69 +106,208 @@ func (t *Tailer) Read(resumeMap map[string]client.MessageSet, filterFn client.Ns
 	}
 }
 
-// Use Postgres logical decoding to retrieve the latest changes
-func (t *Tailer) pluckFromLogicalDecoding(s *Session, filterFn client.NsFilterFunc) ([]client.MessageSet, error) {
-	var result []client.MessageSet
-	dataMatcher := regexp.MustCompile("^table ([^\\.]+).([^\\.]+): (INSERT|DELETE|UPDATE): (.+)$") // 1 - schema, 2 - table, 3 - action, 4 - remaining
-
-	changesResult, err := s.pqSession.Query(fmt.Sprintf("SELECT * FROM pg_logical_slot_get_changes('%v', NULL, NULL);", t.replicationSlot))
+// stream starts replication from the slot and sends the changes of each transaction as soon as
+// it's committed. The slot is only advanced past a transaction once it has been decoded and sent,
+// so after an error replication restarts from the first transaction which wasn't sent and no
+// changes are lost. nil is returned once done is closed.
+func (t *Tailer) stream(s *Session, filterFn client.NsFilterFunc, out chan<- client.MessageSet, done chan struct{}) error {
+	config, err := pgx.ParseConnectionString(t.uri)
 	if err != nil {
-		return result, err
+		return err
 	}
+	conn, err := pgx.ReplicationConnect(config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.StartReplication(t.replicationSlot, 0, -1, t.decoder.options()...); err != nil {
+		return err
+	}
+	log.With("db", s.db).With("logical_decoding_slot", t.replicationSlot).Infoln("Listening for changes...")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// flushed is the position of the last transaction sent, the server is told the slot can be
+	// advanced up to it
+	var flushed uint64
+	for {
+		waitCtx, waitCancel := context.WithTimeout(ctx, t.statusInterval)
+		rm, err := conn.WaitForReplicationMessage(waitCtx)
+		waitCancel()
+		switch {
+		case ctx.Err() != nil:
+			return nil
+		case err == context.DeadlineExceeded:
+			if err := sendStatus(conn, flushed); err != nil {
+				return err
+			}
+			continue
+		case err != nil:
+			return err
+		case rm == nil:
+			continue
+		}
 
-	for changesResult.Next() {
-		var (
-			location string
-			xid      string
-			d        string
-		)
-
-		err = changesResult.Scan(&location, &xid, &d)
+		if rm.ServerHeartbeat != nil && rm.ServerHeartbeat.ReplyRequested == 1 {
+			if err := sendStatus(conn, flushed); err != nil {
+				return err
+			}
+		}
+		if rm.WalMessage == nil {
+			continue
+		}
+		txns, err := t.decoder.decode(rm.WalMessage.WalData)
 		if err != nil {
-			return result, err
+			return err
 		}
-
-		// Ensure we are getting a data change row
-		dataMatches := dataMatcher.FindStringSubmatch(d)
-		if len(dataMatches) == 0 {
+		if len(txns) == 0 {
 			continue
 		}
+		for _, msg := range transactionMessages(txns, filterFn) {
+			select {
+			case out <- msg:
+			case <-done:
+				return nil
+			}
+		}
+		flushed = rm.WalMessage.WalStart
+		if err := sendStatus(conn, flushed); err != nil {
+			return err
+		}
+	}
+}
 
-		// Skippable because no primary key on record
-		// Make sure we are getting changes on valid tables
-		schemaAndTable := fmt.Sprintf("%v.%v", dataMatches[1], dataMatches[2])
-		if !filterFn(schemaAndTable) {
-			continue
+// sendStatus reports the position the slot can be advanced to, no position is reported until a
+// transaction has been sent.
+func sendStatus(conn *pgx.ReplicationConn, flushed uint64) error {
+	status, err := pgx.NewStandbyStatus(flushed)
+	if err != nil {
+		return err
+	}
+	return conn.SendStandbyStatus(status)
+}
+
+// transactionMessages converts the changes of committed transactions which pass the filter into
+// messages.
+func transactionMessages(txns []transaction, filterFn client.NsFilterFunc) []client.MessageSet {
+	var result []client.MessageSet
+	for _, txn := range txns {
+		for _, c := range txn.changes {
+			if !filterFn(c.namespace) {
+				continue
+			}
+			log.With("op", c.op).With("table", c.namespace).With("xid", txn.xid).Debugln("received")
+			for _, msg := range c.messages() {
+				result = append(result, client.MessageSet{
+					Msg:       msg,
+					Timestamp: txn.timestamp,
+					Mode:      commitlog.Sync,
+				})
+			}
 		}
+	}
+	return result
+}
 
-		if dataMatches[4] == "(no-tuple-data)" {
-			log.With("op", dataMatches[3]).With("schema", schemaAndTable).Infoln("no tuple data")
-			continue
+// messages converts the change into the messages needed to apply it to a sink. An update which
+// changed the primary key is sent as a delete of the old row followed by an insert of the new
+// one so sinks never update the wrong row.
+func (c change) messages() []message.Msg {
+	switch {
+	case c.op == ops.Delete && c.oldKeys != nil:
+		return []message.Msg{message.From(ops.Delete, c.namespace, c.oldKeys)}
+	case c.keyChanged():
+		return []message.Msg{
+			message.From(ops.Delete, c.namespace, c.oldKeys),
+			message.From(ops.Insert, c.namespace, c.data),
 		}
+	}
+	return []message.Msg{message.From(c.op, c.namespace, c.data)}
+}
 
-		// normalize the action
-		var action ops.Op
-		switch dataMatches[3] {
-		case "INSERT":
-			action = ops.Insert
-		case "DELETE":
-			action = ops.Delete
-		case "UPDATE":
-			action = ops.Update
-		default:
-			return result, fmt.Errorf("Error processing action from string: %v", d)
+var (
+	dataMatcher   = regexp.MustCompile("^table ([^\\.]+).([^\\.]+): (INSERT|DELETE|UPDATE): (.+)$") // 1 - schema, 2 - table, 3 - action, 4 - remaining
+	beginMatcher  = regexp.MustCompile("^BEGIN ([0-9]+)$")
+	commitMatcher = regexp.MustCompile("^COMMIT ([0-9]+)(?: \\(at (.+)\\))?$")
+)
+
+// testDecoder parses the textual output of the test_decoding plugin.
+type testDecoder struct {
+	current *transaction
+}
+
+func (t *testDecoder) plugin() string {
+	return testDecodingPlugin
+}
+
+func (t *testDecoder) options() []string {
+	return pluginOptions("include-timestamp", "on")
+}
+
+func (t *testDecoder) decode(b []byte) ([]transaction, error) {
+	d := string(b)
+	if m := beginMatcher.FindStringSubmatch(d); m != nil {
+		xid, _ := strconv.ParseUint(m[1], 10, 32)
+		t.current = &transaction{xid: uint32(xid)}
+		return nil, nil
+	}
+	if t.current == nil {
+		t.current = &transaction{}
+	}
+	if m := commitMatcher.FindStringSubmatch(d); m != nil {
+		txn := *t.current
+		t.current = nil
+		txn.timestamp = time.Now().Unix()
+		if ts, err := parseTimestamp(m[2]); err == nil {
+			txn.timestamp = ts.Unix()
 		}
+		return []transaction{txn}, nil
+	}
 
-		log.With("op", action).With("table", schemaAndTable).Debugln("received")
+	// Ensure we are getting a data change row
+	dataMatches := dataMatcher.FindStringSubmatch(d)
+	if len(dataMatches) == 0 {
+		return nil, nil
+	}
 
-		docMap := parseLogicalDecodingData(dataMatches[4])
-		result = append(result, client.MessageSet{
-			Msg:  message.From(action, schemaAndTable, docMap),
-			Mode: commitlog.Sync,
-		})
+	schemaAndTable := fmt.Sprintf("%v.%v", dataMatches[1], dataMatches[2])
+	// Skippable because no primary key on record
+	if dataMatches[4] == "(no-tuple-data)" {
+		log.With("op", dataMatches[3]).With("schema", schemaAndTable).Infoln("no tuple data")
+		return nil, nil
 	}
 
-	return result, err
+	// normalize the action
+	c := change{namespace: schemaAndTable}
+	switch dataMatches[3] {
+	case "INSERT":
+		c.op = ops.Insert
+	case "DELETE":
+		c.op = ops.Delete
+	case "UPDATE":
+		c.op = ops.Update
+	default:
+		return nil, fmt.Errorf("Error processing action from string: %v", d)
+	}
+
+	tuple := dataMatches[4]
+	if strings.HasPrefix(tuple, "old-key: ") {
+		if i := strings.Index(tuple, " new-tuple: "); i > 0 {
+			c.oldKeys = parseLogicalDecodingData(tuple[len("old-key: "):i])
+			tuple = tuple[i+len(" new-tuple: "):]
+		}
+	}
+	c.data = parseLogicalDecodingData(tuple)
+	t.current.changes = append(t.current.changes, c)
+	return nil, nil
 }
 
 func parseLogicalDecodingData(d string) data.Data {
@@ -234,56 +407,111 @@ func parseLogicalDecodingData(d string) data.Data {
 	return data
 }
 
+var (
+	findArray     = regexp.MustCompile("[[]]$")
+	typeModifiers = regexp.MustCompile("\\([^)]*\\)")
+)
+
 func casifyValue(value string, valueType string) interface{} {
-	findArray := regexp.MustCompile("[[]]$")
+	if value == "null" {
+		return nil
+	}
+	return typedValue(value, valueType)
+}
+
+// typedValue converts the text representation of a value into the Go type best matching the
+// given Postgres type name, values of unknown types are returned as strings.
+func typedValue(value string, valueType string) interface{} {
+	valueType = typeModifiers.ReplaceAllString(valueType, "")
 
 	switch {
-	case value == "null":
-		return nil
-	case valueType == "integer" || valueType == "smallint" || valueType == "bigint":
-		i, _ := strconv.Atoi(value)
+	case valueType == "integer" || valueType == "smallint" || valueType == "bigint" || valueType == "oid":
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return value
+		}
 		return i
-	case valueType == "double precision" || valueType == "numeric" || valueType == "money":
-		if valueType == "money" { // remove the dollar sign for money
-			value = value[1:len(value)]
+	case valueType == "double precision" || valueType == "real" || valueType == "numeric" || valueType == "money":
+		if valueType == "money" { // remove the currency symbol and separators for money
+			value = strings.Replace(strings.TrimLeft(value, "$"), ",", "", -1)
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return value
 		}
-		f, _ := strconv.ParseFloat(value, 64)
 		return f
 	case valueType == "boolean":
-		return value == "true"
-	case valueType == "jsonb[]" || valueType == "json":
-		var m map[string]interface{}
-		json.Unmarshal([]byte(value), &m)
-		return m
+		return value == "true" || value == "t"
+	case valueType == "json" || valueType == "jsonb" || valueType == "jsonb[]":
+		var v interface{}
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return value
+		}
+		return v
 	case len(findArray.FindAllString(valueType, 1)) > 0:
 		var result []interface{}
 		arrayValueType := findArray.ReplaceAllString(valueType, "")
+		if len(value) < 2 {
+			return value
+		}
 
 		r := csv.NewReader(strings.NewReader(value[1 : len(value)-1]))
 		arrayValues, err := r.ReadAll()
 		if err != nil {
 			return value
 		}
+		if len(arrayValues) == 0 {
+			return result
+		}
 
 		for _, arrayValue := range arrayValues[0] {
-			result = append(result, casifyValue(arrayValue, arrayValueType))
+			if arrayValue == "NULL" {
+				result = append(result, nil)
+				continue
+			}
+			result = append(result, typedValue(arrayValue, arrayValueType))
 		}
 
 		return result
-	case valueType == "timestamp without time zone":
-		// parse time like 2015-08-21 16:09:02.988058
-		t, err := time.Parse("2006-01-02 15:04:05.9", value)
+	case valueType == "timestamp without time zone" || valueType == "timestamp with time zone":
+		// parse time like 2015-08-21 16:09:02.988058 with an optional offset
+		t, err := parseTimestamp(value)
 		if err != nil {
-			fmt.Printf("\nTime (%v) parse error: %v\n\n", value, err)
+			log.With("value", value).Errorf("timestamp parse error, %s", err)
+			return value
 		}
 		return t
 	case valueType == "date":
 		t, err := time.Parse("2006-01-02", value)
 		if err != nil {
-			fmt.Printf("\nTime (%v) parse error: %v\n\n", value, err)
+			log.With("value", value).Errorf("date parse error, %s", err)
+			return value
 		}
 		return t
+	case valueType == "bytea" && strings.HasPrefix(value, "\\x"):
+		b, err := hex.DecodeString(value[2:])
+		if err != nil {
+			return value
+		}
+		return b
 	}
 
 	return value
 }
+
+var timestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999-07:00",
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	var err error
+	for _, layout := range timestampLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
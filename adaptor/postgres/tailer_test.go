@@ -3,6 +3,7 @@ package postgres
 import (
 	"database/sql"
 	"fmt"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
@@ -49,7 +50,7 @@ func TestTailer(t *testing.T) {
 	}
 	time.Sleep(1 * time.Second)
 
-	r := newTailer("test_slot")
+	r := newTailer(c.uri, "test_slot", &testDecoder{})
 	readFunc := r.Read(map[string]client.MessageSet{}, func(table string) bool {
 		if strings.HasPrefix(table, "information_schema.") || strings.HasPrefix(table, "pg_catalog.") {
 			return false
@@ -86,6 +87,54 @@ func TestTailer(t *testing.T) {
 	close(done)
 }
 
+var (
+	tailerSnapshotTestData = &TestData{"tailer_snapshot_test", "tailer_snapshot_test_table", basicSchema, 10}
+)
+
+func TestTailerSnapshot(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping Tailer in short mode")
+	}
+	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", tailerSnapshotTestData.DB)))
+	if err != nil {
+		t.Fatalf("unable to initialize connection to postgres, %s", err)
+	}
+	defer c.Close()
+	s, err := c.Connect()
+	if err != nil {
+		t.Fatalf("unable to obtain session to postgres, %s", err)
+	}
+	s.(*Session).pqSession.Exec("SELECT * FROM pg_drop_replication_slot('snapshot_slot');")
+	defer s.(*Session).pqSession.Exec("SELECT * FROM pg_drop_replication_slot('snapshot_slot');")
+
+	r := newTailer(c.uri, "snapshot_slot", &testDecoder{})
+	readFunc := r.Read(map[string]client.MessageSet{}, func(table string) bool {
+		return table == fmt.Sprintf("public.%s", tailerSnapshotTestData.Table)
+	})
+	done := make(chan struct{})
+	msgChan, err := readFunc(s, done)
+	if err != nil {
+		t.Fatalf("unexpected Read error, %s\n", err)
+	}
+	// rows inserted after the snapshot was exported are only received from the slot
+	for i := 10; i < 15; i++ {
+		s.(*Session).pqSession.Exec(fmt.Sprintf(`INSERT INTO %s VALUES (%d, '%s', now() at time zone 'utc');`,
+			tailerSnapshotTestData.Table, i, randomHeros[i%len(randomHeros)]))
+	}
+	checkCount("snapshot and tailed data", tailerSnapshotTestData.InsertCount+5, msgChan, t)
+	close(done)
+}
+
+func TestWithReplication(t *testing.T) {
+	uri, err := withReplication("postgres://127.0.0.1:5432/test?sslmode=disable")
+	if err != nil {
+		t.Fatalf("unexpected error, %s", err)
+	}
+	if expected := "postgres://127.0.0.1:5432/test?replication=database&sslmode=disable"; uri != expected {
+		t.Errorf("wrong uri, expected %s, got %s", expected, uri)
+	}
+}
+
 func checkCount(desc string, expected int, msgChan <-chan client.MessageSet, t *testing.T) {
 	var numMsgs int
 	var wg sync.WaitGroup
@@ -111,3 +160,38 @@ func checkCount(desc string, expected int, msgChan <-chan client.MessageSet, t *
 		t.Errorf("[%s] bad message count, expected %d, got %d\n", desc, expected, numMsgs)
 	}
 }
+
+func TestTestDecoderDecode(t *testing.T) {
+	d := &testDecoder{}
+	for _, line := range []string{
+		"BEGIN 529",
+		"table public.heros: INSERT: id[integer]:1 name[text]:'it''s a bird' active[boolean]:true",
+		"table public.heros: UPDATE: old-key: id[integer]:1 new-tuple: id[integer]:2 name[text]:'plane' active[boolean]:false",
+	} {
+		txns, err := d.decode([]byte(line))
+		if err != nil || len(txns) != 0 {
+			t.Fatalf("unexpected decode result for %s, %+v, %v", line, txns, err)
+		}
+	}
+	txns, err := d.decode([]byte("COMMIT 529 (at 2017-03-29 17:49:53.453419+00)"))
+	if err != nil {
+		t.Fatalf("unexpected decode error, %s", err)
+	}
+	if len(txns) != 1 || txns[0].xid != 529 || len(txns[0].changes) != 2 {
+		t.Fatalf("bad transaction, %+v", txns)
+	}
+	if name := txns[0].changes[0].data.Get("name"); name != "it's a bird" {
+		t.Errorf("bad value, expected it's a bird, got %v", name)
+	}
+	if !txns[0].changes[1].keyChanged() {
+		t.Errorf("expected primary key change to be detected, %+v", txns[0].changes[1])
+	}
+}
+
+func TestPluginOptions(t *testing.T) {
+	expected := []string{`"proto_version" '1'`, `"publication_names" 'o''neil'`}
+	opts := newPgoutputDecoder("o'neil").options()
+	if !reflect.DeepEqual(opts, expected) {
+		t.Errorf("wrong options, expected %v, got %v", expected, opts)
+	}
+}
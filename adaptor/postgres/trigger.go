@@ -0,0 +1,507 @@
+package postgres
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/commitlog"
+	"github.com/compose/transporter/log"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultChangelogTable = "public.transporter_changelog"
+	// listenPollInterval is how often the changelog is checked when notifications are used, in
+	// case one was missed while the listener reconnected.
+	listenPollInterval = 30 * time.Second
+)
+
+var (
+	_ client.Reader = &TriggerTailer{}
+	_ client.Acker  = &TriggerTailer{}
+)
+
+// TriggerTailer implements client.Reader for databases which don't allow logical decoding. It
+// installs a trigger on every table which records each row change in a changelog table, the
+// changelog is then read in order and rows are deleted once every sink has committed them.
+type TriggerTailer struct {
+	reader       *Reader
+	uri          string
+	changelog    string
+	listen       bool
+	batchSize    int
+	pollInterval time.Duration
+
+	sync.Mutex
+	session *Session
+	// pending maps the last message sent for each changelog row to its id.
+	pending map[message.Msg]int64
+	pkeys   map[string][]string
+}
+
+func newTriggerTailer(uri, changelog string, listen bool) client.Reader {
+	if changelog == "" {
+		changelog = defaultChangelogTable
+	}
+	schema, table := splitNamespace(changelog)
+	return &TriggerTailer{
+		reader:       newReader().(*Reader),
+		uri:          uri,
+		changelog:    fmt.Sprintf("%v.%v", schema, table),
+		listen:       listen,
+		batchSize:    defaultBatchSize,
+		pollInterval: defaultPollInterval,
+		pending:      make(map[message.Msg]int64),
+		pkeys:        make(map[string][]string),
+	}
+}
+
+// Read copies every table and then reads changes from the changelog. On the first run the copy is
+// made from a snapshot taken after the triggers were installed, the changes visible in it are
+// deleted and only changes committed after it are read. When resuming every change which hasn't
+// been deleted yet is read.
+func (t *TriggerTailer) Read(resumeMap map[string]client.MessageSet, filterFn client.NsFilterFunc) client.MessageChanFunc {
+	return func(s client.Session, done chan struct{}) (chan client.MessageSet, error) {
+		session := s.(*Session)
+		t.Lock()
+		t.session = session
+		t.Unlock()
+		filter := func(table string) bool {
+			return table != t.changelog && filterFn(table)
+		}
+		if err := t.install(session, filter); err != nil {
+			return nil, err
+		}
+
+		var (
+			snapshotTx *sql.Tx
+			since      string
+		)
+		if len(resumeMap) == 0 {
+			var err error
+			if snapshotTx, t.reader.snapshot, since, err = exportTransactionSnapshot(session.pqSession); err != nil {
+				return nil, err
+			}
+			// changes committed before the snapshot are part of the copy and are never read
+			if err := t.deleteVisible(session, since); err != nil {
+				snapshotTx.Rollback()
+				return nil, err
+			}
+		}
+		readFunc := t.reader.Read(resumeMap, filter)
+		msgChan, err := readFunc(s, done)
+		if snapshotTx != nil {
+			// the copy has imported the snapshot so it no longer needs to be exported
+			snapshotTx.Rollback()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var listener *pq.Listener
+		if t.listen {
+			listener = pq.NewListener(t.uri, 10*time.Second, time.Minute, nil)
+			if err := listener.Listen(t.channel()); err != nil {
+				listener.Close()
+				return nil, err
+			}
+		}
+
+		out := make(chan client.MessageSet)
+		go func() {
+			defer close(out)
+			var notify <-chan *pq.Notification
+			if listener != nil {
+				defer listener.Close()
+				notify = listener.Notify
+			}
+			// read until reader done
+			for msg := range msgChan {
+				select {
+				case out <- msg:
+				case <-done:
+					return
+				}
+			}
+
+			log.With("db", session.db).With("changelog", t.changelog).Infoln("Listening for changes...")
+			interval := t.pollInterval
+			if t.listen {
+				interval = listenPollInterval
+			}
+			until, err := currentSnapshot(session.pqSession)
+			if err != nil {
+				log.With("db", session.db).Errorf("error reading snapshot %v", err)
+			}
+			var (
+				lastID     int64
+				windowRows bool
+			)
+			for {
+				select {
+				case <-done:
+					log.With("db", session.db).Infoln("tailing stopping...")
+					return
+				default:
+				}
+				msgs, next, err := t.readChanges(session, filter, since, until, lastID)
+				if err != nil {
+					log.With("db", session.db).Errorf("error reading changelog %v", err)
+				}
+				for _, msg := range msgs {
+					select {
+					case out <- msg.set:
+					case <-done:
+						log.With("db", session.db).Infoln("tailing stopping...")
+						return
+					}
+				}
+				if err == nil {
+					if next != lastID {
+						// keep draining the changelog while it has a backlog
+						lastID, windowRows = next, true
+						continue
+					}
+					// every change visible in until has been sent, the next window starts after it
+					since, lastID = until, 0
+					if until, err = currentSnapshot(session.pqSession); err != nil {
+						log.With("db", session.db).Errorf("error reading snapshot %v", err)
+					}
+					if windowRows {
+						windowRows = false
+						continue
+					}
+				}
+				select {
+				case <-done:
+					log.With("db", session.db).Infoln("tailing stopping...")
+					return
+				case <-notify:
+				case <-time.After(interval):
+				}
+			}
+		}()
+
+		return out, nil
+	}
+}
+
+// Ack implements client.Acker, the changelog rows of every committed message are deleted.
+func (t *TriggerTailer) Ack(msgs []client.MessageSet) error {
+	t.Lock()
+	var ids []int64
+	for _, msg := range msgs {
+		if id, ok := t.pending[msg.Msg]; ok {
+			ids = append(ids, id)
+			delete(t.pending, msg.Msg)
+		}
+	}
+	session := t.session
+	t.Unlock()
+	if len(ids) == 0 || session == nil {
+		return nil
+	}
+	log.With("changelog", t.changelog).With("count", len(ids)).Debugln("deleting committed changes")
+	_, err := session.pqSession.Exec(fmt.Sprintf("DELETE FROM %v WHERE id = ANY($1);", quoteNamespace(t.changelog)), pq.Array(ids))
+	return err
+}
+
+// deleteVisible deletes the changelog rows of every transaction visible in the snapshot.
+func (t *TriggerTailer) deleteVisible(s *Session, snapshot string) error {
+	_, err := s.pqSession.Exec(fmt.Sprintf("DELETE FROM %v WHERE txid_visible_in_snapshot(txid, $1::txid_snapshot);", quoteNamespace(t.changelog)), snapshot)
+	return err
+}
+
+func (t *TriggerTailer) channel() string {
+	_, table := splitNamespace(t.changelog)
+	return table
+}
+
+func (t *TriggerTailer) triggerName() string {
+	return t.channel()
+}
+
+// install creates the changelog table and trigger function and adds the trigger to every table
+// which passes the filter.
+func (t *TriggerTailer) install(s *Session, filterFn client.NsFilterFunc) error {
+	tx, err := s.pqSession.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range t.changelogStatements() {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	tables, err := baseTables(tx, filterFn)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		log.With("db", s.db).With("table", table).Infoln("installing changelog trigger")
+		for _, statement := range t.triggerStatements(table) {
+			if _, err := tx.Exec(statement); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// changelogStatements returns the statements creating the changelog table and the function called
+// by the triggers.
+func (t *TriggerTailer) changelogStatements() []string {
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %v (
+			id bigserial PRIMARY KEY,
+			txid bigint NOT NULL DEFAULT txid_current(),
+			tstamp timestamp with time zone NOT NULL DEFAULT now(),
+			namespace text NOT NULL,
+			op char(1) NOT NULL,
+			data jsonb NOT NULL,
+			old_data jsonb
+		);`, quoteNamespace(t.changelog)),
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION %[1]v() RETURNS trigger AS $$
+		BEGIN
+			IF TG_OP = 'DELETE' THEN
+				INSERT INTO %[2]v (namespace, op, data) VALUES (TG_TABLE_SCHEMA || '.' || TG_TABLE_NAME, 'd', to_jsonb(OLD));
+			ELSIF TG_OP = 'UPDATE' THEN
+				INSERT INTO %[2]v (namespace, op, data, old_data) VALUES (TG_TABLE_SCHEMA || '.' || TG_TABLE_NAME, 'u', to_jsonb(NEW), to_jsonb(OLD));
+			ELSE
+				INSERT INTO %[2]v (namespace, op, data) VALUES (TG_TABLE_SCHEMA || '.' || TG_TABLE_NAME, 'i', to_jsonb(NEW));
+			END IF;
+			PERFORM pg_notify('%[3]v', '');
+			RETURN NULL;
+		END;
+		$$ LANGUAGE plpgsql;`, t.function(), quoteNamespace(t.changelog), strings.Replace(t.channel(), "'", "''", -1)),
+	}
+}
+
+// triggerStatements returns the statements replacing the trigger on the table.
+func (t *TriggerTailer) triggerStatements(table string) []string {
+	return []string{
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %v ON %v;", pq.QuoteIdentifier(t.triggerName()), quoteNamespace(table)),
+		fmt.Sprintf(
+			"CREATE TRIGGER %v AFTER INSERT OR UPDATE OR DELETE ON %v FOR EACH ROW EXECUTE PROCEDURE %v();",
+			pq.QuoteIdentifier(t.triggerName()), quoteNamespace(table), t.function(),
+		),
+	}
+}
+
+// function returns the quoted name of the function which records changes in the changelog.
+func (t *TriggerTailer) function() string {
+	return quoteNamespace(t.changelog + "_fn")
+}
+
+// changelogMsg is a message read from the changelog along with the id of its row.
+type changelogMsg struct {
+	id  int64
+	set client.MessageSet
+}
+
+// readChanges returns the changes of up to batchSize rows after lastID, ordered by id, made by
+// transactions which are visible in the until snapshot but weren't in the since snapshot.
+// Reading changes in windows between snapshots orders them by commit, which ids alone don't since
+// they are assigned on insert. The id of the last row read is returned along with the changes.
+func (t *TriggerTailer) readChanges(s *Session, filterFn client.NsFilterFunc, since, until string, lastID int64) ([]changelogMsg, int64, error) {
+	var (
+		query = fmt.Sprintf(`SELECT id, extract(epoch FROM tstamp)::bigint, namespace, op, data, old_data
+			FROM %v
+			WHERE id > $1
+				AND txid_visible_in_snapshot(txid, $2::txid_snapshot)`, quoteNamespace(t.changelog))
+		args     = []interface{}{lastID, until}
+		result   []changelogMsg
+		filtered []int64
+	)
+	if since != "" {
+		query += " AND NOT txid_visible_in_snapshot(txid, $3::txid_snapshot)"
+		args = append(args, since)
+	}
+	rows, err := s.pqSession.Query(fmt.Sprintf("%v ORDER BY id LIMIT %d;", query, t.batchSize), args...)
+	if err != nil {
+		return result, lastID, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id, ts        int64
+			namespace, op string
+			d, oldData    []byte
+		)
+		if err := rows.Scan(&id, &ts, &namespace, &op, &d, &oldData); err != nil {
+			return result, lastID, err
+		}
+		lastID = id
+		if !filterFn(namespace) {
+			filtered = append(filtered, id)
+			continue
+		}
+		c, err := t.changelogChange(s, namespace, op, d, oldData)
+		if err != nil {
+			return result, lastID, err
+		}
+		log.With("op", c.op).With("table", c.namespace).With("id", id).Debugln("received")
+		for _, msg := range c.messages() {
+			result = append(result, changelogMsg{
+				id: id,
+				set: client.MessageSet{
+					Msg:       msg,
+					Timestamp: ts,
+					Mode:      commitlog.Sync,
+				},
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return result, lastID, err
+	}
+
+	// only the last message of each row is tracked, acks are in order so the earlier messages
+	// have been committed by the time it is acked
+	t.Lock()
+	for i, msg := range result {
+		if i == len(result)-1 || result[i+1].id != msg.id {
+			t.pending[msg.set.Msg] = msg.id
+		}
+	}
+	t.Unlock()
+	if len(filtered) > 0 {
+		// changes to tables outside the namespace filter are never sent, and so never acked
+		if _, err := s.pqSession.Exec(fmt.Sprintf("DELETE FROM %v WHERE id = ANY($1);", quoteNamespace(t.changelog)), pq.Array(filtered)); err != nil {
+			return result, lastID, err
+		}
+	}
+	return result, lastID, nil
+}
+
+func (t *TriggerTailer) changelogChange(s *Session, namespace, op string, d, oldData []byte) (change, error) {
+	c := change{namespace: namespace}
+	switch op {
+	case "i":
+		c.op = ops.Insert
+	case "u":
+		c.op = ops.Update
+	case "d":
+		c.op = ops.Delete
+	default:
+		return c, fmt.Errorf("unknown changelog operation %q", op)
+	}
+	var err error
+	if c.data, err = changelogData(d); err != nil {
+		return c, err
+	}
+	if oldData == nil {
+		return c, nil
+	}
+	old, err := changelogData(oldData)
+	if err != nil {
+		return c, err
+	}
+	pkeys, err := t.primaryKeys(s, namespace)
+	if err != nil {
+		return c, err
+	}
+	c.oldKeys = make(data.Data, len(pkeys))
+	for _, k := range pkeys {
+		c.oldKeys[k] = old.Get(k)
+	}
+	return c, nil
+}
+
+func (t *TriggerTailer) primaryKeys(s *Session, namespace string) ([]string, error) {
+	t.Lock()
+	pkeys, ok := t.pkeys[namespace]
+	t.Unlock()
+	if ok {
+		return pkeys, nil
+	}
+	pkeys, err := orderedPrimaryKeys(namespace, s.pqSession)
+	if err != nil {
+		return pkeys, err
+	}
+	t.Lock()
+	t.pkeys[namespace] = pkeys
+	t.Unlock()
+	return pkeys, nil
+}
+
+// changelogData decodes a row stored as jsonb, whole numbers are returned as int64 to match
+// the values produced when copying.
+func changelogData(b []byte) (data.Data, error) {
+	d := make(data.Data)
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&d); err != nil {
+		return nil, err
+	}
+	for k, v := range d {
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				d[k] = i
+			} else {
+				d[k], _ = n.Float64()
+			}
+		}
+	}
+	return d, nil
+}
+
+// baseTables returns every table, excluding views and system tables, which passes the filter.
+func baseTables(s execer, filterFn client.NsFilterFunc) ([]string, error) {
+	rows, err := s.Query("SELECT table_schema, table_name FROM information_schema.tables WHERE table_type = 'BASE TABLE';")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var schema, table string
+		if err := rows.Scan(&schema, &table); err != nil {
+			return nil, err
+		}
+		name := fmt.Sprintf("%s.%s", schema, table)
+		if matchFunc(name) && filterFn(name) {
+			tables = append(tables, name)
+		}
+	}
+	return tables, rows.Err()
+}
+
+// exportTransactionSnapshot starts a transaction and exports its snapshot, which stays
+// importable until the transaction ends. The txid snapshot identifies which transactions are
+// visible in it.
+func exportTransactionSnapshot(db *sql.DB) (*sql.Tx, string, string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, "", "", err
+	}
+	if _, err := tx.Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY"); err != nil {
+		tx.Rollback()
+		return nil, "", "", err
+	}
+	var name, txids string
+	if err := tx.QueryRow("SELECT pg_export_snapshot(), txid_current_snapshot()::text;").Scan(&name, &txids); err != nil {
+		tx.Rollback()
+		return nil, "", "", err
+	}
+	return tx, name, txids, nil
+}
+
+func currentSnapshot(db *sql.DB) (string, error) {
+	var txids string
+	err := db.QueryRow("SELECT txid_current_snapshot()::text;").Scan(&txids)
+	return txids, err
+}
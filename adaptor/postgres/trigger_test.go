@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/message/data"
+)
+
+var (
+	triggerTailerTestData   = &TestData{"trigger_tailer_test", "trigger_tailer_test_table", basicSchema, 10}
+	triggerSnapshotTestData = &TestData{"trigger_snapshot_test", "trigger_snapshot_test_table", basicSchema, 10}
+)
+
+func TestTriggerTailer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TriggerTailer in short mode")
+	}
+	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", triggerTailerTestData.DB)))
+	if err != nil {
+		t.Fatalf("unable to initialize connection to postgres, %s", err)
+	}
+	defer c.Close()
+	s, err := c.Connect()
+	if err != nil {
+		t.Fatalf("unable to obtain session to postgres, %s", err)
+	}
+	pqSession := s.(*Session).pqSession
+
+	r := newTriggerTailer(c.uri, "", true)
+	readFunc := r.Read(map[string]client.MessageSet{}, func(table string) bool {
+		return table == fmt.Sprintf("public.%s", triggerTailerTestData.Table)
+	})
+	done := make(chan struct{})
+	msgChan, err := readFunc(s, done)
+	if err != nil {
+		t.Fatalf("unexpected Read error, %s\n", err)
+	}
+	received := make(chan client.MessageSet)
+	var msgs []client.MessageSet
+	go func() {
+		for msg := range msgChan {
+			msgs = append(msgs, msg)
+			received <- msg
+		}
+		close(received)
+	}()
+	checkCount("initial drain", triggerTailerTestData.InsertCount, received, t)
+
+	for i := 10; i < 15; i++ {
+		pqSession.Exec(fmt.Sprintf(`INSERT INTO %s VALUES (%d, '%s', now() at time zone 'utc');`,
+			triggerTailerTestData.Table, i, randomHeros[i%len(randomHeros)]))
+	}
+	checkCount("tailed data", 5, received, t)
+
+	for i := 10; i < 15; i++ {
+		pqSession.Exec(fmt.Sprintf("UPDATE %s SET colvar = 'hello' WHERE id = %d;", triggerTailerTestData.Table, i))
+	}
+	checkCount("updated data", 5, received, t)
+
+	for i := 10; i < 15; i++ {
+		pqSession.Exec(fmt.Sprintf("DELETE FROM %v WHERE id = %d;", triggerTailerTestData.Table, i))
+	}
+	checkCount("deleted data", 5, received, t)
+
+	if err := r.(client.Acker).Ack(msgs); err != nil {
+		t.Errorf("unexpected Ack error, %s", err)
+	}
+	var count int
+	if err := pqSession.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s;", defaultChangelogTable)).Scan(&count); err != nil {
+		t.Errorf("unable to count changelog, %s", err)
+	}
+	if count != 0 {
+		t.Errorf("acked changes were not deleted, %d remaining", count)
+	}
+	close(done)
+}
+
+func TestTriggerTailerSnapshot(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping TriggerTailerSnapshot in short mode")
+	}
+	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", triggerSnapshotTestData.DB)))
+	if err != nil {
+		t.Fatalf("unable to initialize connection to postgres, %s", err)
+	}
+	defer c.Close()
+	s, err := c.Connect()
+	if err != nil {
+		t.Fatalf("unable to obtain session to postgres, %s", err)
+	}
+	pqSession := s.(*Session).pqSession
+
+	r := newTriggerTailer(c.uri, "", false).(*TriggerTailer)
+	filter := func(table string) bool {
+		return table == fmt.Sprintf("public.%s", triggerSnapshotTestData.Table)
+	}
+	// changes committed once the triggers are installed but before the snapshot is exported are
+	// part of the copy
+	if err := r.install(s.(*Session), filter); err != nil {
+		t.Fatalf("unexpected install error, %s", err)
+	}
+	for i := 10; i < 15; i++ {
+		pqSession.Exec(fmt.Sprintf(`INSERT INTO %s VALUES (%d, '%s', now() at time zone 'utc');`,
+			triggerSnapshotTestData.Table, i, randomHeros[i%len(randomHeros)]))
+	}
+	done := make(chan struct{})
+	defer close(done)
+	msgChan, err := r.Read(map[string]client.MessageSet{}, filter)(s, done)
+	if err != nil {
+		t.Fatalf("unexpected Read error, %s\n", err)
+	}
+	checkCount("initial drain", triggerSnapshotTestData.InsertCount+5, msgChan, t)
+
+	var count int
+	if err := pqSession.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s;", defaultChangelogTable)).Scan(&count); err != nil {
+		t.Errorf("unable to count changelog, %s", err)
+	}
+	if count != 0 {
+		t.Errorf("changes copied from the snapshot were left in the changelog, %d remaining", count)
+	}
+}
+
+func TestChangelogData(t *testing.T) {
+	d, err := changelogData([]byte(`{"id": 1, "price": 1.5, "name": "batman", "tags": ["a"], "deleted": null}`))
+	if err != nil {
+		t.Fatalf("unexpected changelogData error, %s", err)
+	}
+	expected := data.Data{"id": int64(1), "price": 1.5, "name": "batman", "tags": []interface{}{"a"}, "deleted": nil}
+	if !reflect.DeepEqual(d, expected) {
+		t.Errorf("wrong data, expected %+v, got %+v", expected, d)
+	}
+}
+
+func TestTriggerStatementsQuoted(t *testing.T) {
+	r := newTriggerTailer("", `Audit.change"log`, false).(*TriggerTailer)
+	statements := append(r.changelogStatements(), r.triggerStatements("public.Heroes")...)
+	for i, expected := range []string{
+		`CREATE TABLE IF NOT EXISTS "Audit"."change""log" (`,
+		`CREATE OR REPLACE FUNCTION "Audit"."change""log_fn"() RETURNS trigger`,
+		`DROP TRIGGER IF EXISTS "change""log" ON "public"."Heroes";`,
+		`CREATE TRIGGER "change""log" AFTER INSERT OR UPDATE OR DELETE ON "public"."Heroes" FOR EACH ROW EXECUTE PROCEDURE "Audit"."change""log_fn"();`,
+	} {
+		if !strings.HasPrefix(statements[i], expected) {
+			t.Errorf("[%d] wrong statement, expected %s, got %s", i, expected, statements[i])
+		}
+	}
+	if !strings.Contains(statements[1], `INSERT INTO "Audit"."change""log" (namespace`) {
+		t.Errorf("function doesn't insert into the quoted changelog, %s", statements[1])
+	}
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
+)
+
+// wal2jsonDecoder parses the output of the wal2json plugin (format-version 1) which emits a
+// single JSON document for every transaction.
+type wal2jsonDecoder struct{}
+
+type wal2jsonTransaction struct {
+	Xid       uint32           `json:"xid"`
+	Timestamp string           `json:"timestamp"`
+	Change    []wal2jsonChange `json:"change"`
+}
+
+type wal2jsonChange struct {
+	Kind         string        `json:"kind"`
+	Schema       string        `json:"schema"`
+	Table        string        `json:"table"`
+	ColumnNames  []string      `json:"columnnames"`
+	ColumnTypes  []string      `json:"columntypes"`
+	ColumnValues []interface{} `json:"columnvalues"`
+	OldKeys      *struct {
+		KeyNames  []string      `json:"keynames"`
+		KeyTypes  []string      `json:"keytypes"`
+		KeyValues []interface{} `json:"keyvalues"`
+	} `json:"oldkeys"`
+}
+
+func (w *wal2jsonDecoder) plugin() string {
+	return wal2jsonPlugin
+}
+
+func (w *wal2jsonDecoder) options() []string {
+	return pluginOptions("include-xids", "1", "include-timestamp", "1", "include-types", "1")
+}
+
+func (w *wal2jsonDecoder) decode(b []byte) ([]transaction, error) {
+	var wt wal2jsonTransaction
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&wt); err != nil {
+		return nil, err
+	}
+
+	txn := transaction{xid: wt.Xid, timestamp: time.Now().Unix()}
+	if ts, err := parseTimestamp(wt.Timestamp); err == nil {
+		txn.timestamp = ts.Unix()
+	}
+	for _, wc := range wt.Change {
+		c := change{namespace: fmt.Sprintf("%v.%v", wc.Schema, wc.Table)}
+		switch wc.Kind {
+		case "insert":
+			c.op = ops.Insert
+		case "update":
+			c.op = ops.Update
+		case "delete":
+			c.op = ops.Delete
+		default:
+			continue
+		}
+		c.data = wal2jsonData(wc.ColumnNames, wc.ColumnTypes, wc.ColumnValues)
+		if wc.OldKeys != nil {
+			c.oldKeys = wal2jsonData(wc.OldKeys.KeyNames, wc.OldKeys.KeyTypes, wc.OldKeys.KeyValues)
+		}
+		txn.changes = append(txn.changes, c)
+	}
+	return []transaction{txn}, nil
+}
+
+func wal2jsonData(names, types []string, values []interface{}) data.Data {
+	d := make(data.Data, len(names))
+	for i, name := range names {
+		if i >= len(values) {
+			break
+		}
+		var valueType string
+		if i < len(types) {
+			valueType = types[i]
+		}
+		switch v := values[i].(type) {
+		case json.Number:
+			d[name] = typedValue(v.String(), valueType)
+		case string:
+			d[name] = typedValue(v, valueType)
+		default:
+			// booleans and nulls are already typed
+			d[name] = v
+		}
+	}
+	return d
+}
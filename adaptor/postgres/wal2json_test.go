@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
+)
+
+var wal2jsonTests = []struct {
+	name     string
+	in       string
+	expected transaction
+}{
+	{
+		"insert",
+		`{"xid":580,"timestamp":"2017-03-29 17:49:53.453419+00","change":[{"kind":"insert","schema":"public","table":"heros","columnnames":["id","name","powers","since"],"columntypes":["integer","character varying(255)","text[]","timestamp without time zone"],"columnvalues":[1,"Wonder Woman","{flight,\"lasso of truth\"}","1941-10-21 00:00:00"]}]}`,
+		transaction{
+			xid:       580,
+			timestamp: time.Date(2017, 3, 29, 17, 49, 53, 0, time.UTC).Unix(),
+			changes: []change{
+				{
+					op:        ops.Insert,
+					namespace: "public.heros",
+					data: data.Data{
+						"id":     int64(1),
+						"name":   "Wonder Woman",
+						"powers": []interface{}{"flight", "lasso of truth"},
+						"since":  time.Date(1941, 10, 21, 0, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+	},
+	{
+		"update_and_delete",
+		`{"xid":581,"timestamp":"2017-03-29 17:49:54+00","change":[{"kind":"update","schema":"public","table":"heros","columnnames":["id","name","active"],"columntypes":["integer","text","boolean"],"columnvalues":[2,"it's a bird",true],"oldkeys":{"keynames":["id"],"keytypes":["integer"],"keyvalues":[1]}},{"kind":"delete","schema":"public","table":"heros","oldkeys":{"keynames":["id"],"keytypes":["integer"],"keyvalues":[2]}}]}`,
+		transaction{
+			xid:       581,
+			timestamp: time.Date(2017, 3, 29, 17, 49, 54, 0, time.UTC).Unix(),
+			changes: []change{
+				{
+					op:        ops.Update,
+					namespace: "public.heros",
+					data:      data.Data{"id": int64(2), "name": "it's a bird", "active": true},
+					oldKeys:   data.Data{"id": int64(1)},
+				},
+				{
+					op:        ops.Delete,
+					namespace: "public.heros",
+					data:      data.Data{},
+					oldKeys:   data.Data{"id": int64(2)},
+				},
+			},
+		},
+	},
+}
+
+func TestWal2jsonDecode(t *testing.T) {
+	for _, wt := range wal2jsonTests {
+		d := &wal2jsonDecoder{}
+		txns, err := d.decode([]byte(wt.in))
+		if err != nil {
+			t.Fatalf("[%s] unexpected decode error, %s", wt.name, err)
+		}
+		if len(txns) != 1 {
+			t.Fatalf("[%s] wrong number of transactions, expected 1, got %d", wt.name, len(txns))
+		}
+		if !reflect.DeepEqual(txns[0], wt.expected) {
+			t.Errorf("[%s] bad transaction, expected %+v, got %+v", wt.name, wt.expected, txns[0])
+		}
+	}
+}
+
+func TestChangeMessages(t *testing.T) {
+	c := wal2jsonTests[1].expected.changes[0]
+	msgs := c.messages()
+	if len(msgs) != 2 {
+		t.Fatalf("wrong number of messages, expected 2, got %d", len(msgs))
+	}
+	if msgs[0].OP() != ops.Delete || !reflect.DeepEqual(msgs[0].Data(), c.oldKeys) {
+		t.Errorf("bad delete message, got %s %+v", msgs[0].OP(), msgs[0].Data())
+	}
+	if msgs[1].OP() != ops.Insert || !reflect.DeepEqual(msgs[1].Data(), c.data) {
+		t.Errorf("bad insert message, got %s %+v", msgs[1].OP(), msgs[1].Data())
+	}
+}
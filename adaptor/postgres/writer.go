@@ -1,37 +1,106 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/compose/mejson"
 	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/events"
 	"github.com/compose/transporter/log"
 	"github.com/compose/transporter/message"
 	"github.com/compose/transporter/message/ops"
+	"github.com/lib/pq"
+	"gopkg.in/mgo.v2/bson"
 )
 
-var _ client.Writer = &Writer{}
+var (
+	_ client.Writer        = &Writer{}
+	_ client.ContextWriter = &Writer{}
+	_ client.EventEmitter  = &Writer{}
+)
+
+// execer is satisfied by both *sql.DB and *sql.Tx so messages can be written directly or as
+// part of a batch.
+type execer interface {
+	Exec(string, ...interface{}) (sql.Result, error)
+	Query(string, ...interface{}) (*sql.Rows, error)
+	Prepare(string) (*sql.Stmt, error)
+}
+
+// contextDB is satisfied by both *sql.DB and *sql.Tx.
+type contextDB interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	PrepareContext(context.Context, string) (*sql.Stmt, error)
+}
+
+// contextExecer is an execer whose statements are aborted once ctx is done.
+type contextExecer struct {
+	ctx context.Context
+	db  contextDB
+}
+
+func (e contextExecer) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return e.db.ExecContext(e.ctx, query, args...)
+}
+
+func (e contextExecer) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return e.db.QueryContext(e.ctx, query, args...)
+}
+
+func (e contextExecer) Prepare(query string) (*sql.Stmt, error) {
+	return e.db.PrepareContext(e.ctx, query)
+}
 
 // Writer implements client.Writer for use with MongoDB
 type Writer struct {
-	writeMap map[ops.Op]func(message.Msg, *sql.DB) error
+	writeMap    map[ops.Op]func(message.Msg, execer) error
+	upsert      bool
+	autoSchema  bool
+	childTables map[string][]ChildTable
+
+	pkCache map[string]map[string]bool
+	pkLock  sync.Mutex
+
+	columnCache map[string]map[string]string
+	schemaLock  sync.Mutex
+	emit        func(events.Event)
 }
 
-func newWriter() *Writer {
-	w := &Writer{}
-	w.writeMap = map[ops.Op]func(message.Msg, *sql.DB) error{
-		ops.Insert: insertMsg,
-		ops.Update: updateMsg,
-		ops.Delete: deleteMsg,
+func newWriter(upsert, autoSchema bool) *Writer {
+	w := &Writer{
+		upsert:      upsert,
+		autoSchema:  autoSchema,
+		pkCache:     make(map[string]map[string]bool),
+		columnCache: make(map[string]map[string]string),
+	}
+	w.writeMap = map[ops.Op]func(message.Msg, execer) error{
+		ops.Insert: w.insertMsg,
+		ops.Update: w.updateMsg,
+		ops.Delete: w.deleteMsg,
 	}
 	return w
 }
 
 func (w *Writer) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	write := w.WriteContext(msg)
 	return func(s client.Session) (message.Msg, error) {
+		return write(context.Background(), s)
+	}
+}
+
+// WriteContext writes msg, aborting the statements being run once ctx is done.
+func (w *Writer) WriteContext(msg message.Msg) func(context.Context, client.Session) (message.Msg, error) {
+	return func(ctx context.Context, s client.Session) (message.Msg, error) {
 		writeFunc, ok := w.writeMap[msg.OP()]
 		if !ok {
 			log.Infof("no function registered for operation, %s", msg.OP())
@@ -40,7 +109,12 @@ func (w *Writer) Write(msg message.Msg) func(client.Session) (message.Msg, error
 			}
 			return msg, nil
 		}
-		if err := writeFunc(msg, s.(*Session).pqSession); err != nil {
+		if _, ok := w.childTables[msg.Namespace()]; ok {
+			// a document and its child rows are written in one transaction
+			if err := w.writeTx(ctx, msg, s.(*Session).pqSession); err != nil {
+				return nil, err
+			}
+		} else if err := writeFunc(msg, contextExecer{ctx, s.(*Session).pqSession}); err != nil {
 			return nil, err
 		}
 		if msg.Confirms() != nil {
@@ -50,144 +124,309 @@ func (w *Writer) Write(msg message.Msg) func(client.Session) (message.Msg, error
 	}
 }
 
-func insertMsg(m message.Msg, s *sql.DB) error {
+func (w *Writer) writeTx(ctx context.Context, m message.Msg, db *sql.DB) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := w.writeMsg(m, contextExecer{ctx, tx}); err != nil {
+		tx.Rollback()
+		w.resetSchema()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		w.resetSchema()
+		return err
+	}
+	return nil
+}
+
+func (w *Writer) insertMsg(m message.Msg, s execer) error {
 	log.With("table", m.Namespace()).Debugln("INSERT")
+	if w.autoSchema {
+		if err := w.ensureSchema(m, s); err != nil {
+			return err
+		}
+	}
+	columnTypes, err := w.columnTypes(m.Namespace(), s)
+	if err != nil {
+		return err
+	}
 	var (
-		keys         []string
+		keys         = sortedKeys(m)
 		placeholders []string
 		data         []interface{}
 	)
 
-	i := 1
-	for key, value := range m.Data() {
-		keys = append(keys, key)
-		placeholders = append(placeholders, fmt.Sprintf("$%v", i))
-
-		switch value.(type) {
-		case map[string]interface{}, mejson.M, []map[string]interface{}, mejson.S:
-			value, _ = json.Marshal(value)
-		case []interface{}:
-			value, _ = json.Marshal(value)
-			value = string(value.([]byte))
-			value = fmt.Sprintf("{%v}", value.(string)[1:len(value.(string))-1])
-		}
-		data = append(data, value)
-
-		i = i + 1
+	for i, key := range keys {
+		placeholders = append(placeholders, fmt.Sprintf("$%v", i+1))
+		data = append(data, columnValue(columnTypes[key], m.Data().Get(key)))
 	}
 
-	query := fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v);", m.Namespace(), strings.Join(keys, ", "), strings.Join(placeholders, ", "))
-	_, err := s.Exec(query, data...)
+	query := fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v)", quoteNamespace(m.Namespace()), strings.Join(quoteIdentifiers(keys), ", "), strings.Join(placeholders, ", "))
+	if w.upsert {
+		conflict, err := w.onConflict(m.Namespace(), keys, s)
+		if err != nil {
+			return err
+		}
+		query += conflict
+	}
+	_, err = s.Exec(query+";", data...)
 	return err
 }
 
-func deleteMsg(m message.Msg, s *sql.DB) error {
+// onConflict builds the ON CONFLICT clause needed to turn an insert of the provided columns into
+// an upsert, tables without a primary key can't conflict so an empty clause is returned.
+func (w *Writer) onConflict(namespace string, columns []string, s execer) (string, error) {
+	pkeys, err := w.primaryKeys(namespace, s)
+	if err != nil || len(pkeys) == 0 {
+		return "", err
+	}
+	var (
+		conflictKeys []string
+		updates      []string
+	)
+	for key := range pkeys {
+		conflictKeys = append(conflictKeys, key)
+	}
+	sort.Strings(conflictKeys)
+	for _, column := range columns {
+		if !pkeys[column] {
+			updates = append(updates, fmt.Sprintf("%v = EXCLUDED.%v", pq.QuoteIdentifier(column), pq.QuoteIdentifier(column)))
+		}
+	}
+	conflictColumns := strings.Join(quoteIdentifiers(conflictKeys), ", ")
+	if len(updates) == 0 {
+		return fmt.Sprintf(" ON CONFLICT (%v) DO NOTHING", conflictColumns), nil
+	}
+	return fmt.Sprintf(" ON CONFLICT (%v) DO UPDATE SET %v", conflictColumns, strings.Join(updates, ", ")), nil
+}
+
+func (w *Writer) deleteMsg(m message.Msg, s execer) error {
 	log.With("table", m.Namespace()).With("values", m.Data()).Debugln("DELETE")
 	var (
 		ckeys []string
 		vals  []interface{}
 	)
-	pkeys, err := primaryKeys(m.Namespace(), s)
+	pkeys, err := w.primaryKeys(m.Namespace(), s)
 	if err != nil {
 		return err
 	}
-	i := 1
-	for key, value := range m.Data() {
+	columnTypes, err := w.columnTypes(m.Namespace(), s)
+	if err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(m) {
 		if pkeys[key] { // key is primary key
-			ckeys = append(ckeys, fmt.Sprintf("%v = $%v", key, i))
+			vals = append(vals, columnValue(columnTypes[key], m.Data().Get(key)))
+			ckeys = append(ckeys, fmt.Sprintf("%v = $%v", pq.QuoteIdentifier(key), len(vals)))
 		}
-		switch value.(type) {
-		case map[string]interface{}, mejson.M, []map[string]interface{}, mejson.S:
-			value, _ = json.Marshal(value)
-		case []interface{}:
-			value, _ = json.Marshal(value)
-			value = string(value.([]byte))
-			value = fmt.Sprintf("{%v}", value.(string)[1:len(value.(string))-1])
-		}
-		vals = append(vals, value)
-		i = i + 1
 	}
 
 	if len(pkeys) != len(ckeys) {
 		return fmt.Errorf("All primary keys were not accounted for. Provided: %v; Required; %v", ckeys, pkeys)
 	}
 
-	query := fmt.Sprintf("DELETE FROM %v WHERE %v;", m.Namespace(), strings.Join(ckeys, " AND "))
+	query := fmt.Sprintf("DELETE FROM %v WHERE %v;", quoteNamespace(m.Namespace()), strings.Join(ckeys, " AND "))
 	_, err = s.Exec(query, vals...)
 	return err
 }
 
-func updateMsg(m message.Msg, s *sql.DB) error {
+func (w *Writer) updateMsg(m message.Msg, s execer) error {
+	if w.upsert {
+		return w.insertMsg(m, s)
+	}
 	log.With("table", m.Namespace()).Debugln("UPDATE")
+	if w.autoSchema {
+		if err := w.ensureSchema(m, s); err != nil {
+			return err
+		}
+	}
 	var (
 		ckeys []string
 		ukeys []string
 		vals  []interface{}
 	)
 
-	pkeys, err := primaryKeys(m.Namespace(), s)
+	pkeys, err := w.primaryKeys(m.Namespace(), s)
+	if err != nil {
+		return err
+	}
+	columnTypes, err := w.columnTypes(m.Namespace(), s)
 	if err != nil {
 		return err
 	}
 
-	i := 1
-	for key, value := range m.Data() {
+	for i, key := range sortedKeys(m) {
 		if pkeys[key] { // key is primary key
-			ckeys = append(ckeys, fmt.Sprintf("%v=$%v", key, i))
+			ckeys = append(ckeys, fmt.Sprintf("%v=$%v", pq.QuoteIdentifier(key), i+1))
 		} else {
-			ukeys = append(ukeys, fmt.Sprintf("%v=$%v", key, i))
-		}
-
-		switch value.(type) {
-		case map[string]interface{}, mejson.M, []map[string]interface{}, mejson.S:
-			value, _ = json.Marshal(value)
-		case []interface{}:
-			value, _ = json.Marshal(value)
-			value = string(value.([]byte))
-			value = fmt.Sprintf("{%v}", value.(string)[1:len(value.(string))-1])
+			ukeys = append(ukeys, fmt.Sprintf("%v=$%v", pq.QuoteIdentifier(key), i+1))
 		}
-		vals = append(vals, value)
-		i = i + 1
+		vals = append(vals, columnValue(columnTypes[key], m.Data().Get(key)))
 	}
 
 	if len(pkeys) != len(ckeys) {
 		return fmt.Errorf("All primary keys were not accounted for. Provided: %v; Required; %v", ckeys, pkeys)
 	}
 
-	query := fmt.Sprintf("UPDATE %v SET %v WHERE %v;", m.Namespace(), strings.Join(ukeys, ", "), strings.Join(ckeys, " AND "))
+	query := fmt.Sprintf("UPDATE %v SET %v WHERE %v;", quoteNamespace(m.Namespace()), strings.Join(ukeys, ", "), strings.Join(ckeys, " AND "))
 	_, err = s.Exec(query, vals...)
 	return err
 }
 
-func primaryKeys(namespace string, db *sql.DB) (primaryKeys map[string]bool, err error) {
-	primaryKeys = map[string]bool{}
+// sortedKeys returns the document keys in a stable order so the generated statements, and the
+// columns used for COPY, are identical for documents with the same shape.
+func sortedKeys(m message.Msg) []string {
+	keys := make([]string, 0, len(m.Data()))
+	for key := range m.Data() {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// prepareValue converts a document value into a statement parameter. ObjectIds are written as
+// their hex string, arrays of scalars as Postgres arrays and nested documents, or arrays which
+// contain them, as JSON.
+func prepareValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.ObjectId:
+		return v.Hex()
+	case nil, string, []byte, time.Time, driver.Valuer:
+		return value
+	case map[string]interface{}, bson.M, mejson.M, []map[string]interface{}:
+		return jsonValue(value)
+	case []interface{}:
+		return arrayValue(v)
+	case mejson.S:
+		return arrayValue(v)
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		elems := make([]interface{}, rv.Len())
+		for i := range elems {
+			elems[i] = rv.Index(i).Interface()
+		}
+		return arrayValue(elems)
+	case reflect.Map, reflect.Struct:
+		return jsonValue(value)
+	}
+	return value
+}
+
+// arrayValue returns a Postgres array parameter when every element is a scalar, otherwise the
+// array is written as JSON.
+func arrayValue(a []interface{}) interface{} {
+	elems := make([]interface{}, len(a))
+	for i, e := range a {
+		switch v := e.(type) {
+		case nil, string, bool, json.Number:
+			elems[i] = v
+		case bson.ObjectId:
+			elems[i] = v.Hex()
+		case time.Time:
+			elems[i] = v.Format(time.RFC3339Nano)
+		default:
+			switch reflect.ValueOf(e).Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+				reflect.Float32, reflect.Float64:
+				elems[i] = v
+			default:
+				return jsonValue(a)
+			}
+		}
+	}
+	return pq.Array(elems)
+}
+
+// jsonValue marshals the value to JSON, it's returned as a string since COPY would otherwise
+// encode it as bytea.
+func jsonValue(value interface{}) interface{} {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	return string(b)
+}
+
+// columnValue converts the value for use as a statement parameter given the data type of the
+// column it's written to, anything written to a json or jsonb column is marshalled to JSON.
+func columnValue(columnType string, value interface{}) interface{} {
+	if value == nil || (columnType != "json" && columnType != "jsonb") {
+		return prepareValue(value)
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return jsonValue(value)
+}
+
+// quoteNamespace quotes the schema and table of the namespace so names which aren't lower case,
+// or aren't valid identifiers, are used as is.
+func quoteNamespace(namespace string) string {
+	schema, table := splitNamespace(namespace)
+	return pq.QuoteIdentifier(schema) + "." + pq.QuoteIdentifier(table)
+}
+
+func quoteIdentifiers(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = pq.QuoteIdentifier(name)
+	}
+	return quoted
+}
+
+// primaryKeys returns the primary key columns of the table, the result is cached for the
+// lifetime of the Writer.
+func (w *Writer) primaryKeys(namespace string, db execer) (map[string]bool, error) {
+	w.pkLock.Lock()
+	defer w.pkLock.Unlock()
+	if pkeys, ok := w.pkCache[namespace]; ok {
+		return pkeys, nil
+	}
+	pkeys, err := primaryKeys(namespace, db)
+	if err != nil {
+		return pkeys, err
+	}
+	w.pkCache[namespace] = pkeys
+	return pkeys, nil
+}
+
+// splitNamespace returns the schema and table of a namespace, namespaces without a schema are
+// assumed to be in the public schema.
+func splitNamespace(namespace string) (string, string) {
 	namespaceArray := strings.SplitN(namespace, ".", 2)
-	var (
-		tableSchema string
-		tableName   string
-		columnName  string
-	)
-	if namespaceArray[1] == "" {
-		tableSchema = "public"
-		tableName = namespaceArray[0]
-	} else {
-		tableSchema = namespaceArray[0]
-		tableName = namespaceArray[1]
+	if len(namespaceArray) == 1 || namespaceArray[1] == "" {
+		return "public", namespaceArray[0]
 	}
+	return namespaceArray[0], namespaceArray[1]
+}
+
+func primaryKeys(namespace string, db execer) (primaryKeys map[string]bool, err error) {
+	primaryKeys = map[string]bool{}
+	var columnName string
+	tableSchema, tableName := splitNamespace(namespace)
 
-	tablesResult, err := db.Query(fmt.Sprintf(`
+	tablesResult, err := db.Query(`
 		SELECT
 			column_name
 		FROM information_schema.table_constraints constraints
 			INNER JOIN information_schema.constraint_column_usage column_map
 				ON column_map.constraint_name = constraints.constraint_name
 		WHERE constraints.constraint_type = 'PRIMARY KEY'
-			AND constraints.table_schema = '%v'
-			AND constraints.table_name = '%v'
-	`, tableSchema, tableName))
+			AND constraints.table_schema = $1
+			AND constraints.table_name = $2
+	`, tableSchema, tableName)
 	if err != nil {
 		return primaryKeys, err
 	}
+	defer tablesResult.Close()
 
 	for tablesResult.Next() {
 		err = tablesResult.Scan(&columnName)
@@ -1,15 +1,19 @@
 package postgres
 
 import (
+	"database/sql/driver"
 	"fmt"
 	"math/rand"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/compose/mejson"
 	"github.com/compose/transporter/adaptor"
 	"github.com/compose/transporter/message"
 	"github.com/compose/transporter/message/data"
 	"github.com/compose/transporter/message/ops"
+	"gopkg.in/mgo.v2/bson"
 )
 
 var optests = []struct {
@@ -24,7 +28,7 @@ var optests = []struct {
 }
 
 func TestOpFunc(t *testing.T) {
-	w := newWriter()
+	w := newWriter(false, false)
 	for _, ot := range optests {
 		if _, ok := w.writeMap[ot.op]; ok != ot.registered {
 			t.Errorf("op (%s) registration incorrect, expected %+v, got %+v\n", ot.op.String(), ot.registered, ok)
@@ -32,6 +36,67 @@ func TestOpFunc(t *testing.T) {
 	}
 }
 
+var quoteNamespaceTests = []struct {
+	namespace string
+	expected  string
+}{
+	{"heroes", `"public"."heroes"`},
+	{"public.heroes", `"public"."heroes"`},
+	{"Gotham.Heroes", `"Gotham"."Heroes"`},
+	{`public.heroes"; DROP TABLE heroes; --`, `"public"."heroes""; DROP TABLE heroes; --"`},
+}
+
+func TestQuoteNamespace(t *testing.T) {
+	for _, qt := range quoteNamespaceTests {
+		if got := quoteNamespace(qt.namespace); got != qt.expected {
+			t.Errorf("wrong quoted namespace for %s, expected %s, got %s", qt.namespace, qt.expected, got)
+		}
+	}
+}
+
+var (
+	objectID = bson.ObjectIdHex("5a0b1a3e9fd1e0f1e0a1b2c3")
+	now      = time.Date(2017, time.November, 14, 16, 30, 0, 0, time.UTC)
+)
+
+var prepareValueTests = []struct {
+	columnType string
+	value      interface{}
+	expected   driver.Value
+}{
+	{"text", nil, nil},
+	{"text", "batman", "batman"},
+	{"text", objectID, "5a0b1a3e9fd1e0f1e0a1b2c3"},
+	{"timestamp with time zone", now, now},
+	{"bigint", int64(10), int64(10)},
+	{"ARRAY", []interface{}{1, 2, 3, 4}, "{1,2,3,4}"},
+	{"ARRAY", []interface{}{"o,ne", `t"wo`, nil}, `{"o,ne","t\"wo",NULL}`},
+	{"ARRAY", []string{"one", "two"}, `{"one","two"}`},
+	{"ARRAY", mejson.S{objectID, now}, `{"5a0b1a3e9fd1e0f1e0a1b2c3","2017-11-14T16:30:00Z"}`},
+	{"jsonb", map[string]interface{}{"name": "batman"}, `{"name":"batman"}`},
+	{"json", []map[string]interface{}{{"name": "batman"}}, `[{"name":"batman"}]`},
+	{"jsonb", []interface{}{1, "two"}, `[1,"two"]`},
+	{"jsonb", `{"name":"batman"}`, `{"name":"batman"}`},
+	{"text", bson.M{"name": "batman"}, `{"name":"batman"}`},
+	{"", []interface{}{map[string]interface{}{"name": "batman"}}, `[{"name":"batman"}]`},
+}
+
+func TestColumnValue(t *testing.T) {
+	for _, pt := range prepareValueTests {
+		got := columnValue(pt.columnType, pt.value)
+		if v, ok := got.(driver.Valuer); ok {
+			var err error
+			if got, err = v.Value(); err != nil {
+				t.Errorf("unexpected Value error for %v, %s", pt.value, err)
+				continue
+			}
+		}
+		if !reflect.DeepEqual(got, pt.expected) {
+			t.Errorf("wrong value for %T written to %s column, expected %v, got %v", pt.value, pt.columnType, pt.expected, got)
+		}
+	}
+}
+
 var (
 	writerTestData = &TestData{"writer_insert_test", "simple_test_table", basicSchema, 0}
 )
@@ -39,7 +104,7 @@ var (
 func TestInsert(t *testing.T) {
 	confirms, cleanup := adaptor.MockConfirmWrites()
 	defer adaptor.VerifyWriteConfirmed(cleanup, t)
-	w := newWriter()
+	w := newWriter(false, false)
 	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", writerTestData.DB)))
 	if err != nil {
 		t.Fatalf("unable to initialize connection to postgres, %s", err)
@@ -113,7 +178,7 @@ var (
 )
 
 func TestComplexInsert(t *testing.T) {
-	w := newWriter()
+	w := newWriter(false, false)
 	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", writerComplexTestData.DB)))
 	if err != nil {
 		t.Fatalf("unable to initialize connection to postgres, %s", err)
@@ -199,7 +264,7 @@ var (
 )
 
 func TestUpdate(t *testing.T) {
-	w := newWriter()
+	w := newWriter(false, false)
 	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", writerUpdateTestData.DB)))
 	if err != nil {
 		t.Fatalf("unable to initialize connection to postgres, %s", err)
@@ -258,7 +323,7 @@ var (
 
 func TestComplexUpdate(t *testing.T) {
 	ranInt := rand.Intn(writerComplexUpdateTestData.InsertCount)
-	w := newWriter()
+	w := newWriter(false, false)
 	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", writerComplexUpdateTestData.DB)))
 	if err != nil {
 		t.Fatalf("unable to initialize connection to postgres, %s", err)
@@ -343,7 +408,7 @@ var (
 )
 
 func TestDelete(t *testing.T) {
-	w := newWriter()
+	w := newWriter(false, false)
 	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", writerDeleteTestData.DB)))
 	if err != nil {
 		t.Fatalf("unable to initialize connection to postgres, %s", err)
@@ -392,7 +457,7 @@ var (
 
 func TestComplexDelete(t *testing.T) {
 	ranInt := rand.Intn(writerComplexDeleteTestData.InsertCount)
-	w := newWriter()
+	w := newWriter(false, false)
 	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", writerComplexDeleteTestData.DB)))
 	if err != nil {
 		t.Fatalf("unable to initialize connection to postgres, %s", err)
@@ -424,7 +489,7 @@ var (
 
 func TestComplexDeleteWithoutAllPrimarykeys(t *testing.T) {
 	ranInt := rand.Intn(writerComplexDeletePkTestData.InsertCount)
-	w := newWriter()
+	w := newWriter(false, false)
 	c, err := NewClient(WithURI(fmt.Sprintf("postgres://127.0.0.1:5432/%s?sslmode=disable", writerComplexDeletePkTestData.DB)))
 	if err != nil {
 		t.Fatalf("unable to initialize connection to postgres, %s", err)
@@ -21,7 +21,7 @@ var (
 	defaultSession *Session
 
 	queuesToTest = []TestData{
-		readerTestData, readerBadDataTest,
+		readerTestData, readerBadDataTest, readerQueuesTestData,
 		writerTestData, writerTestData2, writerTestData3,
 	}
 )
@@ -5,6 +5,12 @@ import (
 
 	"github.com/compose/transporter/adaptor"
 	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/message/codec"
+)
+
+const (
+	// DefaultCodec is used to encode and decode message bodies when no codec is configured.
+	DefaultCodec = "json"
 )
 
 const (
@@ -12,8 +18,24 @@ const (
   "uri": "${RABBITMQ_URI}",
   "routing_key": "",
   "key_in_field": false
+  // "codec": "json", // json, bson, msgpack, raw or protobuf
+  // "detect_content_type": false, // decode deliveries with the codec for their content type
+  // "proto_descriptor": "/path/to/descriptor.pb", // protoc --include_imports --descriptor_set_out
+  // "proto_message": "package.Message",
+  // "confirm": false, // wait for the server to acknowledge every published message
+  // "mandatory": false, // fail writes which can't be routed to a queue when confirm is set
+  // "exchanges": [{"name": "transporter", "type": "topic", "durable": true}],
+  // "queues": [{"name": "transporter", "durable": true}],
+  // "bindings": [{"queue": "transporter", "exchange": "transporter", "routing_key": "#"}],
   // "delivery_mode": 1, // non-persistent (1) or persistent (2)
-  // "api_port": 15672,
+  // "api_port": 15672, // used to list the queues to read when neither read_queues nor read_exchange are set
+  // "read_queues": ["queue"],
+  // "read_exchange": "exchange",
+  // "binding_key": "#", // binds the queue declared for read_exchange
+  // "read_queue": "", // name of the queue declared for read_exchange, the server names it when empty
+  // "namespace_from": "queue", // queue, routing_key or header
+  // "namespace_header": "namespace",
+  // "prefetch_count": 0, // unacknowledged deliveries per queue, 0 means unlimited
   // "ssl": false,
   // "cacerts": ["/path/to/cert.pem"]
 }`
@@ -25,15 +47,28 @@ var (
 	_ adaptor.Adaptor = &rabbitMQ{}
 )
 
-// RabbitMQ defines all configurable elements for connecting to and sending/receiving JSON.
+// RabbitMQ defines all configurable elements for connecting to and sending/receiving messages.
 type rabbitMQ struct {
 	adaptor.BaseConfig
-	RoutingKey   string   `json:"routing_key"`
-	KeyInField   bool     `json:"key_in_field"`
-	DeliveryMode uint8    `json:"delivery_mode"`
-	APIPort      int      `json:"api_port"`
-	SSL          bool     `json:"ssl"`
-	CACerts      []string `json:"cacerts"`
+	Topology
+	codec.Options
+	Codec             string   `json:"codec"`
+	DetectContentType bool     `json:"detect_content_type"`
+	RoutingKey        string   `json:"routing_key"`
+	KeyInField        bool     `json:"key_in_field"`
+	Confirm           bool     `json:"confirm"`
+	Mandatory         bool     `json:"mandatory"`
+	DeliveryMode      uint8    `json:"delivery_mode"`
+	APIPort           int      `json:"api_port"`
+	PrefetchCount     int      `json:"prefetch_count"`
+	ReadQueues        []string `json:"read_queues"`
+	ReadExchange      string   `json:"read_exchange"`
+	BindingKey        string   `json:"binding_key"`
+	ReadQueue         string   `json:"read_queue"`
+	NamespaceFrom     string   `json:"namespace_from"`
+	NamespaceHeader   string   `json:"namespace_header"`
+	SSL               bool     `json:"ssl"`
+	CACerts           []string `json:"cacerts"`
 }
 
 func init() {
@@ -41,10 +76,15 @@ func init() {
 		"rabbitmq",
 		func() adaptor.Adaptor {
 			return &rabbitMQ{
-				BaseConfig:   adaptor.BaseConfig{URI: DefaultURI},
-				RoutingKey:   DefaultRoutingKey,
-				DeliveryMode: DefaultDeliveryMode,
-				APIPort:      DefaultAPIPort,
+				BaseConfig:      adaptor.BaseConfig{URI: DefaultURI},
+				Codec:           DefaultCodec,
+				RoutingKey:      DefaultRoutingKey,
+				DeliveryMode:    DefaultDeliveryMode,
+				APIPort:         DefaultAPIPort,
+				PrefetchCount:   DefaultPrefetchCount,
+				BindingKey:      DefaultBindingKey,
+				NamespaceFrom:   namespaceFromQueue,
+				NamespaceHeader: DefaultNamespaceHeader,
 			}
 		},
 	)
@@ -57,11 +97,36 @@ func (r *rabbitMQ) Client() (client.Client, error) {
 }
 
 func (r *rabbitMQ) Reader() (client.Reader, error) {
-	return &Reader{r.URI, r.APIPort}, nil
+	switch r.NamespaceFrom {
+	case namespaceFromQueue, namespaceFromRoutingKey, namespaceFromHeader:
+	default:
+		return nil, UnsupportedNamespaceFromError{r.NamespaceFrom}
+	}
+	reader := newReader(r.URI, r.APIPort, r.PrefetchCount)
+	reader.queues = r.ReadQueues
+	reader.exchange = r.ReadExchange
+	reader.bindingKey = r.BindingKey
+	reader.queue = r.ReadQueue
+	reader.namespaceFrom = r.NamespaceFrom
+	reader.namespaceHeader = r.NamespaceHeader
+	reader.topology = r.Topology
+	if err := reader.setCodec(r.Codec, r.Options, r.DetectContentType); err != nil {
+		return nil, err
+	}
+	return reader, nil
 }
 
 func (r *rabbitMQ) Writer(done chan struct{}, wg *sync.WaitGroup) (client.Writer, error) {
-	return &Writer{r.DeliveryMode, r.RoutingKey, r.KeyInField}, nil
+	c, err := codec.GetCodec(r.Codec, r.Options)
+	if err != nil {
+		return nil, err
+	}
+	w, err := newWriter(r.DeliveryMode, r.RoutingKey, r.KeyInField, r.Confirm, r.Mandatory, r.Topology)
+	if err != nil {
+		return nil, err
+	}
+	w.Codec = c
+	return w, nil
 }
 
 func (r *rabbitMQ) Description() string {
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/compose/transporter/adaptor"
+	"github.com/compose/transporter/message/codec"
 )
 
 func TestDescription(t *testing.T) {
@@ -22,6 +23,9 @@ func TestSampleConfig(t *testing.T) {
 
 var initTests = []map[string]interface{}{
 	{},
+	{"read_queues": []string{"queue"}, "namespace_from": "routing_key"},
+	{"read_exchange": "exchange", "namespace_from": "header", "namespace_header": "ns"},
+	{"codec": "msgpack", "detect_content_type": true},
 }
 
 func TestInit(t *testing.T) {
@@ -41,3 +45,28 @@ func TestInit(t *testing.T) {
 		}
 	}
 }
+
+func TestReaderErr(t *testing.T) {
+	a, err := adaptor.GetAdaptor("rabbitmq", map[string]interface{}{"namespace_from": "body"})
+	if err != nil {
+		t.Fatalf("unexpected GetAdaptor() error, %s", err)
+	}
+	expectedErr := UnsupportedNamespaceFromError{"body"}
+	if _, err := a.Reader(); err != expectedErr {
+		t.Errorf("wrong Reader() error, expected %v, got %v", expectedErr, err)
+	}
+}
+
+func TestCodecErr(t *testing.T) {
+	a, err := adaptor.GetAdaptor("rabbitmq", map[string]interface{}{"codec": "xml"})
+	if err != nil {
+		t.Fatalf("unexpected GetAdaptor() error, %s", err)
+	}
+	expectedErr := codec.ErrNotFound{Name: "xml"}
+	if _, err := a.Reader(); err != expectedErr {
+		t.Errorf("wrong Reader() error, expected %v, got %v", expectedErr, err)
+	}
+	if _, err := a.Writer(nil, nil); err != expectedErr {
+		t.Errorf("wrong Writer() error, expected %v, got %v", expectedErr, err)
+	}
+}
@@ -1,7 +1,6 @@
 package rabbitmq
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,6 +11,8 @@ import (
 	"github.com/compose/transporter/commitlog"
 	"github.com/compose/transporter/log"
 	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/codec"
+	"github.com/compose/transporter/message/data"
 	"github.com/compose/transporter/message/ops"
 	"github.com/streadway/amqp"
 )
@@ -19,22 +20,121 @@ import (
 const (
 	// DefaultAPIPort is the default API port for RabbitMQ
 	DefaultAPIPort = 15672
+
+	// DefaultPrefetchCount is the number of unacknowledged deliveries the server will send to the
+	// Reader on each queue, 0 means there's no limit.
+	DefaultPrefetchCount = 0
+
+	// DefaultBindingKey binds the queue declared for read_exchange to every message published to a
+	// topic exchange.
+	DefaultBindingKey = "#"
+
+	// DefaultNamespaceHeader is the header the namespace is read from when namespace_from is header.
+	DefaultNamespaceHeader = "namespace"
+)
+
+const (
+	namespaceFromQueue      = "queue"
+	namespaceFromRoutingKey = "routing_key"
+	namespaceFromHeader     = "header"
 )
 
+// UnsupportedNamespaceFromError represents the error when an unknown namespace_from has been set.
+type UnsupportedNamespaceFromError struct {
+	From string
+}
+
+func (e UnsupportedNamespaceFromError) Error() string {
+	return fmt.Sprintf("unsupported namespace_from, %s", e.From)
+}
+
 var (
 	_ client.Reader = &Reader{}
+	_ client.Acker  = &Reader{}
 )
 
 // Reader implements client.Reader by consuming messages from the cluster based on its configuration.
+// Deliveries are only acknowledged once every sink has committed them, so anything which hasn't
+// been written when transporter stops is redelivered.
+//
+// The queues consumed are either listed in queues, a queue the Reader declares and binds to
+// exchange or, when neither is set, every queue returned by the management API which matches the
+// namespace filter.
+//
+// Bodies are decoded with codec or, when detectContentType is set, the codec registered for the
+// content type of the delivery.
 type Reader struct {
-	uri     string
-	apiPort int
+	uri           string
+	apiPort       int
+	prefetchCount int
+
+	queues          []string
+	exchange        string
+	bindingKey      string
+	queue           string
+	namespaceFrom   string
+	namespaceHeader string
+	topology        Topology
+
+	codec             codec.Codec
+	detectContentType bool
+	codecs            map[string]codec.Codec
+
+	sync.Mutex
+	pending map[message.Msg]amqp.Delivery
+}
+
+func newReader(uri string, apiPort, prefetchCount int) *Reader {
+	return &Reader{
+		uri:             uri,
+		apiPort:         apiPort,
+		prefetchCount:   prefetchCount,
+		bindingKey:      DefaultBindingKey,
+		namespaceFrom:   namespaceFromQueue,
+		namespaceHeader: DefaultNamespaceHeader,
+		codec:           codec.JSON{},
+		pending:         make(map[message.Msg]amqp.Delivery),
+	}
+}
+
+// setCodec sets the codec used to decode bodies, when detectContentType is set every registered
+// codec which can be created with opts is made available for content type detection.
+func (r *Reader) setCodec(name string, opts codec.Options, detectContentType bool) error {
+	c, err := codec.GetCodec(name, opts)
+	if err != nil {
+		return err
+	}
+	r.codec = c
+	r.detectContentType = detectContentType
+	if detectContentType {
+		r.codecs = make(map[string]codec.Codec)
+		for _, n := range codec.RegisteredCodecs() {
+			if c, err := codec.GetCodec(n, opts); err == nil {
+				r.codecs[n] = c
+			}
+		}
+	}
+	return nil
+}
+
+// decode decodes the body of the delivery using the codec for its content type when content type
+// detection is enabled and the type is known, otherwise the configured codec.
+func (r *Reader) decode(d amqp.Delivery) (data.Data, error) {
+	c := r.codec
+	if r.detectContentType {
+		if name, ok := codec.ForContentType(d.ContentType); ok {
+			if detected, ok := r.codecs[name]; ok {
+				c = detected
+			}
+		}
+	}
+	return c.Decode(d.Body)
 }
 
 func (r *Reader) Read(_ map[string]client.MessageSet, filterFn client.NsFilterFunc) client.MessageChanFunc {
 	return func(s client.Session, done chan struct{}) (chan client.MessageSet, error) {
 		out := make(chan client.MessageSet)
-		queues, err := r.listQueues(filterFn)
+		queues, err := r.consumeQueues(s.(*Session), filterFn)
 		if err != nil {
 			return nil, err
 		}
@@ -44,11 +144,16 @@ func (r *Reader) Read(_ map[string]client.MessageSet, filterFn client.NsFilterFu
 			for _, q := range queues {
 				consumeChannel, cerr := session.conn.Channel()
 				if cerr != nil {
-					return
+					log.With("queue", q).Errorf("unable to open channel, %s", cerr)
+					continue
+				}
+				if cerr := consumeChannel.Qos(r.prefetchCount, 0, false); cerr != nil {
+					log.With("queue", q).Errorf("unable to set prefetch count, %s", cerr)
+					continue
 				}
 				wg.Add(1)
-				log.With("vhost", session.conn.Config.Vhost).With("queue", q).Infoln("consuming...")
-				go consumeQueue(consumeChannel, q, &wg, done, out)
+				log.With("vhost", session.conn.Config.Vhost).With("queue", q).With("prefetch_count", r.prefetchCount).Infoln("consuming...")
+				go r.consumeQueue(consumeChannel, q, filterFn, &wg, done, out)
 			}
 			wg.Wait()
 		}(queues, s.(*Session))
@@ -56,6 +161,41 @@ func (r *Reader) Read(_ map[string]client.MessageSet, filterFn client.NsFilterFu
 	}
 }
 
+// consumeQueues declares the topology and returns the queues to consume from.
+func (r *Reader) consumeQueues(s *Session, filterFn client.NsFilterFunc) ([]string, error) {
+	if r.exchange == "" && len(r.queues) == 0 {
+		return r.listQueues(filterFn)
+	}
+	ch, err := s.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+	if err := r.topology.declare(ch); err != nil {
+		return nil, err
+	}
+	if r.exchange == "" {
+		for _, q := range r.queues {
+			if _, err := ch.QueueInspect(q); err != nil {
+				return nil, err
+			}
+		}
+		return r.queues, nil
+	}
+	// a named queue outlives the connection so nothing published while transporter isn't running
+	// is lost, otherwise the server names a queue which is deleted when the connection closes.
+	durable := r.queue != ""
+	q, err := ch.QueueDeclare(r.queue, durable, !durable, !durable, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	log.With("queue", q.Name).With("exchange", r.exchange).With("binding_key", r.bindingKey).Infoln("binding queue")
+	if err := ch.QueueBind(q.Name, r.bindingKey, r.exchange, false, nil); err != nil {
+		return nil, err
+	}
+	return []string{q.Name}, nil
+}
+
 func (r *Reader) listQueues(filterFn client.NsFilterFunc) ([]string, error) {
 	u, _ := url.Parse(r.uri)
 	httpScheme := "http"
@@ -100,8 +240,10 @@ func (r *Reader) listQueues(filterFn client.NsFilterFunc) ([]string, error) {
 	return out, nil
 }
 
-// TODO: create a Consumer struct with fields to run this since we don't use anything from Reader
-func consumeQueue(c *amqp.Channel, queue string, wg *sync.WaitGroup, done chan struct{}, out chan client.MessageSet) error {
+// consumeQueue sends every delivery from the queue, deliveries are acknowledged by Ack once
+// they've been committed. Deliveries which can't be decoded are rejected and deliveries whose
+// namespace doesn't match the filter are acknowledged straight away.
+func (r *Reader) consumeQueue(c *amqp.Channel, queue string, filterFn client.NsFilterFunc, wg *sync.WaitGroup, done chan struct{}, out chan client.MessageSet) error {
 	defer func() {
 		log.With("queue", queue).Infoln("consuming complete")
 		wg.Done()
@@ -114,17 +256,64 @@ func consumeQueue(c *amqp.Channel, queue string, wg *sync.WaitGroup, done chan s
 		select {
 		case <-done:
 			return nil
-		case msg := <-deliveries:
-			var result map[string]interface{}
-			if jerr := json.NewDecoder(bytes.NewReader(msg.Body)).Decode(&result); jerr != nil {
-				log.Errorf("unable to decode message to JSON, %s", jerr)
+		case d, ok := <-deliveries:
+			if !ok {
+				return nil
+			}
+			result, derr := r.decode(d)
+			if derr != nil {
+				log.With("queue", queue).With("content_type", d.ContentType).Errorf("unable to decode message, %s", derr)
+				d.Reject(false)
+				continue
+			}
+			ns := r.namespace(queue, d)
+			if !filterFn(ns) {
+				d.Ack(false)
 				continue
 			}
-			out <- client.MessageSet{
-				Msg:  message.From(ops.Insert, queue, result),
-				Mode: commitlog.Sync,
+			msg := message.From(ops.Insert, ns, result.AsMap())
+			r.Lock()
+			r.pending[msg] = d
+			r.Unlock()
+			select {
+			case out <- client.MessageSet{Msg: msg, Mode: commitlog.Sync}:
+			case <-done:
+				return nil
 			}
-			msg.Ack(false)
 		}
 	}
 }
+
+// namespace returns the namespace of the message in the delivery, the queue name is used when the
+// routing key or header is empty.
+func (r *Reader) namespace(queue string, d amqp.Delivery) string {
+	switch r.namespaceFrom {
+	case namespaceFromRoutingKey:
+		if d.RoutingKey != "" {
+			return d.RoutingKey
+		}
+	case namespaceFromHeader:
+		if ns, ok := d.Headers[r.namespaceHeader].(string); ok && ns != "" {
+			return ns
+		}
+	}
+	return queue
+}
+
+// Ack implements client.Acker, acknowledging the deliveries of the messages.
+func (r *Reader) Ack(msgs []client.MessageSet) error {
+	var err error
+	for _, m := range msgs {
+		r.Lock()
+		d, ok := r.pending[m.Msg]
+		delete(r.pending, m.Msg)
+		r.Unlock()
+		if !ok {
+			continue
+		}
+		if aerr := d.Ack(false); aerr != nil && err == nil {
+			err = aerr
+		}
+	}
+	return err
+}
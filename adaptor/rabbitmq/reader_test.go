@@ -1,24 +1,30 @@
 package rabbitmq
 
 import (
+	"reflect"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/codec"
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
 	"github.com/streadway/amqp"
 )
 
 var (
-	readerTestData    = TestData{"reader_queue", "reader_key", 10}
-	readerBadDataTest = TestData{"reader_bad_data_queue", "reader_bad_data_key", 10}
+	readerTestData       = TestData{"reader_queue", "reader_key", 10}
+	readerBadDataTest    = TestData{"reader_bad_data_queue", "reader_bad_data_key", 10}
+	readerQueuesTestData = TestData{"reader_queues_queue", "reader_queues_key", 10}
 )
 
 func TestRead(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping Read in short mode")
 	}
-	reader := &Reader{DefaultURI, DefaultAPIPort}
+	reader := newReader(DefaultURI, DefaultAPIPort, DefaultPrefetchCount)
 	readFunc := reader.Read(map[string]client.MessageSet{},
 		func(queue string) bool { return queue == readerTestData.Queue })
 	done := make(chan struct{})
@@ -34,7 +40,7 @@ func TestReadBadData(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping Read in short mode")
 	}
-	reader := &Reader{DefaultURI, DefaultAPIPort}
+	reader := newReader(DefaultURI, DefaultAPIPort, DefaultPrefetchCount)
 	readFunc := reader.Read(map[string]client.MessageSet{},
 		func(queue string) bool { return queue == readerBadDataTest.Queue })
 	done := make(chan struct{})
@@ -55,6 +61,146 @@ func TestReadBadData(t *testing.T) {
 	close(done)
 }
 
+func TestReadQueues(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping Read in short mode")
+	}
+	reader := newReader(DefaultURI, 0, DefaultPrefetchCount)
+	reader.queues = []string{readerQueuesTestData.Queue}
+	readFunc := reader.Read(map[string]client.MessageSet{}, func(string) bool { return true })
+	done := make(chan struct{})
+	msgChan, err := readFunc(defaultSession, done)
+	if err != nil {
+		t.Fatalf("unexpected Read error, %s\n", err)
+	}
+	checkCount(readerQueuesTestData.Queue, readerQueuesTestData.InsertCount, msgChan, t)
+	close(done)
+}
+
+func TestReadExchange(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping Read in short mode")
+	}
+	reader := newReader(DefaultURI, 0, DefaultPrefetchCount)
+	reader.exchange = testExchange
+	reader.bindingKey = "reader_exchange_key"
+	reader.namespaceFrom = namespaceFromRoutingKey
+	readFunc := reader.Read(map[string]client.MessageSet{}, func(string) bool { return true })
+	done := make(chan struct{})
+	msgChan, err := readFunc(defaultSession, done)
+	if err != nil {
+		t.Fatalf("unexpected Read error, %s\n", err)
+	}
+	for i := 0; i < 5; i++ {
+		msg := amqp.Publishing{
+			ContentType: "application/json",
+			Body:        []byte(`{"message": "hello"}`),
+		}
+		if err := defaultSession.channel.Publish(testExchange, "reader_exchange_key", false, false, msg); err != nil {
+			t.Fatalf("failed to publish to exchange, %s", err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		select {
+		case msg := <-msgChan:
+			if msg.Msg.Namespace() != "reader_exchange_key" {
+				t.Errorf("wrong namespace, expected reader_exchange_key, got %s", msg.Msg.Namespace())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for message %d", i)
+		}
+	}
+	close(done)
+}
+
+var namespaceTests = []struct {
+	from     string
+	delivery amqp.Delivery
+	expected string
+}{
+	{namespaceFromQueue, amqp.Delivery{RoutingKey: "key"}, "queue"},
+	{namespaceFromRoutingKey, amqp.Delivery{RoutingKey: "key"}, "key"},
+	{namespaceFromRoutingKey, amqp.Delivery{}, "queue"},
+	{namespaceFromHeader, amqp.Delivery{Headers: amqp.Table{"namespace": "header"}}, "header"},
+	{namespaceFromHeader, amqp.Delivery{RoutingKey: "key"}, "queue"},
+}
+
+func TestNamespace(t *testing.T) {
+	for _, nt := range namespaceTests {
+		reader := newReader(DefaultURI, DefaultAPIPort, DefaultPrefetchCount)
+		reader.namespaceFrom = nt.from
+		if ns := reader.namespace("queue", nt.delivery); ns != nt.expected {
+			t.Errorf("[%s] wrong namespace, expected %s, got %s", nt.from, nt.expected, ns)
+		}
+	}
+}
+
+type ackRecorder struct {
+	acked []uint64
+}
+
+func (a *ackRecorder) Ack(tag uint64, multiple bool) error {
+	a.acked = append(a.acked, tag)
+	return nil
+}
+
+func (a *ackRecorder) Nack(tag uint64, multiple bool, requeue bool) error {
+	return nil
+}
+
+func (a *ackRecorder) Reject(tag uint64, requeue bool) error {
+	return nil
+}
+
+func TestAck(t *testing.T) {
+	reader := newReader(DefaultURI, DefaultAPIPort, DefaultPrefetchCount)
+	acker := &ackRecorder{}
+	var msgs []client.MessageSet
+	for i := 1; i <= 3; i++ {
+		msg := message.From(ops.Insert, "queue", data.Data{"id": i})
+		reader.pending[msg] = amqp.Delivery{Acknowledger: acker, DeliveryTag: uint64(i)}
+		msgs = append(msgs, client.MessageSet{Msg: msg})
+	}
+	if err := reader.Ack(msgs[:2]); err != nil {
+		t.Fatalf("unexpected Ack error, %s", err)
+	}
+	if len(acker.acked) != 2 || acker.acked[0] != 1 || acker.acked[1] != 2 {
+		t.Errorf("wrong deliveries acked, %v", acker.acked)
+	}
+	if len(reader.pending) != 1 {
+		t.Errorf("acked deliveries still pending, %v", reader.pending)
+	}
+}
+
+var decodeTests = []struct {
+	contentType string
+	body        []byte
+	want        data.Data
+}{
+	{"application/json", []byte(`{"id": 1}`), data.Data{"id": float64(1)}},
+	{"application/x-msgpack", []byte{0x81, 0xa2, 'i', 'd', 0x01}, data.Data{"id": int64(1)}},
+	{"application/octet-stream", []byte("hello"), data.Data{codec.RawField: []byte("hello")}},
+	{"", []byte{0x81, 0xa2, 'i', 'd', 0x02}, data.Data{"id": int64(2)}},
+	{"text/plain", []byte{0x81, 0xa2, 'i', 'd', 0x03}, data.Data{"id": int64(3)}},
+}
+
+func TestDecode(t *testing.T) {
+	reader := newReader(DefaultURI, DefaultAPIPort, DefaultPrefetchCount)
+	if err := reader.setCodec("msgpack", codec.Options{}, true); err != nil {
+		t.Fatalf("unexpected setCodec error, %s", err)
+	}
+	for _, dt := range decodeTests {
+		d, err := reader.decode(amqp.Delivery{ContentType: dt.contentType, Body: dt.body})
+		if err != nil {
+			t.Errorf("[%s] unexpected decode error, %s", dt.contentType, err)
+			continue
+		}
+		if !reflect.DeepEqual(d, dt.want) {
+			t.Errorf("[%s] wrong data, expected %+v, got %+v", dt.contentType, dt.want, d)
+		}
+	}
+}
+
 func checkCount(desc string, expected int, msgChan <-chan client.MessageSet, t *testing.T) {
 	var numMsgs int
 	var wg sync.WaitGroup
@@ -0,0 +1,65 @@
+package rabbitmq
+
+import (
+	"github.com/compose/transporter/log"
+	"github.com/streadway/amqp"
+)
+
+// DefaultExchangeType is used when an exchange is declared without a type.
+const DefaultExchangeType = "direct"
+
+// Exchange is an exchange declared before any messages are published or consumed.
+type Exchange struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	Durable    bool   `json:"durable"`
+	AutoDelete bool   `json:"auto_delete"`
+}
+
+// Queue is a queue declared before any messages are published or consumed.
+type Queue struct {
+	Name       string `json:"name"`
+	Durable    bool   `json:"durable"`
+	AutoDelete bool   `json:"auto_delete"`
+}
+
+// Binding binds a queue to an exchange with the routing key, or pattern for topic exchanges.
+type Binding struct {
+	Queue      string `json:"queue"`
+	Exchange   string `json:"exchange"`
+	RoutingKey string `json:"routing_key"`
+}
+
+// Topology holds the exchanges, queues and bindings declared by the adaptor. Declarations are
+// idempotent so existing exchanges and queues are left untouched as long as their settings match.
+type Topology struct {
+	Exchanges []Exchange `json:"exchanges"`
+	Queues    []Queue    `json:"queues"`
+	Bindings  []Binding  `json:"bindings"`
+}
+
+func (t Topology) declare(ch *amqp.Channel) error {
+	for _, e := range t.Exchanges {
+		kind := e.Type
+		if kind == "" {
+			kind = DefaultExchangeType
+		}
+		log.With("exchange", e.Name).With("type", kind).Debugln("declaring exchange")
+		if err := ch.ExchangeDeclare(e.Name, kind, e.Durable, e.AutoDelete, false, false, nil); err != nil {
+			return err
+		}
+	}
+	for _, q := range t.Queues {
+		log.With("queue", q.Name).Debugln("declaring queue")
+		if _, err := ch.QueueDeclare(q.Name, q.Durable, q.AutoDelete, false, false, nil); err != nil {
+			return err
+		}
+	}
+	for _, b := range t.Bindings {
+		log.With("queue", b.Queue).With("exchange", b.Exchange).With("routing_key", b.RoutingKey).Debugln("declaring binding")
+		if err := ch.QueueBind(b.Queue, b.RoutingKey, b.Exchange, false, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,11 +2,16 @@ package rabbitmq
 
 import (
 	"bytes"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"text/template"
 	"time"
 
 	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/log"
 	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/codec"
+	"github.com/compose/transporter/message/data"
 	"github.com/compose/transporter/message/ops"
 	"github.com/streadway/amqp"
 )
@@ -22,37 +27,199 @@ const (
 
 var (
 	_ client.Writer = &Writer{}
+
+	// ErrPublishNacked is returned when the server refuses to take responsibility for a message
+	// published in confirm mode.
+	ErrPublishNacked = errors.New("publish was nacked by the server")
 )
 
+// PublishReturnedError is returned when a mandatory message couldn't be routed to any queue.
+type PublishReturnedError struct {
+	Exchange   string
+	RoutingKey string
+	Reason     string
+}
+
+func (e PublishReturnedError) Error() string {
+	return fmt.Sprintf("message to exchange %s with routing key %s was returned, %s", e.Exchange, e.RoutingKey, e.Reason)
+}
+
 // Writer implements client.Writer by publishing messages to the cluster based on its configuration.
+// In confirm mode each message is only confirmed once the server has acknowledged it. Bodies are
+// encoded with Codec and published with its content type.
 type Writer struct {
 	DeliveryMode uint8
 	RoutingKey   string
 	KeyInField   bool
+	Confirm      bool
+	Mandatory    bool
+	Topology     Topology
+	Codec        codec.Codec
+
+	routingKey *template.Template
+	channel    *amqp.Channel
+	acks       chan uint64
+	nacks      chan uint64
+	returns    chan amqp.Return
+	closes     chan *amqp.Error
+}
+
+// routingKeyData is passed to the routing_key template.
+type routingKeyData struct {
+	Namespace string
+	Op        string
+	Data      data.Data
+}
+
+func newWriter(deliveryMode uint8, routingKey string, keyInField, confirm, mandatory bool, topology Topology) (*Writer, error) {
+	w := &Writer{
+		DeliveryMode: deliveryMode,
+		RoutingKey:   routingKey,
+		KeyInField:   keyInField,
+		Confirm:      confirm,
+		Mandatory:    mandatory,
+		Topology:     topology,
+		Codec:        codec.JSON{},
+	}
+	if !keyInField {
+		t, err := template.New("routing_key").Option("missingkey=error").Parse(routingKey)
+		if err != nil {
+			return nil, err
+		}
+		w.routingKey = t
+	}
+	return w, nil
 }
 
 func (w *Writer) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
 	return func(s client.Session) (message.Msg, error) {
 		if msg.OP() == ops.Insert || msg.OP() == ops.Update {
-			b := new(bytes.Buffer)
-			json.NewEncoder(b).Encode(msg.Data())
+			ch, err := w.openChannel(s.(*Session))
+			if err != nil {
+				return nil, err
+			}
+			routingKey, err := w.routingKeyFor(msg)
+			if err != nil {
+				return nil, err
+			}
+			body, err := w.Codec.Encode(msg.Data())
+			if err != nil {
+				return nil, err
+			}
 			amqpMsg := amqp.Publishing{
 				DeliveryMode: w.DeliveryMode,
 				Timestamp:    time.Unix(msg.Timestamp(), 0),
-				ContentType:  "application/json",
-				Body:         b.Bytes(),
+				ContentType:  w.Codec.ContentType(),
+				Body:         body,
+			}
+			if err := ch.Publish(msg.Namespace(), routingKey, w.Mandatory, false, amqpMsg); err != nil {
+				ch.Close()
+				w.channel = nil
+				return nil, err
 			}
-			if w.KeyInField {
-				return msg,
-					s.(*Session).channel.Publish(
-						msg.Namespace(),
-						msg.Data().Get(w.RoutingKey).(string),
-						false,
-						false,
-						amqpMsg)
+			if w.Confirm {
+				if err := w.waitForConfirm(msg.Namespace(), routingKey); err != nil {
+					return nil, err
+				}
 			}
-			return msg, s.(*Session).channel.Publish(msg.Namespace(), w.RoutingKey, false, false, amqpMsg)
+		}
+		if msg.Confirms() != nil {
+			msg.Confirms() <- struct{}{}
 		}
 		return msg, nil
 	}
 }
+
+// openChannel returns the channel messages are published on, opening a new one and declaring
+// the topology when there isn't one or the server has closed it.
+func (w *Writer) openChannel(s *Session) (*amqp.Channel, error) {
+	if w.channel != nil {
+		select {
+		case err := <-w.closes:
+			log.Errorf("channel closed, reopening, %s", err)
+			w.channel = nil
+		default:
+			return w.channel, nil
+		}
+	}
+	ch, err := s.conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Topology.declare(ch); err != nil {
+		ch.Close()
+		return nil, err
+	}
+	w.closes = ch.NotifyClose(make(chan *amqp.Error, 1))
+	if w.Confirm {
+		if err := ch.Confirm(false); err != nil {
+			ch.Close()
+			return nil, err
+		}
+		w.acks, w.nacks = ch.NotifyConfirm(make(chan uint64, 1), make(chan uint64, 1))
+	}
+	if w.Mandatory {
+		w.returns = ch.NotifyReturn(make(chan amqp.Return, 1))
+		if !w.Confirm {
+			// without confirms a return can't be matched to the message so it's only logged
+			go func(returns chan amqp.Return) {
+				for r := range returns {
+					log.With("exchange", r.Exchange).With("routing_key", r.RoutingKey).Errorf("message returned, %s", r.ReplyText)
+				}
+			}(w.returns)
+		}
+	}
+	w.channel = ch
+	return ch, nil
+}
+
+// waitForConfirm blocks until the server has acknowledged the last message published. A mandatory
+// message which couldn't be routed is returned before it's acknowledged.
+func (w *Writer) waitForConfirm(exchange, routingKey string) error {
+	select {
+	case _, ok := <-w.acks:
+		if !ok {
+			return w.channelClosed()
+		}
+	case _, ok := <-w.nacks:
+		if !ok {
+			return w.channelClosed()
+		}
+		return ErrPublishNacked
+	case err := <-w.closes:
+		w.channel = nil
+		if err == nil {
+			return amqp.ErrClosed
+		}
+		return err
+	}
+	select {
+	case r := <-w.returns:
+		return PublishReturnedError{Exchange: exchange, RoutingKey: routingKey, Reason: r.ReplyText}
+	default:
+	}
+	return nil
+}
+
+func (w *Writer) channelClosed() error {
+	w.channel = nil
+	if err, ok := <-w.closes; ok && err != nil {
+		return err
+	}
+	return amqp.ErrClosed
+}
+
+func (w *Writer) routingKeyFor(msg message.Msg) (string, error) {
+	if w.KeyInField {
+		key, ok := msg.Data().Get(w.RoutingKey).(string)
+		if !ok {
+			return "", fmt.Errorf("routing key field %s is missing or isn't a string", w.RoutingKey)
+		}
+		return key, nil
+	}
+	var b bytes.Buffer
+	if err := w.routingKey.Execute(&b, routingKeyData{msg.Namespace(), msg.OP().String(), msg.Data()}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
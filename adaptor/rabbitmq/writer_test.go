@@ -8,8 +8,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/compose/transporter/adaptor"
 	"github.com/compose/transporter/log"
 	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/data"
 	"github.com/compose/transporter/message/ops"
 	"github.com/streadway/amqp"
 )
@@ -21,7 +23,10 @@ var (
 )
 
 func TestWriteWithKeyInField(t *testing.T) {
-	w := &Writer{amqp.Transient, "my_key", true}
+	w, err := newWriter(amqp.Transient, "my_key", true, false, false, Topology{})
+	if err != nil {
+		t.Fatalf("unexpected newWriter error, %s\n", err)
+	}
 	for i := 0; i < 10; i++ {
 		_, err := w.Write(
 			message.From(
@@ -34,7 +39,7 @@ func TestWriteWithKeyInField(t *testing.T) {
 			t.Fatalf("unexpected Write error, %s\n", err)
 		}
 	}
-	_, err := w.Write(
+	_, err = w.Write(
 		message.From(
 			ops.Delete,
 			testExchange,
@@ -48,7 +53,10 @@ func TestWriteWithKeyInField(t *testing.T) {
 }
 
 func TestWriteWithStaticKey(t *testing.T) {
-	w := &Writer{amqp.Transient, writerTestData2.RoutingKey, false}
+	w, err := newWriter(amqp.Transient, writerTestData2.RoutingKey, false, false, false, Topology{})
+	if err != nil {
+		t.Fatalf("unexpected newWriter error, %s\n", err)
+	}
 	for i := 0; i < 10; i++ {
 		_, err := w.Write(
 			message.From(
@@ -65,7 +73,10 @@ func TestWriteWithStaticKey(t *testing.T) {
 }
 
 func TestWriteWithEmptyKey(t *testing.T) {
-	w := &Writer{amqp.Transient, writerTestData3.RoutingKey, false}
+	w, err := newWriter(amqp.Transient, writerTestData3.RoutingKey, false, false, false, Topology{})
+	if err != nil {
+		t.Fatalf("unexpected newWriter error, %s\n", err)
+	}
 	for i := 0; i < 10; i++ {
 		_, err := w.Write(
 			message.From(
@@ -81,6 +92,85 @@ func TestWriteWithEmptyKey(t *testing.T) {
 	checkQueueCount(writerTestData3.Queue, 10, t)
 }
 
+var routingKeyTests = []struct {
+	routingKey string
+	keyInField bool
+	msg        message.Msg
+	expected   string
+	expectErr  bool
+}{
+	{"static", false, message.From(ops.Insert, "orders", data.Data{}), "static", false},
+	{"", false, message.From(ops.Insert, "orders", data.Data{}), "", false},
+	{"{{.Namespace}}.{{.Op}}.{{.Data.status}}", false, message.From(ops.Update, "orders", data.Data{"status": "shipped"}), "orders.update.shipped", false},
+	{"{{.Data.missing}}", false, message.From(ops.Insert, "orders", data.Data{}), "", true},
+	{"my_key", true, message.From(ops.Insert, "orders", data.Data{"my_key": "key"}), "key", false},
+	{"my_key", true, message.From(ops.Insert, "orders", data.Data{}), "", true},
+}
+
+func TestRoutingKey(t *testing.T) {
+	for _, rt := range routingKeyTests {
+		w, err := newWriter(amqp.Transient, rt.routingKey, rt.keyInField, false, false, Topology{})
+		if err != nil {
+			t.Fatalf("unexpected newWriter error, %s", err)
+		}
+		key, err := w.routingKeyFor(rt.msg)
+		if (err != nil) != rt.expectErr {
+			t.Errorf("[%s] unexpected routing key error, %v", rt.routingKey, err)
+		}
+		if key != rt.expected {
+			t.Errorf("[%s] wrong routing key, expected %s, got %s", rt.routingKey, rt.expected, key)
+		}
+	}
+}
+
+var (
+	confirmTopology = Topology{
+		Exchanges: []Exchange{{Name: "transporter-confirm-tests", Type: "topic"}},
+		Queues:    []Queue{{Name: "writer_confirm_queue", AutoDelete: true}},
+		Bindings:  []Binding{{Queue: "writer_confirm_queue", Exchange: "transporter-confirm-tests", RoutingKey: "orders.#"}},
+	}
+)
+
+func TestWriteWithConfirms(t *testing.T) {
+	confirms, cleanup := adaptor.MockConfirmWrites()
+	defer adaptor.VerifyWriteConfirmed(cleanup, t)
+	w, err := newWriter(amqp.Transient, "orders.{{.Op}}", false, true, true, confirmTopology)
+	if err != nil {
+		t.Fatalf("unexpected newWriter error, %s\n", err)
+	}
+	for i := 0; i < 10; i++ {
+		msg := message.From(ops.Insert, "transporter-confirm-tests", data.Data{"i": i})
+		if _, err := w.Write(message.WithConfirms(confirms, msg))(defaultSession); err != nil {
+			t.Fatalf("unexpected Write error, %s\n", err)
+		}
+	}
+	checkQueueCount("writer_confirm_queue", 10, t)
+}
+
+func TestWriteReturned(t *testing.T) {
+	w, err := newWriter(amqp.Transient, "unbound", false, true, true, confirmTopology)
+	if err != nil {
+		t.Fatalf("unexpected newWriter error, %s\n", err)
+	}
+	msg := message.From(ops.Insert, "transporter-confirm-tests", data.Data{"i": 1})
+	if _, err := w.Write(msg)(defaultSession); err == nil {
+		t.Errorf("expected returned error, got nil")
+	} else if _, ok := err.(PublishReturnedError); !ok {
+		t.Errorf("wrong error type, expected PublishReturnedError, got %T", err)
+	}
+}
+
+func TestWriteMissingExchange(t *testing.T) {
+	w, err := newWriter(amqp.Transient, "", false, true, false, Topology{})
+	if err != nil {
+		t.Fatalf("unexpected newWriter error, %s\n", err)
+	}
+	msg := message.From(ops.Insert, "transporter-missing-exchange", data.Data{"i": 1})
+	if _, err := w.Write(msg)(defaultSession); err == nil {
+		t.Errorf("expected error publishing to a missing exchange, got nil")
+	}
+}
+
 func checkQueueCount(queue string, count int, t *testing.T) {
 	time.Sleep(5 * time.Second)
 	u, _ := url.Parse(DefaultURI)
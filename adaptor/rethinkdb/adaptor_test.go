@@ -16,7 +16,7 @@ var (
 	}
 	defaultSession *Session
 
-	dbsToTest = []*TestData{readerTestData, tailTestData, writerTestData}
+	dbsToTest = []*TestData{readerTestData, tailTestData, tailResumeTestData, writerTestData}
 )
 
 type TestData struct {
@@ -1,6 +1,7 @@
 package rethinkdb
 
 import (
+	"context"
 	"errors"
 	"sync"
 
@@ -13,18 +14,27 @@ import (
 	re "gopkg.in/gorethink/gorethink.v3"
 )
 
+const (
+	defaultBatchSize = 1000
+
+	// changefeedReady is the state of a changefeed once every initial value has been sent.
+	changefeedReady = "ready"
+)
+
 var (
-	_ client.Reader = &Reader{}
+	_ client.Reader        = &Reader{}
+	_ client.ContextReader = &Reader{}
 )
 
 // Reader fulfills the client.Reader interface for use with both copying and tailing a RethinkDB
-// database.
+// database. When tailing, tables are copied by their changefeed rather than paging through them.
 type Reader struct {
-	tail bool
+	tail      bool
+	batchSize int
 }
 
 func newReader(tail bool) client.Reader {
-	return &Reader{tail}
+	return &Reader{tail: tail, batchSize: defaultBatchSize}
 }
 
 type iterationComplete struct {
@@ -32,51 +42,63 @@ type iterationComplete struct {
 	table  string
 }
 
-func (r *Reader) Read(_ map[string]client.MessageSet, filterFn client.NsFilterFunc) client.MessageChanFunc {
+func (r *Reader) Read(resumeMap map[string]client.MessageSet, filterFn client.NsFilterFunc) client.MessageChanFunc {
 	return func(s client.Session, done chan struct{}) (chan client.MessageSet, error) {
-		out := make(chan client.MessageSet)
-		session := s.(*Session).session
-		go func() {
-			defer close(out)
-			log.With("db", session.Database()).Infoln("starting Read func")
-			tables, err := r.listTables(session, filterFn)
-			if err != nil {
-				log.With("db", session.Database()).Errorf("unable to list tables, %s", err)
-				return
-			}
-			iterationComplete := r.iterateTable(session, tables, out, done)
-			var wg sync.WaitGroup
-			func() {
-				for {
-					select {
-					case <-done:
+		return r.read(resumeMap, filterFn, s, done)
+	}
+}
+
+// ReadContext reads like Read until ctx is done, the copy and changefeed of every table stop along
+// with it.
+func (r *Reader) ReadContext(resumeMap map[string]client.MessageSet, filterFn client.NsFilterFunc) func(context.Context, client.Session) (chan client.MessageSet, error) {
+	return func(ctx context.Context, s client.Session) (chan client.MessageSet, error) {
+		return r.read(resumeMap, filterFn, s, ctx.Done())
+	}
+}
+
+func (r *Reader) read(resumeMap map[string]client.MessageSet, filterFn client.NsFilterFunc, s client.Session, done <-chan struct{}) (chan client.MessageSet, error) {
+	out := make(chan client.MessageSet)
+	session := s.(*Session).session
+	go func() {
+		defer close(out)
+		log.With("db", session.Database()).Infoln("starting Read func")
+		tables, err := r.listTables(session, filterFn)
+		if err != nil {
+			log.With("db", session.Database()).Errorf("unable to list tables, %s", err)
+			return
+		}
+		iterationComplete := r.iterateTable(session, tables, resumeMap, out, done)
+		var wg sync.WaitGroup
+		func() {
+			for {
+				select {
+				case <-done:
+					return
+				case i, ok := <-iterationComplete:
+					if !ok {
 						return
-					case i, ok := <-iterationComplete:
-						if !ok {
-							return
-						}
-						log.With("db", session.Database()).With("table", i.table).Infoln("iterating complete")
-						if i.cursor != nil {
-							go func(wg *sync.WaitGroup, t string, c *re.Cursor) {
-								wg.Add(1)
-								defer wg.Done()
-								errc := r.sendChanges(session.Database(), t, c, out, done)
-								for err := range errc {
-									log.With("db", session.Database()).With("table", t).Errorln(err)
-									return
-								}
-							}(&wg, i.table, i.cursor)
-						}
+					}
+					log.With("db", session.Database()).With("table", i.table).Infoln("iterating complete")
+					if i.cursor != nil {
+						go func(wg *sync.WaitGroup, t string, c *re.Cursor) {
+							wg.Add(1)
+							defer wg.Done()
+							errc := r.sendChanges(session.Database(), t, c, out, done)
+							for err := range errc {
+								log.With("db", session.Database()).With("table", t).Errorln(err)
+								return
+							}
+						}(&wg, i.table, i.cursor)
 					}
 				}
-			}()
-			log.With("db", session.Database()).Infoln("Read completed")
-			// this will block if we're tailing
-			wg.Wait()
-			return
+			}
 		}()
-		return out, nil
-	}
+		log.With("db", session.Database()).Infoln("Read completed")
+		// this will block if we're tailing
+		wg.Wait()
+		return
+	}()
+	return out, nil
 }
 
 func (r *Reader) listTables(session *re.Session, filterFn func(name string) bool) (<-chan string, error) {
@@ -103,7 +125,7 @@ func (r *Reader) listTables(session *re.Session, filterFn func(name string) bool
 	return out, nil
 }
 
-func (r *Reader) iterateTable(session *re.Session, in <-chan string, out chan<- client.MessageSet, done chan struct{}) <-chan iterationComplete {
+func (r *Reader) iterateTable(session *re.Session, in <-chan string, resumeMap map[string]client.MessageSet, out chan<- client.MessageSet, done <-chan struct{}) <-chan iterationComplete {
 	tableDone := make(chan iterationComplete)
 	go func() {
 		defer close(tableDone)
@@ -113,33 +135,38 @@ func (r *Reader) iterateTable(session *re.Session, in <-chan string, out chan<-
 				if !ok {
 					return
 				}
-				log.With("db", session.Database()).With("table", t).Infoln("iterating...")
-				cursor, err := re.DB(session.Database()).Table(t).Run(session)
-				if err != nil {
-					return
-				}
-				defer cursor.Close()
-				var ccursor *re.Cursor
+				m, resuming := resumeMap[t]
 				if r.tail {
-					var err error
-					ccursor, err = re.DB(session.Database()).Table(t).Changes(re.ChangesOpts{}).Run(session)
+					ccursor, err := r.startChanges(session, t, !resuming || m.Mode == commitlog.Copy, out, done)
 					if err != nil {
+						log.With("db", session.Database()).With("table", t).Errorln(err)
 						return
 					}
+					tableDone <- iterationComplete{ccursor, t}
+					continue
 				}
-
-				var result map[string]interface{}
-				for cursor.Next(&result) {
-					out <- client.MessageSet{
-						Msg: message.From(ops.Insert, t, result),
+				if resuming && m.Mode != commitlog.Copy {
+					log.With("db", session.Database()).With("table", t).Infoln("copy previously completed, skipping...")
+					tableDone <- iterationComplete{nil, t}
+					continue
+				}
+				log.With("db", session.Database()).With("table", t).Infoln("iterating...")
+				pk, err := primaryKey(session, t)
+				if err != nil {
+					log.With("db", session.Database()).With("table", t).Errorf("unable to determine primary key, %s", err)
+					return
+				}
+				var lastID interface{}
+				if resuming {
+					if lastID = m.Msg.Data().Get(pk); lastID != nil {
+						log.With("db", session.Database()).With("table", t).With(pk, lastID).Infoln("resuming copy")
 					}
-					result = map[string]interface{}{}
 				}
-
-				if err := cursor.Err(); err != nil {
+				if err := r.iteratePages(session, t, pk, lastID, out, done); err != nil {
+					log.With("db", session.Database()).With("table", t).Errorln(err)
 					return
 				}
-				tableDone <- iterationComplete{ccursor, t}
+				tableDone <- iterationComplete{nil, t}
 			case <-done:
 				log.With("db", session.Database()).Infoln("iterating no more")
 				return
@@ -149,13 +176,154 @@ func (r *Reader) iterateTable(session *re.Session, in <-chan string, out chan<-
 	return tableDone
 }
 
+// iteratePages sends every document of the table ordered by primary key, fetching batchSize
+// documents at a time and continuing after the key of the last document sent, which makes the
+// copy resumable.
+func (r *Reader) iteratePages(session *re.Session, t, pk string, lastID interface{}, out chan<- client.MessageSet, done <-chan struct{}) error {
+	for {
+		cursor, err := r.pageQuery(session.Database(), t, pk, lastID).Run(session)
+		if err != nil {
+			return err
+		}
+		var (
+			count  int
+			result map[string]interface{}
+		)
+		for cursor.Next(&result) {
+			count++
+			lastID = result[pk]
+			select {
+			case out <- client.MessageSet{Msg: message.From(ops.Insert, t, result)}:
+			case <-done:
+				cursor.Close()
+				return errors.New("iteration cancelled")
+			}
+			result = map[string]interface{}{}
+		}
+		if err := cursor.Err(); err != nil {
+			cursor.Close()
+			return err
+		}
+		cursor.Close()
+		if count < r.batchSize || lastID == nil {
+			return nil
+		}
+	}
+}
+
+func (r *Reader) pageQuery(db, t, pk string, lastID interface{}) re.Term {
+	term := re.DB(db).Table(t)
+	if lastID != nil {
+		term = term.Between(lastID, re.MaxVal, re.BetweenOpts{LeftBound: "open"})
+	}
+	return term.OrderBy(re.OrderByOpts{Index: pk}).Limit(r.batchSize)
+}
+
+func primaryKey(session *re.Session, t string) (string, error) {
+	cursor, err := re.DB(session.Database()).Table(t).Info().Field("primary_key").Run(session)
+	if err != nil {
+		return "", err
+	}
+	defer cursor.Close()
+	var pk string
+	err = cursor.One(&pk)
+	return pk, err
+}
+
+// startChanges opens the changefeed of the table. With includeInitial, the feed includes the current
+// contents of the table and is read until it's ready, so documents and the changes made to them
+// while they're being copied form a single stream. The last document of the copy is sent in Sync
+// mode, recording in the commit log that the table doesn't need to be copied again.
+// Without includeInitial, the feed starts from the current state of the table, RethinkDB
+// changefeeds can't be resumed from a position so any changes made while stopped are lost.
+func (r *Reader) startChanges(session *re.Session, t string, includeInitial bool, out chan<- client.MessageSet, done <-chan struct{}) (*re.Cursor, error) {
+	if !includeInitial {
+		log.With("db", session.Database()).With("table", t).Infoln("copy previously completed, resuming changes...")
+		log.With("db", session.Database()).With("table", t).Infoln("changefeeds can't resume from a position, changes made while stopped are not replayed")
+		return re.DB(session.Database()).Table(t).Changes(re.ChangesOpts{}).Run(session)
+	}
+	log.With("db", session.Database()).With("table", t).Infoln("iterating...")
+	ccursor, err := re.DB(session.Database()).Table(t).Changes(re.ChangesOpts{
+		IncludeInitial: true,
+		IncludeStates:  true,
+	}).Run(session)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.sendInitial(t, ccursor, out, done); err != nil {
+		ccursor.Close()
+		return nil, err
+	}
+	return ccursor, nil
+}
+
+// sendInitial sends the notifications of the changefeed in Copy mode until it's ready. Each
+// message is held back until the next one arrives so the final one can be sent in Sync mode.
+func (r *Reader) sendInitial(table string, ccursor *re.Cursor, out chan<- client.MessageSet, done <-chan struct{}) error {
+	var (
+		change rethinkDbChangeNotification
+		last   *client.MessageSet
+	)
+	send := func(m client.MessageSet) error {
+		select {
+		case out <- m:
+			return nil
+		case <-done:
+			return errors.New("iteration cancelled")
+		}
+	}
+	for ccursor.Next(&change) {
+		if change.State == changefeedReady {
+			if last != nil {
+				last.Mode = commitlog.Sync
+				return send(*last)
+			}
+			return nil
+		}
+		msg, err := changeMsg(table, change)
+		if err != nil {
+			return err
+		}
+		change = rethinkDbChangeNotification{}
+		if msg == nil {
+			continue
+		}
+		if last != nil {
+			if err := send(*last); err != nil {
+				return err
+			}
+		}
+		last = &client.MessageSet{Msg: msg, Mode: commitlog.Copy}
+	}
+	if err := ccursor.Err(); err != nil {
+		return err
+	}
+	return errors.New("changefeed closed before the copy completed")
+}
+
 type rethinkDbChangeNotification struct {
 	Error  string                 `gorethink:"error"`
+	State  string                 `gorethink:"state"`
 	OldVal map[string]interface{} `gorethink:"old_val"`
 	NewVal map[string]interface{} `gorethink:"new_val"`
 }
 
-func (r *Reader) sendChanges(db, table string, ccursor *re.Cursor, out chan<- client.MessageSet, done chan struct{}) chan error {
+// changeMsg converts the notification into a message, state notifications return a nil message.
+func changeMsg(table string, change rethinkDbChangeNotification) (message.Msg, error) {
+	switch {
+	case change.Error != "":
+		return nil, errors.New(change.Error)
+	case change.OldVal != nil && change.NewVal != nil:
+		return message.From(ops.Update, table, change.NewVal), nil
+	case change.NewVal != nil:
+		return message.From(ops.Insert, table, change.NewVal), nil
+	case change.OldVal != nil:
+		return message.From(ops.Delete, table, change.OldVal), nil
+	}
+	return nil, nil
+}
+
+func (r *Reader) sendChanges(db, table string, ccursor *re.Cursor, out chan<- client.MessageSet, done <-chan struct{}) chan error {
 	errc := make(chan error)
 	go func() {
 		defer ccursor.Close()
@@ -179,19 +347,18 @@ func (r *Reader) sendChanges(db, table string, ccursor *re.Cursor, out chan<- cl
 				}
 				log.With("db", db).With("table", table).With("change", change).Debugln("received")
 
-				var msg message.Msg
-				if change.Error != "" {
-					errc <- errors.New(change.Error)
-				} else if change.OldVal != nil && change.NewVal != nil {
-					msg = message.From(ops.Update, table, change.NewVal)
-				} else if change.NewVal != nil {
-					msg = message.From(ops.Insert, table, change.NewVal)
-				} else if change.OldVal != nil {
-					msg = message.From(ops.Delete, table, change.OldVal)
+				msg, err := changeMsg(table, change)
+				if err != nil {
+					errc <- err
+					return
 				}
-				out <- client.MessageSet{
-					Msg:  msg,
-					Mode: commitlog.Sync,
+				if msg == nil {
+					continue
+				}
+				select {
+				case out <- client.MessageSet{Msg: msg, Mode: commitlog.Sync}:
+				case <-done:
+					return
 				}
 			}
 		}
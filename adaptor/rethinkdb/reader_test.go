@@ -7,6 +7,10 @@ import (
 	"time"
 
 	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/commitlog"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
 
 	r "gopkg.in/gorethink/gorethink.v3"
 )
@@ -46,6 +50,47 @@ func TestRead(t *testing.T) {
 	close(done)
 }
 
+func TestReadResume(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping Read in short mode")
+	}
+
+	reader := newReader(false)
+	reader.(*Reader).batchSize = 3
+	resumeMap := map[string]client.MessageSet{
+		readerTestData.T: {
+			Msg:  message.From(ops.Insert, readerTestData.T, data.Data{"id": float64(4), "i": float64(4)}),
+			Mode: commitlog.Copy,
+		},
+	}
+	readFunc := reader.Read(resumeMap, func(c string) bool { return true })
+	done := make(chan struct{})
+	c, err := NewClient(WithURI(fmt.Sprintf("rethinkdb://127.0.0.1:28015/%s", readerTestData.DB)))
+	if err != nil {
+		t.Fatalf("unable to initialize connection to rethinkdb, %s", err)
+	}
+	defer c.Close()
+	s, err := c.Connect()
+	if err != nil {
+		t.Fatalf("unable to obtain session to rethinkdb, %s", err)
+	}
+	msgChan, err := readFunc(s, done)
+	if err != nil {
+		t.Fatalf("unexpected Read error, %s\n", err)
+	}
+	expectedID := float64(5)
+	for msg := range msgChan {
+		if id := msg.Msg.Data().Get("id"); id != expectedID {
+			t.Errorf("unexpected id, expected %v, got %v", expectedID, id)
+		}
+		expectedID++
+	}
+	if expectedID != float64(readerTestData.InsertCount) {
+		t.Errorf("bad message count, expected %d, got %v\n", readerTestData.InsertCount-5, expectedID-5)
+	}
+	close(done)
+}
+
 var (
 	tailTestData   = &TestData{"tail_test", "foo", 50}
 	tailTestTables = []string{"bar", "baz", "blah", "boo", "skip"}
@@ -153,6 +198,92 @@ func TestTail(t *testing.T) {
 	close(done)
 }
 
+var tailResumeTestData = &TestData{"tail_resume_test", "foo", 10}
+
+func TestTailResume(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping Tail in short mode")
+	}
+
+	c, err := NewClient(WithURI(fmt.Sprintf("rethinkdb://127.0.0.1:28015/%s", tailResumeTestData.DB)))
+	if err != nil {
+		t.Fatalf("unable to initialize connection to rethinkdb, %s", err)
+	}
+	defer c.Close()
+	s, err := c.Connect()
+	if err != nil {
+		t.Fatalf("unable to obtain session to rethinkdb, %s", err)
+	}
+
+	done := make(chan struct{})
+	msgChan, err := newReader(true).Read(map[string]client.MessageSet{}, func(string) bool { return true })(s, done)
+	if err != nil {
+		t.Fatalf("unexpected Tail error, %s\n", err)
+	}
+	var last client.MessageSet
+	for i := 0; i < tailResumeTestData.InsertCount; i++ {
+		select {
+		case last = <-msgChan:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for initial value %d", i)
+		}
+		expectedMode := commitlog.Copy
+		if i == tailResumeTestData.InsertCount-1 {
+			expectedMode = commitlog.Sync
+		}
+		if last.Mode != expectedMode {
+			t.Errorf("wrong mode for initial value %d, expected %s, got %s", i, expectedMode, last.Mode)
+		}
+	}
+	close(done)
+
+	// resuming after the copy completed only sends new changes
+	done = make(chan struct{})
+	resumeMap := map[string]client.MessageSet{tailResumeTestData.T: last}
+	msgChan, err = newReader(true).Read(resumeMap, func(string) bool { return true })(s, done)
+	if err != nil {
+		t.Fatalf("unexpected Tail error, %s\n", err)
+	}
+	checkCount("resume", 0, msgChan, t)
+	if _, err := r.DB(tailResumeTestData.DB).Table(tailResumeTestData.T).Insert(map[string]interface{}{"i": 100}).RunWrite(defaultSession.session); err != nil {
+		t.Fatalf("unexpected Insert error, %s\n", err)
+	}
+	checkCount("resumed changes", 1, msgChan, t)
+	close(done)
+}
+
+var changeMsgTests = []struct {
+	change rethinkDbChangeNotification
+	op     ops.Op
+	id     interface{}
+	err    bool
+}{
+	{rethinkDbChangeNotification{NewVal: map[string]interface{}{"id": 1}}, ops.Insert, 1, false},
+	{rethinkDbChangeNotification{OldVal: map[string]interface{}{"id": 1}, NewVal: map[string]interface{}{"id": 2}}, ops.Update, 2, false},
+	{rethinkDbChangeNotification{OldVal: map[string]interface{}{"id": 3}}, ops.Delete, 3, false},
+	{rethinkDbChangeNotification{State: "initializing"}, ops.Noop, nil, false},
+	{rethinkDbChangeNotification{Error: "changefeed aborted"}, ops.Noop, nil, true},
+}
+
+func TestChangeMsg(t *testing.T) {
+	for _, ct := range changeMsgTests {
+		msg, err := changeMsg("foo", ct.change)
+		if (err != nil) != ct.err {
+			t.Errorf("[%+v] wrong error, expected error %t, got %v", ct.change, ct.err, err)
+			continue
+		}
+		if msg == nil {
+			if ct.op != ops.Noop {
+				t.Errorf("[%+v] expected %s message but got nil", ct.change, ct.op)
+			}
+			continue
+		}
+		if msg.OP() != ct.op || msg.Data().Get("id") != ct.id || msg.Namespace() != "foo" {
+			t.Errorf("[%+v] wrong message, got %s %s %+v", ct.change, msg.OP(), msg.Namespace(), msg.Data())
+		}
+	}
+}
+
 func checkCount(desc string, expected int, msgChan <-chan client.MessageSet, t *testing.T) {
 	var numMsgs int
 	var wg sync.WaitGroup
@@ -56,7 +56,7 @@ func (r *rethinkDB) Reader() (client.Reader, error) {
 }
 
 func (r *rethinkDB) Writer(done chan struct{}, wg *sync.WaitGroup) (client.Writer, error) {
-	return newWriter(done, wg), nil
+	return newWriter(), nil
 }
 
 // Description for rethinkdb adaptor
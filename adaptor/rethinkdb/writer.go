@@ -1,10 +1,9 @@
 package rethinkdb
 
 import (
+	"context"
 	"fmt"
 	"strings"
-	"sync"
-	"time"
 
 	"github.com/compose/transporter/client"
 	"github.com/compose/transporter/log"
@@ -19,148 +18,151 @@ const (
 )
 
 var (
-	_ client.Writer = &Writer{}
+	_ client.Writer             = &Writer{}
+	_ client.ContextWriter      = &Writer{}
+	_ client.BatchWriter        = &Writer{}
+	_ client.ContextBatchWriter = &Writer{}
 )
 
-// Writer implements client.Writer for use with RethinkDB
-type Writer struct {
-	bulkMap map[string]*bulkOperation
-	*sync.Mutex
-	opCounter int
-}
+// Writer implements client.BatchWriter for use with RethinkDB, the inserts into each table in a
+// batch are written with a single query.
+type Writer struct{}
 
-type bulkOperation struct {
-	s        *r.Session
-	confirms chan struct{}
-	docs     []map[string]interface{}
+// bulkInsert holds the documents inserted into each table until they're written.
+type bulkInsert struct {
+	ctx    context.Context
+	s      *r.Session
+	tables []string
+	docs   map[string][]map[string]interface{}
+	count  int
 }
 
-func newWriter(done chan struct{}, wg *sync.WaitGroup) *Writer {
-	w := &Writer{
-		bulkMap: make(map[string]*bulkOperation),
-		Mutex:   &sync.Mutex{},
-	}
-	wg.Add(1)
-	go w.run(done, wg)
-	return w
+func newWriter() *Writer {
+	return &Writer{}
 }
 
 func (w *Writer) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	write := w.WriteContext(msg)
 	return func(s client.Session) (message.Msg, error) {
-		table := msg.Namespace()
+		return write(context.Background(), s)
+	}
+}
+
+// WriteContext writes msg in a batch of its own, see WriteBatchContext.
+func (w *Writer) WriteContext(msg message.Msg) func(context.Context, client.Session) (message.Msg, error) {
+	return func(ctx context.Context, s client.Session) (message.Msg, error) {
+		if err := w.WriteBatchContext([]message.Msg{msg})(ctx, s); err != nil {
+			return nil, err
+		}
+		if msg.Confirms() != nil {
+			msg.Confirms() <- struct{}{}
+		}
+		return msg, nil
+	}
+}
+
+// WriteBatch writes msgs in order, consecutive inserts are buffered and written to each table in
+// bulk before any update or delete.
+func (w *Writer) WriteBatch(msgs []message.Msg) func(client.Session) error {
+	write := w.WriteBatchContext(msgs)
+	return func(s client.Session) error {
+		return write(context.Background(), s)
+	}
+}
+
+// WriteBatchContext is WriteBatch, no more queries are run once ctx is done. The driver can't
+// interrupt a query it has already sent so that one is left to finish.
+func (w *Writer) WriteBatchContext(msgs []message.Msg) func(context.Context, client.Session) error {
+	return func(ctx context.Context, s client.Session) error {
 		rSession := s.(*Session).session
-		switch msg.OP() {
-		case ops.Delete:
-			w.flushAll()
-			return msg, do(
-				r.DB(rSession.Database()).Table(table).Get(prepareDocument(msg)["id"]).Delete(),
-				rSession,
-				msg.Confirms(),
-			)
-		case ops.Insert:
-			w.Lock()
-			bOp, ok := w.bulkMap[table]
-			if !ok {
-				bOp = &bulkOperation{
-					s:    rSession,
-					docs: make([]map[string]interface{}, 0),
+		b := &bulkInsert{ctx: ctx, s: rSession, docs: make(map[string][]map[string]interface{})}
+		for _, msg := range msgs {
+			table := msg.Namespace()
+			switch msg.OP() {
+			case ops.Delete:
+				if err := b.flush(); err != nil {
+					return err
 				}
-				w.bulkMap[table] = bOp
-			}
-			if msg.Confirms() != nil {
-				bOp.confirms = msg.Confirms()
-			}
-			bOp.docs = append(bOp.docs, prepareDocument(msg))
-			w.Unlock()
-			w.opCounter++
-			if w.opCounter >= maxObjSize {
-				if err := w.flushAll(); err != nil {
-					return nil, err
+				if err := do(ctx, r.DB(rSession.Database()).Table(table).Get(prepareDocument(msg)["id"]).Delete(), rSession); err != nil {
+					return err
+				}
+			case ops.Insert:
+				if err := b.add(table, prepareDocument(msg)); err != nil {
+					return err
+				}
+			case ops.Update:
+				if err := b.flush(); err != nil {
+					return err
+				}
+				if err := do(ctx, r.DB(rSession.Database()).Table(table).Insert(prepareDocument(msg), r.InsertOpts{Conflict: "replace"}), rSession); err != nil {
+					return err
 				}
 			}
-		case ops.Update:
-			if err := w.flushAll(); err != nil {
-				return nil, err
-			}
-			return msg, do(
-				r.DB(rSession.Database()).Table(table).Insert(prepareDocument(msg), r.InsertOpts{Conflict: "replace"}),
-				rSession,
-				msg.Confirms(),
-			)
 		}
-		return msg, nil
+		return b.flush()
 	}
 }
 
-// prepareDocument checks for an `_id` field and moves it to `id`.
+// prepareDocument returns a copy of the message's document with its `_id` field moved to `id`,
+// the message is left unchanged so it can be written again.
 func prepareDocument(msg message.Msg) map[string]interface{} {
 	if _, ok := msg.Data()["id"]; ok {
 		return msg.Data()
 	}
-
-	if _, ok := msg.Data()["_id"]; ok {
-		msg.Data().Set("id", msg.ID())
-		msg.Data().Delete("_id")
+	doc := make(map[string]interface{}, len(msg.Data()))
+	for k, v := range msg.Data() {
+		doc[k] = v
+	}
+	if _, ok := doc["_id"]; ok {
+		doc["id"] = msg.ID()
+		delete(doc, "_id")
 	}
-	return msg.Data()
+	return doc
 }
 
-func (w *Writer) run(done chan struct{}, wg *sync.WaitGroup) {
-	defer wg.Done()
-	for {
-		select {
-		case <-time.After(2 * time.Second):
-			if err := w.flushAll(); err != nil {
-				log.Errorf("flush error, %s", err)
-				return
-			}
-		case <-done:
-			log.Debugln("received done channel")
-			if err := w.flushAll(); err != nil {
-				log.Errorf("flush error, %s", err)
-			}
-			return
-		}
+func (b *bulkInsert) add(table string, doc map[string]interface{}) error {
+	if _, ok := b.docs[table]; !ok {
+		b.tables = append(b.tables, table)
+	}
+	b.docs[table] = append(b.docs[table], doc)
+	b.count++
+	if b.count >= maxObjSize {
+		return b.flush()
 	}
+	return nil
 }
 
-func (w *Writer) flushAll() error {
-	w.Lock()
-	defer func() {
-		w.opCounter = 0
-		w.Unlock()
-	}()
-	for t, bOp := range w.bulkMap {
-		log.With("db", bOp.s.Database()).With("table", t).With("op_counter", w.opCounter).With("doc_count", len(bOp.docs)).Infoln("flushing bulk messages")
-		resp, err := r.DB(bOp.s.Database()).Table(t).Insert(bOp.docs, r.InsertOpts{Conflict: "replace"}).RunWrite(bOp.s)
-		if err != nil {
-			return err
-		}
-		if err := handleResponse(&resp, bOp.confirms); err != nil {
+// flush inserts the buffered documents into each table.
+func (b *bulkInsert) flush() error {
+	for _, t := range b.tables {
+		log.With("db", b.s.Database()).With("table", t).With("doc_count", len(b.docs[t])).Debugln("flushing bulk messages")
+		if err := do(b.ctx, r.DB(b.s.Database()).Table(t).Insert(b.docs[t], r.InsertOpts{Conflict: "replace"}), b.s); err != nil {
 			return err
 		}
 	}
-	w.bulkMap = make(map[string]*bulkOperation)
+	b.tables = nil
+	b.docs = make(map[string][]map[string]interface{})
+	b.count = 0
 	return nil
 }
 
-func do(t r.Term, s *r.Session, confirms chan struct{}) error {
+func do(ctx context.Context, t r.Term, s *r.Session) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	resp, err := t.RunWrite(s)
 	if err != nil {
 		return err
 	}
-	return handleResponse(&resp, confirms)
+	return handleResponse(&resp)
 }
 
 // handleresponse takes the rethink response and turn it into something we can consume elsewhere
-func handleResponse(resp *r.WriteResponse, confirms chan struct{}) error {
+func handleResponse(resp *r.WriteResponse) error {
 	if resp.Errors != 0 {
 		if !strings.Contains(resp.FirstError, "Duplicate primary key") { // we don't care about this error
 			return fmt.Errorf("%s\n%s", "problem inserting docs", resp.FirstError)
 		}
 	}
-	if confirms != nil {
-		confirms <- struct{}{}
-	}
 	return nil
 }
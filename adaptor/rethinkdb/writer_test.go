@@ -1,11 +1,10 @@
 package rethinkdb
 
 import (
+	"context"
 	"fmt"
 	"reflect"
-	"sync"
 	"testing"
-	"time"
 
 	"github.com/compose/transporter/log"
 	"github.com/compose/transporter/message"
@@ -61,9 +60,7 @@ func TestBulkInsert(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping Insert in short mode")
 	}
-	var wg sync.WaitGroup
-	done := make(chan struct{})
-	w := newWriter(done, &wg)
+	w := newWriter()
 	if _, err := r.DB(writerTestData.DB).TableCreate("bulk").RunWrite(defaultSession.session); err != nil {
 		log.Errorf("failed to create table (bulk) in %s, may affect tests!, %s", writerTestData.DB, err)
 	}
@@ -77,21 +74,13 @@ func TestBulkInsert(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unable to obtain session to rethinkdb, %s", err)
 	}
-	confirms := make(chan struct{})
-	var confirmed bool
-	go func() {
-		<-confirms
-		confirmed = true
-	}()
+	var msgs []message.Msg
 	for i := 0; i < 999; i++ {
-		msg := message.From(ops.Insert, "bulk", map[string]interface{}{"id": i, "i": i})
-		if _, err := w.Write(message.WithConfirms(confirms, msg))(s); err != nil {
-			t.Errorf("unexpected Insert error, %s", err)
-		}
+		msgs = append(msgs, message.From(ops.Insert, "bulk", map[string]interface{}{"id": i, "i": i}))
+	}
+	if err := w.WriteBatch(msgs)(s); err != nil {
+		t.Errorf("unexpected Insert error, %s", err)
 	}
-	time.Sleep(3 * time.Second)
-	close(done)
-	wg.Wait()
 	countResp, err := r.DB(writerTestData.DB).Table("bulk").Count().Run(defaultSession.session)
 	if err != nil {
 		t.Errorf("unable to determine table count, %s", err)
@@ -103,15 +92,12 @@ func TestBulkInsert(t *testing.T) {
 		t.Errorf("[bulk] mismatched doc count, expected 999, got %d", count)
 	}
 
-	if !confirmed {
-		t.Errorf("[bulk] confirm chan never closed but should have")
-	}
-
+	msgs = nil
 	for i := 0; i < 2000; i++ {
-		msg := message.From(ops.Insert, "bulk", map[string]interface{}{"id": i, "i": i})
-		if _, err := w.Write(msg)(s); err != nil {
-			t.Errorf("unexpected Insert error, %s", err)
-		}
+		msgs = append(msgs, message.From(ops.Insert, "bulk", map[string]interface{}{"id": i, "i": i}))
+	}
+	if err := w.WriteBatch(msgs)(s); err != nil {
+		t.Errorf("unexpected Insert error, %s", err)
 	}
 
 	countResp, err = r.DB(writerTestData.DB).Table("bulk").Count().Run(defaultSession.session)
@@ -131,9 +117,7 @@ func TestInsert(t *testing.T) {
 	}
 
 	for _, it := range inserttests {
-		var wg sync.WaitGroup
-		done := make(chan struct{})
-		w := newWriter(done, &wg)
+		w := newWriter()
 
 		if _, err := r.DB(writerTestData.DB).TableCreate(it.table).RunWrite(defaultSession.session); err != nil {
 			log.Errorf("failed to create table (%s) in %s, may affect tests!, %s", it.table, writerTestData.DB, err)
@@ -154,8 +138,6 @@ func TestInsert(t *testing.T) {
 				t.Errorf("unexpected Insert error, %s\n", err)
 			}
 		}
-		close(done)
-		wg.Wait()
 		countResp, err := r.DB(writerTestData.DB).Table(it.table).Count().Run(defaultSession.session)
 		if err != nil {
 			t.Errorf("unable to determine table count, %s\n", err)
@@ -182,6 +164,20 @@ func TestInsert(t *testing.T) {
 	}
 }
 
+func TestWriteContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	msgs := []message.Msg{
+		message.From(ops.Insert, "done", map[string]interface{}{"id": 0}),
+		message.From(ops.Delete, "done", map[string]interface{}{"id": 0}),
+	}
+	for _, msg := range msgs {
+		if err := newWriter().WriteBatchContext([]message.Msg{msg})(ctx, defaultSession); err != context.Canceled {
+			t.Errorf("[%s] wrong WriteBatchContext error, expected %s, got %v", msg.OP(), context.Canceled, err)
+		}
+	}
+}
+
 var updatetests = []struct {
 	table       string
 	originalDoc data.Data
@@ -200,9 +196,7 @@ func TestUpdate(t *testing.T) {
 	}
 
 	for _, ut := range updatetests {
-		var wg sync.WaitGroup
-		done := make(chan struct{})
-		w := newWriter(done, &wg)
+		w := newWriter()
 		if _, err := r.DB(writerTestData.DB).TableCreate(ut.table).RunWrite(defaultSession.session); err != nil {
 			log.Errorf("failed to create table (%s) in %s, may affect tests!, %s", ut.table, writerTestData.DB, err)
 		}
@@ -226,8 +220,6 @@ func TestUpdate(t *testing.T) {
 		if _, err := w.Write(msg)(s); err != nil {
 			t.Errorf("unexpected Update error, %s\n", err)
 		}
-		close(done)
-		wg.Wait()
 		// Validate update
 		expectedDoc := map[string]interface{}{}
 		for k, v := range ut.updatedDoc {
@@ -263,9 +255,7 @@ func TestDelete(t *testing.T) {
 		t.Skip("skipping Delete in short mode")
 	}
 	for _, dt := range deletetests {
-		var wg sync.WaitGroup
-		done := make(chan struct{})
-		w := newWriter(done, &wg)
+		w := newWriter()
 		if _, err := r.DB(writerTestData.DB).TableCreate(dt.table).RunWrite(defaultSession.session); err != nil {
 			log.Errorf("failed to create table (%s) in %s, may affect tests!, %s", dt.table, writerTestData.DB, err)
 		}
@@ -290,8 +280,6 @@ func TestDelete(t *testing.T) {
 		if _, err := w.Write(msg)(s); err != nil {
 			t.Errorf("unexpected Delete error, %s\n", err)
 		}
-		close(done)
-		wg.Wait()
 		// Validate delete
 		var result map[string]interface{}
 		cursor, _ := r.DB(writerTestData.DB).Table(dt.table).Get(dt.id).Run(defaultSession.session)
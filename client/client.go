@@ -1,7 +1,10 @@
 package client
 
 import (
+	"context"
+
 	"github.com/compose/transporter/commitlog"
+	"github.com/compose/transporter/events"
 	"github.com/compose/transporter/message"
 )
 
@@ -38,17 +41,112 @@ type Reader interface {
 	Read(map[string]MessageSet, NsFilterFunc) MessageChanFunc
 }
 
+// ContextReader is implemented by a Reader which stops reading once a context.Context is done,
+// the pipeline calls ReadContext in place of Read.
+type ContextReader interface {
+	ReadContext(map[string]MessageSet, NsFilterFunc) func(context.Context, Session) (chan MessageSet, error)
+}
+
+// Acker is implemented by a Reader which needs to be told when messages have been processed by
+// every sink, e.g. to acknowledge or delete them at the source. Ack is called with the messages
+// in the order they were read once the offsets committed by every sink have passed them.
+type Acker interface {
+	Ack([]MessageSet) error
+}
+
 // Writer represents all possible functions needing to be implemented to handle messages.
 type Writer interface {
 	Write(message.Msg) func(Session) (message.Msg, error)
 }
 
+// ContextWriter is implemented by a Writer which stops a write once a context.Context is done,
+// e.g. when it times out or the pipeline is stopped, as far as the underlying driver allows. The
+// pipeline calls WriteContext in place of Write and waits for it to return, a Writer which isn't a
+// ContextWriter is left writing in the background when it times out.
+type ContextWriter interface {
+	WriteContext(message.Msg) func(context.Context, Session) (message.Msg, error)
+}
+
+// BatchWriter is implemented by a Writer which writes messages in batches. The pipeline calls
+// WriteBatch in place of Write with batches bounded in size and time, and confirms the whole
+// batch once the returned func succeeds so messages don't need to be confirmed by the writer.
+type BatchWriter interface {
+	WriteBatch([]message.Msg) func(Session) error
+}
+
+// ContextBatchWriter is implemented by a BatchWriter which aborts writing a batch once a
+// context.Context is done, the pipeline calls WriteBatchContext in place of WriteBatch.
+type ContextBatchWriter interface {
+	WriteBatchContext([]message.Msg) func(context.Context, Session) error
+}
+
+// ParallelWriter is implemented by a Writer which can write messages from several goroutines at
+// once. WritesInParallel must only report true if Write is safe for concurrent use and each
+// message is confirmed once it's been written, rather than when a later message is, otherwise the
+// pipeline writes one message at a time.
+type ParallelWriter interface {
+	WritesInParallel() bool
+}
+
+// EventEmitter is implemented by a Reader or Writer which emits events of its own, such as
+// schema changes. The pipeline calls SetEmitter with the func used to deliver them.
+type EventEmitter interface {
+	SetEmitter(func(events.Event))
+}
+
 // Write encapsulates the function of determining which function to call based on the msg.OP() and
 // also wraps the function call with a Session.
 func Write(client Client, writer Writer, msg message.Msg) (message.Msg, error) {
 	return sessionFunc(client, writer.Write(msg))
 }
 
+// WriteContext is Write for ctx, the write is aborted once ctx is done if writer is a
+// ContextWriter.
+func WriteContext(ctx context.Context, client Client, writer Writer, msg message.Msg) (message.Msg, error) {
+	cw, ok := writer.(ContextWriter)
+	if !ok {
+		return Write(client, writer, msg)
+	}
+	return sessionFunc(client, func(s Session) (message.Msg, error) {
+		return cw.WriteContext(msg)(ctx, s)
+	})
+}
+
+// Read starts reading messages with reader until ctx is done, a Reader which isn't a
+// ContextReader is passed a done channel which is closed once ctx is done.
+func Read(ctx context.Context, s Session, reader Reader, nsMap map[string]MessageSet, filterFn NsFilterFunc) (chan MessageSet, error) {
+	if cr, ok := reader.(ContextReader); ok {
+		return cr.ReadContext(nsMap, filterFn)(ctx, s)
+	}
+	done := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		close(done)
+	}()
+	return reader.Read(nsMap, filterFn)(s, done)
+}
+
+// WriteBatch wraps the BatchWriter's write of msgs with a Session.
+func WriteBatch(client Client, writer BatchWriter, msgs []message.Msg) error {
+	_, err := sessionFunc(client, func(s Session) (message.Msg, error) {
+		return nil, writer.WriteBatch(msgs)(s)
+	})
+	return err
+}
+
+// WriteBatchContext is WriteBatch for ctx, the write is aborted once ctx is done if writer is a
+// ContextBatchWriter.
+func WriteBatchContext(ctx context.Context, client Client, writer BatchWriter, msgs []message.Msg) error {
+	cw, ok := writer.(ContextBatchWriter)
+	if !ok {
+		return WriteBatch(client, writer, msgs)
+	}
+	_, err := sessionFunc(client, func(s Session) (message.Msg, error) {
+		return nil, cw.WriteBatchContext(msgs)(ctx, s)
+	})
+	return err
+}
+
 func sessionFunc(client Client, op func(Session) (message.Msg, error)) (message.Msg, error) {
 	sess, err := client.Connect()
 	if err != nil {
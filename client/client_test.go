@@ -1,9 +1,11 @@
 package client_test
 
 import (
+	"context"
 	"reflect"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/compose/transporter/client"
 	"github.com/compose/transporter/message"
@@ -71,6 +73,25 @@ func TestWriteWithConfirms(t *testing.T) {
 	}
 }
 
+func TestWriteBatch(t *testing.T) {
+	w := &client.MockBatchWriter{}
+	c := &client.Mock{}
+	defer c.Close()
+	msgs := []message.Msg{
+		message.From(ops.Insert, "test", map[string]interface{}{"hello": "client"}),
+		message.From(ops.Insert, "test", map[string]interface{}{"hello": "batch"}),
+	}
+	if err := client.WriteBatch(c, w, msgs); err != nil {
+		t.Fatalf("unexpected WriteBatch error, %s", err)
+	}
+	if len(w.Batches) != 1 || w.MsgCount != 2 {
+		t.Errorf("batch never received, %v", w.Batches)
+	}
+	if err := client.WriteBatch(&client.MockErr{}, w, msgs); err != client.ErrMockConnect {
+		t.Errorf("wrong WriteBatch error, expected %s, got %v", client.ErrMockConnect, err)
+	}
+}
+
 var (
 	testMsgCount = 10
 )
@@ -92,3 +113,103 @@ func TestRead(t *testing.T) {
 		t.Errorf("wrong message count, expected %d, got %d", testMsgCount, count)
 	}
 }
+
+func TestWriteContext(t *testing.T) {
+	c := &client.Mock{}
+	w := &client.MockWriter{}
+	if _, err := client.WriteContext(context.Background(), c, w, message.From(ops.Insert, "test", map[string]interface{}{})); err != nil {
+		t.Fatalf("unexpected WriteContext error, %s", err)
+	}
+	if w.MsgCount != 1 {
+		t.Errorf("message never received")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	cw := &client.MockContextWriter{}
+	if _, err := client.WriteContext(ctx, c, cw, message.From(ops.Insert, "test", map[string]interface{}{})); err != context.DeadlineExceeded {
+		t.Errorf("wrong WriteContext error, expected %s, got %v", context.DeadlineExceeded, err)
+	}
+	if cw.MsgCount != 0 {
+		t.Errorf("Write called instead of WriteContext")
+	}
+}
+
+func TestWriteBatchContext(t *testing.T) {
+	c := &client.Mock{}
+	msgs := []message.Msg{message.From(ops.Insert, "test", map[string]interface{}{})}
+	w := &client.MockBatchWriter{}
+	if err := client.WriteBatchContext(context.Background(), c, w, msgs); err != nil {
+		t.Fatalf("unexpected WriteBatchContext error, %s", err)
+	}
+	if len(w.Batches) != 1 {
+		t.Errorf("batch never received")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	cw := &client.MockContextBatchWriter{}
+	if err := client.WriteBatchContext(ctx, c, cw, msgs); err != context.DeadlineExceeded {
+		t.Errorf("wrong WriteBatchContext error, expected %s, got %v", context.DeadlineExceeded, err)
+	}
+	if len(cw.Batches) != 0 {
+		t.Errorf("WriteBatch called instead of WriteBatchContext")
+	}
+}
+
+// doneReader sends messages until done is closed.
+type doneReader struct{}
+
+func (r *doneReader) Read(_ map[string]client.MessageSet, _ client.NsFilterFunc) client.MessageChanFunc {
+	return func(s client.Session, done chan struct{}) (chan client.MessageSet, error) {
+		out := make(chan client.MessageSet)
+		go func() {
+			defer close(out)
+			for {
+				select {
+				case out <- client.MessageSet{Msg: message.From(ops.Insert, "test", map[string]interface{}{})}:
+				case <-done:
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+// ctxReader reads messages until ctx is done.
+type ctxReader struct {
+	doneReader
+}
+
+func (r *ctxReader) ReadContext(_ map[string]client.MessageSet, _ client.NsFilterFunc) func(context.Context, client.Session) (chan client.MessageSet, error) {
+	return func(ctx context.Context, s client.Session) (chan client.MessageSet, error) {
+		out := make(chan client.MessageSet)
+		go func() {
+			<-ctx.Done()
+			close(out)
+		}()
+		return out, nil
+	}
+}
+
+func TestReadContext(t *testing.T) {
+	for _, r := range []client.Reader{&doneReader{}, &ctxReader{}} {
+		ctx, cancel := context.WithCancel(context.Background())
+		msgChan, err := client.Read(ctx, &client.MockSession{}, r, map[string]client.MessageSet{}, func(string) bool { return true })
+		if err != nil {
+			t.Fatalf("unexpected Read error, %s", err)
+		}
+		cancel()
+		timeout := time.After(time.Second)
+	L:
+		for {
+			select {
+			case _, ok := <-msgChan:
+				if !ok {
+					break L
+				}
+			case <-timeout:
+				t.Fatalf("%T didn't stop reading once the context was canceled", r)
+			}
+		}
+	}
+}
@@ -15,6 +15,9 @@ func (e InvalidURIError) Error() string {
 	return fmt.Sprintf("Invalid URI (%s), %s", e.URI, e.Err)
 }
 
+// Retryable satisfies the RetryableError interface, a bad URI won't fix itself.
+func (e InvalidURIError) Retryable() bool { return false }
+
 // InvalidTimeoutError wraps the underlying error when the provided is not parsable time.ParseDuration.
 type InvalidTimeoutError struct {
 	Timeout string
@@ -24,6 +27,9 @@ func (e InvalidTimeoutError) Error() string {
 	return fmt.Sprintf("Invalid Timeout, %s", e.Timeout)
 }
 
+// Retryable satisfies the RetryableError interface.
+func (e InvalidTimeoutError) Retryable() bool { return false }
+
 // ErrInvalidCert represents the error returned when a specified certificate was not valid
 var ErrInvalidCert = errors.New("invalid cert error")
 
@@ -36,6 +42,9 @@ func (e ConnectError) Error() string {
 	return fmt.Sprintf("connection error, %s", e.Reason)
 }
 
+// Retryable satisfies the RetryableError interface, the database may be reachable again.
+func (e ConnectError) Retryable() bool { return true }
+
 // VersionError represents any failure in attempting to obtain the version from the provided uri.
 type VersionError struct {
 	URI string
@@ -49,3 +58,46 @@ func (e VersionError) Error() string {
 	}
 	return fmt.Sprintf("%s running %s, %s", e.URI, e.V, e.Err)
 }
+
+// Retryable satisfies the RetryableError interface.
+func (e VersionError) Retryable() bool { return false }
+
+// RetryableError is implemented by errors which know whether the operation that failed is worth
+// retrying, e.g. a dropped connection is temporary while a document the database rejected is not.
+type RetryableError interface {
+	error
+	Retryable() bool
+}
+
+// IsRetryable reports whether the operation which returned err should be retried, errors which
+// don't implement RetryableError are assumed to be transient.
+func IsRetryable(err error) bool {
+	if re, ok := err.(RetryableError); ok {
+		return re.Retryable()
+	}
+	return true
+}
+
+// PermanentError wraps an error which retrying won't fix.
+type PermanentError struct {
+	Err error
+}
+
+func (e PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Retryable satisfies the RetryableError interface.
+func (e PermanentError) Retryable() bool { return false }
+
+// TemporaryError wraps an error which may succeed when retried.
+type TemporaryError struct {
+	Err error
+}
+
+func (e TemporaryError) Error() string {
+	return e.Err.Error()
+}
+
+// Retryable satisfies the RetryableError interface.
+func (e TemporaryError) Retryable() bool { return true }
@@ -1,6 +1,7 @@
 package client_test
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/compose/transporter/client"
@@ -45,3 +46,27 @@ func TestErrors(t *testing.T) {
 		}
 	}
 }
+
+var retryableTests = []struct {
+	name      string
+	e         error
+	retryable bool
+}{
+	{"plain error", errors.New("oops"), true},
+	{"InvalidURIError", client.InvalidURIError{URI: "blah", Err: "blah"}, false},
+	{"ConnectError", client.ConnectError{Reason: "no reachable servers"}, true},
+	{"VersionError", client.VersionError{URI: "rethinkdb://localhost:28105", Err: "its bad"}, false},
+	{"PermanentError", client.PermanentError{Err: errors.New("duplicate key")}, false},
+	{"TemporaryError", client.TemporaryError{Err: errors.New("server busy")}, true},
+}
+
+func TestIsRetryable(t *testing.T) {
+	for _, rt := range retryableTests {
+		if client.IsRetryable(rt.e) != rt.retryable {
+			t.Errorf("[%s] wrong IsRetryable(), expected %t", rt.name, rt.retryable)
+		}
+	}
+	if err := (client.PermanentError{Err: errors.New("duplicate key")}); err.Error() != "duplicate key" {
+		t.Errorf("wrong Error(), expected duplicate key, got %s", err.Error())
+	}
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -84,6 +85,48 @@ func (w *MockWriter) Write(msg message.Msg) func(Session) (message.Msg, error) {
 	}
 }
 
+// MockBatchWriter can be used to record the batches sent to WriteBatch.
+type MockBatchWriter struct {
+	MockWriter
+	Batches [][]message.Msg
+}
+
+// WriteBatch satisfies the BatchWriter interface.
+func (w *MockBatchWriter) WriteBatch(msgs []message.Msg) func(Session) error {
+	return func(s Session) error {
+		w.MsgCount += len(msgs)
+		w.Batches = append(w.Batches, msgs)
+		return nil
+	}
+}
+
+// MockContextWriter can be used to simulate writes which only finish once they're aborted.
+type MockContextWriter struct {
+	MockWriter
+}
+
+// WriteContext satisfies the ContextWriter interface.
+func (w *MockContextWriter) WriteContext(msg message.Msg) func(context.Context, Session) (message.Msg, error) {
+	return func(ctx context.Context, s Session) (message.Msg, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+}
+
+// MockContextBatchWriter can be used to simulate batch writes which only finish once they're
+// aborted.
+type MockContextBatchWriter struct {
+	MockBatchWriter
+}
+
+// WriteBatchContext satisfies the ContextBatchWriter interface.
+func (w *MockContextBatchWriter) WriteBatchContext(msgs []message.Msg) func(context.Context, Session) error {
+	return func(ctx context.Context, s Session) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+}
+
 // MockErrWriter can be used to similate write errors in tests.
 type MockErrWriter struct {
 }
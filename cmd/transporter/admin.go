@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/compose/transporter/pipeline"
+)
+
+// adminHandler serves the endpoints to control a running pipeline. POST /pause and POST /resume
+// pause and resume the node named by the node query parameter, or the whole pipeline without one.
+// They, and GET /status, respond with whether each node is paused.
+func adminHandler(p *pipeline.Pipeline) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeStatus(w, p)
+	})
+	for path, f := range map[string]func(string) error{
+		"/pause":  p.Pause,
+		"/resume": p.Resume,
+	} {
+		f := f
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if err := f(r.URL.Query().Get("node")); err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			writeStatus(w, p)
+		})
+	}
+	return mux
+}
+
+func writeStatus(w http.ResponseWriter, p *pipeline.Pipeline) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"paused": p.Paused()})
+}
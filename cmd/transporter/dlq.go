@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/compose/transporter/log"
+	"github.com/compose/transporter/pipeline"
+	"github.com/olekukonko/tablewriter"
+)
+
+func runDLQ(args []string) error {
+	flagset := baseFlagSet("dlq")
+	flagset.Usage = usageFor(flagset, "transporter dlq [flags] list|replay SINK [<pipeline>]")
+	if err := flagset.Parse(args); err != nil {
+		return err
+	}
+
+	args = flagset.Args()
+	if len(args) <= 0 {
+		return errors.New("missing subcommand list|replay")
+	}
+	if len(args) < 2 {
+		return errors.New("missing sink argument")
+	}
+	pipelineFile := defaultPipelineFile
+	if len(args) > 2 {
+		pipelineFile = args[2]
+	}
+
+	if args[0] == "list" {
+		log.Orig().Out = ioutil.Discard
+	}
+
+	builder, err := newBuilder(pipelineFile)
+	if err != nil {
+		return err
+	}
+	if builder.sourceNode == nil {
+		return errors.New("no source defined in pipeline")
+	}
+	var sink *pipeline.Node
+	for _, source := range builder.sources {
+		if n := source.Find(args[1]); n != nil && n != source {
+			sink = n
+			break
+		}
+	}
+	if sink == nil {
+		return fmt.Errorf("no sink named %s in pipeline", args[1])
+	}
+
+	switch args[0] {
+	case "list":
+		records, err := sink.DeadLetters()
+		if err != nil {
+			return err
+		}
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"offset", "namespace", "op", "attempts", "error"})
+		for _, r := range records {
+			table.Append([]string{strconv.FormatUint(r.Offset, 10), r.Namespace, r.Op, strconv.Itoa(r.Attempts), r.Error})
+		}
+		table.Render()
+	case "replay":
+		replayed, err := sink.ReplayDeadLetters()
+		if err != nil {
+			return err
+		}
+		remaining, err := sink.DeadLetters()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "replayed %d messages, %d failed and remain dead-lettered\n", replayed, len(remaining))
+	default:
+		return fmt.Errorf("unknown subcommand %s, expected list|replay", args[0])
+	}
+	return nil
+}
@@ -2,14 +2,17 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/compose/transporter/events"
 	"github.com/compose/transporter/offset"
 	"github.com/compose/transporter/pipeline"
 )
@@ -126,3 +129,40 @@ func TestNewBuilderWithEnv(t *testing.T) {
 		t.Errorf("misconfigured transporter\nexpected:\n%s\ngot:\n%s", expected, actual)
 	}
 }
+
+func TestNewBuilderWithNativeFunction(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "native_function")
+	if err != nil {
+		t.Fatalf("unexpected TempDir error, %s", err)
+	}
+	defer os.RemoveAll(dataDir)
+	in, out := filepath.Join(dataDir, "in.json"), filepath.Join(dataDir, "out.json")
+	if err := ioutil.WriteFile(in, []byte(`{"_id":"1","a":1,"b":2}`+"\n"), 0644); err != nil {
+		t.Fatalf("unexpected WriteFile error, %s", err)
+	}
+	os.Setenv("TEST_NATIVE_IN", in)
+	os.Setenv("TEST_NATIVE_OUT", out)
+	defer os.Unsetenv("TEST_NATIVE_IN")
+	defer os.Unsetenv("TEST_NATIVE_OUT")
+
+	builder, err := newBuilder("testdata/test_pipeline_native.js")
+	if err != nil {
+		t.Fatalf("unexpected error, %s", err)
+	}
+	p, err := pipeline.NewPipeline(version, builder.sourceNode, events.NoopEmitter(), time.Second)
+	if err != nil {
+		t.Fatalf("unexpected NewPipeline error, %s", err)
+	}
+	if err := p.Run(); err != nil {
+		t.Fatalf("unexpected Run error, %s", err)
+	}
+	p.Stop()
+
+	b, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("unexpected ReadFile error, %s", err)
+	}
+	if got := strings.TrimSpace(string(b)); got != `{"a":1}` {
+		t.Errorf("pick wasn't applied, expected {\"a\":1}, got %s", got)
+	}
+}
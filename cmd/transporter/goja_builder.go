@@ -6,17 +6,25 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/compose/mejson"
 	"github.com/compose/transporter/adaptor"
 	"github.com/compose/transporter/commitlog"
+	"github.com/compose/transporter/dlq"
 	"github.com/compose/transporter/events"
 	"github.com/compose/transporter/function"
+	"github.com/compose/transporter/log"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/ops"
 	"github.com/compose/transporter/offset"
 	"github.com/compose/transporter/pipeline"
 	"github.com/dop251/goja"
@@ -25,7 +33,9 @@ import (
 )
 
 const (
-	defaultNamespace = "/.*/"
+	defaultNamespace           = "/.*/"
+	defaultDeadLetterNamespace = "dead_letter"
+	sourceLogPrefix            = "__source"
 )
 
 func newBuilder(file string) (*Transporter, error) {
@@ -52,9 +62,41 @@ func newBuilder(file string) (*Transporter, error) {
 	if _, err := t.vm.RunString(string(ba)); err != nil {
 		return nil, err
 	}
+	if err := t.checkSources(); err != nil {
+		return nil, err
+	}
 	return t, nil
 }
 
+// checkSources returns an error unless every source is run as part of the pipeline, i.e. it's
+// the first source or it's joined to a sink fed by a source which is run.
+func (t *Transporter) checkSources() error {
+	run := map[*pipeline.Node]bool{t.sourceNode: true}
+	for found := true; found; {
+		found = false
+		for _, parents := range t.joins {
+			for _, p := range parents {
+				if !run[p] {
+					continue
+				}
+				for _, j := range parents {
+					if !run[j] {
+						run[j] = true
+						found = true
+					}
+				}
+				break
+			}
+		}
+	}
+	for _, source := range t.sources {
+		if !run[source] {
+			return fmt.Errorf("source %s is never run, it must be joined to a sink of the first source with Join()", source.Name)
+		}
+	}
+	return nil
+}
+
 // setConfigEnvironment replaces environment variables marked in the form ${FOO} with the
 // value stored in the environment variable `FOO`
 func setConfigEnvironment(ba []byte) []byte {
@@ -76,9 +118,14 @@ func setConfigEnvironment(ba []byte) []byte {
 // Transporter defins the top level construct for creating a pipeline.
 type Transporter struct {
 	vm *goja.Runtime
+	// vmMu serializes the routes calling functions in vm, which isn't safe for concurrent use
+	vmMu sync.Mutex
 
 	config     *config
 	sourceNode *pipeline.Node
+	sources    []*pipeline.Node
+	// joins holds the sources feeding each sink saved from joined sources
+	joins [][]*pipeline.Node
 }
 
 type config struct {
@@ -86,21 +133,75 @@ type config struct {
 	MaxSegmentBytes    int    `json:"max_segment_bytes"`
 	CompactionInterval string `json:"compaction_interval"`
 	WriteTimeout       string `json:"write_timeout"`
+	ErrorPolicy        string `json:"error_policy"`
+	Retry              *retry `json:"retry"`
+	MaxLag             int64  `json:"max_lag"`
+	LagPolicy          string `json:"lag_policy"`
+	BatchSize          int    `json:"batch_size"`
+	BatchInterval      string `json:"batch_interval"`
+	DrainTimeout       string `json:"drain_timeout"`
+}
+
+// retry configures a pipeline.RetryPolicy, any field which isn't set keeps the value from
+// pipeline.DefaultRetryPolicy.
+type retry struct {
+	MaxAttempts     *int     `json:"max_attempts"`
+	InitialInterval string   `json:"initial_interval"`
+	MaxInterval     string   `json:"max_interval"`
+	Deadline        string   `json:"deadline"`
+	Jitter          *float64 `json:"jitter"`
+}
+
+func (r *retry) policy() (pipeline.RetryPolicy, error) {
+	p := pipeline.DefaultRetryPolicy
+	if r == nil {
+		return p, nil
+	}
+	if r.MaxAttempts != nil {
+		p.MaxAttempts = *r.MaxAttempts
+	}
+	if r.Jitter != nil {
+		p.Jitter = *r.Jitter
+	}
+	for _, d := range []struct {
+		s string
+		v *time.Duration
+	}{
+		{r.InitialInterval, &p.InitialInterval},
+		{r.MaxInterval, &p.MaxInterval},
+		{r.Deadline, &p.Deadline},
+	} {
+		if d.s == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.s)
+		if err != nil {
+			return p, err
+		}
+		*d.v = v
+	}
+	return p, nil
 }
 
 // Node encapsulates a sink/source node in the pipeline.
 type Node struct {
+	t      *Transporter
 	vm     *goja.Runtime
 	parent *pipeline.Node
 	config *config
+	routes map[string]pipeline.Predicate
+	joined []*pipeline.Node
 }
 
 // Transformer encapsulates a pipeline.Transform and tracks the Source node.
 type Transformer struct {
+	t          *Transporter
 	vm         *goja.Runtime
 	source     *pipeline.Node
 	transforms []*pipeline.Transform
 	config     *config
+	routes     map[string]pipeline.Predicate
+	joined     []*pipeline.Node
 }
 
 // Adaptor wraps the underlyig adaptor.Adaptor to be exposed in the JS.
@@ -109,17 +210,34 @@ type Adaptor struct {
 	a    adaptor.Adaptor
 }
 
-func (t *Transporter) run() error {
+func (t *Transporter) run(adminAddr string) error {
 	var g group.Group
 	p, err := pipeline.NewPipeline(version, t.sourceNode, events.LogEmitter(), 5*time.Second)
 	if err != nil {
 		return err
 	}
+	drainTimeout := pipeline.DefaultDrainTimeout
+	if t.config.DrainTimeout != "" {
+		if drainTimeout, err = time.ParseDuration(t.config.DrainTimeout); err != nil {
+			return err
+		}
+	}
 	{
 		g.Add(func() error {
 			return p.Run()
 		}, func(error) {
-			p.Stop()
+			if err := p.Drain(drainTimeout); err != nil {
+				log.Errorf("failed to drain pipeline, %s", err)
+			}
+		})
+	}
+	if adminAddr != "" {
+		srv := &http.Server{Addr: adminAddr, Handler: adminHandler(p)}
+		g.Add(func() error {
+			log.With("addr", adminAddr).Infoln("serving admin endpoints")
+			return srv.ListenAndServe()
+		}, func(error) {
+			srv.Close()
 		})
 	}
 	{
@@ -147,7 +265,11 @@ func interrupt(cancel <-chan struct{}) error {
 // String represents the pipelines as a string
 func (t *Transporter) String() string {
 	out := "Transporter:\n"
-	out += fmt.Sprintf("%s", t.sourceNode.String())
+	sources := make([]string, len(t.sources))
+	for i, source := range t.sources {
+		sources[i] = source.String()
+	}
+	out += strings.Join(sources, "\n")
 	return out
 }
 
@@ -199,9 +321,14 @@ func (t *Transporter) Source(call goja.FunctionCall) goja.Value {
 		pipeline.WithCompactionInterval(t.config.CompactionInterval),
 	}
 	if t.config.LogDir != "" {
+		// the first source keeps its commitlog in log_dir, any others each have their own directory
+		path := t.config.LogDir
+		if t.sourceNode != nil {
+			path = filepath.Join(path, fmt.Sprintf("%s-%s", sourceLogPrefix, name))
+		}
 		options = append(options, pipeline.WithCommitLog(
 			[]commitlog.OptionFunc{
-				commitlog.WithPath(t.config.LogDir),
+				commitlog.WithPath(path),
 				commitlog.WithMaxSegmentBytes(int64(t.config.MaxSegmentBytes)),
 			}...))
 	}
@@ -210,8 +337,11 @@ func (t *Transporter) Source(call goja.FunctionCall) goja.Value {
 	if err != nil {
 		panic(err)
 	}
-	t.sourceNode = n
-	return t.vm.ToValue(&Node{t.vm, n, t.config})
+	if t.sourceNode == nil {
+		t.sourceNode = n
+	}
+	t.sources = append(t.sources, n)
+	return t.vm.ToValue(&Node{t: t, vm: t.vm, parent: n, config: t.config})
 }
 
 func (n *Node) Transform(call goja.FunctionCall) goja.Value {
@@ -221,10 +351,13 @@ func (n *Node) Transform(call goja.FunctionCall) goja.Value {
 		panic(err)
 	}
 	tf := &Transformer{
+		t:          n.t,
 		vm:         n.vm,
 		source:     n.parent,
 		transforms: make([]*pipeline.Transform, 0),
 		config:     n.config,
+		routes:     n.routes,
+		joined:     n.joined,
 	}
 	tf.transforms = append(tf.transforms, &pipeline.Transform{Name: name, Fn: f.(function.Function), NsFilter: compiledNs})
 	return n.vm.ToValue(tf)
@@ -242,14 +375,22 @@ func (tf *Transformer) Transform(call goja.FunctionCall) goja.Value {
 }
 
 func (n *Node) Save(call goja.FunctionCall) goja.Value {
-	name, out, namespace := exportArgs(call.Arguments)
+	args, opts := nodeOptions(call.Arguments)
+	name, out, namespace := exportArgs(args)
 	a := out.(Adaptor)
 	options := []pipeline.OptionFunc{
-		pipeline.WithParent(n.parent),
+		n.t.withParents(n.parent, n.joined),
 		pipeline.WithClient(a.a),
 		pipeline.WithWriter(a.a),
 		pipeline.WithWriteTimeout(n.config.WriteTimeout),
 	}
+	if p, ok := n.routes[name]; ok {
+		options = append(options, pipeline.WithRoute(p))
+	}
+	options = append(options, n.config.errorOptions(name, opts)...)
+	options = append(options, n.config.lagOption(opts))
+	options = append(options, parallelOptions(opts)...)
+	options = append(options, n.config.batchOptions(opts)...)
 
 	if n.config.LogDir != "" {
 		om, err := offset.NewLogManager(n.config.LogDir, name)
@@ -263,19 +404,27 @@ func (n *Node) Save(call goja.FunctionCall) goja.Value {
 	if err != nil {
 		panic(err)
 	}
-	return n.vm.ToValue(&Node{n.vm, child, n.config})
+	return n.vm.ToValue(&Node{t: n.t, vm: n.vm, parent: child, config: n.config})
 }
 
 func (tf *Transformer) Save(call goja.FunctionCall) goja.Value {
-	name, out, namespace := exportArgs(call.Arguments)
+	args, opts := nodeOptions(call.Arguments)
+	name, out, namespace := exportArgs(args)
 	a := out.(Adaptor)
 	options := []pipeline.OptionFunc{
-		pipeline.WithParent(tf.source),
+		tf.t.withParents(tf.source, tf.joined),
 		pipeline.WithClient(a.a),
 		pipeline.WithWriter(a.a),
 		pipeline.WithTransforms(tf.transforms),
 		pipeline.WithWriteTimeout(tf.config.WriteTimeout),
 	}
+	if p, ok := tf.routes[name]; ok {
+		options = append(options, pipeline.WithRoute(p))
+	}
+	options = append(options, tf.config.errorOptions(name, opts)...)
+	options = append(options, tf.config.lagOption(opts))
+	options = append(options, parallelOptions(opts)...)
+	options = append(options, tf.config.batchOptions(opts)...)
 
 	if tf.config.LogDir != "" {
 		om, err := offset.NewLogManager(tf.config.LogDir, name)
@@ -289,7 +438,285 @@ func (tf *Transformer) Save(call goja.FunctionCall) goja.Value {
 	if err != nil {
 		panic(err)
 	}
-	return tf.vm.ToValue(&Node{tf.vm, child, tf.config})
+	return tf.vm.ToValue(&Node{t: tf.t, vm: tf.vm, parent: child, config: tf.config})
+}
+
+// Route sends messages only to the sinks saved from the returned node whose route matches, e.g.
+// Route({"orders": {"namespace": "/^orders$/", "op": ["insert", "update"]}, "audit": {"fields": {"audited": true}}})
+// or Route({"big": function(msg) { return msg.data.total > 1000 }})
+// Sinks without a route receive every message.
+func (n *Node) Route(call goja.FunctionCall) goja.Value {
+	r := *n
+	r.routes = n.t.exportRoutes(call.Arguments)
+	return n.vm.ToValue(&r)
+}
+
+// Route sends messages only to the sinks saved from the returned transformer whose route matches.
+func (tf *Transformer) Route(call goja.FunctionCall) goja.Value {
+	r := *tf
+	r.routes = tf.t.exportRoutes(call.Arguments)
+	return tf.vm.ToValue(&r)
+}
+
+// Join feeds the sinks saved from the returned node with the messages of the other sources too,
+// e.g. users.Join(orders).Save("search", elasticsearch({...})). Each source keeps its own
+// commitlog and the sinks track the offsets of each source separately.
+func (n *Node) Join(call goja.FunctionCall) goja.Value {
+	j := *n
+	j.joined = append([]*pipeline.Node{}, n.joined...)
+	for _, arg := range call.Arguments {
+		source, ok := arg.Export().(*Node)
+		if !ok {
+			panic("Join requires source nodes")
+		}
+		j.joined = append(j.joined, source.parent)
+	}
+	return n.vm.ToValue(&j)
+}
+
+// withParents returns the option attaching a sink to parent, and any sources joined to it.
+func (t *Transporter) withParents(parent *pipeline.Node, joined []*pipeline.Node) pipeline.OptionFunc {
+	if len(joined) == 0 {
+		return pipeline.WithParent(parent)
+	}
+	parents := append([]*pipeline.Node{parent}, joined...)
+	t.joins = append(t.joins, parents)
+	return pipeline.WithParents(parents...)
+}
+
+// exportRoutes builds a pipeline.Predicate for each sink name in the routes object, a route is
+// either a namespace regex, a function returning whether the message matches or an object with
+// any of the following:
+// "op": an op or array of ops, e.g. "insert" or ["insert", "update"]
+// "namespace": a namespace regex, e.g. "/^orders$/"
+// "fields": an object of values the message's data must have, e.g. {"status": "paid", "customer.country": "CA"}
+func (t *Transporter) exportRoutes(args []goja.Value) map[string]pipeline.Predicate {
+	if len(args) == 0 {
+		panic("Route requires an object of sink names to routes")
+	}
+	if _, ok := args[0].Export().(map[string]interface{}); !ok {
+		panic("Route requires an object of sink names to routes")
+	}
+	routes := args[0].ToObject(t.vm)
+	predicates := make(map[string]pipeline.Predicate)
+	for _, name := range routes.Keys() {
+		r := routes.Get(name)
+		if fn, ok := goja.AssertFunction(r); ok {
+			predicates[name] = t.funcPredicate(name, fn)
+			continue
+		}
+		m, err := exportMatch(r.Export())
+		if err != nil {
+			panic(fmt.Errorf("invalid route for %s, %s", name, err))
+		}
+		predicates[name] = m.Predicate()
+	}
+	return predicates
+}
+
+// funcPredicate returns the pipeline.Predicate calling fn with the message in the same form the
+// transformer function receives it, i.e. {"ts": ..., "op": ..., "ns": ..., "data": {...}}.
+// A message isn't routed to the sink when fn fails.
+func (t *Transporter) funcPredicate(name string, fn goja.Callable) pipeline.Predicate {
+	return func(msg message.Msg) bool {
+		doc, err := mejson.Marshal(msg.Data().AsMap())
+		if err != nil {
+			log.With("sink", name).Errorf("unable to route message, %s", err)
+			return false
+		}
+		m := map[string]interface{}{"ts": msg.Timestamp(), "op": msg.OP().String(), "ns": msg.Namespace(), "data": doc}
+
+		t.vmMu.Lock()
+		defer t.vmMu.Unlock()
+		v, err := fn(goja.Undefined(), t.vm.ToValue(m))
+		if err != nil {
+			log.With("sink", name).Errorf("unable to route message, %s", err)
+			return false
+		}
+		return v.ToBoolean()
+	}
+}
+
+func exportMatch(r interface{}) (pipeline.Match, error) {
+	var m pipeline.Match
+	if ns, ok := r.(string); ok {
+		r = map[string]interface{}{"namespace": ns}
+	}
+	route, ok := r.(map[string]interface{})
+	if !ok {
+		return m, errors.New("route must be a namespace or an object")
+	}
+	for k, v := range route {
+		switch k {
+		case "op":
+			opList, ok := v.([]interface{})
+			if !ok {
+				opList = []interface{}{v}
+			}
+			for _, o := range opList {
+				s, ok := o.(string)
+				if !ok || s == "" {
+					return m, fmt.Errorf("invalid op %v", o)
+				}
+				op := ops.OpTypeFromString(s)
+				if op == ops.Unknown || op.String() != s {
+					return m, fmt.Errorf("invalid op %s", s)
+				}
+				m.Ops = append(m.Ops, op)
+			}
+		case "namespace":
+			s, ok := v.(string)
+			if !ok {
+				return m, fmt.Errorf("invalid namespace %v", v)
+			}
+			ns, err := regexp.Compile(strings.Trim(s, "/"))
+			if err != nil {
+				return m, err
+			}
+			m.Namespace = ns
+		case "fields":
+			fields, ok := v.(map[string]interface{})
+			if !ok {
+				return m, fmt.Errorf("invalid fields %v", v)
+			}
+			m.Fields = fields
+		default:
+			return m, fmt.Errorf("unknown key %s", k)
+		}
+	}
+	return m, nil
+}
+
+// errorOptions configures how the sink handles messages it fails to transform or write, the
+// retry and error_policy can be overridden for each sink along with where messages are dead-lettered:
+// dead_letter is either an adaptor, which messages are written to in dead_letter_namespace, or
+// the path of a file. By default messages are dead-lettered in a commit log in log_dir.
+func (c *config) errorOptions(name string, opts map[string]interface{}) []pipeline.OptionFunc {
+	policy := c.ErrorPolicy
+	if p, ok := opts["error_policy"].(string); ok {
+		policy = p
+	}
+	r := c.Retry
+	if cfg, ok := opts["retry"]; ok {
+		b, err := json.Marshal(cfg)
+		if err != nil {
+			panic(err)
+		}
+		r = &retry{}
+		if err := json.Unmarshal(b, r); err != nil {
+			panic(err)
+		}
+	}
+	retryPolicy, err := r.policy()
+	if err != nil {
+		panic(err)
+	}
+	options := []pipeline.OptionFunc{
+		pipeline.WithErrorPolicy(policy),
+		pipeline.WithRetryPolicy(retryPolicy),
+	}
+	if pipeline.ErrorPolicy(policy) != pipeline.PolicyDeadLetter {
+		return options
+	}
+	switch dl := opts["dead_letter"].(type) {
+	case Adaptor:
+		ns, ok := opts["dead_letter_namespace"].(string)
+		if !ok {
+			ns = defaultDeadLetterNamespace
+		}
+		options = append(options, pipeline.WithDeadLetterAdaptor(dl.a, ns))
+	case string:
+		q, err := dlq.NewFileQueue(dl)
+		if err != nil {
+			panic(err)
+		}
+		options = append(options, pipeline.WithDeadLetterQueue(q))
+	default:
+		if c.LogDir != "" {
+			q, err := dlq.NewLogQueue(c.LogDir, name)
+			if err != nil {
+				panic(err)
+			}
+			options = append(options, pipeline.WithDeadLetterQueue(q))
+		}
+	}
+	return options
+}
+
+// lagOption configures how far the sink can fall behind the source's commit log, max_lag and
+// lag_policy can be overridden for each sink.
+func (c *config) lagOption(opts map[string]interface{}) pipeline.OptionFunc {
+	maxLag := c.MaxLag
+	switch l := opts["max_lag"].(type) {
+	case int64:
+		maxLag = l
+	case float64:
+		maxLag = int64(l)
+	}
+	policy := c.LagPolicy
+	if p, ok := opts["lag_policy"].(string); ok {
+		policy = p
+	}
+	return pipeline.WithLagLimit(maxLag, policy)
+}
+
+// batchOptions returns the options bounding the batches written by a sink whose adaptor writes in
+// batches, e.g. Save("sink", file({...}), "/.*/", {"batch_size": 1000, "batch_interval": "5s"})
+func (c *config) batchOptions(opts map[string]interface{}) []pipeline.OptionFunc {
+	size := c.BatchSize
+	switch s := opts["batch_size"].(type) {
+	case int64:
+		size = int(s)
+	case float64:
+		size = int(s)
+	}
+	interval := c.BatchInterval
+	if i, ok := opts["batch_interval"].(string); ok {
+		interval = i
+	}
+	return []pipeline.OptionFunc{
+		pipeline.WithBatchSize(size),
+		pipeline.WithBatchInterval(interval),
+	}
+}
+
+// parallelOptions returns the options setting how many workers a sink writes with and the fields
+// hashed to pick a message's worker, e.g.
+// Save("sink", mongodb({...}), "/.*/", {"parallelism": 4, "partition_key": ["customer_id"]})
+func parallelOptions(opts map[string]interface{}) []pipeline.OptionFunc {
+	var workers int
+	switch p := opts["parallelism"].(type) {
+	case int64:
+		workers = int(p)
+	case float64:
+		workers = int(p)
+	}
+	var key []string
+	switch k := opts["partition_key"].(type) {
+	case string:
+		key = []string{k}
+	case []interface{}:
+		for _, field := range k {
+			if f, ok := field.(string); ok {
+				key = append(key, f)
+			}
+		}
+	}
+	return []pipeline.OptionFunc{
+		pipeline.WithParallelism(workers),
+		pipeline.WithPartitionKey(key...),
+	}
+}
+
+// nodeOptions splits the optional object of node options from the end of the arguments, e.g.
+// Save("sink", mongodb({...}), "/.*/", {"error_policy": "skip"})
+func nodeOptions(args []goja.Value) ([]goja.Value, map[string]interface{}) {
+	if len(args) > 1 {
+		if opts, ok := args[len(args)-1].Export().(map[string]interface{}); ok {
+			return args[:len(args)-1], opts
+		}
+	}
+	return args, map[string]interface{}{}
 }
 
 // arguments can be any of the following forms:
@@ -29,6 +29,7 @@ func usage() {
 	fmt.Fprintf(os.Stderr, "  init      initialize a config and pipeline file based from provided adaptors\n")
 	fmt.Fprintf(os.Stderr, "  xlog      manage the commit log\n")
 	fmt.Fprintf(os.Stderr, "  offset    manage the offset for sinks\n")
+	fmt.Fprintf(os.Stderr, "  dlq       list and replay messages dead-lettered by sinks\n")
 	fmt.Fprintf(os.Stderr, "\n")
 	fmt.Fprintf(os.Stderr, "VERSION\n")
 	fmt.Fprintf(os.Stderr, "  %s\n", version)
@@ -55,6 +56,8 @@ func main() {
 		run = runXlog
 	case "offset":
 		run = runOffset
+	case "dlq":
+		run = runDLQ
 	default:
 		usage()
 		os.Exit(1)
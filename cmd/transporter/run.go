@@ -2,6 +2,7 @@ package main
 
 func runRun(args []string) error {
 	flagset := baseFlagSet("run")
+	adminAddr := flagset.String("admin", "", "address to serve the pause/resume endpoints on, e.g. localhost:4242, disabled by default")
 	flagset.Usage = usageFor(flagset, "transporter run [flags] <pipeline>")
 	if err := flagset.Parse(args); err != nil {
 		return err
@@ -18,5 +19,5 @@ func runRun(args []string) error {
 		return err
 	}
 
-	return builder.run()
+	return builder.run(*adminAddr)
 }
@@ -0,0 +1,3 @@
+t.Source("source", file({uri: "file://${TEST_NATIVE_IN}"}))
+  .Transform(pick({fields: ["a"]}))
+  .Save("sink", file({uri: "file://${TEST_NATIVE_OUT}"}))
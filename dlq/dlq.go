@@ -0,0 +1,74 @@
+// Package dlq provides dead-letter queues used to record the messages a node failed to transform
+// or write so they can be inspected and replayed once the cause has been fixed.
+package dlq
+
+import (
+	"errors"
+	"time"
+
+	"github.com/compose/mejson"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/ops"
+	"github.com/compose/transporter/offset"
+)
+
+var (
+	// ErrInvalidRecord is returned when the data stored in a Record isn't a document.
+	ErrInvalidRecord = errors.New("dead letter record data is not a document")
+)
+
+// Record describes a message which failed to be processed by the node at Path.
+type Record struct {
+	Path      string      `json:"path"`
+	Namespace string      `json:"ns"`
+	Op        string      `json:"op"`
+	Data      interface{} `json:"data"`
+	Error     string      `json:"error"`
+	Offset    uint64      `json:"offset"`
+	Attempts  int         `json:"attempts"`
+	Timestamp int64       `json:"ts"`
+}
+
+// NewRecord creates a Record for msg, the data is stored as mongo extended JSON so types such as
+// ObjectIds and dates survive being replayed.
+func NewRecord(path string, msg message.Msg, off offset.Offset, attempts int, err error) (Record, error) {
+	d, merr := mejson.Marshal(msg.Data().AsMap())
+	if merr != nil {
+		return Record{}, merr
+	}
+	return Record{
+		Path:      path,
+		Namespace: msg.Namespace(),
+		Op:        msg.OP().String(),
+		Data:      d,
+		Error:     err.Error(),
+		Offset:    off.LogOffset,
+		Attempts:  attempts,
+		Timestamp: time.Now().Unix(),
+	}, nil
+}
+
+// Msg rebuilds the message.Msg which failed.
+func (r Record) Msg() (message.Msg, error) {
+	m, ok := r.Data.(map[string]interface{})
+	if !ok {
+		return nil, ErrInvalidRecord
+	}
+	d, err := mejson.Unmarshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return message.From(ops.OpTypeFromString(r.Op), r.Namespace, map[string]interface{}(d)), nil
+}
+
+// Queue stores the Records of failed messages.
+type Queue interface {
+	Put(Record) error
+}
+
+// Replayer is implemented by a Queue whose Records can be read back. Reset replaces every stored
+// Record with the ones provided, i.e. those which failed again when replayed.
+type Replayer interface {
+	Records() ([]Record, error)
+	Reset([]Record) error
+}
@@ -0,0 +1,101 @@
+package dlq_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/compose/transporter/dlq"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/ops"
+	"github.com/compose/transporter/offset"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func init() {
+	rand.Seed(time.Now().Unix())
+}
+
+var (
+	testID  = bson.NewObjectId()
+	testErr = errors.New("write failed")
+)
+
+func testRecord(t *testing.T, i int) dlq.Record {
+	msg := message.From(ops.Update, "orders", map[string]interface{}{"_id": testID, "i": i})
+	r, err := dlq.NewRecord("source/sink", msg, offset.Offset{Namespace: "orders", LogOffset: uint64(i)}, 1, testErr)
+	if err != nil {
+		t.Fatalf("unexpected NewRecord error, %s", err)
+	}
+	return r
+}
+
+func TestRecord(t *testing.T) {
+	r := testRecord(t, 7)
+	if r.Path != "source/sink" || r.Error != testErr.Error() || r.Offset != 7 || r.Attempts != 1 || r.Op != "update" {
+		t.Errorf("wrong record, %+v", r)
+	}
+	// records are persisted as JSON so make sure the message survives the round trip
+	b, _ := json.Marshal(r)
+	var decoded dlq.Record
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unexpected Unmarshal error, %s", err)
+	}
+	msg, err := decoded.Msg()
+	if err != nil {
+		t.Fatalf("unexpected Msg error, %s", err)
+	}
+	if msg.OP() != ops.Update || msg.Namespace() != "orders" {
+		t.Errorf("wrong message, %s %s", msg.OP(), msg.Namespace())
+	}
+	if !reflect.DeepEqual(msg.Data().Get("_id"), testID) {
+		t.Errorf("wrong _id, expected %v, got %v", testID, msg.Data().Get("_id"))
+	}
+}
+
+func TestRecordInvalid(t *testing.T) {
+	if _, err := (dlq.Record{Op: "insert", Data: "oops"}).Msg(); err != dlq.ErrInvalidRecord {
+		t.Errorf("wrong Msg error, expected %s, got %v", dlq.ErrInvalidRecord, err)
+	}
+}
+
+// testQueue puts count records in q and verifies they're read back and can be reset.
+func testQueue(t *testing.T, q interface {
+	dlq.Queue
+	dlq.Replayer
+}, count int) {
+	for i := 0; i < count; i++ {
+		if err := q.Put(testRecord(t, i)); err != nil {
+			t.Fatalf("unexpected Put error, %s", err)
+		}
+	}
+	records, err := q.Records()
+	if err != nil {
+		t.Fatalf("unexpected Records error, %s", err)
+	}
+	if len(records) != count {
+		t.Fatalf("wrong number of records, expected %d, got %d", count, len(records))
+	}
+	for i, r := range records {
+		if r.Offset != uint64(i) {
+			t.Errorf("records out of order, expected offset %d, got %d", i, r.Offset)
+		}
+	}
+	if err := q.Reset(records[count-2:]); err != nil {
+		t.Fatalf("unexpected Reset error, %s", err)
+	}
+	if err := q.Put(testRecord(t, count)); err != nil {
+		t.Fatalf("unexpected Put error, %s", err)
+	}
+	records, err = q.Records()
+	if err != nil {
+		t.Fatalf("unexpected Records error, %s", err)
+	}
+	if len(records) != 3 || records[0].Offset != uint64(count-2) || records[2].Offset != uint64(count) {
+		t.Errorf("wrong records after Reset, %+v", records)
+	}
+}
@@ -0,0 +1,111 @@
+package dlq
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	_ Queue    = &FileQueue{}
+	_ Replayer = &FileQueue{}
+)
+
+// FileQueue stores each Record as a line of JSON in a file.
+type FileQueue struct {
+	path string
+	f    *os.File
+	sync.Mutex
+}
+
+// NewFileQueue opens, or creates, the file at path and appends any new Records to it.
+func NewFileQueue(path string) (*FileQueue, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileQueue{path: path, f: f}, nil
+}
+
+// Put appends the Record to the file.
+func (q *FileQueue) Put(r Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	q.Lock()
+	defer q.Unlock()
+	_, err = q.f.Write(append(b, '\n'))
+	return err
+}
+
+// Records reads every Record stored in the file.
+func (q *FileQueue) Records() ([]Record, error) {
+	q.Lock()
+	defer q.Unlock()
+	f, err := os.Open(q.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	records := make([]Record, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// Reset rewrites the file so it only contains records, the new file is swapped in once it has
+// been written so nothing is lost if transporter stops part way through.
+func (q *FileQueue) Reset(records []Record) error {
+	q.Lock()
+	defer q.Unlock()
+	swap := q.path + ".swap"
+	f, err := os.Create(swap)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, r := range records {
+		b, err := json.Marshal(r)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(append(b, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(swap, q.path); err != nil {
+		return err
+	}
+	q.f.Close()
+	q.f, err = os.OpenFile(q.path, os.O_APPEND|os.O_WRONLY, 0644)
+	return err
+}
+
+// Close closes the underlying file.
+func (q *FileQueue) Close() error {
+	q.Lock()
+	defer q.Unlock()
+	return q.f.Close()
+}
@@ -0,0 +1,22 @@
+package dlq_test
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/compose/transporter/dlq"
+)
+
+func TestFileQueue(t *testing.T) {
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("dlqfiletest%d", rand.Int63()))
+	defer os.RemoveAll(dir)
+	q, err := dlq.NewFileQueue(filepath.Join(dir, "sink.json"))
+	if err != nil {
+		t.Fatalf("unexpected NewFileQueue error, %s", err)
+	}
+	defer q.Close()
+	testQueue(t, q, 10)
+}
@@ -0,0 +1,128 @@
+package dlq
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+
+	"github.com/compose/transporter/commitlog"
+	"github.com/compose/transporter/message/ops"
+)
+
+const (
+	// LogPrefixDir is prepended to the name of the node to create the directory of its LogQueue.
+	LogPrefixDir = "__dead_letter"
+)
+
+var (
+	_ Queue    = &LogQueue{}
+	_ Replayer = &LogQueue{}
+)
+
+// LogQueue stores Records in a commitlog.CommitLog dedicated to a single node, each entry is keyed
+// by the namespace of the message and its value is the JSON encoded Record.
+type LogQueue struct {
+	path string
+	log  *commitlog.CommitLog
+	sync.Mutex
+}
+
+// NewLogQueue opens, or creates, the dead-letter log for the node name in the directory path.
+func NewLogQueue(path, name string) (*LogQueue, error) {
+	q := &LogQueue{path: filepath.Join(path, fmt.Sprintf("%s-%s", LogPrefixDir, name))}
+	l, err := q.open()
+	if err != nil {
+		return nil, err
+	}
+	q.log = l
+	return q, nil
+}
+
+func (q *LogQueue) open() (*commitlog.CommitLog, error) {
+	return commitlog.New(
+		commitlog.WithPath(q.path),
+		commitlog.WithMaxSegmentBytes(1024*1024*1024),
+	)
+}
+
+// Put appends the Record to the log.
+func (q *LogQueue) Put(r Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	q.Lock()
+	defer q.Unlock()
+	return q.append(r, b)
+}
+
+func (q *LogQueue) append(r Record, b []byte) error {
+	_, err := q.log.Append(commitlog.NewLogFromEntry(commitlog.LogEntry{
+		Key:       []byte(r.Namespace),
+		Mode:      commitlog.Sync,
+		Op:        ops.OpTypeFromString(r.Op),
+		Timestamp: uint64(r.Timestamp),
+		Value:     b,
+	}))
+	return err
+}
+
+// Records reads every Record stored in the log.
+func (q *LogQueue) Records() ([]Record, error) {
+	q.Lock()
+	defer q.Unlock()
+	records := make([]Record, 0)
+	if q.log.NewestOffset() == q.log.OldestOffset() {
+		return records, nil
+	}
+	rd, err := q.log.NewReader(q.log.OldestOffset())
+	if err != nil {
+		return nil, err
+	}
+	for {
+		_, e, err := commitlog.ReadEntry(rd)
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+		var r Record
+		if err := json.Unmarshal(e.Value, &r); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+}
+
+// Reset deletes the log and appends records to a new one.
+func (q *LogQueue) Reset(records []Record) error {
+	q.Lock()
+	defer q.Unlock()
+	if err := q.log.DeleteAll(); err != nil {
+		return err
+	}
+	l, err := q.open()
+	if err != nil {
+		return err
+	}
+	q.log = l
+	for _, r := range records {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if err := q.append(r, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the underlying commitlog.CommitLog.
+func (q *LogQueue) Close() error {
+	q.Lock()
+	defer q.Unlock()
+	return q.log.Close()
+}
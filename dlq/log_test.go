@@ -0,0 +1,37 @@
+package dlq_test
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/compose/transporter/dlq"
+)
+
+func TestLogQueue(t *testing.T) {
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("dlqlogtest%d", rand.Int63()))
+	defer os.RemoveAll(dir)
+	q, err := dlq.NewLogQueue(dir, "sink")
+	if err != nil {
+		t.Fatalf("unexpected NewLogQueue error, %s", err)
+	}
+	records, err := q.Records()
+	if err != nil || len(records) != 0 {
+		t.Errorf("expected no records in a new log, got %d, %v", len(records), err)
+	}
+	testQueue(t, q, 10)
+	q.Close()
+
+	// records are kept when the log is opened again
+	q, err = dlq.NewLogQueue(dir, "sink")
+	if err != nil {
+		t.Fatalf("unexpected NewLogQueue error, %s", err)
+	}
+	defer q.Close()
+	records, err = q.Records()
+	if err != nil || len(records) != 3 {
+		t.Errorf("wrong number of records after reopening, expected 3, got %d, %v", len(records), err)
+	}
+}
@@ -0,0 +1,46 @@
+package dlq
+
+import (
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/ops"
+)
+
+var (
+	_ Queue = &WriterQueue{}
+)
+
+// WriterQueue sends each Record to another adaptor, the Record is inserted as a document in the
+// namespace provided, e.g. a collection in mongodb or an index in elasticsearch. Records written
+// to an adaptor can't be replayed with a Replayer, they need to be read by a pipeline using the
+// adaptor as its source.
+type WriterQueue struct {
+	c  client.Client
+	w  client.Writer
+	ns string
+}
+
+// NewWriterQueue creates a WriterQueue which writes to ns with the client.Writer.
+func NewWriterQueue(c client.Client, w client.Writer, ns string) *WriterQueue {
+	return &WriterQueue{c, w, ns}
+}
+
+// Put inserts the Record, the data of the failed message is stored with its original types rather
+// than as extended JSON.
+func (q *WriterQueue) Put(r Record) error {
+	m, err := r.Msg()
+	if err != nil {
+		return err
+	}
+	_, err = client.Write(q.c, q.w, message.From(ops.Insert, q.ns, map[string]interface{}{
+		"path":     r.Path,
+		"ns":       r.Namespace,
+		"op":       r.Op,
+		"data":     m.Data().AsMap(),
+		"error":    r.Error,
+		"offset":   int64(r.Offset),
+		"attempts": r.Attempts,
+		"ts":       r.Timestamp,
+	}))
+	return err
+}
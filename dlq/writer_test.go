@@ -0,0 +1,43 @@
+package dlq_test
+
+import (
+	"testing"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/dlq"
+	"github.com/compose/transporter/message"
+)
+
+type recordWriter struct {
+	msgs []message.Msg
+}
+
+func (w *recordWriter) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	return func(client.Session) (message.Msg, error) {
+		w.msgs = append(w.msgs, msg)
+		return msg, nil
+	}
+}
+
+func TestWriterQueue(t *testing.T) {
+	w := &recordWriter{}
+	q := dlq.NewWriterQueue(&client.Mock{}, w, "dead_letters")
+	if err := q.Put(testRecord(t, 3)); err != nil {
+		t.Fatalf("unexpected Put error, %s", err)
+	}
+	if len(w.msgs) != 1 {
+		t.Fatalf("wrong number of messages written, expected 1, got %d", len(w.msgs))
+	}
+	m := w.msgs[0]
+	if m.Namespace() != "dead_letters" || m.Data().Get("error") != testErr.Error() || m.Data().Get("path") != "source/sink" {
+		t.Errorf("wrong message written, %s %+v", m.Namespace(), m.Data())
+	}
+	if d := m.Data().Get("data").(map[string]interface{}); d["_id"] != testID {
+		t.Errorf("wrong data written, expected _id %v, got %v", testID, d["_id"])
+	}
+
+	q = dlq.NewWriterQueue(&client.Mock{}, &client.MockErrWriter{}, "dead_letters")
+	if err := q.Put(testRecord(t, 3)); err != client.ErrMockWrite {
+		t.Errorf("wrong Put error, expected %s, got %v", client.ErrMockWrite, err)
+	}
+}
@@ -3,6 +3,7 @@ package events
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/compose/transporter/log"
 )
@@ -136,3 +137,164 @@ func (e *errorEvent) String() string {
 func (e *errorEvent) Logger() log.Logger {
 	return log.With("ts", e.Ts).With("path", e.Path)
 }
+
+// schemaEvent is an event that indicates a sink altered the schema of the underlying
+// database, i.e. created a table or added a column.
+type schemaEvent struct {
+	Ts   int64  `json:"ts"`
+	Kind string `json:"name"`
+
+	// Namespace is the table/collection the change was applied to
+	Namespace string `json:"namespace"`
+
+	// Statement describes the change that was applied
+	Statement string `json:"statement"`
+}
+
+// NewSchemaEvent creates a new event to record a schema change made by a sink
+func NewSchemaEvent(ts int64, namespace, statement string) Event {
+	e := &schemaEvent{
+		Ts:        ts,
+		Kind:      "schema",
+		Namespace: namespace,
+		Statement: statement,
+	}
+	return e
+}
+
+// Emit prepares the event to be emitted and marshalls the event into an json
+func (e *schemaEvent) Emit() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+func (e *schemaEvent) String() string {
+	return fmt.Sprintf("%s %s %s", e.Kind, e.Namespace, e.Statement)
+}
+
+func (e *schemaEvent) Logger() log.Logger {
+	return log.With("ts", e.Ts).With("namespace", e.Namespace)
+}
+
+// retryEvent is an event that indicates a node is going to retry a failed write
+type retryEvent struct {
+	Ts   int64  `json:"ts"`
+	Kind string `json:"name"`
+	Path string `json:"path"`
+
+	// Attempt is the number of the attempt which failed, starting at 1
+	Attempt int `json:"attempt"`
+
+	// Wait is how many milliseconds the node waits before the next attempt
+	Wait int64 `json:"wait_ms"`
+
+	// Message is the error message as a string
+	Message string `json:"message,omitempty"`
+}
+
+// NewRetryEvent creates a new event sent when a node waits to retry a failed write
+func NewRetryEvent(ts int64, path string, attempt int, wait time.Duration, message string) Event {
+	e := &retryEvent{
+		Ts:      ts,
+		Kind:    "retry",
+		Path:    path,
+		Attempt: attempt,
+		Wait:    int64(wait / time.Millisecond),
+		Message: message,
+	}
+	return e
+}
+
+// Emit prepares the event to be emitted and marshalls the event into an json
+func (e *retryEvent) Emit() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+func (e *retryEvent) String() string {
+	return fmt.Sprintf("%s attempt: %d, wait: %dms, message: %s", e.Kind, e.Attempt, e.Wait, e.Message)
+}
+
+func (e *retryEvent) Logger() log.Logger {
+	return log.With("ts", e.Ts).With("path", e.Path)
+}
+
+// lagEvent is an event that indicates how far a node has fallen behind its source
+type lagEvent struct {
+	Ts   int64  `json:"ts"`
+	Kind string `json:"name"`
+	Path string `json:"path"`
+
+	// Lag is the number of messages in the source's commitlog the node hasn't received yet
+	Lag int64 `json:"lag"`
+
+	// Limit is the lag the node is allowed
+	Limit int64 `json:"limit"`
+}
+
+// NewLagEvent creates a new event sent when a node's lag goes over its limit, or back under it
+func NewLagEvent(ts int64, path string, lag, limit int64) Event {
+	e := &lagEvent{
+		Ts:    ts,
+		Kind:  "lag",
+		Path:  path,
+		Lag:   lag,
+		Limit: limit,
+	}
+	return e
+}
+
+// Emit prepares the event to be emitted and marshalls the event into an json
+func (e *lagEvent) Emit() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+func (e *lagEvent) String() string {
+	return fmt.Sprintf("%s lag: %d, limit: %d", e.Kind, e.Lag, e.Limit)
+}
+
+func (e *lagEvent) Logger() log.Logger {
+	return log.With("ts", e.Ts).With("path", e.Path)
+}
+
+// drainEvent is an event that summarizes how the pipeline drained when it was stopped
+type drainEvent struct {
+	Ts   int64  `json:"ts"`
+	Kind string `json:"name"`
+
+	// Duration is how many milliseconds the drain took
+	Duration int64 `json:"duration_ms"`
+
+	// Complete is false if the drain timed out before every message was written
+	Complete bool `json:"complete"`
+
+	// Records is the number of documents each node transmitted, by path
+	Records map[string]int `json:"records"`
+
+	// Offsets is the last offset committed by each node tracking offsets, by path
+	Offsets map[string]int64 `json:"offsets,omitempty"`
+}
+
+// NewDrainEvent creates a new event sent once the pipeline has drained, or given up draining
+func NewDrainEvent(ts int64, duration time.Duration, complete bool, records map[string]int, offsets map[string]int64) Event {
+	e := &drainEvent{
+		Ts:       ts,
+		Kind:     "drain",
+		Duration: int64(duration / time.Millisecond),
+		Complete: complete,
+		Records:  records,
+		Offsets:  offsets,
+	}
+	return e
+}
+
+// Emit prepares the event to be emitted and marshalls the event into an json
+func (e *drainEvent) Emit() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+func (e *drainEvent) String() string {
+	return fmt.Sprintf("%s complete: %t, duration: %dms, records: %v, offsets: %v", e.Kind, e.Complete, e.Duration, e.Records, e.Offsets)
+}
+
+func (e *drainEvent) Logger() log.Logger {
+	return log.With("ts", e.Ts)
+}
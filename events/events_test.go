@@ -3,6 +3,7 @@ package events
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestEvent(t *testing.T) {
@@ -36,6 +37,26 @@ func TestEvent(t *testing.T) {
 			[]byte(`{"ts":12345,"name":"error","path":"test","record":{"hello":"world"},"message":"something broke"}`),
 			`error record: map[hello:world], message: something broke`,
 		},
+		{
+			NewSchemaEvent(12345, "public.heros", "ALTER TABLE public.heros ADD COLUMN name text"),
+			[]byte(`{"ts":12345,"name":"schema","namespace":"public.heros","statement":"ALTER TABLE public.heros ADD COLUMN name text"}`),
+			`schema public.heros ALTER TABLE public.heros ADD COLUMN name text`,
+		},
+		{
+			NewRetryEvent(12345, "test", 2, 1500*time.Millisecond, "connection refused"),
+			[]byte(`{"ts":12345,"name":"retry","path":"test","attempt":2,"wait_ms":1500,"message":"connection refused"}`),
+			`retry attempt: 2, wait: 1500ms, message: connection refused`,
+		},
+		{
+			NewLagEvent(12345, "test/sink", 2000, 1000),
+			[]byte(`{"ts":12345,"name":"lag","path":"test/sink","lag":2000,"limit":1000}`),
+			`lag lag: 2000, limit: 1000`,
+		},
+		{
+			NewDrainEvent(12345, 1500*time.Millisecond, true, map[string]int{"test": 10}, map[string]int64{"test/sink": 9}),
+			[]byte(`{"ts":12345,"name":"drain","duration_ms":1500,"complete":true,"records":{"test":10},"offsets":{"test/sink":9}}`),
+			`drain complete: true, duration: 1500ms, records: map[test:10], offsets: map[test/sink:9]`,
+		},
 	}
 
 	for _, d := range data {
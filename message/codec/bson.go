@@ -0,0 +1,32 @@
+package codec
+
+import (
+	"github.com/compose/transporter/message/data"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func init() {
+	Add("bson", func(Options) (Codec, error) { return BSON{}, nil }, "application/bson")
+}
+
+// BSON encodes documents as BSON, preserving types such as ObjectIds and dates.
+type BSON struct{}
+
+// Encode implements Codec.
+func (BSON) Encode(d data.Data) ([]byte, error) {
+	return bson.Marshal(map[string]interface{}(d))
+}
+
+// Decode implements Codec.
+func (BSON) Decode(b []byte) (data.Data, error) {
+	var d bson.M
+	if err := bson.Unmarshal(b, &d); err != nil {
+		return nil, err
+	}
+	return data.Data(d), nil
+}
+
+// ContentType implements Codec.
+func (BSON) ContentType() string {
+	return "application/bson"
+}
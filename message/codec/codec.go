@@ -0,0 +1,80 @@
+// Package codec converts message data to and from the bytes published to, or consumed from,
+// message brokers so queue like adaptors can share the supported formats.
+package codec
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/compose/transporter/message/data"
+)
+
+// Codec encodes message data into a message body and decodes it back.
+type Codec interface {
+	Encode(data.Data) ([]byte, error)
+	Decode([]byte) (data.Data, error)
+	ContentType() string
+}
+
+// Options holds the settings needed by codecs which can't be created from their name alone.
+type Options struct {
+	ProtoDescriptor string `json:"proto_descriptor"`
+	ProtoMessage    string `json:"proto_message"`
+}
+
+// ErrNotFound gives the details of the codec which isn't registered.
+type ErrNotFound struct {
+	Name string
+}
+
+func (e ErrNotFound) Error() string {
+	return fmt.Sprintf("codec '%s' not found in registry", e.Name)
+}
+
+// Creator defines the init structure for a Codec.
+type Creator func(Options) (Codec, error)
+
+var (
+	codecs       = map[string]Creator{}
+	contentTypes = map[string]string{}
+)
+
+// Add should be called in init func of an implementing Codec, the content types are used by
+// ForContentType to find the codec for a message.
+func Add(name string, creator Creator, types ...string) {
+	codecs[name] = creator
+	for _, contentType := range types {
+		contentTypes[contentType] = name
+	}
+}
+
+// GetCodec looks up a codec by name and creates it with the provided Options.
+// returns ErrNotFound if the provided name was not registered.
+func GetCodec(name string, opts Options) (Codec, error) {
+	creator, ok := codecs[strings.ToLower(name)]
+	if !ok {
+		return nil, ErrNotFound{name}
+	}
+	return creator(opts)
+}
+
+// ForContentType returns the name of the codec registered for the content type, parameters such
+// as the charset are ignored. false is returned when the content type isn't known.
+func ForContentType(contentType string) (string, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	name, ok := contentTypes[mediaType]
+	return name, ok
+}
+
+// RegisteredCodecs returns a slice of the names of every codec registered.
+func RegisteredCodecs() []string {
+	all := make([]string, 0)
+	for i := range codecs {
+		all = append(all, i)
+	}
+	return all
+}
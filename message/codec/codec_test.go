@@ -0,0 +1,104 @@
+package codec
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/compose/transporter/message/data"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var forContentTypeTests = []struct {
+	contentType string
+	want        string
+	ok          bool
+}{
+	{"application/json", "json", true},
+	{"application/json; charset=utf-8", "json", true},
+	{"application/x-msgpack", "msgpack", true},
+	{"application/bson", "bson", true},
+	{"application/octet-stream", "raw", true},
+	{"application/x-protobuf", "protobuf", true},
+	{"text/plain", "", false},
+	{"", "", false},
+}
+
+func TestForContentType(t *testing.T) {
+	for _, ft := range forContentTypeTests {
+		name, ok := ForContentType(ft.contentType)
+		if name != ft.want || ok != ft.ok {
+			t.Errorf("ForContentType(%q) = %s, %t, expected %s, %t", ft.contentType, name, ok, ft.want, ft.ok)
+		}
+	}
+}
+
+func TestGetCodec(t *testing.T) {
+	for _, name := range []string{"json", "JSON", "bson", "msgpack", "raw"} {
+		if _, err := GetCodec(name, Options{}); err != nil {
+			t.Errorf("unexpected GetCodec(%s) error, %s", name, err)
+		}
+	}
+	if _, err := GetCodec("xml", Options{}); err != (ErrNotFound{"xml"}) {
+		t.Errorf("wrong GetCodec error, expected %s, got %v", ErrNotFound{"xml"}, err)
+	}
+	if _, err := GetCodec("protobuf", Options{}); err != ErrMissingProtoDescriptor {
+		t.Errorf("wrong GetCodec error, expected %s, got %v", ErrMissingProtoDescriptor, err)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	c := JSON{}
+	b, err := c.Encode(data.Data{"name": "nick", "count": 2})
+	if err != nil {
+		t.Fatalf("unexpected Encode error, %s", err)
+	}
+	d, err := c.Decode(b)
+	if err != nil {
+		t.Fatalf("unexpected Decode error, %s", err)
+	}
+	want := data.Data{"name": "nick", "count": float64(2)}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("wrong data, expected %+v, got %+v", want, d)
+	}
+	if _, err := c.Decode([]byte("[1, 2]")); err == nil {
+		t.Error("expected error decoding an array but didn't get one")
+	}
+}
+
+func TestBSON(t *testing.T) {
+	c := BSON{}
+	id := bson.NewObjectId()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	b, err := c.Encode(data.Data{"_id": id, "created": now, "count": 2})
+	if err != nil {
+		t.Fatalf("unexpected Encode error, %s", err)
+	}
+	d, err := c.Decode(b)
+	if err != nil {
+		t.Fatalf("unexpected Decode error, %s", err)
+	}
+	if d.Get("_id") != id || d.Get("count") != 2 || !d.Get("created").(time.Time).Equal(now) {
+		t.Errorf("wrong data, got %+v", d)
+	}
+}
+
+func TestRaw(t *testing.T) {
+	c := Raw{}
+	d, err := c.Decode([]byte("hello"))
+	if err != nil {
+		t.Fatalf("unexpected Decode error, %s", err)
+	}
+	if string(d.Get(RawField).([]byte)) != "hello" {
+		t.Errorf("wrong data, got %+v", d)
+	}
+	for _, v := range []interface{}{[]byte("hello"), "hello"} {
+		b, err := c.Encode(data.Data{RawField: v})
+		if err != nil || string(b) != "hello" {
+			t.Errorf("wrong Encode result for %T, got %s, %v", v, b, err)
+		}
+	}
+	if _, err := c.Encode(data.Data{"name": "hello"}); err == nil {
+		t.Error("expected error encoding without a data field but didn't get one")
+	}
+}
@@ -0,0 +1,34 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/compose/transporter/message/data"
+)
+
+func init() {
+	Add("json", func(Options) (Codec, error) { return JSON{}, nil }, "application/json", "text/json")
+}
+
+// JSON encodes documents as JSON objects.
+type JSON struct{}
+
+// Encode implements Codec.
+func (JSON) Encode(d data.Data) ([]byte, error) {
+	return json.Marshal(d)
+}
+
+// Decode implements Codec, the body must be a single JSON object.
+func (JSON) Decode(b []byte) (data.Data, error) {
+	var d map[string]interface{}
+	if err := json.NewDecoder(bytes.NewReader(b)).Decode(&d); err != nil {
+		return nil, err
+	}
+	return data.Data(d), nil
+}
+
+// ContentType implements Codec.
+func (JSON) ContentType() string {
+	return "application/json"
+}
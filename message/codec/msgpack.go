@@ -0,0 +1,154 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/compose/transporter/message/data"
+	"github.com/vmihailenco/msgpack"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	// ErrMsgpackNotMap is returned when a MessagePack body doesn't contain a map.
+	ErrMsgpackNotMap = errors.New("msgpack body is not a map")
+
+	// ErrMsgpackShort is returned when a MessagePack body ends in the middle of a value.
+	ErrMsgpackShort = errors.New("msgpack body is truncated")
+)
+
+func init() {
+	Add("msgpack", func(Options) (Codec, error) { return Msgpack{}, nil },
+		"application/msgpack", "application/x-msgpack", "application/vnd.msgpack")
+}
+
+// Msgpack encodes documents as MessagePack maps. Integers are decoded as int64, or uint64 when
+// they don't fit, and timestamps as time.Time, other extension types can't be decoded.
+type Msgpack struct{}
+
+// Encode implements Codec.
+func (Msgpack) Encode(d data.Data) ([]byte, error) {
+	v, err := msgpackValue(map[string]interface{}(d))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := msgpack.NewEncoder(&buf).SortMapKeys(true).UseCompactEncoding(true).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode implements Codec.
+func (Msgpack) Decode(b []byte) (data.Data, error) {
+	v, err := msgpack.NewDecoder(bytes.NewReader(b)).DecodeInterface()
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, ErrMsgpackShort
+	} else if err != nil {
+		return nil, err
+	}
+	m, ok := fromMsgpack(v).(map[string]interface{})
+	if !ok {
+		return nil, ErrMsgpackNotMap
+	}
+	return data.Data(m), nil
+}
+
+// ContentType implements Codec.
+func (Msgpack) ContentType() string {
+	return "application/msgpack"
+}
+
+// msgpackValue converts the types which MessagePack has no representation for, ObjectIds are
+// encoded as their hex string and maps keyed by anything but strings with their keys formatted.
+func msgpackValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, bool, string, []byte, time.Time:
+		return v, nil
+	case bson.ObjectId:
+		return v.Hex(), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		m := make(map[string]interface{}, rv.Len())
+		for _, k := range rv.MapKeys() {
+			value, err := msgpackValue(rv.MapIndex(k).Interface())
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(k.Interface())] = value
+		}
+		return m, nil
+	case reflect.Slice, reflect.Array:
+		a := make([]interface{}, rv.Len())
+		for i := range a {
+			value, err := msgpackValue(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			a[i] = value
+		}
+		return a, nil
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return msgpackValue(rv.Elem().Interface())
+	}
+	return v, nil
+}
+
+// fromMsgpack converts decoded values to the types used by the other codecs, integers are
+// returned as int64 unless they don't fit, floats as float64, timestamps are in UTC and every map
+// is keyed by string.
+func fromMsgpack(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *time.Time:
+		return v.UTC()
+	case time.Time:
+		return v.UTC()
+	case float32:
+		return float64(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = fromMsgpack(v[i])
+		}
+		return v
+	case map[string]interface{}:
+		for k, value := range v {
+			v[k] = fromMsgpack(value)
+		}
+		return v
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := rv.Uint(); u > math.MaxInt64 {
+			return u
+		}
+		return int64(rv.Uint())
+	case reflect.Map:
+		m := make(map[string]interface{}, rv.Len())
+		for _, k := range rv.MapKeys() {
+			m[fmt.Sprint(k.Interface())] = fromMsgpack(rv.MapIndex(k).Interface())
+		}
+		return m
+	}
+	return v
+}
@@ -0,0 +1,136 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/compose/transporter/message/data"
+)
+
+var msgpackTests = []struct {
+	name string
+	in   interface{}
+	out  interface{}
+}{
+	{"nil", nil, nil},
+	{"bool", true, true},
+	{"fixint", 7, int64(7)},
+	{"negative fixint", -7, int64(-7)},
+	{"int8", -100, int64(-100)},
+	{"int16", -1000, int64(-1000)},
+	{"int32", -100000, int64(-100000)},
+	{"int64", int64(math.MinInt64), int64(math.MinInt64)},
+	{"uint8", 200, int64(200)},
+	{"uint16", 60000, int64(60000)},
+	{"uint32", 4000000000, int64(4000000000)},
+	{"uint64", uint64(math.MaxUint64), uint64(math.MaxUint64)},
+	{"float32", float32(1.5), float64(1.5)},
+	{"float64", 1.25, 1.25},
+	{"fixstr", "hello", "hello"},
+	{"str8", strings.Repeat("a", 40), strings.Repeat("a", 40)},
+	{"str16", strings.Repeat("a", 300), strings.Repeat("a", 300)},
+	{"bin", []byte{1, 2, 3}, []byte{1, 2, 3}},
+	{"array", []interface{}{1, "a"}, []interface{}{int64(1), "a"}},
+	{"array16", make([]interface{}, 20), make([]interface{}, 20)},
+	{"map", map[string]interface{}{"a": 1}, map[string]interface{}{"a": int64(1)}},
+	{"timestamp32", time.Unix(1500000000, 0).UTC(), time.Unix(1500000000, 0).UTC()},
+	{"timestamp64", time.Unix(1500000000, 5).UTC(), time.Unix(1500000000, 5).UTC()},
+	{"timestamp96", time.Unix(-1, 5).UTC(), time.Unix(-1, 5).UTC()},
+}
+
+func TestMsgpack(t *testing.T) {
+	c := Msgpack{}
+	for _, mt := range msgpackTests {
+		b, err := c.Encode(data.Data{"v": mt.in})
+		if err != nil {
+			t.Errorf("[%s] unexpected Encode error, %s", mt.name, err)
+			continue
+		}
+		d, err := c.Decode(b)
+		if err != nil {
+			t.Errorf("[%s] unexpected Decode error, %s", mt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(d.Get("v"), mt.out) {
+			t.Errorf("[%s] wrong value, expected %#v, got %#v", mt.name, mt.out, d.Get("v"))
+		}
+	}
+}
+
+func TestMsgpackWire(t *testing.T) {
+	// {"compact": true, "schema": 0} as published by the reference implementation
+	body := []byte{0x82, 0xa7, 'c', 'o', 'm', 'p', 'a', 'c', 't', 0xc3, 0xa6, 's', 'c', 'h', 'e', 'm', 'a', 0x00}
+	d, err := Msgpack{}.Decode(body)
+	if err != nil {
+		t.Fatalf("unexpected Decode error, %s", err)
+	}
+	want := data.Data{"compact": true, "schema": int64(0)}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("wrong data, expected %+v, got %+v", want, d)
+	}
+	b, err := Msgpack{}.Encode(want)
+	if err != nil {
+		t.Fatalf("unexpected Encode error, %s", err)
+	}
+	if !bytes.Equal(b, body) {
+		t.Errorf("wrong body, expected %x, got %x", body, b)
+	}
+}
+
+func TestMsgpackErrors(t *testing.T) {
+	if _, err := (Msgpack{}).Decode([]byte{0x93, 0x01}); err != ErrMsgpackShort {
+		t.Errorf("wrong error, expected %s, got %v", ErrMsgpackShort, err)
+	}
+	if _, err := (Msgpack{}).Decode([]byte{0x01}); err != ErrMsgpackNotMap {
+		t.Errorf("wrong error, expected %s, got %v", ErrMsgpackNotMap, err)
+	}
+	if _, err := (Msgpack{}).Decode([]byte{0x81, 0xa1, 'a', 0xcd, 0x01}); err != ErrMsgpackShort {
+		t.Errorf("wrong error, expected %s, got %v", ErrMsgpackShort, err)
+	}
+	if _, err := (Msgpack{}).Encode(data.Data{"f": func() {}}); err == nil {
+		t.Error("expected error encoding a func but didn't get one")
+	}
+}
+
+// msgpackReferenceTests are bodies as encoded by the reference implementation.
+var msgpackReferenceTests = []struct {
+	name string
+	body string
+	v    interface{}
+}{
+	{"nil", "81a161c0", nil},
+	{"int8", "81a161d0df", int64(-33)},
+	{"uint32", "81a161ce00010000", int64(65536)},
+	{"float64", "81a161cb3ff8000000000000", 1.5},
+	{"array", "81a1619201a162", []interface{}{int64(1), "b"}},
+	{"bin", "81a161c4020102", []byte{1, 2}},
+	{"timestamp32", "81a161d6ff59682f00", time.Unix(1500000000, 0).UTC()},
+	{"map", "81a16181a162a163", map[string]interface{}{"b": "c"}},
+}
+
+func TestMsgpackReference(t *testing.T) {
+	for _, rt := range msgpackReferenceTests {
+		body, _ := hex.DecodeString(rt.body)
+		d, err := Msgpack{}.Decode(body)
+		if err != nil {
+			t.Errorf("[%s] unexpected Decode error, %s", rt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(d.Get("a"), rt.v) {
+			t.Errorf("[%s] wrong value, expected %#v, got %#v", rt.name, rt.v, d.Get("a"))
+		}
+		b, err := Msgpack{}.Encode(d)
+		if err != nil {
+			t.Errorf("[%s] unexpected Encode error, %s", rt.name, err)
+			continue
+		}
+		if !bytes.Equal(b, body) {
+			t.Errorf("[%s] wrong body, expected %s, got %x", rt.name, rt.body, b)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/compose/transporter/message/data"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var (
+	// ErrMissingProtoDescriptor is returned when the protobuf codec is used without proto_descriptor.
+	ErrMissingProtoDescriptor = errors.New("protobuf codec requires proto_descriptor")
+
+	// ErrMissingProtoMessage is returned when the protobuf codec is used without proto_message.
+	ErrMissingProtoMessage = errors.New("protobuf codec requires proto_message")
+
+	// ErrProtoInvalid is returned when a protobuf body can't be parsed as the configured message,
+	// for example because it ends in the middle of a field.
+	ErrProtoInvalid = errors.New("protobuf body is not a valid message")
+)
+
+// UnknownProtoMessageError is returned when a message type can't be found in the descriptor set.
+type UnknownProtoMessageError struct {
+	Name string
+}
+
+func (e UnknownProtoMessageError) Error() string {
+	return fmt.Sprintf("protobuf message '%s' not found in descriptor set", e.Name)
+}
+
+func init() {
+	Add("protobuf", newProtobuf,
+		"application/protobuf", "application/x-protobuf", "application/vnd.google.protobuf")
+}
+
+// Protobuf encodes documents as protobuf messages described by a FileDescriptorSet, as written by
+// `protoc --include_imports --descriptor_set_out`. Fields are keyed by their proto name, enums
+// are decoded to their value name and map fields to documents.
+type Protobuf struct {
+	message protoreflect.MessageDescriptor
+}
+
+func newProtobuf(opts Options) (Codec, error) {
+	if opts.ProtoDescriptor == "" {
+		return nil, ErrMissingProtoDescriptor
+	}
+	if opts.ProtoMessage == "" {
+		return nil, ErrMissingProtoMessage
+	}
+	b, err := ioutil.ReadFile(opts.ProtoDescriptor)
+	if err != nil {
+		return nil, err
+	}
+	var set descriptor.FileDescriptorSet
+	if err := proto.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("unable to parse proto_descriptor, %s", err)
+	}
+	return NewProtobuf(&set, opts.ProtoMessage)
+}
+
+// NewProtobuf creates a Protobuf codec for the named message, the name is fully qualified
+// with its package, e.g. "inventory.Item".
+func NewProtobuf(set *descriptor.FileDescriptorSet, message string) (*Protobuf, error) {
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proto_descriptor, %s", err)
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(strings.TrimPrefix(message, ".")))
+	if err != nil {
+		return nil, UnknownProtoMessageError{message}
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, UnknownProtoMessageError{message}
+	}
+	return &Protobuf{message: md}, nil
+}
+
+// Encode implements Codec.
+func (p *Protobuf) Encode(d data.Data) ([]byte, error) {
+	msg := dynamicpb.NewMessage(p.message)
+	if err := setFields(msg, map[string]interface{}(d)); err != nil {
+		return nil, err
+	}
+	return proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+}
+
+// Decode implements Codec.
+func (p *Protobuf) Decode(b []byte) (data.Data, error) {
+	msg := dynamicpb.NewMessage(p.message)
+	if err := proto.Unmarshal(b, msg); err != nil {
+		return nil, ErrProtoInvalid
+	}
+	return data.Data(document(msg)), nil
+}
+
+// ContentType implements Codec.
+func (p *Protobuf) ContentType() string {
+	return "application/protobuf"
+}
+
+// document returns the fields set in the message keyed by name.
+func document(m protoreflect.Message) map[string]interface{} {
+	doc := make(map[string]interface{})
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			values := make(map[string]interface{}, v.Map().Len())
+			v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				values[fmt.Sprint(k.Interface())] = fieldValue(fd.MapValue(), mv)
+				return true
+			})
+			doc[string(fd.Name())] = values
+		case fd.IsList():
+			values := make([]interface{}, v.List().Len())
+			for i := range values {
+				values[i] = fieldValue(fd, v.List().Get(i))
+			}
+			doc[string(fd.Name())] = values
+		default:
+			doc[string(fd.Name())] = fieldValue(fd, v)
+		}
+		return true
+	})
+	return doc
+}
+
+func fieldValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return int32(v.Enum())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return document(v.Message())
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return v.Float()
+	case protoreflect.BytesKind:
+		return append([]byte(nil), v.Bytes()...)
+	}
+	return v.Interface()
+}
+
+// setFields sets every field of the message found in the document, keys which aren't fields of
+// the message are ignored.
+func setFields(m protoreflect.Message, doc map[string]interface{}) error {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		v, ok := doc[string(fd.Name())]
+		if !ok || v == nil {
+			continue
+		}
+		if err := setField(m, fd, v); err != nil {
+			return fmt.Errorf("unable to encode field %s, %s", fd.Name(), err)
+		}
+	}
+	return nil
+}
+
+func setField(m protoreflect.Message, fd protoreflect.FieldDescriptor, v interface{}) error {
+	switch {
+	case fd.IsMap():
+		values, err := asDocument(v)
+		if err != nil {
+			return err
+		}
+		mp := m.Mutable(fd).Map()
+		for k, value := range values {
+			key, err := mapKey(fd.MapKey(), k)
+			if err != nil {
+				return err
+			}
+			mv := fd.MapValue().Default()
+			if value != nil || fd.MapValue().Message() != nil {
+				if mv, err = protoValue(fd.MapValue(), value, mp.NewValue); err != nil {
+					return err
+				}
+			}
+			mp.Set(key, mv)
+		}
+	case fd.IsList():
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			return fmt.Errorf("repeated field requires an array, got %T", v)
+		}
+		l := m.Mutable(fd).List()
+		for i := 0; i < rv.Len(); i++ {
+			value, err := protoValue(fd, rv.Index(i).Interface(), l.NewElement)
+			if err != nil {
+				return err
+			}
+			l.Append(value)
+		}
+	default:
+		value, err := protoValue(fd, v, func() protoreflect.Value { return m.NewField(fd) })
+		if err != nil {
+			return err
+		}
+		m.Set(fd, value)
+	}
+	return nil
+}
+
+// protoValue converts a document value into a value of the field's kind, newMessage creates the
+// message set from a document.
+func protoValue(fd protoreflect.FieldDescriptor, v interface{}, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		s, ok := v.(string)
+		if !ok {
+			s = fmt.Sprint(v)
+		}
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BytesKind:
+		switch b := v.(type) {
+		case []byte:
+			return protoreflect.ValueOfBytes(b), nil
+		case string:
+			return protoreflect.ValueOfBytes([]byte(b)), nil
+		}
+		return protoreflect.Value{}, fmt.Errorf("bytes field requires []byte or a string, got %T", v)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		doc, err := asDocument(v)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		value := newMessage()
+		if err := setFields(value.Message(), doc); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return value, nil
+	case protoreflect.BoolKind:
+		b, ok := v.(bool)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("bool field requires a bool, got %T", v)
+		}
+		return protoreflect.ValueOfBool(b), nil
+	case protoreflect.DoubleKind, protoreflect.FloatKind:
+		n, err := toFloat(v)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		if fd.Kind() == protoreflect.FloatKind {
+			return protoreflect.ValueOfFloat32(float32(n)), nil
+		}
+		return protoreflect.ValueOfFloat64(n), nil
+	case protoreflect.EnumKind:
+		if name, ok := v.(string); ok {
+			ev := fd.Enum().Values().ByName(protoreflect.Name(name))
+			if ev == nil {
+				return protoreflect.Value{}, fmt.Errorf("unknown value %s for enum %s", name, fd.Enum().FullName())
+			}
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+	}
+
+	n, err := toInt(v)
+	if err != nil {
+		return protoreflect.Value{}, err
+	}
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(n)), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(n)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(uint64(n)), nil
+	}
+	return protoreflect.ValueOfInt64(n), nil
+}
+
+// mapKey parses a document key as the key of a map field.
+func mapKey(fd protoreflect.FieldDescriptor, k string) (protoreflect.MapKey, error) {
+	var (
+		key interface{} = k
+		err error
+	)
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+	case protoreflect.BoolKind:
+		key, err = strconv.ParseBool(k)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		key, err = strconv.ParseUint(k, 10, 64)
+	default:
+		key, err = strconv.ParseInt(k, 10, 64)
+	}
+	if err != nil {
+		return protoreflect.MapKey{}, err
+	}
+	v, err := protoValue(fd, key, nil)
+	if err != nil {
+		return protoreflect.MapKey{}, err
+	}
+	return v.MapKey(), nil
+}
+
+func asDocument(v interface{}) (map[string]interface{}, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return v, nil
+	case data.Data:
+		return v, nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("message field requires a document, got %T", v)
+	}
+	doc := make(map[string]interface{}, rv.Len())
+	for _, k := range rv.MapKeys() {
+		doc[k.String()] = rv.MapIndex(k).Interface()
+	}
+	return doc, nil
+}
+
+func toInt(v interface{}) (int64, error) {
+	if n, ok := v.(json.Number); ok {
+		return n.Int64()
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) {
+			return 0, fmt.Errorf("integer field can't hold %v", f)
+		}
+		return int64(f), nil
+	}
+	return 0, fmt.Errorf("integer field requires a number, got %T", v)
+}
+
+func toFloat(v interface{}) (float64, error) {
+	if n, ok := v.(json.Number); ok {
+		return n.Float64()
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	return 0, fmt.Errorf("floating point field requires a number, got %T", v)
+}
@@ -0,0 +1,279 @@
+package codec
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/compose/transporter/message/data"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func protoField(name string, number int32, t descriptor.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptor.FieldDescriptorProto {
+	label := descriptor.FieldDescriptorProto_LABEL_OPTIONAL
+	if repeated {
+		label = descriptor.FieldDescriptorProto_LABEL_REPEATED
+	}
+	f := &descriptor.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Type:   t.Enum(),
+		Label:  label.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+// testDescriptorSet describes the following file
+//
+//	syntax = "proto3";
+//	package inventory;
+//	enum Status { UNKNOWN = 0; ACTIVE = 1; }
+//	message Item {
+//	  message Dimensions { double width = 1; double height = 2; }
+//	  string name = 1;
+//	  int64 count = 2;
+//	  sint32 delta = 3;
+//	  bool active = 4;
+//	  bytes blob = 5;
+//	  float weight = 6;
+//	  Status status = 7;
+//	  Dimensions size = 8;
+//	  repeated string tags = 9;
+//	  repeated int32 levels = 10;
+//	  map<string, int64> stock = 11;
+//	  fixed64 serial = 12;
+//	}
+func testDescriptorSet() *descriptor.FileDescriptorSet {
+	dimensions := &descriptor.DescriptorProto{
+		Name: proto.String("Dimensions"),
+		Field: []*descriptor.FieldDescriptorProto{
+			protoField("width", 1, descriptor.FieldDescriptorProto_TYPE_DOUBLE, "", false),
+			protoField("height", 2, descriptor.FieldDescriptorProto_TYPE_DOUBLE, "", false),
+		},
+	}
+	stockEntry := &descriptor.DescriptorProto{
+		Name: proto.String("StockEntry"),
+		Field: []*descriptor.FieldDescriptorProto{
+			protoField("key", 1, descriptor.FieldDescriptorProto_TYPE_STRING, "", false),
+			protoField("value", 2, descriptor.FieldDescriptorProto_TYPE_INT64, "", false),
+		},
+		Options: &descriptor.MessageOptions{MapEntry: proto.Bool(true)},
+	}
+	item := &descriptor.DescriptorProto{
+		Name: proto.String("Item"),
+		Field: []*descriptor.FieldDescriptorProto{
+			protoField("name", 1, descriptor.FieldDescriptorProto_TYPE_STRING, "", false),
+			protoField("count", 2, descriptor.FieldDescriptorProto_TYPE_INT64, "", false),
+			protoField("delta", 3, descriptor.FieldDescriptorProto_TYPE_SINT32, "", false),
+			protoField("active", 4, descriptor.FieldDescriptorProto_TYPE_BOOL, "", false),
+			protoField("blob", 5, descriptor.FieldDescriptorProto_TYPE_BYTES, "", false),
+			protoField("weight", 6, descriptor.FieldDescriptorProto_TYPE_FLOAT, "", false),
+			protoField("status", 7, descriptor.FieldDescriptorProto_TYPE_ENUM, ".inventory.Status", false),
+			protoField("size", 8, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".inventory.Item.Dimensions", false),
+			protoField("tags", 9, descriptor.FieldDescriptorProto_TYPE_STRING, "", true),
+			protoField("levels", 10, descriptor.FieldDescriptorProto_TYPE_INT32, "", true),
+			protoField("stock", 11, descriptor.FieldDescriptorProto_TYPE_MESSAGE, ".inventory.Item.StockEntry", true),
+			protoField("serial", 12, descriptor.FieldDescriptorProto_TYPE_FIXED64, "", false),
+		},
+		NestedType: []*descriptor.DescriptorProto{dimensions, stockEntry},
+	}
+	return &descriptor.FileDescriptorSet{
+		File: []*descriptor.FileDescriptorProto{
+			{
+				Name:    proto.String("inventory.proto"),
+				Package: proto.String("inventory"),
+				Syntax:  proto.String("proto3"),
+				EnumType: []*descriptor.EnumDescriptorProto{
+					{
+						Name: proto.String("Status"),
+						Value: []*descriptor.EnumValueDescriptorProto{
+							{Name: proto.String("UNKNOWN"), Number: proto.Int32(0)},
+							{Name: proto.String("ACTIVE"), Number: proto.Int32(1)},
+						},
+					},
+				},
+				MessageType: []*descriptor.DescriptorProto{item},
+			},
+		},
+	}
+}
+
+func writeDescriptorSet(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "transporter_codec")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, %s", err)
+	}
+	b, err := proto.Marshal(testDescriptorSet())
+	if err != nil {
+		t.Fatalf("unable to marshal descriptor set, %s", err)
+	}
+	path := filepath.Join(dir, "inventory.pb")
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatalf("unable to write descriptor set, %s", err)
+	}
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestProtobuf(t *testing.T) {
+	path, cleanup := writeDescriptorSet(t)
+	defer cleanup()
+
+	c, err := GetCodec("protobuf", Options{ProtoDescriptor: path, ProtoMessage: "inventory.Item"})
+	if err != nil {
+		t.Fatalf("unexpected GetCodec error, %s", err)
+	}
+	in := data.Data{
+		"name":   "widget",
+		"count":  3,
+		"delta":  -2,
+		"active": true,
+		"blob":   []byte{0xde, 0xad},
+		"weight": 1.5,
+		"status": "ACTIVE",
+		"size":   map[string]interface{}{"width": 2, "height": 0.5},
+		"tags":   []interface{}{"a", "b"},
+		"levels": []interface{}{1, -1},
+		"stock":  map[string]interface{}{"east": 4, "west": 0},
+		"serial": uint64(1 << 40),
+		"ignore": "not in the descriptor",
+	}
+	b, err := c.Encode(in)
+	if err != nil {
+		t.Fatalf("unexpected Encode error, %s", err)
+	}
+	out, err := c.Decode(b)
+	if err != nil {
+		t.Fatalf("unexpected Decode error, %s", err)
+	}
+	want := data.Data{
+		"name":   "widget",
+		"count":  int64(3),
+		"delta":  int32(-2),
+		"active": true,
+		"blob":   []byte{0xde, 0xad},
+		"weight": float64(1.5),
+		"status": "ACTIVE",
+		"size":   map[string]interface{}{"width": float64(2), "height": 0.5},
+		"tags":   []interface{}{"a", "b"},
+		"levels": []interface{}{int32(1), int32(-1)},
+		"stock":  map[string]interface{}{"east": int64(4), "west": int64(0)},
+		"serial": uint64(1 << 40),
+	}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("wrong data, expected %+v, got %+v", want, out)
+	}
+}
+
+func TestProtobufWire(t *testing.T) {
+	c, err := NewProtobuf(testDescriptorSet(), ".inventory.Item")
+	if err != nil {
+		t.Fatalf("unexpected NewProtobuf error, %s", err)
+	}
+	// name: "ab", count: 150, levels: [1, 2] (packed), levels: 3 (unpacked), unknown field 15
+	body := []byte{0x0a, 0x02, 'a', 'b', 0x10, 0x96, 0x01, 0x52, 0x02, 0x01, 0x02, 0x50, 0x03, 0x78, 0x01}
+	d, err := c.Decode(body)
+	if err != nil {
+		t.Fatalf("unexpected Decode error, %s", err)
+	}
+	want := data.Data{"name": "ab", "count": int64(150), "levels": []interface{}{int32(1), int32(2), int32(3)}}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("wrong data, expected %+v, got %+v", want, d)
+	}
+	b, err := c.Encode(want)
+	if err != nil {
+		t.Fatalf("unexpected Encode error, %s", err)
+	}
+	encoded := []byte{0x0a, 0x02, 'a', 'b', 0x10, 0x96, 0x01, 0x52, 0x03, 0x01, 0x02, 0x03}
+	if !bytes.Equal(b, encoded) {
+		t.Errorf("wrong body, expected %x, got %x", encoded, b)
+	}
+}
+
+func TestProtobufErrors(t *testing.T) {
+	path, cleanup := writeDescriptorSet(t)
+	defer cleanup()
+
+	errTests := []struct {
+		name string
+		opts Options
+		err  error
+	}{
+		{"missing descriptor", Options{ProtoMessage: "inventory.Item"}, ErrMissingProtoDescriptor},
+		{"missing message", Options{ProtoDescriptor: path}, ErrMissingProtoMessage},
+		{"unknown message", Options{ProtoDescriptor: path, ProtoMessage: "inventory.Order"}, UnknownProtoMessageError{"inventory.Order"}},
+	}
+	for _, et := range errTests {
+		if _, err := GetCodec("protobuf", et.opts); err != et.err {
+			t.Errorf("[%s] wrong error, expected %v, got %v", et.name, et.err, err)
+		}
+	}
+
+	c, _ := NewProtobuf(testDescriptorSet(), "inventory.Item")
+	if _, err := c.Decode([]byte{0x0a, 0x05, 'a'}); err != ErrProtoInvalid {
+		t.Errorf("wrong error, expected %s, got %v", ErrProtoInvalid, err)
+	}
+	if _, err := c.Encode(data.Data{"status": "RETIRED"}); err == nil {
+		t.Error("expected error encoding an unknown enum value but didn't get one")
+	}
+	if _, err := c.Encode(data.Data{"count": "three"}); err == nil {
+		t.Error("expected error encoding a string as an integer but didn't get one")
+	}
+}
+
+func TestProtobufReference(t *testing.T) {
+	// messages of descriptor.proto are encoded by the generated code of the reference implementation
+	set := &descriptor.FileDescriptorSet{
+		File: []*descriptor.FileDescriptorProto{protodesc.ToFileDescriptorProto(descriptorpb.File_google_protobuf_descriptor_proto)},
+	}
+	c, err := NewProtobuf(set, "google.protobuf.FieldDescriptorProto")
+	if err != nil {
+		t.Fatalf("unexpected NewProtobuf error, %s", err)
+	}
+	ref := &descriptor.FieldDescriptorProto{
+		Name:     proto.String("levels"),
+		Number:   proto.Int32(10),
+		Label:    descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+		Type:     descriptor.FieldDescriptorProto_TYPE_INT32.Enum(),
+		JsonName: proto.String("levels"),
+		Options:  &descriptor.FieldOptions{Packed: proto.Bool(true)},
+	}
+	body, err := proto.Marshal(ref)
+	if err != nil {
+		t.Fatalf("unable to marshal reference message, %s", err)
+	}
+	d, err := c.Decode(body)
+	if err != nil {
+		t.Fatalf("unexpected Decode error, %s", err)
+	}
+	want := data.Data{
+		"name":      "levels",
+		"number":    int32(10),
+		"label":     "LABEL_REPEATED",
+		"type":      "TYPE_INT32",
+		"json_name": "levels",
+		"options":   map[string]interface{}{"packed": true},
+	}
+	if !reflect.DeepEqual(d, want) {
+		t.Errorf("wrong data, expected %+v, got %+v", want, d)
+	}
+	b, err := c.Encode(d)
+	if err != nil {
+		t.Fatalf("unexpected Encode error, %s", err)
+	}
+	var out descriptor.FieldDescriptorProto
+	if err := proto.Unmarshal(b, &out); err != nil {
+		t.Fatalf("unable to unmarshal encoded message, %s", err)
+	}
+	if !proto.Equal(&out, ref) {
+		t.Errorf("wrong message, expected %v, got %v", ref, &out)
+	}
+}
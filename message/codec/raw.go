@@ -0,0 +1,38 @@
+package codec
+
+import (
+	"fmt"
+
+	"github.com/compose/transporter/message/data"
+)
+
+// RawField is the field of the document holding the body read or written by the Raw codec.
+const RawField = "data"
+
+func init() {
+	Add("raw", func(Options) (Codec, error) { return Raw{}, nil }, "application/octet-stream")
+}
+
+// Raw passes bodies through untouched, they are stored as []byte in the data field.
+type Raw struct{}
+
+// Encode implements Codec, the data field must be a []byte or string.
+func (Raw) Encode(d data.Data) ([]byte, error) {
+	switch v := d.Get(RawField).(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("raw codec requires a %s field containing []byte or a string", RawField)
+}
+
+// Decode implements Codec.
+func (Raw) Decode(b []byte) (data.Data, error) {
+	return data.Data{RawField: b}, nil
+}
+
+// ContentType implements Codec.
+func (Raw) ContentType() string {
+	return "application/octet-stream"
+}
@@ -96,7 +96,7 @@ func (m *LogManager) buildMap() error {
 func (m *LogManager) CommitOffset(o Offset, override bool) error {
 	m.Lock()
 	defer m.Unlock()
-	if currentOffset, ok := m.nsMap[o.Namespace]; !override && ok && currentOffset >= o.LogOffset {
+	if currentOffset, ok := m.nsMap[o.key()]; !override && ok && currentOffset >= o.LogOffset {
 		log.With("currentOffest", currentOffset).
 			With("providedOffset", o.LogOffset).
 			Debugln("refusing to commit offset")
@@ -106,7 +106,7 @@ func (m *LogManager) CommitOffset(o Offset, override bool) error {
 	if err != nil {
 		return err
 	}
-	m.nsMap[o.Namespace] = o.LogOffset
+	m.nsMap[o.key()] = o.LogOffset
 	return nil
 }
 
@@ -133,3 +133,10 @@ func (m *LogManager) NewestOffset() int64 {
 	}
 	return int64(newestOffset)
 }
+
+// Close closes the log the offsets are persisted to.
+func (m *LogManager) Close() error {
+	m.Lock()
+	defer m.Unlock()
+	return m.log.Close()
+}
@@ -22,6 +22,17 @@ type Offset struct {
 	Namespace string
 	LogOffset uint64
 	Timestamp int64
+	// Source is the name of the source the LogOffset belongs to when a writer is fed by more than
+	// one source, each has its own commitlog so the offsets are tracked separately.
+	Source string
+}
+
+// key returns the key the offset is tracked under, the namespace prefixed by the source if any.
+func (o Offset) key() string {
+	if o.Source == "" {
+		return o.Namespace
+	}
+	return o.Source + sourceSeparator + o.Namespace
 }
 
 // Bytes converts Offset to the binary format to be stored on disk.
@@ -30,7 +41,7 @@ func (o Offset) Bytes() []byte {
 	encoding.PutUint64(valBytes, o.LogOffset)
 
 	l := commitlog.NewLogFromEntry(commitlog.LogEntry{
-		Key:       []byte(o.Namespace),
+		Key:       []byte(o.key()),
 		Value:     valBytes,
 		Timestamp: uint64(o.Timestamp),
 	})
@@ -51,6 +51,25 @@ var (
 				0, 0, 0, 0, 0, 0, 0, 100, // offset
 			},
 		},
+		{
+			"source",
+			offset.Offset{
+				Namespace: "ns",
+				LogOffset: 100,
+				Timestamp: int64(1491252302),
+				Source:    "db",
+			},
+			[]byte{
+				0, 0, 0, 0, 0, 0, 0, 0, // offset
+				0, 0, 0, 21, // size
+				0, 0, 0, 0, 88, 226, 180, 78, // timestamp
+				0,          // mode
+				0, 0, 0, 5, // key length
+				100, 98, 47, 110, 115, // key
+				0, 0, 0, 8, // value length
+				0, 0, 0, 0, 0, 0, 0, 100, // offset
+			},
+		},
 	}
 )
 
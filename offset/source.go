@@ -0,0 +1,56 @@
+package offset
+
+import "strings"
+
+const (
+	sourceSeparator = "/"
+)
+
+var (
+	_ Manager = &sourceManager{}
+)
+
+// sourceManager tracks the offsets of a single source in a Manager shared by several sources.
+type sourceManager struct {
+	m      Manager
+	source string
+}
+
+// ForSource returns a Manager for the offsets of messages from the named source, the offsets are
+// committed to m but kept apart from those of any other source m tracks.
+func ForSource(m Manager, source string) Manager {
+	return &sourceManager{m, source}
+}
+
+// CommitOffset commits o as an offset of the source.
+func (s *sourceManager) CommitOffset(o Offset, override bool) error {
+	o.Source = s.source
+	return s.m.CommitOffset(o, override)
+}
+
+// OffsetMap returns the newest offset for every namespace of the source.
+func (s *sourceManager) OffsetMap() map[string]uint64 {
+	prefix := s.source + sourceSeparator
+	nsMap := make(map[string]uint64)
+	for key, o := range s.m.OffsetMap() {
+		if strings.HasPrefix(key, prefix) {
+			nsMap[strings.TrimPrefix(key, prefix)] = o
+		}
+	}
+	return nsMap
+}
+
+// NewestOffset returns the highest offset of the source.
+func (s *sourceManager) NewestOffset() int64 {
+	nsMap := s.OffsetMap()
+	if len(nsMap) == 0 {
+		return -1
+	}
+	var newestOffset uint64
+	for _, v := range nsMap {
+		if newestOffset < v {
+			newestOffset = v
+		}
+	}
+	return int64(newestOffset)
+}
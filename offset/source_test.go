@@ -0,0 +1,48 @@
+package offset_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/compose/transporter/offset"
+)
+
+func TestForSource(t *testing.T) {
+	m := &offset.MockManager{MemoryMap: map[string]uint64{}}
+	users := offset.ForSource(m, "users_db")
+	orders := offset.ForSource(m, "orders_db")
+	if users.NewestOffset() != -1 {
+		t.Errorf("wrong NewestOffset, expected -1, got %d", users.NewestOffset())
+	}
+	for _, c := range []struct {
+		m  offset.Manager
+		ns string
+		o  uint64
+	}{
+		{users, "users", 10},
+		{users, "roles", 3},
+		{orders, "orders", 7},
+		{m, "orders", 20},
+	} {
+		if err := c.m.CommitOffset(offset.Offset{Namespace: c.ns, LogOffset: c.o}, false); err != nil {
+			t.Fatalf("unexpected CommitOffset error, %s", err)
+		}
+	}
+	// committing with the source set on the Offset is the same as committing to the source Manager
+	m.CommitOffset(offset.Offset{Namespace: "orders", LogOffset: 8, Source: "orders_db"}, false)
+
+	expected := map[string]uint64{"users": 10, "roles": 3}
+	if !reflect.DeepEqual(users.OffsetMap(), expected) {
+		t.Errorf("bad OffsetMap, expected %+v, got %+v", expected, users.OffsetMap())
+	}
+	expected = map[string]uint64{"orders": 8}
+	if !reflect.DeepEqual(orders.OffsetMap(), expected) {
+		t.Errorf("bad OffsetMap, expected %+v, got %+v", expected, orders.OffsetMap())
+	}
+	if users.NewestOffset() != 10 || orders.NewestOffset() != 8 {
+		t.Errorf("wrong NewestOffset, expected 10 and 8, got %d and %d", users.NewestOffset(), orders.NewestOffset())
+	}
+	if m.OffsetMap()["orders"] != 20 {
+		t.Errorf("wrong offset without a source, expected 20, got %d", m.OffsetMap()["orders"])
+	}
+}
@@ -27,19 +27,19 @@ func (m *MockManager) CommitOffset(o Offset, override bool) error {
 			time.Sleep(m.CommitDelay)
 			m.Lock()
 			defer m.Unlock()
-			if currentOffset, ok := m.MemoryMap[o.Namespace]; ok && currentOffset >= o.LogOffset {
+			if currentOffset, ok := m.MemoryMap[o.key()]; ok && currentOffset >= o.LogOffset {
 				return
 			}
-			m.MemoryMap[o.Namespace] = o.LogOffset
+			m.MemoryMap[o.key()] = o.LogOffset
 		}()
 		return nil
 	}
 	m.Lock()
 	defer m.Unlock()
-	if currentOffset, ok := m.MemoryMap[o.Namespace]; ok && currentOffset >= o.LogOffset {
+	if currentOffset, ok := m.MemoryMap[o.key()]; ok && currentOffset >= o.LogOffset {
 		return nil
 	}
-	m.MemoryMap[o.Namespace] = o.LogOffset
+	m.MemoryMap[o.key()] = o.LogOffset
 	return nil
 }
 
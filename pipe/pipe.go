@@ -29,12 +29,17 @@ func newMessageChan() messageChan {
 	return make(chan TrackedMessage, 10)
 }
 
-// TrackedMessage wraps the message.Msg and offset.Offset flowing through the pipeline.
+// TrackedMessage wraps the message.Msg and offset.Offset flowing through the pipeline. Msg is nil
+// when a Router sent the message to other Out channels, only its offset is passed along.
 type TrackedMessage struct {
 	Msg message.Msg
 	Off offset.Offset
 }
 
+// Router decides which of a Pipe's Out channels a message is sent to, it returns whether to send
+// the message to each Out channel in order.
+type Router func(message.Msg) []bool
+
 // Pipe provides a set of methods to let transporter nodes communicate with each other.
 //
 // Pipes contain In, Out, Err, and Event channels. Messages are consumed by a node through the 'in' chan, emitted from the node by the 'out' chan.
@@ -50,9 +55,12 @@ type Pipe struct {
 	MessageCount int
 
 	path      string // the path of this pipe (for events and errors)
+	router    Router
 	chStop    chan struct{}
 	listening bool
 	wg        sync.WaitGroup
+	abort     chan struct{}
+	abortOnce sync.Once
 }
 
 // NewPipe creates a new Pipe.  If the pipe that is passed in is nil, then this pipe will be treated as a source pipe that just serves to emit messages.
@@ -64,6 +72,7 @@ func NewPipe(pipe *Pipe, path string) *Pipe {
 		Out:    make([]messageChan, 0),
 		path:   path,
 		chStop: make(chan struct{}),
+		abort:  make(chan struct{}),
 	}
 
 	if pipe != nil {
@@ -79,8 +88,15 @@ func NewPipe(pipe *Pipe, path string) *Pipe {
 	return p
 }
 
+// Join makes p also receive the messages sent by parent, so a pipe can be fed by more than one
+// parent.
+func (p *Pipe) Join(parent *Pipe) {
+	parent.Out = append(parent.Out, p.In)
+}
+
 // Listen starts a listening loop that pulls messages from the In chan, applies fn(msg), a `func(message.Msg) error`, and emits them on the Out channel.
 // Errors will be emitted to the Pipe's Err chan, and will terminate the loop.
+// fn is called with a nil message.Msg for messages the parent's Router didn't send to this Pipe.
 // The listening loop can be interrupted by calls to Stop().
 func (p *Pipe) Listen(fn func(message.Msg, offset.Offset) (message.Msg, error)) error {
 	if p.In == nil {
@@ -91,7 +107,7 @@ func (p *Pipe) Listen(fn func(message.Msg, offset.Offset) (message.Msg, error))
 	for {
 		select {
 		case <-p.chStop:
-			if len(p.In) > 0 {
+			if len(p.In) > 0 && !p.aborted() {
 				log.With("path", p.path).With("buffer_length", len(p.In)).Infoln("received stop, message buffer not empty, continuing...")
 				continue
 			}
@@ -102,10 +118,19 @@ func (p *Pipe) Listen(fn func(message.Msg, offset.Offset) (message.Msg, error))
 			outmsg, err := fn(m.Msg, m.Off)
 			if err != nil {
 				p.Stopped = true
-				p.Err <- err
+				select {
+				case p.Err <- err:
+				case <-p.chStop:
+					// the error is only returned once the Pipe is being stopped
+				}
 				p.wg.Done()
 				return err
 			}
+			if m.Msg == nil && len(p.Out) > 0 {
+				// pass the offset along so nodes further down commit it too
+				p.Send(nil, m.Off)
+				break
+			}
 			if outmsg == nil {
 				break
 			}
@@ -118,7 +143,9 @@ func (p *Pipe) Listen(fn func(message.Msg, offset.Offset) (message.Msg, error))
 	}
 }
 
-// Stop terminates the channels listening loop, and allows any timeouts in send to fail
+// Stop terminates the listening loop once every message in the In channel has been processed, a
+// Pipe which isn't listening waits for its Out channels to clear instead. Either gives up when the
+// Pipe is aborted.
 func (p *Pipe) Stop() {
 	if !p.Stopped {
 		p.Stopped = true
@@ -130,22 +157,45 @@ func (p *Pipe) Stop() {
 			return
 		}
 
-		timeout := time.After(10 * time.Second)
-		for {
+		for !p.empty() {
 			select {
-			case <-timeout:
-				log.With("path", p.path).Errorln("timeout reached waiting for Out channels to clear")
-				return
-			default:
-			}
-			if p.empty() {
+			case <-p.abort:
+				log.With("path", p.path).Errorln("aborted waiting for Out channels to clear")
 				return
+			case <-time.After(100 * time.Millisecond):
 			}
-			time.Sleep(1 * time.Second)
 		}
 	}
 }
 
+// Stopping returns a channel which is closed when Stop is called on a listening Pipe, it lets the
+// function passed to Listen give up on work which could otherwise delay stopping.
+func (p *Pipe) Stopping() <-chan struct{} {
+	return p.chStop
+}
+
+// Abort makes Stop give up waiting for messages to be delivered, and any Send waiting on a full Out
+// channel give up on its message. Any messages which haven't been delivered are lost.
+func (p *Pipe) Abort() {
+	p.abortOnce.Do(func() {
+		close(p.abort)
+	})
+}
+
+// Aborted returns a channel which is closed when Abort is called.
+func (p *Pipe) Aborted() <-chan struct{} {
+	return p.abort
+}
+
+func (p *Pipe) aborted() bool {
+	select {
+	case <-p.abort:
+		return true
+	default:
+		return false
+	}
+}
+
 func (p *Pipe) empty() bool {
 	for _, ch := range p.Out {
 		if len(ch) > 0 {
@@ -155,18 +205,33 @@ func (p *Pipe) empty() bool {
 	return true
 }
 
-// Send emits the given message on the 'Out' channel.  the send Timesout after 100 ms in order to chaeck of the Pipe has stopped and we've been asked to exit.
-// If the Pipe has been stopped, the send will fail and there is no guarantee of either success or failure
+// SetRouter makes Send deliver messages only to the Out channels chosen by r, every other Out
+// channel receives the offset without the message.
+func (p *Pipe) SetRouter(r Router) {
+	p.router = r
+}
+
+// Send emits the given message on the 'Out' channel, blocking until every Out channel has room for
+// it or the Pipe is aborted, in which case the message is dropped.
 func (p *Pipe) Send(msg message.Msg, off offset.Offset) {
-	p.MessageCount++
-	for _, ch := range p.Out {
+	var routes []bool
+	if msg != nil {
+		p.MessageCount++
+		if p.router != nil {
+			routes = p.router(msg)
+		}
+	}
+	for i, ch := range p.Out {
+		m := TrackedMessage{msg, off}
+		if routes != nil && !routes[i] {
+			m.Msg = nil
+		}
 
-	A:
-		for {
-			select {
-			case ch <- TrackedMessage{msg, off}:
-				break A
-			}
+		select {
+		case ch <- m:
+		case <-p.abort:
+			log.With("path", p.path).Errorln("pipe aborted, dropping message")
+			return
 		}
 	}
 }
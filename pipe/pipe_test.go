@@ -97,6 +97,56 @@ func TestSkipMessage(t *testing.T) {
 	}
 }
 
+func TestRouteMessage(t *testing.T) {
+	var (
+		orders, audit  []message.Msg
+		auditOffsets   int
+		chainedOffsets int
+	)
+	source := NewPipe(nil, "source")
+	sink1 := NewPipe(source, "orders")
+	go sink1.Listen(func(msg message.Msg, _ offset.Offset) (message.Msg, error) {
+		if msg != nil {
+			orders = append(orders, msg)
+		}
+		return msg, nil
+	})
+	sink2 := NewPipe(source, "audit")
+	go sink2.Listen(func(msg message.Msg, _ offset.Offset) (message.Msg, error) {
+		auditOffsets++
+		if msg != nil {
+			audit = append(audit, msg)
+		}
+		return msg, nil
+	})
+	sink3 := NewPipe(sink1, "orders_archive")
+	go sink3.Listen(func(msg message.Msg, _ offset.Offset) (message.Msg, error) {
+		chainedOffsets++
+		return msg, nil
+	})
+	source.SetRouter(func(msg message.Msg) []bool {
+		return []bool{msg.Namespace() == "orders", true}
+	})
+	source.Send(message.From(ops.Insert, "orders", map[string]interface{}{}), offset.Offset{})
+	source.Send(message.From(ops.Insert, "users", map[string]interface{}{}), offset.Offset{})
+	source.Stop()
+	sink1.Stop()
+	sink2.Stop()
+	sink3.Stop()
+	if len(orders) != 1 || orders[0].Namespace() != "orders" {
+		t.Errorf("wrong messages routed to orders, %v", orders)
+	}
+	if len(audit) != 2 || auditOffsets != 2 {
+		t.Errorf("wrong messages routed to audit, expected 2, got %d", len(audit))
+	}
+	if chainedOffsets != 2 {
+		t.Errorf("wrong offsets passed along, expected 2, got %d", chainedOffsets)
+	}
+	if source.MessageCount != 2 || sink1.MessageCount != 1 {
+		t.Errorf("wrong message counts, expected 2 and 1, got %d and %d", source.MessageCount, sink1.MessageCount)
+	}
+}
+
 var errListen = errors.New("listen error")
 
 func TestListenErr(t *testing.T) {
@@ -117,10 +167,33 @@ func TestListenErr(t *testing.T) {
 	source.Send(message.From(ops.Insert, "test", map[string]interface{}{}), offset.Offset{})
 	source.Send(message.From(ops.Insert, "test", map[string]interface{}{}), offset.Offset{})
 	wg.Wait()
+	// the sink stopped listening so the second message is never delivered
+	source.Abort()
 	source.Stop()
 	sink.Stop()
 }
 
+func TestAbort(t *testing.T) {
+	source := NewPipe(nil, "source")
+	NewPipe(source, "sink")
+	sent := make(chan struct{})
+	go func() {
+		// the sink isn't listening so the send blocks once its In channel is full
+		for i := 0; i < 20; i++ {
+			source.Send(message.From(ops.Insert, "test", map[string]interface{}{}), offset.Offset{})
+		}
+		close(sent)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	source.Abort()
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("Send wasn't aborted")
+	}
+	source.Stop()
+}
+
 func TestListeNilErr(t *testing.T) {
 	source := NewPipe(nil, "source")
 	err := source.Listen(nil)
@@ -0,0 +1,133 @@
+// Copyright 2014 The Transporter Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pipeline
+
+import (
+	"sync"
+	"time"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/offset"
+)
+
+const (
+	defaultBatchSize     = 500
+	defaultBatchInterval = 1 * time.Second
+)
+
+// batch buffers the messages written by a Node whose writer is a client.BatchWriter, they're
+// written once batchSize messages have been added or batchInterval has passed, whichever is
+// first. The offsets of a batch are committed as soon as it has been written.
+type batch struct {
+	sync.Mutex
+	n       *Node
+	w       client.BatchWriter
+	entries []batchEntry
+	// err is set once a batch fails, any messages added after are dropped
+	err error
+}
+
+type batchEntry struct {
+	// in is the message as it was received, before any transforms were applied
+	in  message.Msg
+	msg message.Msg
+	off offset.Offset
+}
+
+func newBatch(n *Node, w client.BatchWriter) *batch {
+	return &batch{n: n, w: w}
+}
+
+// run writes the batch every batchInterval until the Node is stopped, a failed write is sent to
+// the pipe's Err channel and returned.
+func (b *batch) run() error {
+	ticker := time.NewTicker(b.n.batchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if b.n.Paused() {
+				continue
+			}
+			b.Lock()
+			err := b.flush()
+			b.Unlock()
+			if err != nil {
+				select {
+				case b.n.pipe.Err <- err:
+				case <-b.n.done:
+				}
+				return err
+			}
+		case <-b.n.done:
+			return nil
+		}
+	}
+}
+
+// add appends e to the batch and writes it once it's full.
+func (b *batch) add(e batchEntry) error {
+	b.Lock()
+	defer b.Unlock()
+	if b.err != nil {
+		// the messages will be written again when the pipeline is restarted
+		return nil
+	}
+	if b.n.om != nil {
+		b.n.offsetLock.Lock()
+		b.n.pendingOffsets = append(b.n.pendingOffsets, e.off)
+		b.n.offsetLock.Unlock()
+	}
+	b.entries = append(b.entries, e)
+	if len(b.entries) >= b.n.batchSize {
+		return b.flush()
+	}
+	return nil
+}
+
+// flush writes the messages in the batch and commits their offsets, the Node's ErrorPolicy is
+// applied to each message of a batch which fails. It must be called with the lock held.
+func (b *batch) flush() error {
+	if len(b.entries) == 0 {
+		return nil
+	}
+	entries := b.entries
+	b.entries = nil
+	msgs := make([]message.Msg, len(entries))
+	for i, e := range entries {
+		msgs[i] = e.msg
+	}
+	b.n.l.With("batch_size", len(msgs)).Debugln("writing batch")
+	if attempts, err := b.n.writeBatchWithRetry(b.w, msgs); err != nil {
+		for _, e := range entries {
+			if err := b.n.applyErrorPolicy(e.in, e.off, attempts, err); err != nil {
+				b.err = err
+				return err
+			}
+		}
+	}
+	if b.n.om != nil {
+		if err := b.n.confirmOffsets(); err != nil {
+			b.err = ErrConfirmOffset
+			return ErrConfirmOffset
+		}
+	}
+	return nil
+}
+
+// close writes any messages left in the batch unless the Node is paused, it must only be called
+// once pipe.Listen has returned.
+func (b *batch) close() {
+	b.Lock()
+	defer b.Unlock()
+	if b.n.Paused() {
+		b.n.l.With("batch_size", len(b.entries)).Infoln("paused, final batch won't be written")
+		return
+	}
+	if err := b.flush(); err != nil {
+		b.n.l.Errorf("failed to write final batch, %s", err)
+	}
+}
@@ -0,0 +1,140 @@
+package pipeline
+
+import (
+	"os"
+	"testing"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/events"
+	"github.com/compose/transporter/log"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/ops"
+	"github.com/compose/transporter/offset"
+)
+
+// failBatchWriter fails to write every batch.
+type failBatchWriter struct {
+	client.MockWriter
+}
+
+func (w *failBatchWriter) WriteBatch(msgs []message.Msg) func(client.Session) error {
+	return func(client.Session) error {
+		return client.ErrMockWrite
+	}
+}
+
+func newBatchNode(t *testing.T, w client.Writer, options ...OptionFunc) (*Node, *offset.MockManager) {
+	om := &offset.MockManager{MemoryMap: map[string]uint64{}}
+	n, err := NewNodeWithOptions("batch", "stopWriter", defaultNsString, append(options, WithOffsetManager(om))...)
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+	}
+	n.l = log.With("name", n.Name)
+	n.writer = w
+	n.batch = newBatch(n, w.(client.BatchWriter))
+	return n, om
+}
+
+func writeBatchMsgs(n *Node, from, to int) error {
+	for i := from; i < to; i++ {
+		msg := message.From(ops.Insert, "foo", map[string]interface{}{"i": i})
+		if _, err := n.write(msg, offset.Offset{Namespace: "foo", LogOffset: uint64(i)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestBatchSize(t *testing.T) {
+	w := &client.MockBatchWriter{}
+	n, om := newBatchNode(t, w, WithBatchSize(3))
+	if err := writeBatchMsgs(n, 0, 7); err != nil {
+		t.Fatalf("unexpected write error, %s", err)
+	}
+	if len(w.Batches) != 2 || w.MsgCount != 6 {
+		t.Errorf("wrong batches written, expected 2 batches of 3, got %v", w.Batches)
+	}
+	if o := om.OffsetMap()["foo"]; o != 5 {
+		t.Errorf("wrong offset committed, expected 5, got %d", o)
+	}
+	n.batch.close()
+	if len(w.Batches) != 3 || len(w.Batches[2]) != 1 {
+		t.Errorf("final batch wasn't written, %v", w.Batches)
+	}
+	if o := om.OffsetMap()["foo"]; o != 6 {
+		t.Errorf("wrong offset committed, expected 6, got %d", o)
+	}
+}
+
+func TestBatchInterval(t *testing.T) {
+	w := &client.MockBatchWriter{}
+	n, om := newBatchNode(t, w, WithBatchInterval("10ms"))
+	go n.batch.run()
+	defer close(n.done)
+	if err := writeBatchMsgs(n, 0, 2); err != nil {
+		t.Fatalf("unexpected write error, %s", err)
+	}
+	if !waitFor(func() bool {
+		n.batch.Lock()
+		defer n.batch.Unlock()
+		return len(w.Batches) == 1
+	}) {
+		t.Fatal("batch wasn't written after the interval")
+	}
+	if o := om.OffsetMap()["foo"]; o != 1 {
+		t.Errorf("wrong offset committed, expected 1, got %d", o)
+	}
+}
+
+func TestBatchErrorPolicy(t *testing.T) {
+	n, om := newBatchNode(t, &failBatchWriter{}, WithBatchSize(2), WithErrorPolicy("skip"))
+	go func(events chan events.Event) {
+		for range events {
+		}
+	}(n.pipe.Event)
+	if err := writeBatchMsgs(n, 0, 2); err != nil {
+		t.Errorf("unexpected write error, %s", err)
+	}
+	if o := om.OffsetMap()["foo"]; o != 1 {
+		t.Errorf("skipped batch wasn't committed, expected 1, got %d", o)
+	}
+
+	n, om = newBatchNode(t, &failBatchWriter{}, WithBatchSize(2))
+	if err := writeBatchMsgs(n, 0, 2); err != client.ErrMockWrite {
+		t.Errorf("wrong write error, expected %s, got %v", client.ErrMockWrite, err)
+	}
+	if err := writeBatchMsgs(n, 2, 4); err != nil {
+		t.Errorf("unexpected write error after a failed batch, %s", err)
+	}
+	if _, ok := om.OffsetMap()["foo"]; ok {
+		t.Errorf("failed batch was committed, %v", om.OffsetMap())
+	}
+}
+
+func TestListenBatch(t *testing.T) {
+	dataDir := scratchDataDir("listen_batch")
+	defer os.RemoveAll(dataDir)
+	source := newConsumerSource(t, dataDir, 10)
+	om := &offset.MockManager{MemoryMap: map[string]uint64{}}
+	sink, err := NewNodeWithOptions("batch", "stopWriter", defaultNsString,
+		WithParent(source),
+		WithOffsetManager(om),
+		WithBatchSize(4),
+	)
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+	}
+	w := &client.MockBatchWriter{}
+	sink.writer = w
+
+	if err := source.Start(); err != nil {
+		t.Fatalf("unexpected Start error, %s", err)
+	}
+	source.Stop()
+	if w.MsgCount != 10 || len(w.Batches) < 3 {
+		t.Errorf("wrong batches written, %v", w.Batches)
+	}
+	if o := om.OffsetMap()["test"]; o != 9 {
+		t.Errorf("wrong offset committed, expected 9, got %d", o)
+	}
+}
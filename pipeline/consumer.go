@@ -0,0 +1,282 @@
+// Copyright 2014 The Transporter Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pipeline
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/compose/transporter/events"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/offset"
+	"github.com/compose/transporter/pipe"
+)
+
+// LagPolicy determines what happens when a Node falls further behind its source than its lag limit.
+type LagPolicy string
+
+const (
+	// LagAlert logs a warning and emits a lag event, this is the default.
+	LagAlert LagPolicy = "alert"
+
+	// LagPause stops the source reading messages until the Node has caught up to within its limit.
+	LagPause LagPolicy = "pause"
+)
+
+const (
+	messageCacheSize = 1024
+	lagCheckInterval = 100 * time.Millisecond
+)
+
+var (
+	// ErrInvalidLagPolicy is returned when the lag policy isn't one of alert or pause.
+	ErrInvalidLagPolicy = errors.New("invalid lag policy, must be one of alert or pause")
+)
+
+// consumer feeds a child Node with the messages in its parent's commitlog, each child has its own
+// so a slow child only holds up itself.
+type consumer struct {
+	child *Node
+	// position is the offset of the next message to send to the child, it's accessed atomically
+	position int64
+	// notify is signalled when a message is appended to the commitlog
+	notify chan struct{}
+	// lagging is only accessed by the source while it reads messages
+	lagging bool
+}
+
+// startConsumers starts a consumer for each child, reading the commitlog from its newest offset.
+// A child which hasn't committed every message in the commitlog resumes from its own offset
+// instead, it has to catch up within its resume timeout. Errors are sent to errc.
+func (n *Node) startConsumers(errc chan error) {
+	n.recent = newMessageCache(messageCacheSize)
+	newestOffset := n.clog.NewestOffset()
+	sendErr := func(err error) {
+		select {
+		case errc <- err:
+		case <-n.done:
+		}
+	}
+	for _, child := range n.children {
+		c := &consumer{
+			child:    child,
+			position: newestOffset,
+			notify:   make(chan struct{}, 1),
+		}
+		if from, ok := n.resumeOffset(child); ok {
+			c.position = from
+			n.resuming.Add(1)
+			go func(c *consumer) {
+				defer n.resuming.Done()
+				if err := n.awaitResume(c, newestOffset-1); err != nil {
+					sendErr(err)
+				}
+			}(c)
+		}
+		n.consumers = append(n.consumers, c)
+		n.wg.Add(1)
+		go func(c *consumer) {
+			defer n.wg.Done()
+			if err := n.consume(c); err != nil {
+				sendErr(err)
+			}
+		}(c)
+	}
+}
+
+// resumeOffset returns the offset a child which hasn't committed every message in the commitlog
+// resumes from, the newest message it has committed is sent again.
+func (n *Node) resumeOffset(child *Node) (int64, bool) {
+	om := n.childOffsets(child)
+	if om == nil || n.clog.OldestOffset() == n.clog.NewestOffset() {
+		return 0, false
+	}
+	o := om.NewestOffset()
+	// we subtract 1 from NewestOffset() because we only need to catch up
+	// to the last entry in the log
+	if o >= n.clog.NewestOffset()-1 {
+		return 0, false
+	}
+	if o < 0 {
+		o = 0
+	}
+	return o, true
+}
+
+// awaitResume waits for the child of a resuming consumer to commit the message at target once
+// it has been sent, failing with ErrResumeTimedOut if that takes longer than the child's resume
+// timeout. The other children carry on consuming the commitlog meanwhile.
+func (n *Node) awaitResume(c *consumer, target int64) error {
+	l := n.l.With("child", c.child.Name).With("newestOffset", target)
+	l.With("position", atomic.LoadInt64(&c.position)).Infoln("child resuming...")
+	for atomic.LoadInt64(&c.position) <= target {
+		select {
+		case <-time.After(lagCheckInterval):
+		case <-n.done:
+			return nil
+		}
+	}
+	l.With("timeout", c.child.resumeTimeout).Infoln("all messages sent down pipeline, waiting for offsets to match...")
+	timeout := time.After(c.child.resumeTimeout)
+	for n.childOffsets(c.child).NewestOffset() < target {
+		select {
+		case <-timeout:
+			l.Errorln(ErrResumeTimedOut)
+			return ErrResumeTimedOut
+		case <-time.After(lagCheckInterval):
+		case <-n.done:
+			return nil
+		}
+	}
+	l.Infoln("child resume complete")
+	return nil
+}
+
+// consume sends the child each message appended to the commitlog from the consumer's position
+// until the Node is stopped.
+func (n *Node) consume(c *consumer) error {
+	from := atomic.LoadInt64(&c.position)
+	// the reader starts at the message before the one we want since from isn't in the commitlog yet
+	r, err := n.clog.NewReader(from - 1)
+	if err != nil {
+		return err
+	}
+	for {
+		d, err := readResumeData(r)
+		if err == io.EOF {
+			select {
+			case <-c.notify:
+				continue
+			case <-n.done:
+				return nil
+			}
+		} else if err != nil {
+			return err
+		}
+		if int64(d.offset) < from {
+			continue
+		}
+		msg := n.recent.get(d.offset)
+		if msg == nil {
+			msg = d.msg.Msg
+		}
+		m := pipe.TrackedMessage{
+			Msg: msg,
+			Off: offset.Offset{
+				Namespace: msg.Namespace(),
+				LogOffset: d.offset,
+				Timestamp: time.Now().Unix(),
+				Source:    n.offsetSource(),
+			},
+		}
+		if !c.child.routed(msg) {
+			m.Msg = nil
+		}
+		select {
+		case c.child.pipe.In <- m:
+		case <-n.done:
+			return nil
+		}
+		atomic.StoreInt64(&c.position, int64(d.offset)+1)
+	}
+}
+
+// appended lets the consumers know a message has been appended to the commitlog, if any child
+// with the LagPause policy has fallen too far behind it blocks until the child catches up.
+func (n *Node) appended() {
+	n.pipe.MessageCount++
+	for _, c := range n.consumers {
+		select {
+		case c.notify <- struct{}{}:
+		default:
+		}
+	}
+	for n.checkLag() {
+		select {
+		case <-time.After(lagCheckInterval):
+		case <-n.done:
+			return
+		}
+	}
+}
+
+// checkLag compares the lag of each consumer to the child's limit, emitting a lag event whenever
+// a child goes over it or catches up to within half of it. It reports whether the source should
+// pause.
+func (n *Node) checkLag() bool {
+	var pause bool
+	newest := n.clog.NewestOffset()
+	for _, c := range n.consumers {
+		limit := c.child.lagLimit
+		if limit <= 0 {
+			continue
+		}
+		lag := newest - atomic.LoadInt64(&c.position)
+		l := n.l.With("child", c.child.Name).With("lag", lag).With("limit", limit)
+		switch {
+		case lag > limit && !c.lagging:
+			c.lagging = true
+			if c.child.lagPolicy == LagPause {
+				l.Errorln("child is lagging, pausing source")
+			} else {
+				l.Errorln("child is lagging")
+			}
+			n.pipe.Event <- events.NewLagEvent(time.Now().UnixNano(), c.child.path, lag, limit)
+		case lag <= limit/2 && c.lagging:
+			c.lagging = false
+			l.Infoln("child has caught up")
+			n.pipe.Event <- events.NewLagEvent(time.Now().UnixNano(), c.child.path, lag, limit)
+		}
+		if c.lagging && c.child.lagPolicy == LagPause {
+			pause = true
+		}
+	}
+	return pause
+}
+
+// waitForConsumers waits for every consumer to send its child all of the messages in the
+// commitlog, giving up if the Node is aborted.
+func (n *Node) waitForConsumers() {
+	for _, c := range n.consumers {
+		for atomic.LoadInt64(&c.position) < n.clog.NewestOffset() {
+			select {
+			case <-n.pipe.Aborted():
+				n.l.With("child", c.child.Name).Errorln("aborted waiting for consumer to catch up")
+				return
+			case <-time.After(lagCheckInterval):
+			}
+		}
+	}
+}
+
+// messageCache keeps the messages most recently appended to a commitlog so consumers which are
+// keeping up send them on as they were read rather than decoded from the commitlog.
+type messageCache struct {
+	sync.Mutex
+	size uint64
+	msgs map[uint64]message.Msg
+}
+
+func newMessageCache(size int) *messageCache {
+	return &messageCache{size: uint64(size), msgs: make(map[uint64]message.Msg)}
+}
+
+func (c *messageCache) add(o uint64, msg message.Msg) {
+	c.Lock()
+	defer c.Unlock()
+	c.msgs[o] = msg
+	if o >= c.size {
+		delete(c.msgs, o-c.size)
+	}
+}
+
+func (c *messageCache) get(o uint64) message.Msg {
+	c.Lock()
+	defer c.Unlock()
+	return c.msgs[o]
+}
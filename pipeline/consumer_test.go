@@ -0,0 +1,162 @@
+package pipeline
+
+import (
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/commitlog"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/ops"
+)
+
+// blockingWriter doesn't write any message until release is closed.
+type blockingWriter struct {
+	release chan struct{}
+	count   int64
+}
+
+func (w *blockingWriter) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	return func(client.Session) (message.Msg, error) {
+		<-w.release
+		atomic.AddInt64(&w.count, 1)
+		return msg, nil
+	}
+}
+
+func (w *blockingWriter) written() int64 {
+	return atomic.LoadInt64(&w.count)
+}
+
+func newConsumerSource(t *testing.T, dataDir string, msgCount int) *Node {
+	n, err := NewNodeWithOptions(
+		"starter", "stopWriter", defaultNsString,
+		WithCommitLog([]commitlog.OptionFunc{
+			commitlog.WithPath(dataDir),
+		}...),
+	)
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+	}
+	n.reader = &client.MockReader{MsgCount: msgCount}
+	return n
+}
+
+func waitFor(cond func() bool) bool {
+	timeout := time.After(5 * time.Second)
+	for !cond() {
+		select {
+		case <-timeout:
+			return false
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return true
+}
+
+func TestSlowSinkIsolation(t *testing.T) {
+	dataDir := scratchDataDir("slow_sink")
+	defer os.RemoveAll(dataDir)
+	source := newConsumerSource(t, dataDir, 100)
+	slow, _ := NewNodeWithOptions("slow", "stopWriter", defaultNsString, WithParent(source))
+	slowWriter := &blockingWriter{release: make(chan struct{})}
+	slow.writer = slowWriter
+	fast, _ := NewNodeWithOptions("fast", "stopWriter", defaultNsString, WithParent(source))
+	fastWriter := &blockingWriter{release: make(chan struct{})}
+	close(fastWriter.release)
+	fast.writer = fastWriter
+
+	if err := source.Start(); err != nil {
+		t.Fatalf("unexpected Start error, %s", err)
+	}
+	if !waitFor(func() bool { return fastWriter.written() == 100 }) {
+		t.Errorf("fast sink was held up by the slow one, expected 100 messages, got %d", fastWriter.written())
+	}
+	if slowWriter.written() != 0 {
+		t.Errorf("slow sink wrote %d messages", slowWriter.written())
+	}
+	close(slowWriter.release)
+	source.Stop()
+	if slowWriter.written() != 100 {
+		t.Errorf("wrong number of messages written by the slow sink, expected 100, got %d", slowWriter.written())
+	}
+}
+
+func TestLagPause(t *testing.T) {
+	dataDir := scratchDataDir("lag_pause")
+	defer os.RemoveAll(dataDir)
+	source := newConsumerSource(t, dataDir, 100)
+	sink, err := NewNodeWithOptions("lagging", "stopWriter", defaultNsString,
+		WithParent(source),
+		WithLagLimit(5, "pause"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+	}
+	w := &blockingWriter{release: make(chan struct{})}
+	sink.writer = w
+	var lagEvents int64
+	go func() {
+		for range source.pipe.Event {
+			atomic.AddInt64(&lagEvents, 1)
+		}
+	}()
+
+	errc := make(chan error)
+	go func() {
+		errc <- source.Start()
+	}()
+	select {
+	case err := <-errc:
+		t.Fatalf("source wasn't paused, Start returned %v", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+	if appended := source.clog.NewestOffset(); appended >= 100 {
+		t.Errorf("source wasn't paused, %d messages appended", appended)
+	}
+	if atomic.LoadInt64(&lagEvents) == 0 {
+		t.Error("no lag event emitted")
+	}
+
+	close(w.release)
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("unexpected Start error, %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("source wasn't resumed")
+	}
+	source.Stop()
+	if w.written() != 100 {
+		t.Errorf("wrong number of messages written, expected 100, got %d", w.written())
+	}
+	if atomic.LoadInt64(&lagEvents) < 2 {
+		t.Error("no lag event emitted once the sink caught up")
+	}
+}
+
+func TestWithLagLimit(t *testing.T) {
+	if _, err := NewNodeWithOptions("lagging", "stopWriter", defaultNsString, WithLagLimit(5, "stop")); err != ErrInvalidLagPolicy {
+		t.Errorf("wrong error, expected %s, got %v", ErrInvalidLagPolicy, err)
+	}
+	n, _ := NewNodeWithOptions("lagging", "stopWriter", defaultNsString, WithLagLimit(5, ""))
+	if n.lagLimit != 5 || n.lagPolicy != LagAlert {
+		t.Errorf("wrong lag limit, %d %s", n.lagLimit, n.lagPolicy)
+	}
+}
+
+func TestMessageCache(t *testing.T) {
+	c := newMessageCache(2)
+	for i := 0; i < 3; i++ {
+		c.add(uint64(i), message.From(ops.Insert, "foo", map[string]interface{}{"i": i}))
+	}
+	if c.get(0) != nil {
+		t.Error("oldest message wasn't evicted")
+	}
+	if m := c.get(2); m == nil || m.Data().Get("i") != 2 {
+		t.Errorf("wrong message cached, %v", m)
+	}
+}
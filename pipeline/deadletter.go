@@ -0,0 +1,162 @@
+package pipeline
+
+import (
+	"errors"
+	"time"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/dlq"
+	"github.com/compose/transporter/events"
+	"github.com/compose/transporter/log"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/offset"
+)
+
+// ErrorPolicy determines what a Node does with a message it fails to transform or write.
+type ErrorPolicy string
+
+const (
+	// PolicyFail stops the pipeline, this is the default.
+	PolicyFail ErrorPolicy = "fail"
+
+	// PolicySkip logs the error, emits an error event and moves on to the next message.
+	PolicySkip ErrorPolicy = "skip"
+
+	// PolicyDeadLetter records the message in the Node's dlq.Queue, emits an error event and moves
+	// on to the next message.
+	PolicyDeadLetter ErrorPolicy = "dead_letter"
+)
+
+var (
+	// ErrDeadLetterNotReplayable is returned by ReplayDeadLetters when the Node's dlq.Queue can't
+	// be read back, i.e. it writes to another adaptor.
+	ErrDeadLetterNotReplayable = errors.New("dead-letter queue can not be replayed")
+)
+
+// handleError applies the Node's ErrorPolicy to msg, the message as it was received before any
+// transforms were applied, after attempts to process it. A nil error is returned when the message has been dealt with, in which
+// case its offset is committed as if it was written so the pipeline moves past it.
+func (n *Node) handleError(msg message.Msg, off offset.Offset, attempts int, err error) error {
+	if err := n.applyErrorPolicy(msg, off, attempts, err); err != nil {
+		return err
+	}
+	n.skipOffset(off)
+	return nil
+}
+
+// applyErrorPolicy is handleError without committing the offset, it's left to the caller.
+func (n *Node) applyErrorPolicy(msg message.Msg, off offset.Offset, attempts int, err error) error {
+	l := n.l.With("ns", msg.Namespace()).With("offset", off.LogOffset)
+	switch n.errorPolicy {
+	case PolicySkip:
+		l.Errorf("skipping message, %s", err)
+	case PolicyDeadLetter:
+		r, rerr := dlq.NewRecord(n.path, msg, off, attempts, err)
+		if rerr == nil {
+			rerr = n.dlq.Put(r)
+		}
+		if rerr != nil {
+			l.Errorf("failed to dead-letter message, %s", rerr)
+			return err
+		}
+		l.Errorf("dead-lettered message, %s", err)
+	default:
+		return err
+	}
+	n.pipe.Event <- events.NewErrorEvent(time.Now().UnixNano(), n.path, msg.Data(), err.Error())
+	return nil
+}
+
+// skipOffset commits the offset of a message which won't be confirmed by the writer. If there are
+// writes waiting to be confirmed the offset is committed along with theirs.
+func (n *Node) skipOffset(off offset.Offset) {
+	if n.om == nil {
+		return
+	}
+	n.offsetLock.Lock()
+	defer n.offsetLock.Unlock()
+	for i, o := range n.pendingOffsets {
+		if o == off {
+			n.pendingOffsets = append(n.pendingOffsets[:i], n.pendingOffsets[i+1:]...)
+			break
+		}
+	}
+	if len(n.pendingOffsets) > 0 {
+		n.pendingOffsets = append(n.pendingOffsets, off)
+		return
+	}
+	if err := n.om.CommitOffset(off, false); err != nil {
+		n.l.Errorf("failed to commit offset, %s", err)
+	}
+}
+
+// DeadLetters returns every message in the Node's dead-letter queue.
+func (n *Node) DeadLetters() ([]dlq.Record, error) {
+	if n.dlq == nil {
+		return nil, ErrMissingDeadLetter
+	}
+	r, ok := n.dlq.(dlq.Replayer)
+	if !ok {
+		return nil, ErrDeadLetterNotReplayable
+	}
+	return r.Records()
+}
+
+// ReplayDeadLetters applies the Node's transforms to every message in its dead-letter queue and
+// writes them again. Messages which fail again are kept in the queue with their attempt count
+// incremented, the number of messages replayed successfully is returned.
+//
+// The Node must not be running and can't be started afterwards, its writer is closed once every
+// message has been replayed so writers which buffer messages flush them.
+func (n *Node) ReplayDeadLetters() (int, error) {
+	if n.l == nil {
+		n.l = log.With("name", n.Name).With("type", n.Type).With("path", n.path)
+	}
+	records, err := n.DeadLetters()
+	if err != nil {
+		return 0, err
+	}
+	r := n.dlq.(dlq.Replayer)
+	n.l.With("records", len(records)).Infoln("replaying dead-lettered messages...")
+	var (
+		replayed int
+		failed   = make([]dlq.Record, 0)
+	)
+	for _, rec := range records {
+		if err := n.replay(rec); err != nil {
+			n.l.With("ns", rec.Namespace).With("offset", rec.Offset).Errorf("replay failed, %s", err)
+			rec.Attempts++
+			rec.Error = err.Error()
+			rec.Timestamp = time.Now().Unix()
+			failed = append(failed, rec)
+			continue
+		}
+		replayed++
+	}
+	close(n.done)
+	n.wg.Wait()
+	if closer, ok := n.writer.(client.Closer); ok {
+		closer.Close()
+	}
+	if closer, ok := n.c.(client.Closer); ok {
+		closer.Close()
+	}
+	if err := r.Reset(failed); err != nil {
+		return replayed, err
+	}
+	n.l.With("replayed", replayed).With("failed", len(failed)).Infoln("replay complete")
+	return replayed, nil
+}
+
+func (n *Node) replay(rec dlq.Record) error {
+	msg, err := rec.Msg()
+	if err != nil {
+		return err
+	}
+	msg, err = n.applyTransforms(msg)
+	if err != nil || msg == nil {
+		return err
+	}
+	_, err = client.Write(n.c, n.writer, msg)
+	return err
+}
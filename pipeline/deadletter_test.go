@@ -0,0 +1,164 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/dlq"
+	"github.com/compose/transporter/function"
+	"github.com/compose/transporter/log"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/ops"
+	"github.com/compose/transporter/offset"
+)
+
+var errPut = errors.New("put failed")
+
+// memQueue is a dlq.Queue which keeps Records in memory.
+type memQueue struct {
+	records []dlq.Record
+	putErr  error
+}
+
+func (q *memQueue) Put(r dlq.Record) error {
+	if q.putErr != nil {
+		return q.putErr
+	}
+	q.records = append(q.records, r)
+	return nil
+}
+
+func (q *memQueue) Records() ([]dlq.Record, error) {
+	return q.records, nil
+}
+
+func (q *memQueue) Reset(records []dlq.Record) error {
+	q.records = records
+	return nil
+}
+
+// failWriter fails to write any message with a "fail" field.
+type failWriter struct {
+	written []message.Msg
+}
+
+func (w *failWriter) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	return func(client.Session) (message.Msg, error) {
+		if _, ok := msg.Data().Has("fail"); ok {
+			return msg, client.ErrMockWrite
+		}
+		w.written = append(w.written, msg)
+		return msg, nil
+	}
+}
+
+var errorPolicyTests = []struct {
+	name      string
+	policy    string
+	q         *memQueue
+	transform error
+	expectErr error
+	records   int
+	committed bool
+}{
+	{"fail", "", nil, nil, client.ErrMockWrite, 0, false},
+	{"skip", "skip", nil, nil, nil, 0, true},
+	{"skip_transform", "skip", nil, errors.New("apply failed"), nil, 0, true},
+	{"dead_letter", "dead_letter", &memQueue{}, nil, nil, 1, true},
+	{"dead_letter_transform", "dead_letter", &memQueue{}, errors.New("apply failed"), nil, 1, true},
+	{"dead_letter_put_err", "dead_letter", &memQueue{putErr: errPut}, nil, client.ErrMockWrite, 0, false},
+}
+
+func TestErrorPolicy(t *testing.T) {
+	for _, et := range errorPolicyTests {
+		om := &offset.MockManager{MemoryMap: map[string]uint64{}}
+		options := []OptionFunc{WithErrorPolicy(et.policy), WithOffsetManager(om)}
+		var q *memQueue
+		if et.q != nil {
+			q = &memQueue{putErr: et.q.putErr}
+			options = append(options, WithDeadLetterQueue(q))
+		}
+		if et.transform != nil {
+			options = append(options, WithTransforms([]*Transform{{"mock", &function.Mock{Err: et.transform}, DefaultNS}}))
+		}
+		n, err := NewNodeWithOptions("errors", "stopWriter", defaultNsString, options...)
+		if err != nil {
+			t.Fatalf("[%s] unexpected NewNodeWithOptions error, %s", et.name, err)
+		}
+		n.l = log.With("name", n.Name)
+		n.writer = &failWriter{}
+
+		msg := message.From(ops.Insert, "foo", map[string]interface{}{"fail": true})
+		_, err = n.write(msg, offset.Offset{Namespace: "foo", LogOffset: 3})
+		if err != et.expectErr {
+			t.Errorf("[%s] wrong write error, expected %v, got %v", et.name, et.expectErr, err)
+		}
+		if _, ok := om.OffsetMap()["foo"]; ok != et.committed {
+			t.Errorf("[%s] wrong offset committed, expected %t, got %t", et.name, et.committed, ok)
+		}
+		if q != nil && len(q.records) != et.records {
+			t.Errorf("[%s] wrong number of records, expected %d, got %d", et.name, et.records, len(q.records))
+		}
+		if et.records > 0 {
+			r := q.records[0]
+			if r.Path != "errors" || r.Offset != 3 || r.Attempts != 1 || r.Namespace != "foo" {
+				t.Errorf("[%s] wrong record, %+v", et.name, r)
+			}
+		}
+	}
+}
+
+func TestErrorPolicyOptions(t *testing.T) {
+	if _, err := NewNodeWithOptions("errors", "stopWriter", defaultNsString, WithErrorPolicy("retry")); err != ErrInvalidErrorPolicy {
+		t.Errorf("wrong error, expected %s, got %v", ErrInvalidErrorPolicy, err)
+	}
+	if _, err := NewNodeWithOptions("errors", "stopWriter", defaultNsString, WithErrorPolicy("dead_letter")); err != ErrMissingDeadLetter {
+		t.Errorf("wrong error, expected %s, got %v", ErrMissingDeadLetter, err)
+	}
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	q := &memQueue{}
+	for i, d := range []map[string]interface{}{{"id": 0}, {"id": 1, "fail": true}, {"id": 2}} {
+		r, err := dlq.NewRecord("errors", message.From(ops.Update, "foo", d), offset.Offset{LogOffset: uint64(i)}, 1, client.ErrMockWrite)
+		if err != nil {
+			t.Fatalf("unexpected NewRecord error, %s", err)
+		}
+		q.Put(r)
+	}
+	mock := &function.Mock{}
+	n, err := NewNodeWithOptions("errors", "stopWriter", defaultNsString,
+		WithErrorPolicy("dead_letter"),
+		WithDeadLetterQueue(q),
+		WithTransforms([]*Transform{{"mock", mock, DefaultNS}}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+	}
+	w := &failWriter{}
+	n.writer = w
+	replayed, err := n.ReplayDeadLetters()
+	if err != nil {
+		t.Fatalf("unexpected ReplayDeadLetters error, %s", err)
+	}
+	if replayed != 2 || len(w.written) != 2 || mock.ApplyCount != 3 {
+		t.Errorf("wrong number of messages replayed, expected 2, got %d (%d written, %d transformed)", replayed, len(w.written), mock.ApplyCount)
+	}
+	for _, m := range w.written {
+		if m.OP() != ops.Update || m.Namespace() != "foo" {
+			t.Errorf("wrong message replayed, %s %s", m.OP(), m.Namespace())
+		}
+	}
+	if len(q.records) != 1 || q.records[0].Offset != 1 || q.records[0].Attempts != 2 {
+		t.Errorf("wrong records remaining, %+v", q.records)
+	}
+
+	n, _ = NewNodeWithOptions("errors", "stopWriter", defaultNsString,
+		WithErrorPolicy("dead_letter"),
+		WithDeadLetterQueue(dlq.NewWriterQueue(&client.Mock{}, &client.MockWriter{}, "dead_letter")),
+	)
+	if _, err := n.ReplayDeadLetters(); err != ErrDeadLetterNotReplayable {
+		t.Errorf("wrong error, expected %s, got %v", ErrDeadLetterNotReplayable, err)
+	}
+}
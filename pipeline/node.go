@@ -21,6 +21,8 @@ import (
 	"github.com/compose/transporter/adaptor"
 	"github.com/compose/transporter/client"
 	"github.com/compose/transporter/commitlog"
+	"github.com/compose/transporter/dlq"
+	"github.com/compose/transporter/events"
 	"github.com/compose/transporter/function"
 	"github.com/compose/transporter/log"
 	"github.com/compose/transporter/message"
@@ -32,6 +34,11 @@ import (
 const (
 	defaultCompactionInterval = 1 * time.Hour
 	defaultWriteTimeout       = 5 * time.Second
+	defaultAckInterval        = 1 * time.Second
+
+	// DefaultDrainTimeout is how long Stop waits for a Node, or Pipeline, to drain before it gives
+	// up on the messages which haven't been written.
+	DefaultDrainTimeout = 30 * time.Second
 )
 
 var (
@@ -46,6 +53,17 @@ var (
 	// ErrConfirmOffset is returned if the underling OffsetManager fails to commit
 	// the offsets.
 	ErrConfirmOffset = errors.New("failed to confirm offsets")
+
+	// ErrInvalidErrorPolicy is returned when the error policy isn't one of fail, skip or dead_letter.
+	ErrInvalidErrorPolicy = errors.New("invalid error policy, must be one of fail, skip or dead_letter")
+
+	// ErrMissingDeadLetter is returned when the dead_letter error policy is used without
+	// configuring a dead-letter queue.
+	ErrMissingDeadLetter = errors.New("dead_letter error policy requires a dead-letter queue")
+
+	// ErrInvalidParents is returned by WithParents unless every parent is a source Node with a
+	// unique name.
+	ErrInvalidParents = errors.New("parents must be source nodes with unique names")
 )
 
 // OptionFunc is a function that configures a Node.
@@ -62,6 +80,7 @@ type Node struct {
 	depth      int
 	children   []*Node
 	parent     *Node
+	parents    []*Node
 	transforms []*Transform
 
 	nsFilter       *regexp.Regexp
@@ -69,6 +88,11 @@ type Node struct {
 	reader         client.Reader
 	writer         client.Writer
 	done           chan struct{}
+	ctx            context.Context
+	cancel         context.CancelFunc
+	readCtx        context.Context
+	stopRead       context.CancelFunc
+	readStopped    chan struct{}
 	wg             sync.WaitGroup
 	l              log.Logger
 	pipe           *pipe.Pipe
@@ -76,14 +100,40 @@ type Node struct {
 	om             offset.Manager
 	confirms       chan struct{}
 	confirmsDone   chan struct{}
+	confirmsWG     sync.WaitGroup
+	pauser         pauser
 	pendingOffsets []offset.Offset
 	offsetLock     sync.Mutex
 	resumeTimeout  time.Duration
 	writeTimeout   time.Duration
+	pendingAcks    []pendingAck
+	ackLock        sync.Mutex
+	errorPolicy    ErrorPolicy
+	dlq            dlq.Queue
+	retryPolicy    RetryPolicy
+	route          Predicate
+	multiSource    bool
+	lagLimit       int64
+	lagPolicy      LagPolicy
+	consumers      []*consumer
+	recent         *messageCache
+	resuming       sync.WaitGroup
+	parallelism    int
+	partitionKey   []string
+	partitions     *partitions
+	batchSize      int
+	batchInterval  time.Duration
+	batch          *batch
 
 	compactionInterval time.Duration
 }
 
+// pendingAck holds a message read by a client.Acker until every sink has committed its offset.
+type pendingAck struct {
+	logOffset int64
+	msg       client.MessageSet
+}
+
 // Transform defines the struct for including a native function in the pipeline.
 type Transform struct {
 	Name     string
@@ -114,13 +164,24 @@ func NewNodeWithOptions(name, kind, ns string, options ...OptionFunc) (*Node, er
 		resumeTimeout:      60 * time.Second,
 		writeTimeout:       defaultWriteTimeout,
 		compactionInterval: defaultCompactionInterval,
+		errorPolicy:        PolicyFail,
+		retryPolicy:        DefaultRetryPolicy,
+		lagPolicy:          LagAlert,
+		batchSize:          defaultBatchSize,
+		batchInterval:      defaultBatchInterval,
+		partitionKey:       defaultPartitionKey,
 	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	n.readCtx, n.stopRead = context.WithCancel(n.ctx)
 	// Run the options on it
 	for _, option := range options {
 		if err := option(n); err != nil {
 			return nil, err
 		}
 	}
+	if n.errorPolicy == PolicyDeadLetter && n.dlq == nil {
+		return nil, ErrMissingDeadLetter
+	}
 	return n, nil
 }
 
@@ -146,12 +207,21 @@ func WithReader(a adaptor.Adaptor) OptionFunc {
 // WithWriter sets the client.Writer to be used to send data to.
 func WithWriter(a adaptor.Adaptor) OptionFunc {
 	return func(n *Node) error {
-		w, err := a.Writer(n.done, &n.wg)
+		w, err := n.newWriter(a)
 		n.writer = w
 		return err
 	}
 }
 
+// newWriter returns a client.Writer from a which is stopped along with the Node, adaptors
+// implementing adaptor.ContextAdaptor are bound to the Node's context.
+func (n *Node) newWriter(a adaptor.Adaptor) (client.Writer, error) {
+	if ca, ok := a.(adaptor.ContextAdaptor); ok {
+		return ca.WriterContext(n.ctx)
+	}
+	return a.Writer(n.done, &n.wg)
+}
+
 // WithParent sets the parent node and reconfigures the pipe.
 func WithParent(parent *Node) OptionFunc {
 	return func(n *Node) error {
@@ -164,6 +234,123 @@ func WithParent(parent *Node) OptionFunc {
 	}
 }
 
+// WithParents makes the Node a child of every one of the source Nodes in parents so it writes the
+// messages from all of them, the offsets of each source are tracked separately since each has its
+// own commitlog. The path of the Node is based on the first parent.
+func WithParents(parents ...*Node) OptionFunc {
+	return func(n *Node) error {
+		names := make(map[string]bool)
+		for _, p := range parents {
+			if p.parent != nil || names[p.Name] {
+				return ErrInvalidParents
+			}
+			names[p.Name] = true
+		}
+		if len(parents) == 0 {
+			return ErrInvalidParents
+		}
+		if err := WithParent(parents[0])(n); err != nil {
+			return err
+		}
+		if len(parents) == 1 {
+			return nil
+		}
+		for _, p := range parents[1:] {
+			p.children = append(p.children, n)
+			n.pipe.Join(p.pipe)
+		}
+		for _, p := range parents {
+			p.multiSource = true
+		}
+		n.parents = parents
+		// any of the parents can resume the Node before the first one starts it
+		n.l = log.With("name", n.Name).With("type", n.Type).With("path", n.path)
+		return nil
+	}
+}
+
+// WithLagLimit sets how many messages the Node can fall behind its source's commitlog before the
+// LagPolicy, alert by default, is applied. A limit of 0 means there's no limit.
+func WithLagLimit(limit int64, policy string) OptionFunc {
+	return func(n *Node) error {
+		n.lagLimit = limit
+		switch p := LagPolicy(policy); p {
+		case "":
+		case LagAlert, LagPause:
+			n.lagPolicy = p
+		default:
+			return ErrInvalidLagPolicy
+		}
+		return nil
+	}
+}
+
+// WithParallelism makes the Node write messages with the given number of workers, each message
+// is written by the worker for the hash of its partition key so those for the same document are
+// still written in order. It only applies to Nodes whose writer is a client.ParallelWriter and
+// which have no children, any other Node writes messages one at a time.
+func WithParallelism(workers int) OptionFunc {
+	return func(n *Node) error {
+		if workers < 0 {
+			return ErrInvalidParallelism
+		}
+		n.parallelism = workers
+		return nil
+	}
+}
+
+// WithPartitionKey configures the fields of a message's data which are hashed to pick the worker
+// it's written by when the Node writes in parallel, defaults to _id.
+func WithPartitionKey(fields ...string) OptionFunc {
+	return func(n *Node) error {
+		if len(fields) == 0 {
+			n.partitionKey = defaultPartitionKey
+			return nil
+		}
+		n.partitionKey = fields
+		return nil
+	}
+}
+
+// WithBatchSize configures the most messages written in a single batch when the Node's writer is
+// a client.BatchWriter.
+func WithBatchSize(size int) OptionFunc {
+	return func(n *Node) error {
+		if size <= 0 {
+			n.batchSize = defaultBatchSize
+			return nil
+		}
+		n.batchSize = size
+		return nil
+	}
+}
+
+// WithBatchInterval configures the longest a message waits to be written when the Node's writer
+// is a client.BatchWriter.
+func WithBatchInterval(interval string) OptionFunc {
+	return func(n *Node) error {
+		if interval == "" {
+			n.batchInterval = defaultBatchInterval
+			return nil
+		}
+		bi, err := time.ParseDuration(interval)
+		if err != nil {
+			return err
+		}
+		n.batchInterval = bi
+		return nil
+	}
+}
+
+// WithRoute makes the Node's parent send it only the messages matching p, the predicate is
+// evaluated once by the parent rather than by the Node.
+func WithRoute(p Predicate) OptionFunc {
+	return func(n *Node) error {
+		n.route = p
+		return nil
+	}
+}
+
 // WithTransforms adds the provided transforms to be applied in the pipeline.
 func WithTransforms(t []*Transform) OptionFunc {
 	return func(n *Node) error {
@@ -230,6 +417,56 @@ func WithCompactionInterval(interval string) OptionFunc {
 	}
 }
 
+// WithRetryPolicy configures how failed writes are retried, defaults to DefaultRetryPolicy.
+func WithRetryPolicy(p RetryPolicy) OptionFunc {
+	return func(n *Node) error {
+		n.retryPolicy = p
+		return nil
+	}
+}
+
+// WithErrorPolicy configures what happens to messages which fail to be transformed or written,
+// defaults to PolicyFail.
+func WithErrorPolicy(policy string) OptionFunc {
+	return func(n *Node) error {
+		switch ErrorPolicy(policy) {
+		case "":
+			n.errorPolicy = PolicyFail
+		case PolicyFail, PolicySkip, PolicyDeadLetter:
+			n.errorPolicy = ErrorPolicy(policy)
+		default:
+			return ErrInvalidErrorPolicy
+		}
+		return nil
+	}
+}
+
+// WithDeadLetterQueue configures the dlq.Queue failed messages are recorded in when using
+// PolicyDeadLetter.
+func WithDeadLetterQueue(q dlq.Queue) OptionFunc {
+	return func(n *Node) error {
+		n.dlq = q
+		return nil
+	}
+}
+
+// WithDeadLetterAdaptor configures failed messages to be recorded by writing them to ns with
+// the adaptor when using PolicyDeadLetter.
+func WithDeadLetterAdaptor(a adaptor.Adaptor, ns string) OptionFunc {
+	return func(n *Node) error {
+		c, err := a.Client()
+		if err != nil {
+			return err
+		}
+		w, err := n.newWriter(a)
+		if err != nil {
+			return err
+		}
+		n.dlq = dlq.NewWriterQueue(c, w, ns)
+		return nil
+	}
+}
+
 func (n *Node) String() string {
 	var (
 		s, prefix string
@@ -257,9 +494,16 @@ func (n *Node) String() string {
 // All descendant nodes run Listen() on the adaptor
 func (n *Node) Start() error {
 	n.l = log.With("name", n.Name).With("type", n.Type).With("path", n.path)
+	if r := n.router(); r != nil {
+		n.pipe.SetRouter(r)
+	}
 
 	errors := make(chan error, 1)
 	for _, child := range n.children {
+		if child.parent != n {
+			// children of several sources are started by their first one
+			continue
+		}
 		child.l = log.With("name", child.Name).With("type", child.Type).With("path", child.path)
 		go func(node *Node) {
 			errors <- node.Start()
@@ -269,82 +513,28 @@ func (n *Node) Start() error {
 	if n.parent == nil {
 		msgMap := make(map[string]client.MessageSet)
 		if n.clog != nil {
-			nsOffsetMap := make(map[string]uint64)
-			errc := make(chan error, len(n.children))
-			// TODO: not entirely sure about this logic check...
-			if n.clog.OldestOffset() != n.clog.NewestOffset() {
-				n.l.With("newestOffset", n.clog.NewestOffset()).
-					With("oldestOffset", n.clog.OldestOffset()).
-					Infoln("existing messages in commitlog, checking writer offsets...")
-				for _, child := range n.children {
-					n.l.With("name", child.Name).Infof("offsetMap: %+v", child.om.OffsetMap())
-					// we subtract 1 from NewestOffset() because we only need to catch up
-					// to the last entry in the log
-					if child.om.NewestOffset() < (n.clog.NewestOffset() - 1) {
-						r, err := n.clog.NewReader(child.om.NewestOffset())
-						if err != nil {
-							return err
-						}
-						go func(r io.Reader) {
-							errc <- child.resume(n.clog.NewestOffset()-1, r)
-						}(r)
-					} else {
-						errc <- nil
-					}
-
-				}
-				n.l.Infoln("waiting for all children to resume...")
-				err := <-errc
-				for i := 1; i < cap(errc); i++ {
-					e := <-errc
-					if err == nil && e != nil {
-						err = e
-					}
-				}
-				n.l.Infoln("done waiting for all children to resume")
-				if err != nil {
-					n.l.Errorln(err)
-					return err
-				}
-				n.l.Infoln("done checking for resume errors")
-				// compute a map of the oldest offset for every namespace from each child
-				for _, child := range n.children {
-					for ns, offset := range child.om.OffsetMap() {
-						if currentOffset, ok := nsOffsetMap[ns]; !ok || currentOffset > offset {
-							nsOffsetMap[ns] = offset
-						}
-					}
-				}
-
-				for _, offset := range nsOffsetMap {
-					r, err := n.clog.NewReader(int64(offset))
-					if err != nil {
-						return err
-					}
-
-					d, err := readResumeData(r)
-					if err != nil {
-						return err
-					}
-					mode := d.msg.Mode
-					// we overwrite the mode to Complete unless the offset
-					// was the last message processed
-					if mode == commitlog.Copy && int64(offset) != (n.clog.NewestOffset()-1) {
-						mode = commitlog.Complete
-					}
-
-					msgMap[d.ns] = client.MessageSet{
-						Msg:       d.msg.Msg,
-						Timestamp: d.msg.Timestamp,
-						Mode:      mode,
-					}
-				}
+			var err error
+			if msgMap, err = n.resumeMap(); err != nil {
+				return err
 			}
 			go n.runCompaction()
+			// every child consumes the commitlog at its own pace, children which haven't committed
+			// all of it resume from their own offset without holding up the others
+			n.startConsumers(errors)
 		}
 		n.l.Infof("starting with metadata %+v", msgMap)
+		n.readStopped = make(chan struct{})
 		go func() {
-			errors <- n.start(msgMap)
+			err := n.start(msgMap)
+			close(n.readStopped)
+			if err == nil {
+				// the Node hasn't started successfully until every child has resumed
+				n.resuming.Wait()
+			}
+			select {
+			case errors <- err:
+			case <-n.done:
+			}
 		}()
 	} else {
 		go func() {
@@ -352,66 +542,90 @@ func (n *Node) Start() error {
 		}()
 	}
 
-	return <-errors
+	err := <-errors
+	if err != nil && n.parent == nil {
+		n.failed()
+	}
+	return err
 }
 
-func (n *Node) resume(newestOffset int64, r io.Reader) error {
-	n.l.Infoln("adaptor Resuming...")
-	defer func() {
-		n.l.Infoln("adaptor Resume complete")
-	}()
-
-	percentComplete := 0.0
-	for {
-		d, err := readResumeData(r)
-		if err != nil {
-			return err
+// resumeMap returns the newest message in the commitlog for each namespace so the reader can
+// resume from them. Namespaces are found from the offsets committed by the children and the
+// messages after the oldest of those, the children don't need to have resumed.
+func (n *Node) resumeMap() (map[string]client.MessageSet, error) {
+	msgMap := make(map[string]client.MessageSet)
+	// TODO: not entirely sure about this logic check...
+	if n.clog.OldestOffset() == n.clog.NewestOffset() {
+		return msgMap, nil
+	}
+	newestOffset := n.clog.NewestOffset()
+	n.l.With("newestOffset", newestOffset).
+		With("oldestOffset", n.clog.OldestOffset()).
+		Infoln("existing messages in commitlog, checking writer offsets...")
+	nsOffsetMap := make(map[string]uint64)
+	from := newestOffset
+	for _, child := range n.children {
+		om := n.childOffsets(child)
+		if om == nil {
+			continue
 		}
-
-		p := (float64(d.offset) / float64(newestOffset)) * 100.0
-		if (p - percentComplete) >= 1.0 {
-			percentComplete = p
-			n.l.With("offset", d.offset).
-				With("log_offset", newestOffset).
-				With("percent_complete", percentComplete).
-				Infoln("still resuming...")
+		n.l.With("name", child.Name).Infof("offsetMap: %+v", om.OffsetMap())
+		// compute a map of the oldest offset for every namespace from each child
+		for ns, offset := range om.OffsetMap() {
+			if currentOffset, ok := nsOffsetMap[ns]; !ok || currentOffset > offset {
+				nsOffsetMap[ns] = offset
+			}
 		}
-		if n.pipe.Stopped {
-			return ErrResumeStopped
+		if o := om.NewestOffset(); o < from {
+			from = o
 		}
-		n.pipe.In <- pipe.TrackedMessage{
-			Msg: d.msg.Msg,
-			Off: offset.Offset{
-				Namespace: d.msg.Msg.Namespace(),
-				LogOffset: d.offset,
-				Timestamp: time.Now().Unix(),
-			},
+	}
+	if from < newestOffset-1 {
+		// namespaces of the messages which haven't been committed by every child yet
+		r, err := n.clog.NewReader(from)
+		if err != nil {
+			return nil, err
 		}
-		if d.offset == uint64(newestOffset) {
-			n.l.Infoln("offset of message sent down pipe matches newestOffset")
-			break
+		for {
+			d, err := readResumeData(r)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			if int64(d.offset) > from {
+				nsOffsetMap[d.ns] = d.offset
+			}
+			if int64(d.offset) >= newestOffset-1 {
+				break
+			}
 		}
 	}
 
-	n.l.With("timeout", n.resumeTimeout).Infoln("all messages sent down pipeline, waiting for offsets to match...")
-	timeout := time.After(n.resumeTimeout)
-	for {
-		select {
-		case <-timeout:
-			return ErrResumeTimedOut
-		case <-n.done:
-			return nil
-		default:
+	for _, offset := range nsOffsetMap {
+		r, err := n.clog.NewReader(int64(offset))
+		if err != nil {
+			return nil, err
 		}
-		n.l.Infoln("checking if offsets match")
-		sinkOffset := n.om.NewestOffset()
-		if sinkOffset == newestOffset {
-			n.l.Infoln("offsets match!!!")
-			return nil
+
+		d, err := readResumeData(r)
+		if err != nil {
+			return nil, err
+		}
+		mode := d.msg.Mode
+		// we overwrite the mode to Complete unless the offset
+		// was the last message processed
+		if mode == commitlog.Copy && int64(offset) != (newestOffset-1) {
+			mode = commitlog.Complete
+		}
+
+		msgMap[d.ns] = client.MessageSet{
+			Msg:       d.msg.Msg,
+			Timestamp: d.msg.Timestamp,
+			Mode:      mode,
 		}
-		n.l.With("sink_offset", sinkOffset).With("newestOffset", newestOffset).Infoln("offsets did not match, checking again in 5 seconds...")
-		time.Sleep(5 * time.Second)
 	}
+	return msgMap, nil
 }
 
 func (n *Node) runCompaction() {
@@ -427,14 +641,59 @@ func (n *Node) runCompaction() {
 		}
 		oldestOffset := uint64(n.clog.NewestOffset())
 		for _, child := range n.children {
-			if oldestOffset > uint64(child.om.NewestOffset()) {
-				oldestOffset = uint64(child.om.NewestOffset())
+			if o := n.childOffsets(child).NewestOffset(); oldestOffset > uint64(o) {
+				oldestOffset = uint64(o)
 			}
 		}
 		compactor.Compact(oldestOffset, n.clog.Segments())
 	}
 }
 
+func (n *Node) runAcks(acker client.Acker) {
+	n.l.With("ack_interval", defaultAckInterval).Infoln("starting ack routine")
+	ticker := time.NewTicker(defaultAckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n.ackCommitted(acker)
+		case <-n.done:
+			// the final ack is made by checkpoint once the children have stopped
+			n.l.Infoln("stopping ack routine")
+			return
+		}
+	}
+}
+
+// ackCommitted passes every pending message whose offset has been committed by all children to
+// the client.Acker.
+func (n *Node) ackCommitted(acker client.Acker) {
+	committed := n.clog.NewestOffset() - 1
+	for _, child := range n.children {
+		if om := n.childOffsets(child); om != nil && committed > om.NewestOffset() {
+			committed = om.NewestOffset()
+		}
+	}
+	n.ackLock.Lock()
+	var i int
+	for i < len(n.pendingAcks) && n.pendingAcks[i].logOffset <= committed {
+		i++
+	}
+	acks := make([]client.MessageSet, i)
+	for j, a := range n.pendingAcks[:i] {
+		acks[j] = a.msg
+	}
+	n.pendingAcks = n.pendingAcks[i:]
+	n.ackLock.Unlock()
+	if len(acks) == 0 {
+		return
+	}
+	n.l.With("ack_count", len(acks)).With("offset", committed).Debugln("acking committed messages")
+	if err := acker.Ack(acks); err != nil {
+		n.l.Errorf("ack error, %s", err)
+	}
+}
+
 // Start the adaptor as a source
 func (n *Node) start(nsMap map[string]client.MessageSet) error {
 	n.l.Infoln("adaptor Starting...")
@@ -450,16 +709,22 @@ func (n *Node) start(nsMap map[string]client.MessageSet) error {
 			n.l.Debugln("session closed...")
 		}()
 	}
-	readFunc := n.reader.Read(nsMap, func(check string) bool { return n.nsFilter.MatchString(check) })
-	msgChan, err := readFunc(s, n.done)
+	msgChan, err := client.Read(n.readCtx, s, n.reader, nsMap, func(check string) bool { return n.nsFilter.MatchString(check) })
 	if err != nil {
 		return err
 	}
+	acker, _ := n.reader.(client.Acker)
+	if acker != nil && n.clog != nil {
+		go n.runAcks(acker)
+	}
 	var logOffset int64
 	for msg := range msgChan {
+		n.pauser.wait(n.readCtx.Done())
 		if n.clog != nil {
 			d, _ := mejson.Marshal(msg.Msg.Data().AsMap())
 			b, _ := json.Marshal(d)
+			// the message is cached before it's appended so consumers never need to decode it
+			n.recent.add(uint64(n.clog.NewestOffset()), msg.Msg)
 			o, err := n.clog.Append(
 				commitlog.NewLogFromEntry(
 					commitlog.LogEntry{
@@ -473,13 +738,28 @@ func (n *Node) start(nsMap map[string]client.MessageSet) error {
 				return err
 			}
 			logOffset = o
-			n.l.With("offset", logOffset).Debugln("attaching offset to message")
+			n.l.With("offset", logOffset).Debugln("appended message")
+			n.appended()
+		} else {
+			n.pipe.Send(msg.Msg, offset.Offset{
+				Namespace: msg.Msg.Namespace(),
+				LogOffset: uint64(logOffset),
+				Timestamp: time.Now().Unix(),
+				Source:    n.offsetSource(),
+			})
+		}
+		if acker != nil {
+			if n.clog == nil {
+				// without a commitlog there are no offsets to wait for
+				if err := acker.Ack([]client.MessageSet{msg}); err != nil {
+					n.l.Errorf("ack error, %s", err)
+				}
+				continue
+			}
+			n.ackLock.Lock()
+			n.pendingAcks = append(n.pendingAcks, pendingAck{logOffset, msg})
+			n.ackLock.Unlock()
 		}
-		n.pipe.Send(msg.Msg, offset.Offset{
-			Namespace: msg.Msg.Namespace(),
-			LogOffset: uint64(logOffset),
-			Timestamp: time.Now().Unix(),
-		})
 	}
 
 	n.l.Infoln("adaptor Start finished...")
@@ -490,22 +770,65 @@ func (n *Node) listen() error {
 	n.l.Infoln("adaptor Listening...")
 	defer n.l.Infoln("adaptor Listen closed...")
 
-	errors := make(chan error, 1)
+	errors := make(chan error, 2)
 	if n.om != nil {
-		errors = make(chan error, 2)
 		n.confirms = make(chan struct{})
 		n.confirmsDone = make(chan struct{})
 		n.pendingOffsets = make([]offset.Offset, 0)
+	}
+
+	if emitter, ok := n.writer.(client.EventEmitter); ok {
+		emitter.SetEmitter(func(e events.Event) { n.pipe.Event <- e })
+	}
+
+	parallel := n.parallelism > 1 && len(n.children) == 0
+	if parallel && !writesInParallel(n.writer) {
+		n.l.With("parallelism", n.parallelism).Infoln("writer can't write in parallel, writing one message at a time")
+		parallel = false
+	}
+
+	write := n.write
+	if w, ok := n.writer.(client.BatchWriter); ok {
+		n.l.With("batch_size", n.batchSize).With("batch_interval", n.batchInterval).Infoln("writing batches")
+		n.batch = newBatch(n, w)
 		go func() {
+			errors <- n.batch.run()
+		}()
+	} else if parallel {
+		n.l.With("parallelism", n.parallelism).With("partition_key", n.partitionKey).Infoln("starting workers")
+		n.partitions = newPartitions(n)
+		write = n.partitions.dispatch
+		go func() {
+			errors <- n.partitions.run()
+		}()
+	} else if n.om != nil {
+		n.confirmsWG.Add(1)
+		go func() {
+			defer n.confirmsWG.Done()
 			errors <- n.waitForConfirms()
 		}()
 	}
 
 	go func() {
-		errors <- n.pipe.Listen(n.write)
+		errors <- n.pipe.Listen(func(msg message.Msg, off offset.Offset) (message.Msg, error) {
+			if !n.pauser.wait(n.pipe.Aborted()) {
+				// the offset isn't committed so the message is written when the pipeline is restarted
+				return nil, nil
+			}
+			msg, err := write(msg, off)
+			if err != nil {
+				// the error may not be received until the pipeline is stopped
+				n.failed()
+			}
+			return msg, err
+		})
 	}()
 
-	return <-errors
+	err := <-errors
+	if err != nil {
+		n.failed()
+	}
+	return err
 }
 
 type writeResult struct {
@@ -514,14 +837,19 @@ type writeResult struct {
 }
 
 func (n *Node) write(msg message.Msg, off offset.Offset) (message.Msg, error) {
-	if n.om != nil {
+	if msg == nil {
+		n.l.With("ns", off.Namespace).Debugln("message routed to another node")
+		n.skipOffset(off)
+		return nil, nil
+	}
+	if n.om != nil && n.batch == nil {
 		n.offsetLock.Lock()
 		msg = message.WithConfirms(n.confirms, msg)
 		n.offsetLock.Unlock()
 	}
 	if !n.nsFilter.MatchString(msg.Namespace()) {
 		n.l.With("ns", msg.Namespace()).Debugln("message skipped by namespace filter")
-		if msg.Confirms() != nil {
+		if n.om != nil {
 			n.offsetLock.Lock()
 			if len(n.pendingOffsets) == 0 {
 				n.om.CommitOffset(off, false)
@@ -530,9 +858,10 @@ func (n *Node) write(msg message.Msg, off offset.Offset) (message.Msg, error) {
 		}
 		return msg, nil
 	}
+	in := msg
 	msg, err := n.applyTransforms(msg)
 	if err != nil {
-		return nil, err
+		return nil, n.handleError(in, off, 1, err)
 	} else if msg == nil {
 		if n.om != nil {
 			n.offsetLock.Lock()
@@ -544,27 +873,20 @@ func (n *Node) write(msg message.Msg, off offset.Offset) (message.Msg, error) {
 		return nil, err
 	}
 
+	if n.batch != nil {
+		// the batch's offsets are committed once it has been written
+		return msg, n.batch.add(batchEntry{in, msg, off})
+	}
 	if n.om != nil {
 		n.offsetLock.Lock()
 		n.pendingOffsets = append(n.pendingOffsets, off)
 		n.offsetLock.Unlock()
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), n.writeTimeout)
-	defer cancel()
-	c := make(chan writeResult)
-	go func() {
-		m, err := client.Write(n.c, n.writer, msg)
-		if err != nil {
-			n.l.Errorf("write error, %s", err)
-		}
-		c <- writeResult{m, err}
-	}()
-	select {
-	case wr := <-c:
-		return wr.msg, wr.err
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	m, attempts, err := n.writeWithRetry(msg)
+	if err != nil {
+		return nil, n.handleError(in, off, attempts, err)
 	}
+	return m, nil
 }
 
 func (n *Node) waitForConfirms() error {
@@ -618,25 +940,119 @@ func (n *Node) applyTransforms(msg message.Msg) (message.Msg, error) {
 	return msg, nil
 }
 
-// Stop this node's adaptor, and sends a stop to each child of this node
+// Stop drains the Node and its children, see Pipeline.Drain, giving up on any messages which
+// haven't been written after DefaultDrainTimeout.
 func (n *Node) Stop() {
+	t := time.AfterFunc(DefaultDrainTimeout, n.abort)
+	defer t.Stop()
+	n.abortPaused()
+	n.stopReading()
 	n.stop()
+	n.stopChildren()
+	n.checkpoint()
+}
+
+// stopReading stops a source Node's reader and waits for the messages it has read to be sent on,
+// or for the Node to be aborted.
+func (n *Node) stopReading() {
+	n.stopRead()
+	if n.readStopped == nil {
+		return
+	}
+	select {
+	case <-n.readStopped:
+	case <-n.pipe.Aborted():
+	}
+}
+
+// abort makes the Node and its children give up on delivering the messages they have left, any
+// write in progress is cancelled.
+func (n *Node) abort() {
+	n.each(func(node *Node) {
+		node.pipe.Abort()
+		node.cancel()
+	})
+}
+
+// failed makes every Node of the tree give up on delivering the messages they have left once the
+// pipeline has failed so Stop doesn't wait for them, writes in progress are left to finish.
+func (n *Node) failed() {
+	root := n
+	for root.parent != nil {
+		root = root.parent
+	}
+	root.each(func(node *Node) {
+		node.pipe.Abort()
+	})
+}
+
+// each calls f for the Node and each of its children, children of several sources are visited
+// through their first one.
+func (n *Node) each(f func(*Node)) {
+	f(n)
+	for _, child := range n.children {
+		if child.parent == n {
+			child.each(f)
+		}
+	}
+}
+
+// checkpoint is the last step of stopping a Node once its children have stopped, every message
+// they've committed is acked and the Node's commitlog and offset.Manager are closed.
+func (n *Node) checkpoint() {
+	if acker, ok := n.reader.(client.Acker); ok && n.clog != nil {
+		n.ackCommitted(acker)
+	}
+	if n.clog != nil {
+		if err := n.clog.Close(); err != nil {
+			n.l.Errorf("failed to close commitlog, %s", err)
+		}
+	}
+	if closer, ok := n.om.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			n.l.Errorf("failed to close offset manager, %s", err)
+		}
+	}
+}
+
+// stopChildren stops the children of the Node, children of several sources are stopped by their
+// first one.
+func (n *Node) stopChildren() {
 	for _, node := range n.children {
-		node.Stop()
+		if node.parent == n {
+			node.Stop()
+		}
 	}
 }
 
 func (n *Node) stop() error {
 	n.l.Infoln("adaptor Stopping...")
+	if len(n.consumers) > 0 {
+		n.waitForConsumers()
+	}
 	n.pipe.Stop()
+	if n.partitions != nil {
+		n.partitions.stop()
+	}
+	if n.batch != nil {
+		n.batch.close()
+	}
 	close(n.done)
+	n.cancel()
 	n.wg.Wait()
 
+	// the writer is closed while confirms are still received since it may flush its messages
+	if closer, ok := n.writer.(client.Closer); ok {
+		closer.Close()
+	}
+
 	if n.om != nil {
 		close(n.confirmsDone)
+		// every confirm received has been committed once this returns
+		n.confirmsWG.Wait()
 	}
 
-	if closer, ok := n.writer.(client.Closer); ok {
+	if closer, ok := n.dlq.(io.Closer); ok {
 		defer func() {
 			closer.Close()
 		}()
@@ -651,6 +1067,23 @@ func (n *Node) stop() error {
 	return nil
 }
 
+// offsetSource returns the source name offsets of the Node's messages are tracked under, there
+// isn't one unless the Node is one of several sources.
+func (n *Node) offsetSource() string {
+	if n.multiSource {
+		return n.Name
+	}
+	return ""
+}
+
+// childOffsets returns the offset.Manager tracking which of the Node's messages child has written.
+func (n *Node) childOffsets(child *Node) offset.Manager {
+	if !n.multiSource || child.om == nil {
+		return child.om
+	}
+	return offset.ForSource(child.om, n.Name)
+}
+
 // Validate ensures that the node tree conforms to a proper structure.
 // Node trees must have at least one source, and one sink.
 // dangling transformers are forbidden.  Validate only knows about default adaptors
@@ -680,3 +1113,17 @@ func (n *Node) Endpoints() map[string]string {
 	}
 	return m
 }
+
+// Find recurses down the node tree and returns the node with the given name, or nil if there
+// isn't one.
+func (n *Node) Find(name string) *Node {
+	if n.Name == name {
+		return n
+	}
+	for _, child := range n.children {
+		if node := child.Find(name); node != nil {
+			return node
+		}
+	}
+	return nil
+}
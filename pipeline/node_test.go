@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -165,6 +166,36 @@ func (s *StopWriter) Close() {
 	s.Closed = true
 }
 
+// ContextWriter is a StopWriter recording the context it was bound to with WriterContext.
+type ContextWriter struct {
+	StopWriter
+	ctx context.Context
+}
+
+func (c *ContextWriter) Writer(done chan struct{}, wg *sync.WaitGroup) (client.Writer, error) {
+	return nil, errors.New("Writer called in place of WriterContext")
+}
+
+func (c *ContextWriter) WriterContext(ctx context.Context) (client.Writer, error) {
+	c.ctx = ctx
+	return &c.StopWriter, nil
+}
+
+func TestWithWriterContext(t *testing.T) {
+	a := &ContextWriter{}
+	n, err := NewNodeWithOptions("sink", "contextWriter", defaultNsString, WithWriter(a), WithDeadLetterAdaptor(a, "dlq"))
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions() error, %s", err)
+	}
+	if a.ctx != n.ctx {
+		t.Fatal("writer wasn't bound to the context of the node")
+	}
+	n.cancel()
+	if a.ctx.Err() != context.Canceled {
+		t.Errorf("wrong context error once the node is stopped, expected %s, got %v", context.Canceled, a.ctx.Err())
+	}
+}
+
 type SkipFunc struct {
 	UsingOp bool
 }
@@ -658,7 +689,7 @@ var (
 				)
 				return n, a, func() {}
 			},
-			2, 0, ErrResumeTimedOut,
+			2, 0, ErrConfirmOffset,
 		},
 	}
 )
@@ -696,3 +727,53 @@ func TestStop(t *testing.T) {
 		}
 	}
 }
+
+type ackRecorder struct {
+	acked []client.MessageSet
+}
+
+func (a *ackRecorder) Ack(msgs []client.MessageSet) error {
+	a.acked = append(a.acked, msgs...)
+	return nil
+}
+
+func TestAckCommitted(t *testing.T) {
+	dataDir := scratchDataDir("ack")
+	defer os.RemoveAll(dataDir)
+	n, err := NewNodeWithOptions(
+		"starter", "stopWriter", defaultNsString,
+		WithCommitLog([]commitlog.OptionFunc{
+			commitlog.WithPath(dataDir),
+		}...),
+	)
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+	}
+	om := &offset.MockManager{MemoryMap: map[string]uint64{"foo": 2}}
+	NewNodeWithOptions("stopper", "stopWriter", defaultNsString, WithParent(n), WithOffsetManager(om))
+	for i := 0; i < 5; i++ {
+		msg := message.From(ops.Insert, "foo", map[string]interface{}{"i": i})
+		o, err := n.clog.Append(commitlog.NewLogFromEntry(commitlog.LogEntry{Key: []byte("foo"), Op: ops.Insert}))
+		if err != nil {
+			t.Fatalf("unexpected Append error, %s", err)
+		}
+		n.pendingAcks = append(n.pendingAcks, pendingAck{o, client.MessageSet{Msg: msg}})
+	}
+
+	n.l = log.With("name", n.Name)
+	a := &ackRecorder{}
+	n.ackCommitted(a)
+	if len(a.acked) != 3 {
+		t.Errorf("wrong number of acked messages, expected 3, got %d", len(a.acked))
+	}
+	om.CommitOffset(offset.Offset{Namespace: "foo", LogOffset: 4}, false)
+	n.ackCommitted(a)
+	if len(a.acked) != 5 {
+		t.Errorf("wrong number of acked messages, expected 5, got %d", len(a.acked))
+	}
+	for i, msg := range a.acked {
+		if msg.Msg.Data().Get("i") != i {
+			t.Errorf("messages acked out of order, expected %d, got %v", i, msg.Msg.Data().Get("i"))
+		}
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright 2014 The Transporter Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/ops"
+	"github.com/compose/transporter/offset"
+)
+
+const (
+	workerBufferSize = 10
+)
+
+var (
+	defaultPartitionKey = []string{"_id"}
+
+	// ErrInvalidParallelism is returned when the parallelism of a Node is negative.
+	ErrInvalidParallelism = errors.New("invalid parallelism, must not be negative")
+)
+
+// partitions shards the messages written by a Node across several workers by the hash of their
+// partition key, all of the messages for a document are written by the same worker so they're
+// written in order. Commands are written once every message before them has been and before any
+// message after them.
+type partitions struct {
+	n       *Node
+	workers []*worker
+	offsets *offsetTracker
+	// inflight counts the messages dispatched to the workers which haven't been processed yet
+	inflight sync.WaitGroup
+	wg       sync.WaitGroup
+	// countLock guards the pipe's MessageCount which every worker updates
+	countLock sync.Mutex
+	failed    chan struct{}
+	failOnce  sync.Once
+	stopOnce  sync.Once
+	err       error
+}
+
+// worker writes the messages of a single partition.
+type worker struct {
+	in chan partitionedMessage
+	// confirms is attached to each message the worker writes, a confirm means the writer has
+	// finished with every one of them sent so far
+	confirms chan struct{}
+	// pending holds the sequence numbers of the messages waiting to be confirmed
+	pending []uint64
+	lock    sync.Mutex
+}
+
+type partitionedMessage struct {
+	msg message.Msg
+	off offset.Offset
+	seq uint64
+}
+
+func newPartitions(n *Node) *partitions {
+	p := &partitions{
+		n:       n,
+		workers: make([]*worker, n.parallelism),
+		failed:  make(chan struct{}),
+	}
+	if n.om != nil {
+		p.offsets = &offsetTracker{om: n.om}
+	}
+	for i := range p.workers {
+		p.workers[i] = &worker{
+			in:       make(chan partitionedMessage, workerBufferSize),
+			confirms: make(chan struct{}),
+		}
+	}
+	return p
+}
+
+// run starts the workers and returns the error of the first one which fails, or nil once the
+// Node is stopped.
+func (p *partitions) run() error {
+	for _, w := range p.workers {
+		p.wg.Add(1)
+		go func(w *worker) {
+			defer p.wg.Done()
+			p.work(w)
+		}(w)
+		if p.offsets != nil {
+			p.n.confirmsWG.Add(1)
+			go func(w *worker) {
+				defer p.n.confirmsWG.Done()
+				p.waitForConfirms(w)
+			}(w)
+		}
+	}
+	select {
+	case <-p.failed:
+		select {
+		case p.n.pipe.Err <- p.err:
+		case <-p.n.done:
+		}
+		return p.err
+	case <-p.n.done:
+		return nil
+	}
+}
+
+// dispatch is passed to pipe.Listen in place of Node.write, it hands each message to the worker
+// for its partition. Messages are dropped once a worker has failed, their offsets are never
+// committed so they're written again when the pipeline is restarted.
+func (p *partitions) dispatch(msg message.Msg, off offset.Offset) (message.Msg, error) {
+	select {
+	case <-p.failed:
+		return nil, nil
+	default:
+	}
+	seq := p.offsets.add(off)
+	if msg == nil {
+		p.n.l.With("ns", off.Namespace).Debugln("message routed to another node")
+		return nil, p.finish(seq)
+	}
+	pm := partitionedMessage{msg, off, seq}
+	if msg.OP() == ops.Command {
+		// a command can affect any document so it's written on its own
+		p.inflight.Wait()
+		p.send(p.workers[0], pm)
+		p.inflight.Wait()
+		return nil, nil
+	}
+	p.send(p.workers[p.partition(msg)], pm)
+	return nil, nil
+}
+
+func (p *partitions) send(w *worker, pm partitionedMessage) {
+	p.inflight.Add(1)
+	w.in <- pm
+}
+
+// partition returns the index of the worker for the message's partition key, messages missing
+// every field of the key are all written by the same worker.
+func (p *partitions) partition(msg message.Msg) int {
+	h := fnv.New32a()
+	d := msg.Data()
+	for _, field := range p.n.partitionKey {
+		if v, ok := d.Has(field); ok {
+			fmt.Fprint(h, v)
+		}
+		h.Write([]byte{0})
+	}
+	return int(h.Sum32() % uint32(len(p.workers)))
+}
+
+// writesInParallel reports whether w can be written to by several workers at once.
+func writesInParallel(w client.Writer) bool {
+	pw, ok := w.(client.ParallelWriter)
+	return ok && pw.WritesInParallel()
+}
+
+// work writes the worker's messages until its channel is closed, once any worker has failed the
+// rest of the messages are discarded.
+func (p *partitions) work(w *worker) {
+	for pm := range w.in {
+		select {
+		case <-p.failed:
+		default:
+			if err := p.write(w, pm); err != nil {
+				p.fail(err)
+			}
+		}
+		p.inflight.Done()
+	}
+}
+
+// write is the equivalent of Node.write for a single worker, the offset of each message is
+// committed once it and every message before it have been finished with.
+func (p *partitions) write(w *worker, pm partitionedMessage) error {
+	n := p.n
+	msg := pm.msg
+	if p.offsets != nil {
+		msg = message.WithConfirms(w.confirms, msg)
+	}
+	if !n.nsFilter.MatchString(msg.Namespace()) {
+		n.l.With("ns", msg.Namespace()).Debugln("message skipped by namespace filter")
+		p.count()
+		return p.finish(pm.seq)
+	}
+	in := msg
+	msg, err := n.applyTransforms(msg)
+	if err != nil {
+		return p.handleError(w, in, pm, 1, err)
+	} else if msg == nil {
+		return p.finish(pm.seq)
+	}
+
+	if p.offsets != nil {
+		w.lock.Lock()
+		w.pending = append(w.pending, pm.seq)
+		w.lock.Unlock()
+	}
+	if _, attempts, err := n.writeWithRetry(msg); err != nil {
+		return p.handleError(w, in, pm, attempts, err)
+	}
+	p.count()
+	return nil
+}
+
+// handleError applies the Node's ErrorPolicy, finishing with the message if it's been dealt with.
+func (p *partitions) handleError(w *worker, msg message.Msg, pm partitionedMessage, attempts int, err error) error {
+	if err := p.n.applyErrorPolicy(msg, pm.off, attempts, err); err != nil {
+		return err
+	}
+	w.lock.Lock()
+	for i, seq := range w.pending {
+		if seq == pm.seq {
+			w.pending = append(w.pending[:i], w.pending[i+1:]...)
+			break
+		}
+	}
+	w.lock.Unlock()
+	return p.finish(pm.seq)
+}
+
+// waitForConfirms finishes with the messages pending for the worker each time the writer
+// confirms them.
+func (p *partitions) waitForConfirms(w *worker) {
+	for {
+		select {
+		case <-w.confirms:
+			w.lock.Lock()
+			pending := w.pending
+			w.pending = nil
+			w.lock.Unlock()
+			if err := p.finish(pending...); err != nil {
+				p.fail(err)
+			}
+		case <-p.n.confirmsDone:
+			return
+		}
+	}
+}
+
+func (p *partitions) finish(seqs ...uint64) error {
+	if err := p.offsets.done(seqs...); err != nil {
+		return ErrConfirmOffset
+	}
+	return nil
+}
+
+func (p *partitions) count() {
+	p.countLock.Lock()
+	p.n.pipe.MessageCount++
+	p.countLock.Unlock()
+}
+
+func (p *partitions) fail(err error) {
+	p.failOnce.Do(func() {
+		p.n.l.Errorf("worker failed, %s", err)
+		p.err = err
+		close(p.failed)
+	})
+}
+
+// stop waits for the workers to finish with every message dispatched to them, it must only be
+// called once pipe.Listen has returned.
+func (p *partitions) stop() {
+	p.stopOnce.Do(func() {
+		for _, w := range p.workers {
+			close(w.in)
+		}
+		p.wg.Wait()
+	})
+}
+
+// offsetTracker commits the offsets of messages which are finished with out of order, an offset
+// is only committed once the messages before it have all been finished with too. Messages are
+// identified by the sequence number returned by add. A nil offsetTracker tracks nothing.
+type offsetTracker struct {
+	sync.Mutex
+	om offset.Manager
+	// first is the sequence number of offsets[0]
+	first   uint64
+	offsets []trackedOffset
+}
+
+type trackedOffset struct {
+	off  offset.Offset
+	done bool
+}
+
+// add tracks off and returns its sequence number.
+func (t *offsetTracker) add(off offset.Offset) uint64 {
+	if t == nil {
+		return 0
+	}
+	t.Lock()
+	defer t.Unlock()
+	t.offsets = append(t.offsets, trackedOffset{off: off})
+	return t.first + uint64(len(t.offsets)-1)
+}
+
+// done marks the offsets with the given sequence numbers as finished with and commits every
+// offset up to the first which isn't.
+func (t *offsetTracker) done(seqs ...uint64) error {
+	if t == nil {
+		return nil
+	}
+	t.Lock()
+	defer t.Unlock()
+	for _, seq := range seqs {
+		t.offsets[seq-t.first].done = true
+	}
+	var i int
+	for i < len(t.offsets) && t.offsets[i].done {
+		i++
+	}
+	// the newest offset of each namespace is committed first so the older ones are ignored
+	for j := i - 1; j >= 0; j-- {
+		if err := t.om.CommitOffset(t.offsets[j].off, false); err != nil {
+			return err
+		}
+	}
+	t.offsets = t.offsets[i:]
+	t.first += uint64(i)
+	return nil
+}
@@ -0,0 +1,247 @@
+package pipeline
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/commitlog"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/ops"
+	"github.com/compose/transporter/offset"
+)
+
+// idReader sends msgCount messages spread over docCount documents, identified by their key field.
+type idReader struct {
+	msgCount, docCount int
+	key                string
+}
+
+func (r *idReader) Read(_ map[string]client.MessageSet, _ client.NsFilterFunc) client.MessageChanFunc {
+	return func(client.Session, chan struct{}) (chan client.MessageSet, error) {
+		out := make(chan client.MessageSet)
+		go func() {
+			defer close(out)
+			for i := 0; i < r.msgCount; i++ {
+				out <- client.MessageSet{
+					Msg:       message.From(ops.Insert, "test", map[string]interface{}{r.key: i % r.docCount, "i": i}),
+					Timestamp: time.Now().Unix(),
+					Mode:      commitlog.Copy,
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+// orderWriter records the order each document's messages are written in, taking a random time
+// to write each one.
+type orderWriter struct {
+	sync.Mutex
+	key     string
+	written map[string][]int
+}
+
+func (w *orderWriter) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	return func(client.Session) (message.Msg, error) {
+		time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+		w.Lock()
+		id := fmt.Sprint(msg.Data().Get(w.key))
+		w.written[id] = append(w.written[id], msg.Data().Get("i").(int))
+		w.Unlock()
+		if msg.Confirms() != nil {
+			msg.Confirms() <- struct{}{}
+		}
+		return msg, nil
+	}
+}
+
+func (w *orderWriter) WritesInParallel() bool {
+	return true
+}
+
+// bufferingWriter confirms every size messages with the confirms of the last one, like the
+// adaptors which write in bulk, and records the most writes made at once.
+type bufferingWriter struct {
+	sync.Mutex
+	size, buffered     int
+	writing, maxWrites int
+}
+
+func (w *bufferingWriter) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	return func(client.Session) (message.Msg, error) {
+		w.Lock()
+		w.writing++
+		if w.writing > w.maxWrites {
+			w.maxWrites = w.writing
+		}
+		w.Unlock()
+		time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
+		w.Lock()
+		defer w.Unlock()
+		w.writing--
+		w.buffered++
+		if w.buffered == w.size && msg.Confirms() != nil {
+			w.buffered = 0
+			msg.Confirms() <- struct{}{}
+		}
+		return msg, nil
+	}
+}
+
+func TestParallelWrite(t *testing.T) {
+	dataDir := scratchDataDir("parallel_write")
+	defer os.RemoveAll(dataDir)
+	source := newConsumerSource(t, dataDir, 0)
+	source.reader = &idReader{msgCount: 200, docCount: 10, key: "_id"}
+	om := &offset.MockManager{MemoryMap: map[string]uint64{}}
+	sink, err := NewNodeWithOptions("parallel", "stopWriter", defaultNsString,
+		WithParent(source),
+		WithOffsetManager(om),
+		WithParallelism(4),
+	)
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+	}
+	w := &orderWriter{key: "_id", written: make(map[string][]int)}
+	sink.writer = w
+
+	if err := source.Start(); err != nil {
+		t.Fatalf("unexpected Start error, %s", err)
+	}
+	source.Stop()
+
+	var total int
+	for id, written := range w.written {
+		total += len(written)
+		for i := 1; i < len(written); i++ {
+			if written[i] < written[i-1] {
+				t.Errorf("document %s written out of order, %v", id, written)
+				break
+			}
+		}
+	}
+	if total != 200 {
+		t.Errorf("wrong number of messages written, expected 200, got %d", total)
+	}
+	if sink.pipe.MessageCount != 200 {
+		t.Errorf("wrong message count, expected 200, got %d", sink.pipe.MessageCount)
+	}
+	if o := om.OffsetMap()["test"]; o != 199 {
+		t.Errorf("wrong offset committed, expected 199, got %d", o)
+	}
+}
+
+func TestParallelBufferingWriter(t *testing.T) {
+	dataDir := scratchDataDir("parallel_buffering")
+	defer os.RemoveAll(dataDir)
+	source := newConsumerSource(t, dataDir, 0)
+	source.reader = &idReader{msgCount: 200, docCount: 10, key: "_id"}
+	om := &offset.MockManager{MemoryMap: map[string]uint64{}}
+	sink, err := NewNodeWithOptions("parallel", "stopWriter", defaultNsString,
+		WithParent(source),
+		WithOffsetManager(om),
+		WithParallelism(4),
+	)
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+	}
+	w := &bufferingWriter{size: 20}
+	sink.writer = w
+
+	if err := source.Start(); err != nil {
+		t.Fatalf("unexpected Start error, %s", err)
+	}
+	source.Stop()
+
+	if sink.partitions != nil {
+		t.Errorf("writer isn't a ParallelWriter but was written to by workers")
+	}
+	if w.maxWrites != 1 {
+		t.Errorf("writer was called %d times at once", w.maxWrites)
+	}
+	if o := om.OffsetMap()["test"]; o != 199 {
+		t.Errorf("wrong offset committed, expected 199, got %d", o)
+	}
+}
+
+func TestParallelPartitionKey(t *testing.T) {
+	dataDir := scratchDataDir("parallel_partition_key")
+	defer os.RemoveAll(dataDir)
+	source := newConsumerSource(t, dataDir, 0)
+	source.reader = &idReader{msgCount: 200, docCount: 10, key: "customer"}
+	om := &offset.MockManager{MemoryMap: map[string]uint64{}}
+	sink, err := NewNodeWithOptions("parallel", "stopWriter", defaultNsString,
+		WithParent(source),
+		WithOffsetManager(om),
+		WithParallelism(4),
+		WithPartitionKey("customer"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+	}
+	w := &orderWriter{key: "customer", written: make(map[string][]int)}
+	sink.writer = w
+
+	if err := source.Start(); err != nil {
+		t.Fatalf("unexpected Start error, %s", err)
+	}
+	source.Stop()
+
+	for id, written := range w.written {
+		for i := 1; i < len(written); i++ {
+			if written[i] < written[i-1] {
+				t.Errorf("customer %s written out of order, %v", id, written)
+				break
+			}
+		}
+	}
+	workers := make(map[int]bool)
+	for i := 0; i < 10; i++ {
+		workers[sink.partitions.partition(message.From(ops.Insert, "test", map[string]interface{}{"customer": i}))] = true
+	}
+	if len(workers) < 2 {
+		t.Errorf("every customer was written by the same worker")
+	}
+	if o := om.OffsetMap()["test"]; o != 199 {
+		t.Errorf("wrong offset committed, expected 199, got %d", o)
+	}
+}
+
+func TestOffsetTracker(t *testing.T) {
+	om := &offset.MockManager{MemoryMap: map[string]uint64{}}
+	tracker := &offsetTracker{om: om}
+	for i := 0; i < 4; i++ {
+		tracker.add(offset.Offset{Namespace: "foo", LogOffset: uint64(i)})
+	}
+	for _, dt := range []struct {
+		seqs   []uint64
+		expect uint64
+		ok     bool
+	}{
+		{[]uint64{1, 3}, 0, false},
+		{[]uint64{0}, 1, true},
+		{[]uint64{2}, 3, true},
+	} {
+		if err := tracker.done(dt.seqs...); err != nil {
+			t.Fatalf("unexpected done error, %s", err)
+		}
+		o, ok := om.OffsetMap()["foo"]
+		if ok != dt.ok || o != dt.expect {
+			t.Errorf("wrong offset committed after %v, expected %d, got %d", dt.seqs, dt.expect, o)
+		}
+	}
+	if seq := tracker.add(offset.Offset{Namespace: "foo", LogOffset: 4}); seq != 4 {
+		t.Errorf("wrong sequence number, expected 4, got %d", seq)
+	}
+}
+
+func TestWithParallelism(t *testing.T) {
+	if _, err := NewNodeWithOptions("parallel", "stopWriter", defaultNsString, WithParallelism(-1)); err != ErrInvalidParallelism {
+		t.Errorf("wrong error, expected %s, got %v", ErrInvalidParallelism, err)
+	}
+}
@@ -0,0 +1,91 @@
+package pipeline
+
+import (
+	"sync"
+
+	"github.com/compose/transporter/log"
+)
+
+// pauser holds up a Node while it's paused.
+type pauser struct {
+	sync.Mutex
+	// resumed is closed when the Node is resumed, it's nil while the Node isn't paused
+	resumed chan struct{}
+}
+
+func (p *pauser) pause() bool {
+	p.Lock()
+	defer p.Unlock()
+	if p.resumed != nil {
+		return false
+	}
+	p.resumed = make(chan struct{})
+	return true
+}
+
+func (p *pauser) resume() bool {
+	p.Lock()
+	defer p.Unlock()
+	if p.resumed == nil {
+		return false
+	}
+	close(p.resumed)
+	p.resumed = nil
+	return true
+}
+
+func (p *pauser) paused() bool {
+	p.Lock()
+	defer p.Unlock()
+	return p.resumed != nil
+}
+
+// wait blocks while the Node is paused, it returns false if stop is closed first.
+func (p *pauser) wait(stop <-chan struct{}) bool {
+	for {
+		p.Lock()
+		resumed := p.resumed
+		p.Unlock()
+		if resumed == nil {
+			return true
+		}
+		select {
+		case <-resumed:
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// Pause stops the Node writing messages until it's resumed, a source stops reading them instead.
+// The messages already being written are finished with and their offsets committed, the rest are
+// left in the commitlog so the Node carries on from the first of them when it's resumed. A Node
+// which is paused when the pipeline is stopped isn't drained.
+func (n *Node) Pause() {
+	if n.pauser.pause() {
+		log.With("path", n.path).Infoln("node paused")
+	}
+}
+
+// Resume carries on writing, or reading, the messages of a paused Node.
+func (n *Node) Resume() {
+	if n.pauser.resume() {
+		log.With("path", n.path).Infoln("node resumed")
+	}
+}
+
+// Paused reports whether the Node is paused.
+func (n *Node) Paused() bool {
+	return n.pauser.paused()
+}
+
+// abortPaused aborts the Node and its children which are paused so stopping doesn't wait for them
+// to write their messages.
+func (n *Node) abortPaused() {
+	n.each(func(node *Node) {
+		if node.parent != nil && node.Paused() {
+			log.With("path", node.path).Infoln("node is paused, messages left won't be written")
+			node.abort()
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/events"
+	"github.com/compose/transporter/offset"
+)
+
+func TestPauser(t *testing.T) {
+	p := &pauser{}
+	if !p.wait(nil) {
+		t.Error("wait blocked while not paused")
+	}
+	if !p.pause() || p.pause() || !p.paused() {
+		t.Error("pauser wasn't paused once")
+	}
+	stop := make(chan struct{})
+	close(stop)
+	if p.wait(stop) {
+		t.Error("wait didn't give up when stopped")
+	}
+	resumed := make(chan bool)
+	go func() {
+		resumed <- p.wait(nil)
+	}()
+	if !p.resume() || p.resume() || p.paused() {
+		t.Error("pauser wasn't resumed once")
+	}
+	select {
+	case ok := <-resumed:
+		if !ok {
+			t.Error("wait returned false once resumed")
+		}
+	case <-time.After(time.Second):
+		t.Error("wait blocked once resumed")
+	}
+}
+
+func newPauseNodes(t *testing.T, dataDir string) (*Node, *Node, *client.MockWriter, *offset.MockManager) {
+	source := newConsumerSource(t, dataDir, 10)
+	om := &offset.MockManager{MemoryMap: map[string]uint64{}}
+	sink, err := NewNodeWithOptions("sink", "stopWriter", defaultNsString,
+		WithParent(source),
+		WithOffsetManager(om),
+	)
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+	}
+	w := &client.MockWriter{}
+	sink.writer = w
+	return source, sink, w, om
+}
+
+func TestPauseSink(t *testing.T) {
+	dataDir := scratchDataDir("pause_sink")
+	defer os.RemoveAll(dataDir)
+	source, sink, w, om := newPauseNodes(t, dataDir)
+
+	sink.Pause()
+	if err := source.Start(); err != nil {
+		t.Fatalf("unexpected Start error, %s", err)
+	}
+	if o := source.clog.NewestOffset(); o != 10 {
+		t.Errorf("source didn't read while the sink was paused, newest offset %d", o)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if w.MsgCount != 0 || len(om.OffsetMap()) != 0 {
+		t.Errorf("paused sink wrote %d messages, offsets %v", w.MsgCount, om.OffsetMap())
+	}
+
+	sink.Resume()
+	if !waitFor(func() bool { return om.NewestOffset() == 9 }) {
+		t.Errorf("resumed sink didn't catch up, offset %d", om.NewestOffset())
+	}
+	source.Stop()
+	if w.MsgCount != 10 {
+		t.Errorf("wrong number of messages written, expected 10, got %d", w.MsgCount)
+	}
+}
+
+func TestPauseSource(t *testing.T) {
+	dataDir := scratchDataDir("pause_source")
+	defer os.RemoveAll(dataDir)
+	source, _, w, om := newPauseNodes(t, dataDir)
+
+	source.Pause()
+	errc := make(chan error)
+	go func() {
+		errc <- source.Start()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if o := source.clog.NewestOffset(); o != 0 {
+		t.Errorf("paused source read messages, newest offset %d", o)
+	}
+
+	source.Resume()
+	if err := <-errc; err != nil {
+		t.Fatalf("unexpected Start error, %s", err)
+	}
+	source.Stop()
+	if w.MsgCount != 10 || om.NewestOffset() != 9 {
+		t.Errorf("wrong messages written, expected 10 up to offset 9, got %d up to %d", w.MsgCount, om.NewestOffset())
+	}
+}
+
+func TestStopPaused(t *testing.T) {
+	dataDir := scratchDataDir("stop_paused")
+	defer os.RemoveAll(dataDir)
+	source, sink, w, om := newPauseNodes(t, dataDir)
+
+	sink.Pause()
+	if err := source.Start(); err != nil {
+		t.Fatalf("unexpected Start error, %s", err)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		source.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop waited for the paused sink")
+	}
+	if w.MsgCount != 0 || len(om.OffsetMap()) != 0 {
+		t.Errorf("paused sink wrote %d messages, offsets %v", w.MsgCount, om.OffsetMap())
+	}
+}
+
+func TestPipelinePause(t *testing.T) {
+	dataDir := scratchDataDir("pipeline_pause")
+	defer os.RemoveAll(dataDir)
+	source, sink, _, _ := newPauseNodes(t, dataDir)
+	p, err := NewPipeline("test", source, events.NoopEmitter(), 1*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected NewPipeline error, %s", err)
+	}
+
+	if err := p.Pause("missing"); err != ErrNodeNotFound {
+		t.Errorf("wrong Pause error, expected %s, got %v", ErrNodeNotFound, err)
+	}
+	if err := p.Pause("sink"); err != nil {
+		t.Errorf("unexpected Pause error, %s", err)
+	}
+	if paused := p.Paused(); !paused["starter/sink"] || paused["starter"] {
+		t.Errorf("only the sink should be paused, %v", paused)
+	}
+	if err := p.Pause(""); err != nil || !source.Paused() {
+		t.Errorf("pipeline wasn't paused, %v", err)
+	}
+	if err := p.Resume(""); err != nil || source.Paused() || sink.Paused() {
+		t.Errorf("pipeline wasn't resumed, %v", err)
+	}
+}
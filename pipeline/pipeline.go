@@ -1,15 +1,33 @@
 package pipeline
 
 import (
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/compose/transporter/events"
+	"github.com/compose/transporter/log"
+)
+
+const (
+	// abortTimeout is how long Drain waits for the nodes to stop once they've been aborted
+	abortTimeout = 5 * time.Second
+)
+
+var (
+	// ErrDrainTimeout is returned by Drain when the pipeline doesn't finish draining before the
+	// deadline, messages which weren't written have their offsets left uncommitted.
+	ErrDrainTimeout = errors.New("drain deadline reached")
+
+	// ErrNodeNotFound is returned by Pause and Resume when the pipeline has no node with the name.
+	ErrNodeNotFound = errors.New("node not found")
 )
 
 // A Pipeline is a the end to end description of a transporter data flow.
 // including the source, sink, and all the transformers along the way
 type Pipeline struct {
 	source        *Node
+	sources       []*Node
 	emitter       events.Emitter
 	metricsTicker *time.Ticker
 	version       string
@@ -51,7 +69,8 @@ func NewDefaultPipeline(source *Node, uri, key, pid, version string, interval ti
 	return NewPipeline(version, source, events.HTTPPostEmitter(uri, key, pid), interval)
 }
 
-// NewPipeline creates a new Transporter Pipeline using the given tree of nodes, and Event Emitter
+// NewPipeline creates a new Transporter Pipeline using the given tree of nodes, and Event Emitter.
+// Any other source feeding a node of the tree, see WithParents, is run as part of the pipeline.
 // eg.
 //   a, err := adaptor.GetAdaptor("mongodb", map[string]interface{}{"uri": "mongo://localhost:27017"})
 //   if err != nil {
@@ -80,10 +99,17 @@ func NewPipeline(version string, source *Node, emit events.EmitFunc, interval ti
 
 	pipeline := &Pipeline{
 		source:        source,
+		sources:       findSources(source),
 		metricsTicker: time.NewTicker(interval),
 		done:          make(chan struct{}),
 	}
 
+	// every node reports to the first source's channels so they're handled in one place
+	pipeline.apply(func(node *Node) {
+		node.pipe.Err = source.pipe.Err
+		node.pipe.Event = source.pipe.Event
+	})
+
 	// init the emitter with the right chan
 	pipeline.emitter = events.NewEmitter(source.pipe.Event, emit)
 
@@ -96,38 +122,171 @@ func NewPipeline(version string, source *Node, emit events.EmitFunc, interval ti
 }
 
 func (pipeline *Pipeline) String() string {
-	return pipeline.source.String()
+	s := make([]string, len(pipeline.sources))
+	for i, source := range pipeline.sources {
+		s[i] = source.String()
+	}
+	return strings.Join(s, "\n")
 }
 
-// Stop sends a stop signal to the emitter and all the nodes, whether they are running or not.
-// the node's database adaptors are expected to clean up after themselves, and stop will block until
-// all nodes have stopped successfully
+// Stop drains the pipeline, see Drain, giving up after DefaultDrainTimeout.
 func (pipeline *Pipeline) Stop() {
-	endpoints := pipeline.source.Endpoints()
-	pipeline.source.Stop()
+	if err := pipeline.Drain(DefaultDrainTimeout); err != nil {
+		log.Errorf("failed to drain pipeline, %s", err)
+	}
+}
+
+// Drain stops the pipeline once every message it has read is written. The reader of each source
+// is stopped, the messages already read are sent to the sinks, every batch and parallel writer is
+// flushed, the offsets are committed as the writers confirm their messages and finally the
+// committed messages are acked and the commitlogs and offset managers are closed.
+// When timeout is reached the nodes give up on the messages they have left and ErrDrainTimeout
+// is returned. A drain event summarizing the messages written is sent before the exit event.
+func (pipeline *Pipeline) Drain(timeout time.Duration) error {
+	start := time.Now()
+	endpoints := pipeline.endpoints()
 
-	// pipeline has stopped, emit one last round of metrics and send the exit event
+	stopped := make(chan struct{})
+	go func() {
+		for _, source := range pipeline.sources {
+			source.abortPaused()
+		}
+		// every source is stopped before any sink so none of them are left sending to a stopped sink
+		for _, source := range pipeline.sources {
+			source.stopReading()
+		}
+		for _, source := range pipeline.sources {
+			source.stop()
+		}
+		for _, source := range pipeline.sources {
+			source.stopChildren()
+		}
+		for _, source := range pipeline.sources {
+			source.checkpoint()
+		}
+		close(stopped)
+	}()
+
+	var err error
+	deadline := time.NewTimer(timeout)
+	select {
+	case <-stopped:
+		deadline.Stop()
+	case <-deadline.C:
+		err = ErrDrainTimeout
+		for _, source := range pipeline.sources {
+			source.abort()
+		}
+		select {
+		case <-stopped:
+		case <-time.After(abortTimeout):
+			log.Errorln("nodes failed to stop after being aborted")
+		}
+	}
+
+	// pipeline has stopped, emit one last round of metrics, the drain summary and the exit event
 	close(pipeline.done)
 	pipeline.emitMetrics()
+	records := make(map[string]int)
+	offsets := make(map[string]int64)
+	pipeline.apply(func(node *Node) {
+		records[node.path] = node.pipe.MessageCount
+		if node.om != nil {
+			offsets[node.path] = node.om.NewestOffset()
+		}
+	})
+	pipeline.source.pipe.Event <- events.NewDrainEvent(time.Now().UnixNano(), time.Since(start), err == nil, records, offsets)
 	pipeline.source.pipe.Event <- events.NewExitEvent(time.Now().UnixNano(), pipeline.version, endpoints)
 	pipeline.emitter.Stop()
+	return err
 }
 
 // Run the pipeline
 func (pipeline *Pipeline) Run() error {
-	endpoints := pipeline.source.Endpoints()
+	endpoints := pipeline.endpoints()
 	// send a boot event
 	pipeline.source.pipe.Event <- events.NewBootEvent(time.Now().UnixNano(), pipeline.version, endpoints)
 
-	errors := make(chan error, 2)
+	errors := make(chan error, 1)
 	go func() {
 		errors <- pipeline.startErrorListener()
 	}()
-	go func() {
-		errors <- pipeline.source.Start()
-	}()
+	sourceErrors := make(chan error, len(pipeline.sources))
+	for _, source := range pipeline.sources {
+		go func(source *Node) {
+			sourceErrors <- source.Start()
+		}(source)
+	}
 
-	return <-errors
+	// the pipeline runs until every source has finished or one of them fails
+	for range pipeline.sources {
+		select {
+		case err := <-errors:
+			if err != nil {
+				pipeline.failed()
+			}
+			return err
+		case err := <-sourceErrors:
+			if err != nil {
+				pipeline.failed()
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// failed makes every node give up on the messages it has left, Stop doesn't wait for them to be
+// written once the pipeline has failed.
+func (pipeline *Pipeline) failed() {
+	for _, source := range pipeline.sources {
+		source.failed()
+	}
+}
+
+// Pause pauses the node with the given name, see Node.Pause, or every node of the pipeline when
+// name is empty.
+func (pipeline *Pipeline) Pause(name string) error {
+	return pipeline.control(name, (*Node).Pause)
+}
+
+// Resume resumes the node with the given name, or every node of the pipeline when name is empty.
+func (pipeline *Pipeline) Resume(name string) error {
+	return pipeline.control(name, (*Node).Resume)
+}
+
+// Paused returns whether each node of the pipeline is paused, by path.
+func (pipeline *Pipeline) Paused() map[string]bool {
+	m := make(map[string]bool)
+	pipeline.apply(func(node *Node) {
+		m[node.path] = node.Paused()
+	})
+	return m
+}
+
+func (pipeline *Pipeline) control(name string, f func(*Node)) error {
+	var found bool
+	pipeline.apply(func(node *Node) {
+		if name == "" || node.Name == name {
+			found = true
+			f(node)
+		}
+	})
+	if !found {
+		return ErrNodeNotFound
+	}
+	return nil
+}
+
+// endpoints returns the name and type of every node in the pipeline.
+func (pipeline *Pipeline) endpoints() map[string]string {
+	m := make(map[string]string)
+	for _, source := range pipeline.sources {
+		for k, v := range source.Endpoints() {
+			m[k] = v
+		}
+	}
+	return m
 }
 
 // start error listener consumes all the events on the pipe's Err channel, and stops the pipeline
@@ -161,13 +320,40 @@ func (pipeline *Pipeline) emitMetrics() {
 	})
 }
 
-// apply maps a function f across all nodes of a pipeline
+// apply maps a function f across all nodes of a pipeline, nodes with several sources are only
+// visited once
 func (pipeline *Pipeline) apply(f func(*Node)) {
-	head := pipeline.source
-	nodes := []*Node{head}
+	var head *Node
+	nodes := append([]*Node{}, pipeline.sources...)
+	visited := make(map[*Node]bool)
 	for len(nodes) > 0 {
 		head, nodes = nodes[0], nodes[1:]
+		if visited[head] {
+			continue
+		}
+		visited[head] = true
 		f(head)
 		nodes = append(nodes, head.children...)
 	}
 }
+
+// findSources returns source along with every other source which feeds one of the nodes fed by
+// source, directly or through other sources.
+func findSources(source *Node) []*Node {
+	sources := []*Node{source}
+	found := map[*Node]bool{source: true}
+	for i := 0; i < len(sources); i++ {
+		nodes := append([]*Node{}, sources[i].children...)
+		for len(nodes) > 0 {
+			n := nodes[0]
+			nodes = append(nodes[1:], n.children...)
+			for _, p := range n.parents {
+				if !found[p] {
+					found[p] = true
+					sources = append(sources, p)
+				}
+			}
+		}
+	}
+	return sources
+}
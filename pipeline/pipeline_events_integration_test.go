@@ -45,6 +45,10 @@ func TestEventsBroadcast(t *testing.T) {
 			"metrics",
 			"dummyFileOut/dummyFileIn",
 		},
+		{
+			"drain",
+			"",
+		},
 		{
 			"exit",
 			"",
@@ -122,8 +126,8 @@ func TestEventsBroadcast(t *testing.T) {
 
 	time.Sleep(time.Duration(1) * time.Second)
 
-	if len(eh.rawEvents) != 4 {
-		t.Errorf("did not receive all events\nexp: %d\ngot: %d", 4, len(eh.rawEvents))
+	if len(eh.rawEvents) != 5 {
+		t.Errorf("did not receive all events\nexp: %d\ngot: %d", 5, len(eh.rawEvents))
 	}
 
 	for _, val := range data {
@@ -1,8 +1,13 @@
 package pipeline
 
 import (
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -163,3 +168,187 @@ func TestRun(t *testing.T) {
 		p.Stop()
 	}
 }
+
+func TestRunMultipleSources(t *testing.T) {
+	logDir, err := ioutil.TempDir("", "pipeline_sources")
+	if err != nil {
+		t.Fatalf("unexpected TempDir error, %s", err)
+	}
+	defer os.RemoveAll(logDir)
+
+	var sources []*Node
+	for _, name := range []string{"users", "orders"} {
+		n, err := NewNodeWithOptions(
+			name, "source", defaultNsString,
+			WithReader(&adaptor.Mock{}),
+			WithCommitLog([]commitlog.OptionFunc{
+				commitlog.WithPath(filepath.Join(logDir, name)),
+			}...),
+		)
+		if err != nil {
+			t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+		}
+		n.reader = &client.MockReader{MsgCount: 5}
+		sources = append(sources, n)
+	}
+	om := &offset.MockManager{MemoryMap: map[string]uint64{}}
+	w := &client.MockWriter{}
+	sink, err := NewNodeWithOptions("sink", "stopWriter", defaultNsString,
+		WithParents(sources...),
+		WithOffsetManager(om),
+	)
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+	}
+	sink.writer = w
+	ordersOnly, _ := NewNodeWithOptions("orders_only", "stopWriter", defaultNsString, WithParent(sources[1]))
+	ordersWriter := &client.MockWriter{}
+	ordersOnly.writer = ordersWriter
+
+	p, err := NewPipeline("test", sources[0], events.LogEmitter(), 1*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected NewPipeline error, %s", err)
+	}
+	if len(p.sources) != 2 {
+		t.Fatalf("wrong number of sources found, expected 2, got %d", len(p.sources))
+	}
+	if err := p.Run(); err != nil {
+		t.Errorf("unexpected Run error, %s", err)
+	}
+	p.Stop()
+
+	if w.MsgCount != 10 || ordersWriter.MsgCount != 5 {
+		t.Errorf("wrong number of messages written, expected 10 and 5, got %d and %d", w.MsgCount, ordersWriter.MsgCount)
+	}
+	for _, source := range sources {
+		if o := offset.ForSource(om, source.Name).NewestOffset(); o != 4 {
+			t.Errorf("wrong offset for %s, expected 4, got %d", source.Name, o)
+		}
+	}
+}
+
+func TestWithParents(t *testing.T) {
+	a, _ := NewNodeWithOptions("a", "source", defaultNsString)
+	b, _ := NewNodeWithOptions("b", "source", defaultNsString)
+	sink, _ := NewNodeWithOptions("sink", "stopWriter", defaultNsString, WithParent(a))
+	dup, _ := NewNodeWithOptions("a", "source", defaultNsString)
+	for _, parents := range [][]*Node{{}, {a, sink}, {a, dup}} {
+		if _, err := NewNodeWithOptions("shared", "stopWriter", defaultNsString, WithParents(parents...)); err != ErrInvalidParents {
+			t.Errorf("wrong error, expected %s, got %v", ErrInvalidParents, err)
+		}
+	}
+	n, err := NewNodeWithOptions("shared", "stopWriter", defaultNsString, WithParents(a, b))
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+	}
+	if n.path != "a/shared" || n.parent != a || len(b.children) != 1 || len(b.pipe.Out) != 1 || b.pipe.Out[0] != n.pipe.In {
+		t.Errorf("node wasn't attached to both parents, path %s", n.path)
+	}
+	if a.offsetSource() != "a" || b.offsetSource() != "b" || sink.parent.offsetSource() != "a" {
+		t.Error("offsets of the sources aren't tracked by source")
+	}
+}
+
+// eventRecorder keeps every event emitted by a pipeline.
+type eventRecorder struct {
+	sync.Mutex
+	events []events.Event
+}
+
+func (r *eventRecorder) emit(e events.Event) error {
+	r.Lock()
+	r.events = append(r.events, e)
+	r.Unlock()
+	return nil
+}
+
+func (r *eventRecorder) find(name string) []byte {
+	r.Lock()
+	defer r.Unlock()
+	for _, e := range r.events {
+		b, _ := e.Emit()
+		if strings.Contains(string(b), `"name":"`+name+`"`) {
+			return b
+		}
+	}
+	return nil
+}
+
+func TestDrain(t *testing.T) {
+	dataDir := scratchDataDir("drain")
+	defer os.RemoveAll(dataDir)
+	source := newConsumerSource(t, dataDir, 10)
+	om, err := offset.NewLogManager(dataDir, "sink")
+	if err != nil {
+		t.Fatalf("unexpected NewLogManager error, %s", err)
+	}
+	sink, err := NewNodeWithOptions("sink", "stopWriter", defaultNsString,
+		WithParent(source),
+		WithOffsetManager(om),
+		WithBatchSize(100),
+		WithBatchInterval("1h"),
+	)
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+	}
+	w := &client.MockBatchWriter{}
+	sink.writer = w
+
+	r := &eventRecorder{}
+	p, err := NewPipeline("test", source, r.emit, 1*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected NewPipeline error, %s", err)
+	}
+	if err := p.Run(); err != nil {
+		t.Fatalf("unexpected Run error, %s", err)
+	}
+	if err := p.Drain(5 * time.Second); err != nil {
+		t.Errorf("unexpected Drain error, %s", err)
+	}
+
+	if w.MsgCount != 10 || len(w.Batches) != 1 {
+		t.Errorf("final batch wasn't written, %v", w.Batches)
+	}
+	if o := om.NewestOffset(); o != 9 {
+		t.Errorf("wrong offset committed, expected 9, got %d", o)
+	}
+	expect := `"complete":true,"records":{"starter":10,"starter/sink":10},"offsets":{"starter/sink":9}}`
+	if b := r.find("drain"); !strings.HasSuffix(string(b), expect) {
+		t.Errorf("wrong drain event, expected %s, got %s", expect, b)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	dataDir := scratchDataDir("drain_timeout")
+	defer os.RemoveAll(dataDir)
+	source := newConsumerSource(t, dataDir, 10)
+	om := &offset.MockManager{MemoryMap: map[string]uint64{}}
+	sink, err := NewNodeWithOptions("sink", "stopWriter", defaultNsString,
+		WithParent(source),
+		WithOffsetManager(om),
+	)
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+	}
+	w := &blockingWriter{release: make(chan struct{})}
+	defer close(w.release)
+	sink.writer = w
+
+	r := &eventRecorder{}
+	p, err := NewPipeline("test", source, r.emit, 1*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected NewPipeline error, %s", err)
+	}
+	if err := p.Run(); err != nil {
+		t.Fatalf("unexpected Run error, %s", err)
+	}
+	if err := p.Drain(100 * time.Millisecond); err != ErrDrainTimeout {
+		t.Errorf("wrong Drain error, expected %s, got %v", ErrDrainTimeout, err)
+	}
+	if _, ok := om.OffsetMap()["test"]; ok {
+		t.Errorf("offsets of unwritten messages were committed, %v", om.OffsetMap())
+	}
+	if b := r.find("drain"); !strings.Contains(string(b), `"complete":false`) {
+		t.Errorf("drain event wasn't incomplete, %s", b)
+	}
+}
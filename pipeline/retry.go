@@ -0,0 +1,163 @@
+// Copyright 2014 The Transporter Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pipeline
+
+import (
+	"context"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/events"
+	"github.com/compose/transporter/message"
+)
+
+// RetryPolicy configures how a Node retries failed writes. The wait between attempts grows
+// exponentially from InitialInterval up to MaxInterval and each wait is randomized by up to Jitter
+// (between 0 and 1) of itself so nodes retrying together don't all hit the sink at once.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts made, including the first. A value of 0 keeps
+	// retrying until the Deadline, or forever without one.
+	MaxAttempts     int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	// Deadline stops the retries once they've been going for this long, 0 means no deadline.
+	Deadline time.Duration
+	Jitter   float64
+}
+
+// DefaultRetryPolicy makes a single attempt, i.e. failed writes aren't retried.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:     1,
+	InitialInterval: backoff.DefaultInitialInterval,
+	MaxInterval:     backoff.DefaultMaxInterval,
+	Jitter:          backoff.DefaultRandomizationFactor,
+}
+
+func (p RetryPolicy) backOff() backoff.BackOff {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = p.InitialInterval
+	b.MaxInterval = p.MaxInterval
+	b.MaxElapsedTime = p.Deadline
+	b.RandomizationFactor = p.Jitter
+	b.Reset()
+	return b
+}
+
+// writeWithRetry writes msg, retrying failed attempts according to the Node's RetryPolicy as long
+// as the error is retryable. The number of attempts made is returned along with the error of the
+// last one. Retries stop when the Node is stopped.
+func (n *Node) writeWithRetry(msg message.Msg) (message.Msg, int, error) {
+	var m message.Msg
+	attempts, err := n.retry(func() error {
+		var err error
+		m, err = n.writeOnce(msg)
+		return err
+	})
+	if err != nil {
+		return nil, attempts, err
+	}
+	return m, attempts, nil
+}
+
+// writeBatchWithRetry is writeWithRetry for a batch of messages.
+func (n *Node) writeBatchWithRetry(w client.BatchWriter, msgs []message.Msg) (int, error) {
+	return n.retry(func() error {
+		return n.writeBatchOnce(w, msgs)
+	})
+}
+
+func (n *Node) retry(write func() error) (int, error) {
+	b := n.retryPolicy.backOff()
+	for attempt := 1; ; attempt++ {
+		err := write()
+		if err == nil {
+			return attempt, nil
+		}
+		if attempt == n.retryPolicy.MaxAttempts || !client.IsRetryable(err) {
+			return attempt, err
+		}
+		wait := b.NextBackOff()
+		if wait == backoff.Stop {
+			n.l.With("attempt", attempt).Errorln("retry deadline reached")
+			return attempt, err
+		}
+		n.l.With("attempt", attempt).With("wait", wait).Infof("retrying write, %s", err)
+		n.pipe.Event <- events.NewRetryEvent(time.Now().UnixNano(), n.path, attempt, wait, err.Error())
+		select {
+		case <-time.After(wait):
+		case <-n.pipe.Stopping():
+			return attempt, err
+		}
+	}
+}
+
+// writeOnce makes a single attempt to write msg, giving up after the Node's writeTimeout. If the
+// writer is a client.ContextWriter the write is aborted when it gives up, otherwise there's no way
+// to stop it and it's left to finish in the background.
+func (n *Node) writeOnce(msg message.Msg) (message.Msg, error) {
+	ctx, cancel := context.WithTimeout(n.ctx, n.writeTimeout)
+	defer cancel()
+	if _, ok := n.writer.(client.ContextWriter); ok {
+		m, err := client.WriteContext(ctx, n.c, n.writer, msg)
+		if err != nil {
+			n.l.Errorf("write error, %s", err)
+		}
+		return m, contextErr(ctx, err)
+	}
+	// buffered so the write can finish after the timeout without blocking
+	c := make(chan writeResult, 1)
+	go func() {
+		m, err := client.Write(n.c, n.writer, msg)
+		if err != nil {
+			n.l.Errorf("write error, %s", err)
+		}
+		c <- writeResult{m, err}
+	}()
+	select {
+	case wr := <-c:
+		return wr.msg, wr.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// writeBatchOnce makes a single attempt to write msgs, giving up after the Node's writeTimeout. If
+// w is a client.ContextBatchWriter the write is aborted when it gives up, otherwise it's left to
+// finish in the background.
+func (n *Node) writeBatchOnce(w client.BatchWriter, msgs []message.Msg) error {
+	ctx, cancel := context.WithTimeout(n.ctx, n.writeTimeout)
+	defer cancel()
+	if _, ok := w.(client.ContextBatchWriter); ok {
+		err := client.WriteBatchContext(ctx, n.c, w, msgs)
+		if err != nil {
+			n.l.With("batch_size", len(msgs)).Errorf("write error, %s", err)
+		}
+		return contextErr(ctx, err)
+	}
+	c := make(chan error, 1)
+	go func() {
+		err := client.WriteBatch(n.c, w, msgs)
+		if err != nil {
+			n.l.With("batch_size", len(msgs)).Errorf("write error, %s", err)
+		}
+		c <- err
+	}()
+	select {
+	case err := <-c:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// contextErr returns the error of ctx if a write failed because it was done, the error returned by
+// the writer may only be a symptom of it, e.g. a closed connection.
+func contextErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,215 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/compose/transporter/client"
+	"github.com/compose/transporter/log"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/ops"
+	"github.com/compose/transporter/offset"
+)
+
+var errFlaky = errors.New("connection reset")
+
+// flakyWriter fails the first failures writes with err.
+type flakyWriter struct {
+	failures int
+	err      error
+	attempts int
+}
+
+func (w *flakyWriter) Write(msg message.Msg) func(client.Session) (message.Msg, error) {
+	return func(client.Session) (message.Msg, error) {
+		w.attempts++
+		if w.attempts <= w.failures {
+			return msg, w.err
+		}
+		return msg, nil
+	}
+}
+
+var retryTests = []struct {
+	name      string
+	policy    RetryPolicy
+	failures  int
+	err       error
+	expectErr error
+	attempts  int
+}{
+	{
+		"no_retry",
+		DefaultRetryPolicy,
+		1, errFlaky,
+		errFlaky, 1,
+	},
+	{
+		"retry_succeeds",
+		RetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond, MaxInterval: 5 * time.Millisecond, Jitter: 0.5},
+		2, errFlaky,
+		nil, 3,
+	},
+	{
+		"max_attempts",
+		RetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond, MaxInterval: 5 * time.Millisecond},
+		5, errFlaky,
+		errFlaky, 3,
+	},
+	{
+		"permanent",
+		RetryPolicy{MaxAttempts: 3, InitialInterval: time.Millisecond, MaxInterval: 5 * time.Millisecond},
+		5, client.PermanentError{Err: errFlaky},
+		client.PermanentError{Err: errFlaky}, 1,
+	},
+}
+
+func TestWriteRetry(t *testing.T) {
+	for _, rt := range retryTests {
+		n, err := NewNodeWithOptions("retry", "stopWriter", defaultNsString, WithRetryPolicy(rt.policy))
+		if err != nil {
+			t.Fatalf("[%s] unexpected NewNodeWithOptions error, %s", rt.name, err)
+		}
+		n.l = log.With("name", n.Name)
+		w := &flakyWriter{failures: rt.failures, err: rt.err}
+		n.writer = w
+
+		_, err = n.write(message.From(ops.Insert, "foo", map[string]interface{}{}), offset.Offset{Namespace: "foo"})
+		if err != rt.expectErr {
+			t.Errorf("[%s] wrong write error, expected %v, got %v", rt.name, rt.expectErr, err)
+		}
+		if w.attempts != rt.attempts {
+			t.Errorf("[%s] wrong number of attempts, expected %d, got %d", rt.name, rt.attempts, w.attempts)
+		}
+		if retries := len(n.pipe.Event); retries != rt.attempts-1 {
+			t.Errorf("[%s] wrong number of retry events, expected %d, got %d", rt.name, rt.attempts-1, retries)
+		}
+	}
+}
+
+func TestWriteRetryDeadline(t *testing.T) {
+	n, _ := NewNodeWithOptions("retry", "stopWriter", defaultNsString,
+		WithRetryPolicy(RetryPolicy{InitialInterval: 20 * time.Millisecond, MaxInterval: 20 * time.Millisecond, Deadline: 50 * time.Millisecond}),
+	)
+	n.l = log.With("name", n.Name)
+	w := &flakyWriter{failures: 100, err: errFlaky}
+	n.writer = w
+	if _, err := n.write(message.From(ops.Insert, "foo", map[string]interface{}{}), offset.Offset{Namespace: "foo"}); err != errFlaky {
+		t.Errorf("wrong write error, expected %s, got %v", errFlaky, err)
+	}
+	// without jitter there's an attempt every 20ms until 50ms have passed
+	if w.attempts < 3 || w.attempts > 4 {
+		t.Errorf("wrong number of attempts, expected 3 or 4, got %d", w.attempts)
+	}
+}
+
+func TestWriteRetryDeadLetter(t *testing.T) {
+	q := &memQueue{}
+	n, err := NewNodeWithOptions("retry", "stopWriter", defaultNsString,
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialInterval: time.Millisecond, MaxInterval: time.Millisecond}),
+		WithErrorPolicy("dead_letter"),
+		WithDeadLetterQueue(q),
+	)
+	if err != nil {
+		t.Fatalf("unexpected NewNodeWithOptions error, %s", err)
+	}
+	n.l = log.With("name", n.Name)
+	n.writer = &flakyWriter{failures: 5, err: errFlaky}
+	if _, err := n.write(message.From(ops.Insert, "foo", map[string]interface{}{}), offset.Offset{Namespace: "foo"}); err != nil {
+		t.Fatalf("unexpected write error, %s", err)
+	}
+	if len(q.records) != 1 || q.records[0].Attempts != 2 || q.records[0].Error != errFlaky.Error() {
+		t.Errorf("wrong records, %+v", q.records)
+	}
+}
+
+func TestWriteRetryStopped(t *testing.T) {
+	source, _ := NewNodeWithOptions("source", "stopWriter", defaultNsString)
+	n, _ := NewNodeWithOptions("retry", "stopWriter", defaultNsString,
+		WithParent(source),
+		WithRetryPolicy(RetryPolicy{InitialInterval: time.Hour, MaxInterval: time.Hour}),
+	)
+	n.l = log.With("name", n.Name)
+	n.writer = &flakyWriter{failures: 5, err: errFlaky}
+	errc := make(chan error)
+	go func() {
+		_, err := n.write(message.From(ops.Insert, "foo", map[string]interface{}{}), offset.Offset{Namespace: "foo"})
+		errc <- err
+	}()
+	<-n.pipe.Event
+	go n.pipe.Listen(func(msg message.Msg, _ offset.Offset) (message.Msg, error) { return msg, nil })
+	time.Sleep(10 * time.Millisecond)
+	n.pipe.Stop()
+	select {
+	case err := <-errc:
+		if err != errFlaky {
+			t.Errorf("wrong write error, expected %s, got %v", errFlaky, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("retry wasn't interrupted by stopping the pipe")
+	}
+}
+
+// abortWriter blocks every write until it's aborted.
+type abortWriter struct {
+	client.MockWriter
+	aborted chan struct{}
+}
+
+func (w *abortWriter) WriteContext(msg message.Msg) func(context.Context, client.Session) (message.Msg, error) {
+	return func(ctx context.Context, _ client.Session) (message.Msg, error) {
+		<-ctx.Done()
+		close(w.aborted)
+		return nil, ctx.Err()
+	}
+}
+
+func TestWriteTimeoutAborted(t *testing.T) {
+	n, _ := NewNodeWithOptions("timeout", "stopWriter", defaultNsString, WithWriteTimeout("10ms"))
+	n.l = log.With("name", n.Name)
+	w := &abortWriter{aborted: make(chan struct{})}
+	n.writer = w
+	if _, err := n.writeOnce(message.From(ops.Insert, "foo", map[string]interface{}{})); err != context.DeadlineExceeded {
+		t.Errorf("wrong write error, expected %s, got %v", context.DeadlineExceeded, err)
+	}
+	select {
+	case <-w.aborted:
+	default:
+		t.Fatal("write wasn't aborted before writeOnce returned")
+	}
+}
+
+func TestWriteBatchTimeoutAborted(t *testing.T) {
+	n, _ := NewNodeWithOptions("timeout", "stopWriter", defaultNsString, WithWriteTimeout("10ms"))
+	n.l = log.With("name", n.Name)
+	w := &client.MockContextBatchWriter{}
+	msgs := []message.Msg{message.From(ops.Insert, "foo", map[string]interface{}{})}
+	if err := n.writeBatchOnce(w, msgs); err != context.DeadlineExceeded {
+		t.Errorf("wrong write error, expected %s, got %v", context.DeadlineExceeded, err)
+	}
+	if len(w.Batches) != 0 {
+		t.Errorf("WriteBatch called instead of WriteBatchContext")
+	}
+}
+
+func TestWriteStopAborted(t *testing.T) {
+	n, _ := NewNodeWithOptions("stopped", "stopWriter", defaultNsString, WithWriteTimeout("1m"))
+	n.l = log.With("name", n.Name)
+	n.writer = &abortWriter{aborted: make(chan struct{})}
+	errc := make(chan error)
+	go func() {
+		_, err := n.writeOnce(message.From(ops.Insert, "foo", map[string]interface{}{}))
+		errc <- err
+	}()
+	n.cancel()
+	select {
+	case err := <-errc:
+		if err != context.Canceled {
+			t.Errorf("wrong write error, expected %s, got %v", context.Canceled, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("write wasn't aborted when the node was stopped")
+	}
+}
@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
+	"github.com/compose/transporter/pipe"
+)
+
+// Predicate reports whether a message should be routed to a Node.
+type Predicate func(message.Msg) bool
+
+// Match is a declarative Predicate over a message's op, namespace and data. A message matches
+// when its op is one of Ops, its namespace matches Namespace and each of Fields equals the value
+// at that key in the message's data, nested values can be matched with a dotted key such as
+// "customer.country". Fields left empty match every message.
+type Match struct {
+	Ops       []ops.Op
+	Namespace *regexp.Regexp
+	Fields    map[string]interface{}
+}
+
+// Predicate returns the Predicate for m.
+func (m Match) Predicate() Predicate {
+	return func(msg message.Msg) bool {
+		if len(m.Ops) > 0 {
+			var found bool
+			for _, op := range m.Ops {
+				if op == msg.OP() {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		if m.Namespace != nil && !m.Namespace.MatchString(msg.Namespace()) {
+			return false
+		}
+		for key, expected := range m.Fields {
+			v, ok := lookup(msg.Data(), key)
+			if !ok || !equal(v, expected) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// lookup returns the value at key in d, descending into nested documents for each "." in key.
+func lookup(d data.Data, key string) (interface{}, bool) {
+	var v interface{} = map[string]interface{}(d)
+	for _, k := range strings.Split(key, ".") {
+		var (
+			m  map[string]interface{}
+			ok bool
+		)
+		switch doc := v.(type) {
+		case map[string]interface{}:
+			m = doc
+		case data.Data:
+			m = doc
+		default:
+			return nil, false
+		}
+		if v, ok = m[k]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// equal compares numbers by value regardless of their type since adaptors and the pipeline
+// config decode them differently, e.g. an int64 from mongodb and a float64 from JSON.
+func equal(a, b interface{}) bool {
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	if aok && bok {
+		return af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// router returns a pipe.Router which sends messages to the children whose route matches, children
+// without a route receive every message. nil is returned when none of the children have a route.
+func (n *Node) router() pipe.Router {
+	var routed bool
+	for _, child := range n.children {
+		if child.route != nil {
+			routed = true
+		}
+	}
+	if !routed {
+		return nil
+	}
+	return func(msg message.Msg) []bool {
+		routes := make([]bool, len(n.children))
+		for i, child := range n.children {
+			routes[i] = child.routed(msg)
+		}
+		return routes
+	}
+}
+
+// routed reports whether the Node's parent sends msg to it.
+func (n *Node) routed(msg message.Msg) bool {
+	return n.route == nil || n.route(msg)
+}
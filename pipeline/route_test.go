@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/compose/transporter/log"
+	"github.com/compose/transporter/message"
+	"github.com/compose/transporter/message/data"
+	"github.com/compose/transporter/message/ops"
+	"github.com/compose/transporter/offset"
+)
+
+var matchTests = []struct {
+	name   string
+	match  Match
+	msg    message.Msg
+	expect bool
+}{
+	{
+		"empty",
+		Match{},
+		message.From(ops.Delete, "foo", map[string]interface{}{}),
+		true,
+	},
+	{
+		"op",
+		Match{Ops: []ops.Op{ops.Insert, ops.Update}},
+		message.From(ops.Update, "foo", map[string]interface{}{}),
+		true,
+	},
+	{
+		"op_mismatch",
+		Match{Ops: []ops.Op{ops.Insert, ops.Update}},
+		message.From(ops.Delete, "foo", map[string]interface{}{}),
+		false,
+	},
+	{
+		"namespace",
+		Match{Namespace: regexp.MustCompile("^orders")},
+		message.From(ops.Insert, "orders_2017", map[string]interface{}{}),
+		true,
+	},
+	{
+		"namespace_mismatch",
+		Match{Namespace: regexp.MustCompile("^orders")},
+		message.From(ops.Insert, "users", map[string]interface{}{}),
+		false,
+	},
+	{
+		"fields",
+		Match{Fields: map[string]interface{}{"status": "paid", "total": 10}},
+		message.From(ops.Insert, "orders", map[string]interface{}{"status": "paid", "total": float64(10)}),
+		true,
+	},
+	{
+		"fields_mismatch",
+		Match{Fields: map[string]interface{}{"status": "paid", "total": 10}},
+		message.From(ops.Insert, "orders", map[string]interface{}{"status": "paid", "total": 11}),
+		false,
+	},
+	{
+		"fields_missing",
+		Match{Fields: map[string]interface{}{"status": "paid"}},
+		message.From(ops.Insert, "orders", map[string]interface{}{}),
+		false,
+	},
+	{
+		"nested_field",
+		Match{Fields: map[string]interface{}{"customer.address.country": "CA"}},
+		message.From(ops.Insert, "orders", map[string]interface{}{
+			"customer": data.Data{"address": map[string]interface{}{"country": "CA"}},
+		}),
+		true,
+	},
+	{
+		"nested_field_not_document",
+		Match{Fields: map[string]interface{}{"customer.country": "CA"}},
+		message.From(ops.Insert, "orders", map[string]interface{}{"customer": "CA"}),
+		false,
+	},
+	{
+		"all",
+		Match{
+			Ops:       []ops.Op{ops.Insert},
+			Namespace: regexp.MustCompile("^orders$"),
+			Fields:    map[string]interface{}{"status": "paid"},
+		},
+		message.From(ops.Insert, "orders", map[string]interface{}{"status": "paid"}),
+		true,
+	},
+}
+
+func TestMatch(t *testing.T) {
+	for _, mt := range matchTests {
+		if got := mt.match.Predicate()(mt.msg); got != mt.expect {
+			t.Errorf("[%s] wrong match, expected %t, got %t", mt.name, mt.expect, got)
+		}
+	}
+}
+
+func TestRouter(t *testing.T) {
+	source, _ := NewNodeWithOptions("source", "stopWriter", defaultNsString)
+	NewNodeWithOptions("all", "stopWriter", defaultNsString, WithParent(source))
+	if source.router() != nil {
+		t.Fatal("expected a nil router without any routes")
+	}
+	NewNodeWithOptions("orders", "stopWriter", defaultNsString,
+		WithParent(source),
+		WithRoute(Match{Namespace: regexp.MustCompile("^orders$")}.Predicate()),
+	)
+	NewNodeWithOptions("deletes", "stopWriter", defaultNsString,
+		WithParent(source),
+		WithRoute(Match{Ops: []ops.Op{ops.Delete}}.Predicate()),
+	)
+	r := source.router()
+	for _, rt := range []struct {
+		msg    message.Msg
+		expect []bool
+	}{
+		{message.From(ops.Insert, "orders", map[string]interface{}{}), []bool{true, true, false}},
+		{message.From(ops.Delete, "orders", map[string]interface{}{}), []bool{true, true, true}},
+		{message.From(ops.Insert, "users", map[string]interface{}{}), []bool{true, false, false}},
+	} {
+		got := r(rt.msg)
+		for i := range rt.expect {
+			if got[i] != rt.expect[i] {
+				t.Errorf("wrong routes for %s %s, expected %v, got %v", rt.msg.OP(), rt.msg.Namespace(), rt.expect, got)
+				break
+			}
+		}
+	}
+}
+
+func TestWriteRouted(t *testing.T) {
+	om := &offset.MockManager{MemoryMap: map[string]uint64{}}
+	n, _ := NewNodeWithOptions("routed", "stopWriter", defaultNsString, WithOffsetManager(om))
+	n.l = log.With("name", n.Name)
+	w := &failWriter{}
+	n.writer = w
+	msg, err := n.write(nil, offset.Offset{Namespace: "foo", LogOffset: 4})
+	if msg != nil || err != nil {
+		t.Errorf("unexpected write result, %v, %v", msg, err)
+	}
+	if len(w.written) != 0 {
+		t.Errorf("routed message was written, %v", w.written)
+	}
+	if o, ok := om.OffsetMap()["foo"]; !ok || o != 4 {
+		t.Errorf("routed offset wasn't committed, %v", om.OffsetMap())
+	}
+}